@@ -32,6 +32,7 @@ import (
 	"github.com/kubesphere/ks-devops/controllers/jenkins/pipelinerun"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/k8s"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/informers"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -46,6 +47,17 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 
 	reconcilers := getAllControllers(mgr, client, informerFactory, devopsClient, s, jenkinsCore)
 	reconcilers["pipeline"] = func(mgr manager.Manager) (err error) {
+		var s3Client s3.Interface
+		if s.FeatureOptions.PipelineRunDataStore == "s3" {
+			if s.S3Options == nil || s.S3Options.Endpoint == "" {
+				return errors.New("the s3 options are required by the s3 PipelineRun data store")
+			}
+			if s3Client, err = s3.NewS3Client(s.S3Options); err != nil {
+				klog.Errorf("unable to create s3 client, err: %v", err)
+				return
+			}
+		}
+
 		// add PipelineRun controller
 		if err = (&pipelinerun.Reconciler{
 			Client:               mgr.GetClient(),
@@ -53,6 +65,8 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			DevOpsClient:         devopsClient,
 			JenkinsCore:          jenkinsCore,
 			PipelineRunDataStore: s.FeatureOptions.PipelineRunDataStore,
+			S3Client:             s3Client,
+			SyncPeriod:           s.FeatureOptions.PipelineRunSyncPeriod,
 			Options:              s.JenkinsOptions,
 		}).SetupWithManager(mgr); err != nil {
 			klog.Errorf("unable to create pipelinerun-controller, err: %v", err)
@@ -68,6 +82,16 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			return
 		}
 
+		// add PipelineRun garbage collector
+		if err = (&pipelinerun.GCReconciler{
+			Client:     mgr.GetClient(),
+			DaysToKeep: s.FeatureOptions.PipelineRunDaysToKeep,
+			NumToKeep:  s.FeatureOptions.PipelineRunNumToKeep,
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelinerun-gc, err: %v", err)
+			return
+		}
+
 		// add Pipeline metadata controller
 		err = (&jenkinspipeline.Reconciler{
 			Client:      mgr.GetClient(),
@@ -142,9 +166,12 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 	return map[string]func(mgr manager.Manager) error{
 		gitRepoReconcilers.GetName(): func(mgr manager.Manager) error {
 			err := (&gitrepository.PullRequestStatusReconciler{
-				Client:          mgr.GetClient(),
-				ExternalAddress: s.FeatureOptions.ExternalAddress,
-				ClusterName:     s.FeatureOptions.ClusterName,
+				Client:             mgr.GetClient(),
+				ExternalAddress:    s.FeatureOptions.ExternalAddress,
+				ClusterName:        s.FeatureOptions.ClusterName,
+				StageStatus:        s.FeatureOptions.StageCommitStatus,
+				CheckRun:           s.FeatureOptions.GitHubCheckRun,
+				PullRequestComment: s.FeatureOptions.PullRequestComment,
 			}).SetupWithManager(mgr)
 			if err != nil {
 				return err
@@ -204,6 +231,12 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 				}
 				err = jenkinsfileReconciler.SetupWithManager(mgr)
 			}
+			if err == nil && s.FeatureOptions.WebhookAddress != "" {
+				err = (&jenkinspipeline.WebhookReconciler{
+					Client:         mgr.GetClient(),
+					WebhookAddress: s.FeatureOptions.WebhookAddress,
+				}).SetupWithManager(mgr)
+			}
 			if err == nil {
 				err = jenkinsAgentLabelsReconciler.SetupWithManager(mgr)
 			}
@@ -18,6 +18,7 @@ package options
 
 import (
 	"strings"
+	"time"
 
 	"github.com/kubesphere/ks-devops/pkg/utils/reflectutils"
 	"github.com/spf13/pflag"
@@ -31,6 +32,22 @@ type FeatureOptions struct {
 	ExternalAddress      string
 	ClusterName          string
 	PipelineRunDataStore string
+	// PipelineRunSyncPeriod is the fallback period of synchronizing running PipelineRuns from Jenkins,
+	// the status is mainly driven by the events sent from Jenkins.
+	PipelineRunSyncPeriod time.Duration
+	// PipelineRunDaysToKeep and PipelineRunNumToKeep are the default retention of PipelineRuns,
+	// they apply to the Pipelines without discarder.
+	PipelineRunDaysToKeep int
+	PipelineRunNumToKeep  int
+	// StageCommitStatus indicates if the status of each stage is reported to the commit as a separate context
+	StageCommitStatus bool
+	// GitHubCheckRun indicates if a GitHub Check Run is reported for the PipelineRuns of GitHub repositories
+	GitHubCheckRun bool
+	// PullRequestComment indicates if a summary comment is posted to the pull request when a PipelineRun finishes
+	PullRequestComment bool
+	// WebhookAddress is the external address of the API server which receives the SCM webhooks,
+	// the repository webhooks of the multi-branch Pipelines are registered automatically if it's not empty.
+	WebhookAddress string
 }
 
 // GetControllers returns the controllers map
@@ -81,9 +98,24 @@ func (o *FeatureOptions) AddFlags(fs *pflag.FlagSet, c *FeatureOptions) {
 	fs.StringVarP(&o.SystemNamespace, "system-namespace", "", "kubesphere-devops-system",
 		"The system namespace that contains ConfigMap, Secrets e.g.")
 	fs.StringVarP(&o.ExternalAddress, "external-address", "", "", "The external address for the UI")
+	fs.StringVarP(&o.WebhookAddress, "webhook-address", "", "",
+		"The external address of the API server which receives the SCM webhooks, "+
+			"the repository webhooks of the multi-branch Pipelines are registered automatically if it's not empty")
 	fs.StringVarP(&o.ClusterName, "cluster-name", "", "default", "Current cluster name")
 	fs.StringVarP(&o.PipelineRunDataStore, "pipelinerun-data-store", "", "configmap",
-		"The data store type of the PipelineRun data, could be empty or configmap")
+		"The data store type of the PipelineRun data, could be empty, configmap or s3")
+	fs.DurationVarP(&o.PipelineRunSyncPeriod, "pipelinerun-sync-period", "", time.Minute,
+		"The fallback period of synchronizing running PipelineRuns from Jenkins in case of missing events")
+	fs.IntVarP(&o.PipelineRunDaysToKeep, "pipelinerun-days-to-keep", "", 0,
+		"The default days to keep the completed PipelineRuns of a Pipeline without discarder, no limit if it's not positive")
+	fs.IntVarP(&o.PipelineRunNumToKeep, "pipelinerun-num-to-keep", "", 0,
+		"The default number of the completed PipelineRuns to keep for a Pipeline without discarder, no limit if it's not positive")
+	fs.BoolVarP(&o.StageCommitStatus, "stage-commit-status", "", false,
+		"Report the status of each stage of a PipelineRun to the commit as a separate context")
+	fs.BoolVarP(&o.GitHubCheckRun, "github-check-run", "", false,
+		"Report a GitHub Check Run with the summary of a PipelineRun, it requires the token of a GitHub App")
+	fs.BoolVarP(&o.PullRequestComment, "pr-comment", "", false,
+		"Post a comment with the summary of a PipelineRun to its pull request when it finishes")
 }
 
 func (o *FeatureOptions) knownControllers() []string {
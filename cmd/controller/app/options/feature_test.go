@@ -119,6 +119,11 @@ func TestFeatureOptions(t *testing.T) {
 	assert.NotNil(t, flagSet.Lookup("enabled-controllers"))
 	assert.NotNil(t, flagSet.Lookup("system-namespace"))
 	assert.NotNil(t, flagSet.Lookup("external-address"))
+	assert.NotNil(t, flagSet.Lookup("webhook-address"))
 	assert.NotNil(t, flagSet.Lookup("cluster-name"))
 	assert.NotNil(t, flagSet.Lookup("pipelinerun-data-store"))
+	assert.NotNil(t, flagSet.Lookup("pipelinerun-sync-period"))
+	assert.NotNil(t, flagSet.Lookup("stage-commit-status"))
+	assert.NotNil(t, flagSet.Lookup("github-check-run"))
+	assert.NotNil(t, flagSet.Lookup("pr-comment"))
 }
@@ -20,12 +20,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"reflect"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/kubesphere/ks-devops/controllers/predicate"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,6 +34,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// maxArgoHistory is the default revision history limit of Argo CD
+	maxArgoHistory = 10
+	// maxArgoResources is the number of the resources which are kept in the status of an Application at most
+	maxArgoResources = 500
+)
+
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;update
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
 //+kubebuilder:rbac:groups=argoproj.io,resources=applications,verbs=get;list;watch
@@ -74,41 +81,56 @@ func (r *ApplicationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	var status map[string]interface{}
 	if status, _, err = unstructured.NestedMap(argoCDApp.Object, "status"); err == nil {
 		var statusData []byte
-		if statusData, err = json.Marshal(status); err == nil && !reflect.DeepEqual([]byte(app.Status.ArgoApp), statusData) {
-			//app = app.DeepCopy()
-			if app.GetLabels() == nil {
-				// make sure the labels are not nil
-				app.SetLabels(map[string]string{})
-			}
-			// set sync status into labels for filtering
-			if syncStatus, found, _ := unstructured.NestedString(status, "sync", "status"); found {
-				app.GetLabels()[v1alpha1.SyncStatusLabelKey] = syncStatus
-			}
-			// set health status into labels for filtering
-			if healthStatus, found, _ := unstructured.NestedString(status, "health", "status"); found {
-				app.GetLabels()[v1alpha1.HealthStatusLabelKey] = healthStatus
-			}
+		if statusData, err = json.Marshal(status); err != nil {
+			return
+		}
 
-			// unset operation field if it was absent
-			if _, found, err := unstructured.NestedMap(argoCDApp.Object, "operation"); err != nil {
-				return ctrl.Result{}, err
-			} else if !found && app.Spec.ArgoApp != nil {
-				app.Spec.ArgoApp.Operation = nil
-			}
+		// only the typed status is kept, the raw one is kept in case of the incompatible Argo CD versions
+		var rawStatus string
+		argoS, parseErr := parseArgoStatus(statusData)
+		if parseErr == nil {
+			truncateArgoStatus(argoS)
+		} else {
+			r.log.Error(parseErr, "cannot parse the status of ArgoCD application", "namespace", appNs, "name", appName)
+			argoS = nil
+			rawStatus = string(statusData)
+		}
+		if app.Status.ArgoApp == rawStatus && equality.Semantic.DeepEqual(app.Status.ArgoAppStatus, argoS) {
+			return
+		}
 
-			var argoS *argoStatus
-			if argoS, err = parseArgoStatus(statusData); err == nil {
-				if app.Annotations == nil {
-					app.Annotations = map[string]string{}
-				}
-				app.Annotations[v1alpha1.AnnoKeyImages] = strings.Join(argoS.Summary.Images, ",")
-			}
+		if app.GetLabels() == nil {
+			// make sure the labels are not nil
+			app.SetLabels(map[string]string{})
+		}
+		// set sync status into labels for filtering
+		if syncStatus, found, _ := unstructured.NestedString(status, "sync", "status"); found {
+			app.GetLabels()[v1alpha1.SyncStatusLabelKey] = syncStatus
+		}
+		// set health status into labels for filtering
+		if healthStatus, found, _ := unstructured.NestedString(status, "health", "status"); found {
+			app.GetLabels()[v1alpha1.HealthStatusLabelKey] = healthStatus
+		}
 
-			// update labels
-			if err = r.Update(ctx, app); err == nil {
-				app.Status.ArgoApp = string(statusData)
-				err = r.Status().Update(ctx, app)
+		// unset operation field if it was absent
+		if _, found, err := unstructured.NestedMap(argoCDApp.Object, "operation"); err != nil {
+			return ctrl.Result{}, err
+		} else if !found && app.Spec.ArgoApp != nil {
+			app.Spec.ArgoApp.Operation = nil
+		}
+
+		if argoS != nil {
+			if app.Annotations == nil {
+				app.Annotations = map[string]string{}
 			}
+			app.Annotations[v1alpha1.AnnoKeyImages] = strings.Join(argoS.Summary.Images, ",")
+		}
+
+		// update labels
+		if err = r.Update(ctx, app); err == nil {
+			app.Status.ArgoApp = rawStatus
+			app.Status.ArgoAppStatus = argoS
+			err = r.Status().Update(ctx, app)
 		}
 	}
 	return
@@ -143,17 +165,20 @@ func createBareArgoCDApplicationObject() *unstructured.Unstructured {
 	return argoApp
 }
 
-// we can add more fields when need it
-type argoStatus struct {
-	Summary argoStatusSummary `json:"summary"`
-}
-
-type argoStatusSummary struct {
-	Images []string `json:"images"`
+// truncateArgoStatus keeps the latest history and the first resources of the status,
+// an Application with lots of resources or history might exceed the size limit of an object
+func truncateArgoStatus(status *v1alpha1.ArgoApplicationStatus) {
+	if len(status.History) > maxArgoHistory {
+		// the history is sorted from the oldest to the latest
+		status.History = status.History[len(status.History)-maxArgoHistory:]
+	}
+	if len(status.Resources) > maxArgoResources {
+		status.Resources = status.Resources[:maxArgoResources]
+	}
 }
 
-func parseArgoStatus(data []byte) (status *argoStatus, err error) {
-	status = &argoStatus{}
+func parseArgoStatus(data []byte) (status *v1alpha1.ArgoApplicationStatus, err error) {
+	status = &v1alpha1.ArgoApplicationStatus{}
 	err = json.Unmarshal(data, status)
 	return
 }
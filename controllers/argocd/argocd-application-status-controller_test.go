@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/kubesphere/ks-devops/controllers/core"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
@@ -108,6 +110,30 @@ func Test_getArgoCDApplicationObject(t *testing.T) {
 	}
 }
 
+func Test_truncateArgoStatus(t *testing.T) {
+	status := &v1alpha1.ArgoApplicationStatus{}
+	for i := 0; i < maxArgoHistory+2; i++ {
+		status.History = append(status.History, v1alpha1.RevisionHistory{ID: int64(i)})
+	}
+	for i := 0; i < maxArgoResources+1; i++ {
+		status.Resources = append(status.Resources, v1alpha1.ResourceStatus{Name: fmt.Sprintf("res-%d", i)})
+	}
+
+	truncateArgoStatus(status)
+	if assert.Equal(t, maxArgoHistory, len(status.History)) {
+		assert.Equal(t, int64(2), status.History[0].ID)
+		assert.Equal(t, int64(maxArgoHistory+1), status.History[maxArgoHistory-1].ID)
+	}
+	if assert.Equal(t, maxArgoResources, len(status.Resources)) {
+		assert.Equal(t, "res-0", status.Resources[0].Name)
+	}
+
+	// nothing is truncated if it's under the limits
+	status = &v1alpha1.ArgoApplicationStatus{History: []v1alpha1.RevisionHistory{{ID: 1}}}
+	truncateArgoStatus(status)
+	assert.Equal(t, []v1alpha1.RevisionHistory{{ID: 1}}, status.History)
+}
+
 func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 	schema, err := v1alpha1.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
@@ -144,6 +170,9 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 		},
 	}, "status")
 
+	appWithInvalidStatus := appWithStatus.DeepCopy()
+	_ = unstructured.SetNestedField(appWithInvalidStatus.Object, "invalid", "status", "history")
+
 	type fields struct {
 		Client client.Client
 	}
@@ -210,7 +239,8 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 	}, {
 		name: "have status from argo application",
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(appWithStatus, defaultApp).Build(),
+			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(appWithStatus, defaultApp).
+				WithStatusSubresource(defaultApp).Build(),
 		},
 		args: args{
 			req: controllerruntime.Request{
@@ -233,6 +263,43 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 			assert.Nil(t, err)
 
 			assert.Equal(t, "nginx", app.Annotations[v1alpha1.AnnoKeyImages])
+			// the raw status is not kept if it can be parsed
+			assert.Empty(t, app.Status.ArgoApp)
+			if assert.NotNil(t, app.Status.ArgoAppStatus) {
+				assert.Equal(t, []string{"nginx"}, app.Status.ArgoAppStatus.Summary.Images)
+				assert.Equal(t, v1alpha1.SyncStatusCode("ready"), app.Status.ArgoAppStatus.Sync.Status)
+			}
+			return true
+		},
+	}, {
+		name: "keep the raw status which cannot be parsed",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(appWithInvalidStatus, defaultApp).
+				WithStatusSubresource(defaultApp).Build(),
+		},
+		args: args{
+			req: controllerruntime.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: "ns",
+					Name:      "name",
+				},
+			},
+		},
+		wantResult: controllerruntime.Result{},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			assert.Nil(t, err)
+
+			c := i[1].(client.Client)
+			app := &v1alpha1.Application{}
+			err = c.Get(context.Background(), types.NamespacedName{
+				Namespace: "ns",
+				Name:      "name",
+			}, app)
+			assert.Nil(t, err)
+
+			assert.Contains(t, app.Status.ArgoApp, `"history":"invalid"`)
+			assert.Nil(t, app.Status.ArgoAppStatus)
+			assert.Equal(t, "ready", app.Labels[v1alpha1.SyncStatusLabelKey])
 			return true
 		},
 	}, {
@@ -275,26 +342,25 @@ func Test_parseArgoStatus(t *testing.T) {
 		dataFile string
 	}
 	tests := []struct {
-		name       string
-		args       args
-		wantStatus *argoStatus
-		wantErr    assert.ErrorAssertionFunc
+		name        string
+		args        args
+		wantSummary v1alpha1.ApplicationSummary
+		wantErr     assert.ErrorAssertionFunc
 	}{{
 		name: "normal",
 		args: args{dataFile: "data/argo-status.json"},
-		wantStatus: &argoStatus{argoStatusSummary{
+		wantSummary: v1alpha1.ApplicationSummary{
 			Images: []string{"ghcr.io/linuxsuren-bot/open-podcasts-ui:v1.0.2",
 				"ghcr.io/linuxsuren-bot/open-podcasts:v1.0.0",
 				"ghcr.io/opensource-f2f/kube-rbac-proxy:v0.8.0",
-				"ghcr.io/opensource-f2f/open-podcasts-apiserver:dev"}}},
+				"ghcr.io/opensource-f2f/open-podcasts-apiserver:dev"}},
 		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 			assert.Nil(t, err)
 			return true
 		},
 	}, {
-		name:       "no summary",
-		args:       args{dataFile: "data/argo-status-without-summary.json"},
-		wantStatus: &argoStatus{},
+		name: "no summary",
+		args: args{dataFile: "data/argo-status-without-summary.json"},
 		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
 			assert.Nil(t, err)
 			return true
@@ -309,11 +375,49 @@ func Test_parseArgoStatus(t *testing.T) {
 			if !tt.wantErr(t, err, fmt.Sprintf("parseArgoStatus(%v)", tt.args.dataFile)) {
 				return
 			}
-			assert.Equalf(t, tt.wantStatus, gotStatus, "parseArgoStatus(%v)", tt.args.dataFile)
+			assert.Equalf(t, tt.wantSummary, gotStatus.Summary, "parseArgoStatus(%v)", tt.args.dataFile)
 		})
 	}
 }
 
+func Test_parseArgoStatusWithOperation(t *testing.T) {
+	data, err := ioutil.ReadFile("data/argo-status-with-operation.json")
+	assert.Nil(t, err)
+
+	status, err := parseArgoStatus(data)
+	assert.Nil(t, err)
+	assert.Equal(t, v1alpha1.SyncStatusCodeOutOfSync, status.Sync.Status)
+	assert.Equal(t, "1d4fcf1f56f5dbe2ae65abb001ffc304f1d58070", status.Sync.Revision)
+	assert.Equal(t, "Kustomize", status.SourceType)
+	assert.True(t, status.ReconciledAt.Equal(&metav1.Time{Time: time.Date(2022, 6, 30, 6, 48, 5, 0, time.UTC)}))
+	assert.Equal(t, v1alpha1.HealthStatusDegraded, status.Health.Status)
+	if assert.Equal(t, 2, len(status.Resources)) {
+		assert.Equal(t, &v1alpha1.HealthStatus{
+			Status:  v1alpha1.HealthStatusDegraded,
+			Message: "Deployment has 0 available replicas",
+		}, status.Resources[0].Health)
+		assert.Nil(t, status.Resources[1].Health)
+	}
+	if assert.Equal(t, 1, len(status.History)) {
+		assert.Equal(t, "a4bc5a9e0e1a0b2c8a3f6c2ad39d1e5a3e1c5b6d", status.History[0].Revision)
+	}
+	if assert.Equal(t, 1, len(status.Conditions)) {
+		assert.Equal(t, "SyncError", status.Conditions[0].Type)
+	}
+	if assert.NotNil(t, status.OperationState) {
+		assert.Equal(t, v1alpha1.OperationFailed, status.OperationState.Phase)
+		assert.Equal(t, "admin", status.OperationState.Operation.InitiatedBy.Username)
+	}
+	if failedHooks := status.GetFailedHooks(); assert.Equal(t, 1, len(failedHooks)) {
+		assert.Equal(t, "db-migration", failedHooks[0].Name)
+		assert.Equal(t, "PreSync", failedHooks[0].HookType)
+	}
+
+	// the raw status is kept even if it cannot be parsed
+	_, err = parseArgoStatus([]byte(`{"health":"Healthy"}`))
+	assert.NotNil(t, err)
+}
+
 func TestApplicationStatusReconciler_SetupWithManager(t *testing.T) {
 	schema, err := v1alpha1.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
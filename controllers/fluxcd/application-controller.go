@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
 	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
 	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
@@ -34,6 +35,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
@@ -65,10 +67,29 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return
 	}
 
-	if err = r.reconcileFluxApp(app); err != nil {
+	// suspend the HelmReleases and Kustomizations when the syncs are blocked by the sync windows
+	var syncWindow *v1alpha1.FluxSyncWindowStatus
+	if syncWindow, err = r.getSyncWindowStatus(ctx, app); err != nil {
+		return
+	}
+	fluxApp := app
+	if syncWindow != nil && syncWindow.State == v1alpha1.SyncWindowStateBlocked {
+		fluxApp = suspendFluxApp(app)
+	}
+
+	if err = r.reconcileFluxApp(fluxApp); err != nil {
 		return
 	}
 
+	if err = r.updateSyncWindowStatus(ctx, app, syncWindow); err != nil {
+		return
+	}
+	if syncWindow != nil {
+		// check the sync windows again once the state changes
+		if result.RequeueAfter = time.Until(syncWindow.NextTransitionTime.Time); result.RequeueAfter < time.Second {
+			result.RequeueAfter = time.Second
+		}
+	}
 	return
 }
 
@@ -536,5 +557,6 @@ func (r *ApplicationReconciler) SetupWithManager(mgr manager.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("fluxcd_application_controller").
 		For(&v1alpha1.Application{}).
+		Watches(&v1alpha3.DevOpsProject{}, handler.EnqueueRequestsFromMapFunc(r.mapProjectToApplications)).
 		Complete(r)
 }
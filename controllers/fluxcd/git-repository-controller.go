@@ -102,6 +102,10 @@ func (r *GitRepositoryReconciler) reconcileFluxGitRepo(repo *v1alpha3.GitReposit
 		// flux git repo existed
 		// update
 		newFluxGitRepo := createUnstructuredFluxGitRepo(repo)
+		// keep the commit only, it's pinned by the rollback of an application until it's un-pinned
+		if commit, _, _ := unstructured.NestedString(fluxGitRepo.Object, "spec", "ref", "commit"); commit != "" {
+			_ = unstructured.SetNestedField(newFluxGitRepo.Object, commit, "spec", "ref", "commit")
+		}
 		fluxGitRepo.Object["spec"] = newFluxGitRepo.Object["spec"]
 		err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
 			latestGitRepo := createBareFluxGitRepoObject()
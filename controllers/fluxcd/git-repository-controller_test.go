@@ -73,6 +73,8 @@ func TestGitRepositoryReconciler_reconcileFluxGitRepo(t *testing.T) {
 
 	FluxGitRepo := createBareFluxGitRepoObject()
 	preDelFluxGitRepo := createUnstructuredFluxGitRepo(ArtifactRepo)
+	pinnedFluxGitRepo := preDelFluxGitRepo.DeepCopy()
+	_ = unstructured.SetNestedField(pinnedFluxGitRepo.Object, "fake-commit", "spec", "ref", "commit")
 
 	type fields struct {
 		Client client.Client
@@ -185,6 +187,28 @@ func TestGitRepositoryReconciler_reconcileFluxGitRepo(t *testing.T) {
 				assert.Equal(t, "https://fakeGitHub.com/faker/another-fake-project", url)
 			},
 		},
+		{
+			name: "update a Artifact git repository (keep the pinned reference)",
+			fields: fields{
+				Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(ArtifactRepo.DeepCopy(), pinnedFluxGitRepo.DeepCopy()).Build(),
+			},
+			args: args{
+				repo: ArtifactRepoWithNewURL.DeepCopy(),
+			},
+			verify: func(t *testing.T, Client client.Client, err error) {
+				assert.Nil(t, err)
+				gitrepo := FluxGitRepo.DeepCopy()
+				err = Client.Get(context.TODO(), types.NamespacedName{
+					Name:      getFluxRepoName(ArtifactRepo.GetName()),
+					Namespace: ArtifactRepo.GetNamespace(),
+				}, gitrepo)
+				assert.Nil(t, err)
+				url, _, _ := unstructured.NestedString(gitrepo.Object, "spec", "url")
+				assert.Equal(t, "https://fakeGitHub.com/faker/another-fake-project", url)
+				commit, _, _ := unstructured.NestedString(gitrepo.Object, "spec", "ref", "commit")
+				assert.Equal(t, "fake-commit", commit)
+			},
+		},
 		{
 			name: "update a Non-Artifact git repository (add the ArtifactRepo Label)",
 			fields: fields{
@@ -0,0 +1,313 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications/status,verbs=get;update
+
+const (
+	// SyncWindowKindAllow is the kind of the sync windows which allow syncs
+	SyncWindowKindAllow = "allow"
+	// SyncWindowKindDeny is the kind of the sync windows which block syncs
+	SyncWindowKindDeny = "deny"
+
+	// inClusterName is the cluster name of a destination without kubeconfig
+	inClusterName = "in-cluster"
+
+	// syncWindowHorizon limits how far the overlapping runs of a window are chained, a window whose runs
+	// always overlap is taken as ending after the horizon, then it's evaluated again
+	syncWindowHorizon = 7 * 24 * time.Hour
+)
+
+// syncWindowParser parses the schedule in the same format as Argo CD
+var syncWindowParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// getSyncWindowStatus evaluates the sync windows of the DevOpsProject which the application belongs to.
+// It's nil if there is no sync window assigned to the application.
+func (r *ApplicationReconciler) getSyncWindowStatus(ctx context.Context, app *v1alpha1.Application) (
+	status *v1alpha1.FluxSyncWindowStatus, err error) {
+	var windows v1alpha3.SyncWindows
+	if windows, err = r.getSyncWindows(ctx, app.GetNamespace()); err != nil || len(windows) == 0 {
+		return
+	}
+
+	var invalidErr error
+	if status, invalidErr = evaluateSyncWindows(app, windows, time.Now()); invalidErr != nil {
+		// the invalid sync windows are ignored, the others still take effect
+		r.log.Error(invalidErr, "found invalid sync windows", "application", app.GetName())
+		r.recorder.Event(app, corev1.EventTypeWarning, "InvalidSyncWindow", invalidErr.Error())
+	}
+	return
+}
+
+// getSyncWindows returns the sync windows of the DevOpsProject which the namespace belongs to
+func (r *ApplicationReconciler) getSyncWindows(ctx context.Context, namespace string) (windows v1alpha3.SyncWindows, err error) {
+	// only the labels of the namespace are needed
+	ns := &metav1.PartialObjectMetadata{}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	if err = r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	projectName := ns.GetLabels()[constants.DevOpsProjectLabelKey]
+	if projectName == "" {
+		return
+	}
+
+	project := &v1alpha3.DevOpsProject{}
+	if err = r.Get(ctx, types.NamespacedName{Name: projectName}, project); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if project.Spec.Argo != nil {
+		windows = project.Spec.Argo.SyncWindows
+	}
+	return
+}
+
+// mapProjectToApplications returns the FluxCD applications in the namespaces of the DevOpsProject,
+// so that the changes of the sync windows take effect immediately
+func (r *ApplicationReconciler) mapProjectToApplications(ctx context.Context, obj client.Object) (requests []reconcile.Request) {
+	namespaces := &metav1.PartialObjectMetadataList{}
+	namespaces.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := r.List(ctx, namespaces, client.MatchingLabels{constants.DevOpsProjectLabelKey: obj.GetName()}); err != nil {
+		r.log.Error(err, "failed to list the namespaces of the DevOpsProject", "project", obj.GetName())
+		return
+	}
+
+	for _, ns := range namespaces.Items {
+		apps := &v1alpha1.ApplicationList{}
+		if err := r.List(ctx, apps, client.InNamespace(ns.GetName())); err != nil {
+			r.log.Error(err, "failed to list the applications", "namespace", ns.GetName())
+			continue
+		}
+		for _, app := range apps.Items {
+			if app.Spec.FluxApp != nil {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: app.GetNamespace(),
+					Name:      app.GetName(),
+				}})
+			}
+		}
+	}
+	return
+}
+
+// updateSyncWindowStatus updates the status of the application if the state of the sync windows is changed
+func (r *ApplicationReconciler) updateSyncWindowStatus(ctx context.Context, app *v1alpha1.Application,
+	status *v1alpha1.FluxSyncWindowStatus) (err error) {
+	oldStatus := app.Status.FluxApp.SyncWindow
+	if equality.Semantic.DeepEqual(oldStatus, status) {
+		return
+	}
+	if status != nil && (oldStatus == nil || oldStatus.State != status.State) {
+		r.recorder.Event(app, corev1.EventTypeNormal, "SyncWindow"+string(status.State), status.Message)
+	}
+
+	app = app.DeepCopy()
+	app.Status.FluxApp.SyncWindow = status
+	return r.Status().Update(ctx, app)
+}
+
+// evaluateSyncWindows returns the state of the sync windows which are assigned to the application.
+// Same as Argo CD, the syncs are blocked if there is an active deny window, or there are allow windows
+// but none of them is active. The invalid windows are ignored and returned as an error.
+func evaluateSyncWindows(app *v1alpha1.Application, windows v1alpha3.SyncWindows, now time.Time) (
+	status *v1alpha1.FluxSyncWindowStatus, err error) {
+	var errs []error
+	var activeAllows, activeDenies, inactiveAllows, inactiveDenies []string
+	var nextTransition time.Time
+	for _, window := range windows {
+		if window == nil || !matchSyncWindow(window, app) {
+			continue
+		}
+
+		active, transition, windowErr := getSyncWindowState(window, now)
+		if windowErr != nil {
+			errs = append(errs, fmt.Errorf("invalid sync window %s %q: %v", window.Kind, window.Schedule, windowErr))
+			continue
+		}
+		if nextTransition.IsZero() || transition.Before(nextTransition) {
+			nextTransition = transition
+		}
+
+		description := fmt.Sprintf("%q(%s)", window.Schedule, window.Duration)
+		switch {
+		case window.Kind == SyncWindowKindDeny && active:
+			activeDenies = append(activeDenies, description)
+		case window.Kind == SyncWindowKindAllow && active:
+			activeAllows = append(activeAllows, description)
+		case window.Kind == SyncWindowKindAllow:
+			inactiveAllows = append(inactiveAllows, description)
+		default:
+			inactiveDenies = append(inactiveDenies, description)
+		}
+	}
+	if err = utilerrors.NewAggregate(errs); nextTransition.IsZero() {
+		return
+	}
+
+	status = &v1alpha1.FluxSyncWindowStatus{
+		State:              v1alpha1.SyncWindowStateAllowed,
+		NextTransitionTime: &metav1.Time{Time: nextTransition.UTC()},
+	}
+	switch {
+	case len(activeDenies) > 0:
+		status.State = v1alpha1.SyncWindowStateBlocked
+		status.Message = "blocked by the active deny windows: " + strings.Join(activeDenies, ", ")
+	case len(activeAllows) > 0:
+		status.Message = "allowed by the active allow windows: " + strings.Join(activeAllows, ", ")
+	case len(inactiveAllows) > 0:
+		status.State = v1alpha1.SyncWindowStateBlocked
+		status.Message = "blocked since none of the allow windows is active: " + strings.Join(inactiveAllows, ", ")
+	default:
+		status.Message = "allowed since none of the deny windows is active: " + strings.Join(inactiveDenies, ", ")
+	}
+	return
+}
+
+// getSyncWindowState returns whether the window is active, and the time when it ends if it's active,
+// or the time when it starts next time if it's inactive. The window keeps active while its runs overlap,
+// such as an hourly schedule with a 2h duration.
+func getSyncWindowState(window *v1alpha3.SyncWindow, now time.Time) (active bool, transition time.Time, err error) {
+	if window.Kind != SyncWindowKindAllow && window.Kind != SyncWindowKindDeny {
+		err = fmt.Errorf("the kind must be %s or %s", SyncWindowKindAllow, SyncWindowKindDeny)
+		return
+	}
+	var schedule cron.Schedule
+	if schedule, err = syncWindowParser.Parse(window.Schedule); err != nil {
+		return
+	}
+	var duration time.Duration
+	if duration, err = time.ParseDuration(window.Duration); err != nil {
+		return
+	} else if duration <= 0 {
+		err = errors.New("the duration must be positive")
+		return
+	}
+	// it's UTC if the time zone is empty
+	var location *time.Location
+	if location, err = time.LoadLocation(window.TimeZone); err != nil {
+		return
+	}
+
+	current := now.In(location)
+	start := schedule.Next(current.Add(-duration))
+	if active = !start.After(current); !active {
+		transition = start
+		return
+	}
+	// the window ends when the last run which starts before the end is over
+	transition = start.Add(duration)
+	for next := schedule.Next(start); !next.After(transition) && next.Before(current.Add(syncWindowHorizon)); next = schedule.Next(next) {
+		if end := next.Add(duration); end.After(transition) {
+			transition = end
+		}
+	}
+	return
+}
+
+// matchSyncWindow returns true if the window is assigned to the application by its name,
+// or the namespace or cluster of any destination
+func matchSyncWindow(window *v1alpha3.SyncWindow, app *v1alpha1.Application) bool {
+	if matchAny(window.Applications, app.GetName()) {
+		return true
+	}
+	for _, destination := range getFluxAppDestinations(app) {
+		cluster := inClusterName
+		if destination.KubeConfig != nil {
+			cluster = destination.KubeConfig.SecretRef.Name
+		}
+		if matchAny(window.Namespaces, destination.TargetNamespace) || matchAny(window.Clusters, cluster) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func getFluxAppDestinations(app *v1alpha1.Application) (destinations []v1alpha1.FluxApplicationDestination) {
+	config := app.Spec.FluxApp.Spec.Config
+	if config == nil {
+		return
+	}
+	if config.HelmRelease != nil {
+		for _, deploy := range config.HelmRelease.Deploy {
+			if deploy != nil {
+				destinations = append(destinations, deploy.Destination)
+			}
+		}
+	}
+	for _, kustomization := range config.Kustomization {
+		if kustomization != nil {
+			destinations = append(destinations, kustomization.Destination)
+		}
+	}
+	return
+}
+
+// suspendFluxApp returns a copy of the application whose HelmReleases and Kustomizations are suspended
+func suspendFluxApp(app *v1alpha1.Application) *v1alpha1.Application {
+	app = app.DeepCopy()
+	config := app.Spec.FluxApp.Spec.Config
+	if config == nil {
+		return app
+	}
+	if config.HelmRelease != nil {
+		for _, deploy := range config.HelmRelease.Deploy {
+			if deploy != nil {
+				deploy.Suspend = true
+			}
+		}
+	}
+	for _, kustomization := range config.Kustomization {
+		if kustomization != nil {
+			kustomization.Suspend = true
+		}
+	}
+	return app
+}
@@ -0,0 +1,372 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	sourcev1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/source/v1beta2"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getSyncWindowState(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		window         *v1alpha3.SyncWindow
+		wantActive     bool
+		wantTransition time.Time
+		wantErr        bool
+	}{{
+		name:           "an active window",
+		window:         &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * * *", Duration: "1h"},
+		wantActive:     true,
+		wantTransition: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:           "an inactive window",
+		window:         &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 12 * * *", Duration: "1h"},
+		wantTransition: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:           "an active window in another time zone",
+		window:         &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 18 * * *", Duration: "1h", TimeZone: "Asia/Shanghai"},
+		wantActive:     true,
+		wantTransition: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:           "the overlapping runs of an active window",
+		window:         &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 9,10 * * *", Duration: "2h"},
+		wantActive:     true,
+		wantTransition: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:           "the chained runs of an active window",
+		window:         &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 9-12 * * *", Duration: "90m"},
+		wantActive:     true,
+		wantTransition: time.Date(2024, 1, 1, 13, 30, 0, 0, time.UTC),
+	}, {
+		name:           "the runs of an active window always overlap",
+		window:         &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 * * * *", Duration: "2h"},
+		wantActive:     true,
+		wantTransition: time.Date(2024, 1, 8, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:    "invalid kind",
+		window:  &v1alpha3.SyncWindow{Kind: "fake", Schedule: "0 10 * * *", Duration: "1h"},
+		wantErr: true,
+	}, {
+		name:    "invalid schedule",
+		window:  &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * *", Duration: "1h"},
+		wantErr: true,
+	}, {
+		name:    "invalid duration",
+		window:  &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * * *", Duration: "1d"},
+		wantErr: true,
+	}, {
+		name:    "zero duration",
+		window:  &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * * *", Duration: "0s"},
+		wantErr: true,
+	}, {
+		name:    "invalid time zone",
+		window:  &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * * *", Duration: "1h", TimeZone: "Fake/Zone"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, transition, err := getSyncWindowState(tt.window, now)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantActive, active)
+			assert.True(t, tt.wantTransition.Equal(transition), "want %v, got %v", tt.wantTransition, transition)
+		})
+	}
+}
+
+func Test_matchSyncWindow(t *testing.T) {
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-app"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{Deploy: []*v1alpha1.Deploy{{
+					Destination: v1alpha1.FluxApplicationDestination{
+						KubeConfig: &helmv2.KubeConfig{
+							SecretRef: meta.SecretKeyReference{Name: "aliyun-kubeconfig"},
+						},
+						TargetNamespace: "prod",
+					},
+				}}},
+				Kustomization: []*v1alpha1.KustomizationSpec{{
+					Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "test"},
+				}},
+			},
+		}}},
+	}
+
+	tests := []struct {
+		name   string
+		window *v1alpha3.SyncWindow
+		want   bool
+	}{
+		{name: "match the application", window: &v1alpha3.SyncWindow{Applications: []string{"fake-*"}}, want: true},
+		{name: "match the namespace", window: &v1alpha3.SyncWindow{Namespaces: []string{"prod"}}, want: true},
+		{name: "match the cluster", window: &v1alpha3.SyncWindow{Clusters: []string{"aliyun-*"}}, want: true},
+		{name: "match the in-cluster", window: &v1alpha3.SyncWindow{Clusters: []string{"in-cluster"}}, want: true},
+		{name: "match any of them", window: &v1alpha3.SyncWindow{
+			Applications: []string{"another-app"},
+			Namespaces:   []string{"test"},
+		}, want: true},
+		{name: "match nothing", window: &v1alpha3.SyncWindow{
+			Applications: []string{"another-app"},
+			Namespaces:   []string{"dev"},
+			Clusters:     []string{"tencentcloud-kubeconfig"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchSyncWindow(tt.window, app))
+		})
+	}
+}
+
+func Test_evaluateSyncWindows(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-app"},
+		Spec:       v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}},
+	}
+	activeAllow := &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 10 * * *", Duration: "1h", Applications: []string{"*"}}
+	inactiveAllow := &v1alpha3.SyncWindow{Kind: "allow", Schedule: "0 12 * * *", Duration: "1h", Applications: []string{"*"}}
+	activeDeny := &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 10 * * *", Duration: "2h", Applications: []string{"*"}}
+	inactiveDeny := &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 11 * * *", Duration: "1h", Applications: []string{"*"}}
+	unmatched := &v1alpha3.SyncWindow{Kind: "deny", Schedule: "0 10 * * *", Duration: "1h", Applications: []string{"another-app"}}
+	invalid := &v1alpha3.SyncWindow{Kind: "deny", Schedule: "fake", Duration: "1h", Applications: []string{"*"}}
+
+	tests := []struct {
+		name               string
+		windows            v1alpha3.SyncWindows
+		wantState          v1alpha1.SyncWindowState
+		wantNextTransition time.Time
+		wantErr            bool
+	}{{
+		name:    "no windows",
+		windows: v1alpha3.SyncWindows{nil, unmatched},
+	}, {
+		name:               "an active allow window",
+		windows:            v1alpha3.SyncWindows{activeAllow, inactiveAllow},
+		wantState:          v1alpha1.SyncWindowStateAllowed,
+		wantNextTransition: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:               "no active allow windows",
+		windows:            v1alpha3.SyncWindows{inactiveAllow},
+		wantState:          v1alpha1.SyncWindowStateBlocked,
+		wantNextTransition: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:               "the deny window takes precedence",
+		windows:            v1alpha3.SyncWindows{activeAllow, activeDeny},
+		wantState:          v1alpha1.SyncWindowStateBlocked,
+		wantNextTransition: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:               "an inactive deny window",
+		windows:            v1alpha3.SyncWindows{inactiveDeny},
+		wantState:          v1alpha1.SyncWindowStateAllowed,
+		wantNextTransition: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+	}, {
+		name:               "the invalid window is ignored",
+		windows:            v1alpha3.SyncWindows{invalid, activeDeny},
+		wantState:          v1alpha1.SyncWindowStateBlocked,
+		wantNextTransition: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		wantErr:            true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := evaluateSyncWindows(app, tt.windows, now)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantState == "" {
+				assert.Nil(t, status)
+				return
+			}
+			if assert.NotNil(t, status) {
+				assert.Equal(t, tt.wantState, status.State)
+				assert.NotEmpty(t, status.Message)
+				assert.True(t, tt.wantNextTransition.Equal(status.NextTransitionTime.Time))
+			}
+		})
+	}
+}
+
+func TestApplicationReconciler_syncWindows(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1alpha3.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = helmv2.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = sourcev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = kusv1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "fake-ns",
+			Labels: map[string]string{constants.DevOpsProjectLabelKey: "fake-project"},
+		},
+	}
+	newProject := func(kind string) *v1alpha3.DevOpsProject {
+		return &v1alpha3.DevOpsProject{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake-project"},
+			Spec: v1alpha3.DevOpsProjectSpec{Argo: &v1alpha3.Argo{SyncWindows: v1alpha3.SyncWindows{{
+				Kind:         kind,
+				Schedule:     "* * * * *",
+				Duration:     "1h",
+				Applications: []string{"fake-app"},
+			}}}},
+		}
+	}
+	kusApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "fake-ns", Name: "fake-app"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+				Source: &v1alpha1.FluxApplicationSource{
+					SourceRef: helmv2.CrossNamespaceObjectReference{
+						APIVersion: "source.toolkit.fluxcd.io/v1beta2",
+						Kind:       "GitRepository",
+						Name:       "fake-repo",
+						Namespace:  "fake-ns",
+					},
+				},
+				Config: &v1alpha1.FluxApplicationConfig{Kustomization: []*v1alpha1.KustomizationSpec{{
+					Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "fake-targetNamespace"},
+					Path:        "./kustomize",
+					Prune:       true,
+				}}},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		project     *v1alpha3.DevOpsProject
+		wantSuspend bool
+		wantState   v1alpha1.SyncWindowState
+	}{{
+		name:        "blocked by a deny window",
+		project:     newProject("deny"),
+		wantSuspend: true,
+		wantState:   v1alpha1.SyncWindowStateBlocked,
+	}, {
+		name:      "allowed by an allow window",
+		project:   newProject("allow"),
+		wantState: v1alpha1.SyncWindowStateAllowed,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).
+				WithObjects(ns.DeepCopy(), tt.project, kusApp.DeepCopy()).
+				WithStatusSubresource(&v1alpha1.Application{}).Build()
+			r := &ApplicationReconciler{
+				Client:   c,
+				log:      logr.New(log.NullLogSink{}),
+				recorder: &record.FakeRecorder{},
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "fake-ns", Name: "fake-app"},
+			})
+			assert.Nil(t, err)
+			assert.True(t, result.RequeueAfter > 0)
+
+			kusList := &kusv1.KustomizationList{}
+			err = c.List(context.Background(), kusList, client.InNamespace("fake-ns"))
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(kusList.Items)) {
+				assert.Equal(t, tt.wantSuspend, kusList.Items[0].Spec.Suspend)
+			}
+
+			app := &v1alpha1.Application{}
+			err = c.Get(context.Background(), types.NamespacedName{Namespace: "fake-ns", Name: "fake-app"}, app)
+			assert.Nil(t, err)
+			if assert.NotNil(t, app.Status.FluxApp.SyncWindow) {
+				assert.Equal(t, tt.wantState, app.Status.FluxApp.SyncWindow.State)
+			}
+			// the spec of the application is not changed
+			assert.False(t, app.Spec.FluxApp.Spec.Config.Kustomization[0].Suspend)
+		})
+	}
+}
+
+func TestApplicationReconciler_mapProjectToApplications(t *testing.T) {
+	schema, err := v1alpha1.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1alpha3.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	newNamespace := func(name, project string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{constants.DevOpsProjectLabelKey: project},
+		}}
+	}
+	newApp := func(namespace, name string, flux bool) *v1alpha1.Application {
+		app := &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		if flux {
+			app.Spec.FluxApp = &v1alpha1.FluxApplication{}
+		} else {
+			app.Spec.ArgoApp = &v1alpha1.ArgoApplication{}
+		}
+		return app
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newNamespace("fake-ns", "fake-project"), newNamespace("other-ns", "other-project"),
+		newApp("fake-ns", "flux-app", true), newApp("fake-ns", "argo-app", false),
+		newApp("other-ns", "other-app", true)).Build()
+	r := &ApplicationReconciler{Client: c, log: logr.New(log.NullLogSink{})}
+
+	requests := r.mapProjectToApplications(context.Background(), &v1alpha3.DevOpsProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-project"},
+	})
+	assert.Equal(t, []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: "fake-ns", Name: "flux-app"},
+	}}, requests)
+
+	requests = r.mapProjectToApplications(context.Background(), &v1alpha3.DevOpsProject{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+	})
+	assert.Empty(t, requests)
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// checkRunRerunAction is the identifier of the "Re-run" button of a Check Run,
+// the webhook creates a new PipelineRun when it's clicked
+const checkRunRerunAction = "rerun"
+
+// checkRun is a GitHub Check Run, see also https://docs.github.com/en/rest/checks/runs
+type checkRun struct {
+	ID          int64            `json:"id,omitempty"`
+	Name        string           `json:"name,omitempty"`
+	HeadSHA     string           `json:"head_sha,omitempty"`
+	DetailsURL  string           `json:"details_url,omitempty"`
+	ExternalID  string           `json:"external_id,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	Conclusion  string           `json:"conclusion,omitempty"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Output      *checkRunOutput  `json:"output,omitempty"`
+	Actions     []checkRunAction `json:"actions,omitempty"`
+}
+
+type checkRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+type checkRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+type checkRunList struct {
+	TotalCount int         `json:"total_count"`
+	CheckRuns  []*checkRun `json:"check_runs"`
+}
+
+// CheckRunMaker creates or updates the GitHub Check Run of a PipelineRun.
+// The Check Run is identified by the namespace and name of the PipelineRun as its external ID.
+type CheckRunMaker struct {
+	*StatusMaker
+}
+
+// NewCheckRunMaker creates an instance of CheckRunMaker, the repository, token and commit come from the StatusMaker
+func NewCheckRunMaker(maker *StatusMaker) *CheckRunMaker {
+	return &CheckRunMaker{StatusMaker: maker}
+}
+
+// CreateOrUpdate creates the Check Run of a PipelineRun, or updates it if it exists
+func (c *CheckRunMaker) CreateOrUpdate(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var scmClient *scm.Client
+	if scmClient, err = c.newClient(); err != nil {
+		return
+	}
+
+	var sha string
+	if sha, err = c.getSHA(ctx, scmClient); err != nil {
+		return
+	}
+
+	run := newCheckRun(pipelineRun, c.target)
+	var existing *checkRun
+	if existing, err = c.findCheckRun(ctx, scmClient, sha, run.ExternalID); err != nil {
+		return
+	}
+
+	if existing == nil {
+		run.HeadSHA = sha
+		err = doGitHubRequest(ctx, scmClient, http.MethodPost, fmt.Sprintf("repos/%s/check-runs", c.repo), run, nil)
+	} else {
+		err = doGitHubRequest(ctx, scmClient, http.MethodPatch, fmt.Sprintf("repos/%s/check-runs/%d", c.repo, existing.ID), run, nil)
+	}
+	return
+}
+
+// findCheckRun finds the Check Run of a commit by its external ID
+func (c *CheckRunMaker) findCheckRun(ctx context.Context, scmClient *scm.Client, sha, externalID string) (target *checkRun, err error) {
+	query := url.Values{}
+	query.Set("check_name", statusLabel)
+	query.Set("filter", "all")
+	query.Set("per_page", "100") // assume this list has not too many items
+
+	runs := &checkRunList{}
+	if err = doGitHubRequest(ctx, scmClient, http.MethodGet,
+		fmt.Sprintf("repos/%s/commits/%s/check-runs?%s", c.repo, sha, query.Encode()), nil, runs); err != nil {
+		err = fmt.Errorf("failed to list the existing check runs, error: %v", err)
+		return
+	}
+	for _, item := range runs.CheckRuns {
+		if item.ExternalID == externalID {
+			target = item
+			break
+		}
+	}
+	return
+}
+
+// newCheckRun converts a PipelineRun to a Check Run, the head SHA is not included
+func newCheckRun(pipelineRun *v1alpha3.PipelineRun, target string) *checkRun {
+	run := &checkRun{
+		Name:       statusLabel,
+		DetailsURL: target,
+		ExternalID: fmt.Sprintf("%s/%s", pipelineRun.Namespace, pipelineRun.Name),
+		Output: &checkRunOutput{
+			Title:   getCheckRunTitle(pipelineRun),
+			Summary: getCheckRunSummary(pipelineRun, target),
+		},
+	}
+	run.Status, run.Conclusion = convertPipelineRunPhaseToCheckRun(pipelineRun.Status.Phase, pipelineRun.Status.CompletionTime != nil)
+	if startTime := pipelineRun.Status.StartTime; startTime != nil {
+		run.StartedAt = &startTime.Time
+	}
+	if run.Status == "completed" {
+		if completionTime := pipelineRun.Status.CompletionTime; completionTime != nil {
+			run.CompletedAt = &completionTime.Time
+		}
+		run.Actions = []checkRunAction{{
+			Label:       "Re-run",
+			Description: "Create a new PipelineRun",
+			Identifier:  checkRunRerunAction,
+		}}
+	}
+	return run
+}
+
+// convertPipelineRunPhaseToCheckRun returns the status and conclusion of a Check Run
+func convertPipelineRunPhaseToCheckRun(phase v1alpha3.RunPhase, completed bool) (status, conclusion string) {
+	switch phase {
+	case v1alpha3.Succeeded:
+		return "completed", "success"
+	case v1alpha3.Failed:
+		return "completed", "failure"
+	case v1alpha3.Cancelled:
+		return "completed", "cancelled"
+	case v1alpha3.Running:
+		return "in_progress", ""
+	case v1alpha3.Unknown:
+		if completed {
+			return "completed", "neutral"
+		}
+		return "in_progress", ""
+	}
+	return "queued", ""
+}
+
+func getCheckRunTitle(pipelineRun *v1alpha3.PipelineRun) string {
+	phase := pipelineRun.Status.Phase
+	if phase == "" {
+		phase = v1alpha3.Pending
+	}
+	if summary := pipelineRun.Status.Summary; summary != nil && summary.FailedStep != nil && phase == v1alpha3.Failed {
+		return fmt.Sprintf("%s at stage %s", phase, summary.FailedStep.Stage)
+	}
+	return string(phase)
+}
+
+// getCheckRunSummary returns a markdown summary of the stages, tests and the failed step of a PipelineRun
+func getCheckRunSummary(pipelineRun *v1alpha3.PipelineRun, target string) string {
+	buf := &strings.Builder{}
+	summary := pipelineRun.Status.Summary
+	if summary == nil {
+		summary = &v1alpha3.PipelineRunSummary{}
+	}
+
+	if len(summary.Stages) > 0 {
+		buf.WriteString("| Stage | Status | Duration |\n| --- | --- | --- |\n")
+		for _, stage := range summary.Stages {
+			var duration string
+			if stage.Duration != nil {
+				duration = stage.Duration.Duration.String()
+			}
+			phase := stage.Phase
+			if phase == "" {
+				phase = v1alpha3.Pending
+			}
+			fmt.Fprintf(buf, "| %s | %s | %s |\n", escapeMarkdownTable(stage.Name), phase, duration)
+		}
+		buf.WriteString("\n")
+	}
+
+	if tests := summary.Tests; tests != nil {
+		fmt.Fprintf(buf, "**Tests:** %d total, %d passed, %d failed, %d skipped\n\n",
+			tests.Total, tests.Passed, tests.Failed, tests.Skipped)
+	}
+
+	if failedStep := summary.FailedStep; failedStep != nil {
+		fmt.Fprintf(buf, "**Failed step:** %s in stage %s\n\n", failedStep.Step, failedStep.Stage)
+		if failedStep.LogExcerpt != "" {
+			fmt.Fprintf(buf, "```\n%s\n```\n\n", strings.ReplaceAll(failedStep.LogExcerpt, "```", "'''"))
+		}
+	}
+
+	if target != "" {
+		fmt.Fprintf(buf, "[View the PipelineRun %s](%s)\n", pipelineRun.Name, target)
+	} else if buf.Len() == 0 {
+		fmt.Fprintf(buf, "PipelineRun %s is %s\n", pipelineRun.Name, getCheckRunTitle(pipelineRun))
+	}
+	return buf.String()
+}
+
+func escapeMarkdownTable(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
+
+// gitHubAPIError is the unexpected response of the GitHub API
+type gitHubAPIError struct {
+	status   int
+	method   string
+	path     string
+	response string
+}
+
+func (e *gitHubAPIError) Error() string {
+	return fmt.Sprintf("unexpected status code %d of %s %s, response: %s", e.status, e.method, e.path, e.response)
+}
+
+// isCheckRunForbidden checks if the token is not allowed to write the Check Runs, such as a personal access token,
+// only a GitHub App is able to do that. It never succeeds with the same token, unlike the rate limit.
+func isCheckRunForbidden(err error) bool {
+	apiErr := &gitHubAPIError{}
+	return errors.As(err, &apiErr) && apiErr.status == http.StatusForbidden &&
+		!strings.Contains(strings.ToLower(apiErr.response), "rate limit")
+}
+
+// doGitHubRequest sends a request to the GitHub API, go-scm does not support the Checks API
+func doGitHubRequest(ctx context.Context, scmClient *scm.Client, method, path string, in, out interface{}) (err error) {
+	request := &scm.Request{
+		Method: method,
+		Path:   path,
+		Header: http.Header{"Accept": []string{"application/vnd.github+json"}},
+	}
+	if in != nil {
+		var data []byte
+		if data, err = json.Marshal(in); err != nil {
+			return
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Body = bytes.NewReader(data)
+	}
+
+	var response *scm.Response
+	if response, err = scmClient.Do(ctx, request); err != nil {
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.Status > 299 {
+		data, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		err = &gitHubAPIError{status: response.Status, method: method, path: path, response: string(data)}
+		return
+	}
+	if out != nil {
+		err = json.NewDecoder(response.Body).Decode(out)
+	}
+	return
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+const checkRunSHA = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+
+func newCheckRunPipelineRun() *v1alpha3.PipelineRun {
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetNamespace("ns")
+	pipelineRun.SetName("fake-abcde")
+	startTime := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	completionTime := metav1.NewTime(startTime.Add(time.Minute))
+	pipelineRun.Status = v1alpha3.PipelineRunStatus{
+		Phase:          v1alpha3.Failed,
+		StartTime:      &startTime,
+		CompletionTime: &completionTime,
+		Summary: &v1alpha3.PipelineRunSummary{
+			Stages: []v1alpha3.StageSummary{
+				{ID: "1", Name: "build", Phase: v1alpha3.Succeeded, Duration: &metav1.Duration{Duration: 1500 * time.Millisecond}},
+				{ID: "2", Name: "test|unit", Phase: v1alpha3.Failed},
+				{ID: "3", Name: "deploy"},
+			},
+			FailedStep: &v1alpha3.FailedStep{
+				StageID:    "2",
+				Stage:      "test|unit",
+				StepID:     "4",
+				Step:       "Shell Script",
+				LogExcerpt: "+ make test\nFAIL",
+			},
+			Tests: &v1alpha3.TestSummary{Total: 10, Passed: 8, Failed: 1, Skipped: 1},
+		},
+	}
+	return pipelineRun
+}
+
+func Test_newCheckRun(t *testing.T) {
+	pipelineRun := newCheckRunPipelineRun()
+	run := newCheckRun(pipelineRun, "https://ks.example.com/run")
+	assert.Equal(t, statusLabel, run.Name)
+	assert.Equal(t, "ns/fake-abcde", run.ExternalID)
+	assert.Equal(t, "https://ks.example.com/run", run.DetailsURL)
+	assert.Equal(t, "completed", run.Status)
+	assert.Equal(t, "failure", run.Conclusion)
+	assert.Equal(t, pipelineRun.Status.StartTime.Time, *run.StartedAt)
+	assert.Equal(t, pipelineRun.Status.CompletionTime.Time, *run.CompletedAt)
+	assert.Equal(t, []checkRunAction{{Label: "Re-run", Description: "Create a new PipelineRun", Identifier: checkRunRerunAction}}, run.Actions)
+	assert.Equal(t, "Failed at stage test|unit", run.Output.Title)
+	assert.Equal(t, "| Stage | Status | Duration |\n| --- | --- | --- |\n"+
+		"| build | Succeeded | 1.5s |\n"+
+		"| test\\|unit | Failed |  |\n"+
+		"| deploy | Pending |  |\n\n"+
+		"**Tests:** 10 total, 8 passed, 1 failed, 1 skipped\n\n"+
+		"**Failed step:** Shell Script in stage test|unit\n\n"+
+		"```\n+ make test\nFAIL\n```\n\n"+
+		"[View the PipelineRun fake-abcde](https://ks.example.com/run)\n", run.Output.Summary)
+
+	// a running PipelineRun cannot be re-run
+	pipelineRun.Status = v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running}
+	run = newCheckRun(pipelineRun, "")
+	assert.Equal(t, "in_progress", run.Status)
+	assert.Empty(t, run.Conclusion)
+	assert.Nil(t, run.StartedAt)
+	assert.Nil(t, run.CompletedAt)
+	assert.Nil(t, run.Actions)
+	assert.Equal(t, "PipelineRun fake-abcde is Running\n", run.Output.Summary)
+}
+
+func Test_convertPipelineRunPhaseToCheckRun(t *testing.T) {
+	tests := []struct {
+		phase          v1alpha3.RunPhase
+		completed      bool
+		wantStatus     string
+		wantConclusion string
+	}{
+		{phase: "", wantStatus: "queued"},
+		{phase: v1alpha3.Pending, wantStatus: "queued"},
+		{phase: v1alpha3.Running, wantStatus: "in_progress"},
+		{phase: v1alpha3.Succeeded, wantStatus: "completed", wantConclusion: "success"},
+		{phase: v1alpha3.Failed, wantStatus: "completed", wantConclusion: "failure"},
+		{phase: v1alpha3.Cancelled, wantStatus: "completed", wantConclusion: "cancelled"},
+		{phase: v1alpha3.Unknown, wantStatus: "in_progress"},
+		{phase: v1alpha3.Unknown, completed: true, wantStatus: "completed", wantConclusion: "neutral"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.phase), func(t *testing.T) {
+			status, conclusion := convertPipelineRunPhaseToCheckRun(tt.phase, tt.completed)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantConclusion, conclusion)
+		})
+	}
+}
+
+func TestCheckRunMaker_CreateOrUpdate(t *testing.T) {
+	mockList := func(body string) {
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/commits/"+checkRunSHA+"/check-runs").
+			MatchParam("check_name", statusLabel).
+			Reply(200).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			BodyString(body)
+	}
+
+	tests := []struct {
+		name    string
+		prepare func()
+		wantErr bool
+	}{{
+		name: "create a check run",
+		prepare: func() {
+			mockList(`{"total_count":1,"check_runs":[{"id":1,"external_id":"ns/other"}]}`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/check-runs").
+				BodyString(`"head_sha":"` + checkRunSHA + `".*"external_id":"ns/fake-abcde","status":"completed","conclusion":"failure"`).
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":2}`)
+		},
+	}, {
+		name: "update the existing check run",
+		prepare: func() {
+			mockList(`{"total_count":1,"check_runs":[{"id":1,"external_id":"ns/fake-abcde"}]}`)
+			gock.New("https://api.github.com").
+				Patch("/repos/octocat/hello-world/check-runs/1").
+				// the head SHA cannot be updated
+				BodyString(`^\{"name":"KubeSphere DevOps","details_url":.*"status":"completed"`).
+				Reply(200).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+	}, {
+		name: "no permission to create a check run",
+		prepare: func() {
+			mockList(`{"total_count":0,"check_runs":[]}`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/check-runs").
+				Reply(403).
+				Type("application/json").
+				BodyString(`{"message":"Resource not accessible by personal access token"}`)
+		},
+		wantErr: true,
+	}, {
+		name: "failed to list the check runs",
+		prepare: func() {
+			gock.New("https://api.github.com").
+				Get("/repos/octocat/hello-world/commits/" + checkRunSHA + "/check-runs").
+				Reply(404).
+				Type("application/json").
+				BodyString(`{"message":"Not Found"}`)
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			tt.prepare()
+
+			maker := NewStatusMaker("octocat/hello-world", "token")
+			maker.WithProvider("github").WithSHA(checkRunSHA).WithTarget("https://ks.example.com/run")
+			err := NewCheckRunMaker(maker).CreateOrUpdate(context.Background(), newCheckRunPipelineRun())
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func Test_isCheckRunForbidden(t *testing.T) {
+	assert.False(t, isCheckRunForbidden(nil))
+	assert.False(t, isCheckRunForbidden(errors.New("connection refused")))
+	assert.False(t, isCheckRunForbidden(&gitHubAPIError{status: http.StatusNotFound}))
+	assert.False(t, isCheckRunForbidden(&gitHubAPIError{status: http.StatusForbidden,
+		response: `{"message":"API rate limit exceeded for user ID 1."}`}))
+	assert.True(t, isCheckRunForbidden(&gitHubAPIError{status: http.StatusForbidden,
+		response: `{"message":"You must authenticate via a GitHub App."}`}))
+	assert.True(t, isCheckRunForbidden(fmt.Errorf("wrapped: %w", &gitHubAPIError{status: http.StatusForbidden})))
+}
@@ -16,6 +16,7 @@ package gitrepository
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -63,6 +64,7 @@ func init() {
 		&gitlabPublicAmend{},
 		&githubPublicAmend{},
 		&bitbucketPublicAmend{},
+		&giteaAmend{},
 	}
 }
 
@@ -126,6 +128,30 @@ func (a *bitbucketPublicAmend) Amend(repo *v1alpha3.GitRepository) (changed bool
 	return
 }
 
+// giteaAmend amends the GitRepository of Gitea or Forgejo, both of them are self-hosted
+type giteaAmend struct {
+}
+
+func (a *giteaAmend) Match(repo *v1alpha3.GitRepository) bool {
+	return isGiteaProvider(strings.ToLower(repo.Spec.Provider))
+}
+
+func (a *giteaAmend) Amend(repo *v1alpha3.GitRepository) (changed bool) {
+	if repo.Spec.URL == "" && repo.Spec.Server != "" {
+		repo.Spec.URL = fmt.Sprintf("%s/%s/%s",
+			strings.TrimSuffix(repo.Spec.Server, "/"), repo.Spec.Owner, repo.Spec.Repo)
+		changed = true
+	}
+
+	if repo.Spec.Server == "" && repo.Spec.URL != "" {
+		if gitURL, err := url.Parse(repo.Spec.URL); err == nil && gitURL.Host != "" {
+			repo.Spec.Server = fmt.Sprintf("%s://%s", gitURL.Scheme, gitURL.Host)
+			changed = true
+		}
+	}
+	return
+}
+
 func (r *AmendReconciler) GetName() string {
 	return "git-repository-amend"
 }
@@ -95,6 +95,64 @@ func Test_amendGitlabURL(t *testing.T) {
 	}
 }
 
+func Test_amendGiteaURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		repo        *v1alpha3.GitRepository
+		wantMatch   bool
+		wantChanged bool
+		wantURL     string
+		wantServer  string
+	}{{
+		name: "not gitea",
+		repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{Provider: "github"}},
+	}, {
+		name: "gitea, have server, owner and repo, but without URL",
+		repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+			Provider: "gitea",
+			Server:   "https://gitea.com/",
+			Owner:    "linuxsuren",
+			Repo:     "test",
+		}},
+		wantMatch:   true,
+		wantChanged: true,
+		wantURL:     "https://gitea.com/linuxsuren/test",
+		wantServer:  "https://gitea.com/",
+	}, {
+		name: "forgejo, have URL, but without server",
+		repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+			Provider: "Forgejo",
+			URL:      "https://codeberg.org/linuxsuren/test.git",
+		}},
+		wantMatch:   true,
+		wantChanged: true,
+		wantURL:     "https://codeberg.org/linuxsuren/test.git",
+		wantServer:  "https://codeberg.org",
+	}, {
+		name: "gitea, nothing to amend",
+		repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+			Provider: "gitea",
+			Server:   "https://gitea.com",
+			URL:      "https://gitea.com/linuxsuren/test",
+		}},
+		wantMatch:  true,
+		wantURL:    "https://gitea.com/linuxsuren/test",
+		wantServer: "https://gitea.com",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amend := giteaAmend{}
+			assert.Equal(t, tt.wantMatch, amend.Match(tt.repo))
+			if !tt.wantMatch {
+				return
+			}
+			assert.Equal(t, tt.wantChanged, amend.Amend(tt.repo))
+			assert.Equal(t, tt.wantURL, tt.repo.Spec.URL)
+			assert.Equal(t, tt.wantServer, tt.repo.Spec.Server)
+		})
+	}
+}
+
 func TestAmendReconciler_SetupWithManager(t *testing.T) {
 	schema, err := v1alpha3.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
@@ -110,7 +110,11 @@ func (r *Reconciler) createOrUpdateWebhook(repo *v1alpha3.GitRepository) (err er
 		}
 
 		// the token is optional, we can ignore the error
-		webhookToken, _ := r.getTokenFromSecret(repo.Spec.Secret, repo.Namespace)
+		secretRef := repo.Spec.Secret
+		if webhook.Spec.Secret != nil {
+			secretRef = webhook.Spec.Secret
+		}
+		webhookToken, _ := r.getTokenFromSecret(secretRef, repo.Namespace)
 
 		// TODO users need to add every single event of target git provider if they want to add all of them
 		//   it's possible to have a solution to allow users add all events in an easy way.
@@ -153,7 +157,12 @@ func (r *Reconciler) getGitClient(repo *v1alpha3.GitRepository) (client *scm.Cli
 	if spec.Secret != nil && spec.Secret.Namespace == "" {
 		spec.Secret.Namespace = repo.Namespace
 	}
-	return git.NewClientFactory(provider, spec.Secret, r.Client).GetClient()
+	factory := git.NewClientFactory(provider, spec.Secret, r.Client)
+	if isGiteaProvider(provider) {
+		// there is no public server of Gitea, the API address depends on the server
+		factory.Server = spec.Server
+	}
+	return factory.GetClient()
 }
 
 func (r *Reconciler) getTokenFromSecret(secretRef *v1.SecretReference, defaultNamespace string) (token string, err error) {
@@ -198,10 +207,19 @@ func getRepo(repo *v1alpha3.GitRepository) string {
 		return strings.ReplaceAll(address, "https://github.com/", "")
 	case "gitlab":
 		return strings.ReplaceAll(address, "https://gitlab.com/", "")
+	case "gitea", "forgejo":
+		if repo.Spec.Owner != "" && repo.Spec.Repo != "" {
+			return fmt.Sprintf("%s/%s", repo.Spec.Owner, repo.Spec.Repo)
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(address, strings.TrimSuffix(repo.Spec.Server, "/")+"/"), ".git")
 	}
 	return ""
 }
 
+func isGiteaProvider(provider string) bool {
+	return provider == "gitea" || provider == "forgejo"
+}
+
 func (r *Reconciler) linkToWebhooks(repo *v1alpha3.GitRepository) (err error) {
 	var failedLinks []string
 	for i := range repo.Spec.Webhooks {
@@ -80,6 +80,26 @@ func Test_getRepo(t *testing.T) {
 			}},
 		},
 		want: "linuxsuren/test",
+	}, {
+		name: "gitea as the provider",
+		args: args{
+			repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+				Provider: "gitea",
+				Owner:    "linuxsuren",
+				Repo:     "test",
+			}},
+		},
+		want: "linuxsuren/test",
+	}, {
+		name: "forgejo as the provider, without owner and repo",
+		args: args{
+			repo: &v1alpha3.GitRepository{Spec: v1alpha3.GitRepositorySpec{
+				Provider: "forgejo",
+				Server:   "https://codeberg.org/",
+				URL:      "https://codeberg.org/linuxsuren/test.git",
+			}},
+		},
+		want: "linuxsuren/test",
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
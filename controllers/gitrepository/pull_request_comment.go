@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// PullRequestCommenter posts the summary of a PipelineRun as a comment of its pull request.
+// There is only one comment for a Pipeline in a pull request, it's updated in place by the later PipelineRuns.
+type PullRequestCommenter struct {
+	*StatusMaker
+}
+
+// NewPullRequestCommenter creates an instance of PullRequestCommenter, the repository, token and pull request come from the StatusMaker
+func NewPullRequestCommenter(maker *StatusMaker) *PullRequestCommenter {
+	return &PullRequestCommenter{StatusMaker: maker}
+}
+
+// CreateOrUpdate creates the comment of a PipelineRun, or updates the existing one of the same Pipeline
+func (c *PullRequestCommenter) CreateOrUpdate(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (err error) {
+	if c.pr <= 0 {
+		return fmt.Errorf("no pull request is specified")
+	}
+
+	var scmClient *scm.Client
+	if scmClient, err = c.newClient(); err != nil {
+		return
+	}
+
+	// anyone is able to write the marker in a comment, only the comments of the token owner are updated
+	var bot *scm.User
+	if bot, _, err = scmClient.Users.Find(ctx); err != nil {
+		err = fmt.Errorf("failed to find the user of the token, error: %v", err)
+		return
+	}
+
+	marker := getCommentMarker(pipelineRun)
+	body := marker + "\n" + getPullRequestCommentBody(pipelineRun, c.target)
+	var existing *scm.Comment
+	if existing, err = c.findComment(ctx, scmClient, bot.Login, marker); err != nil {
+		return
+	}
+
+	if existing == nil {
+		_, _, err = scmClient.PullRequests.CreateComment(ctx, c.repo, c.pr, &scm.CommentInput{Body: body})
+	} else if existing.Body != body {
+		_, _, err = scmClient.PullRequests.EditComment(ctx, c.repo, c.pr, existing.ID, &scm.CommentInput{Body: body})
+	}
+	return
+}
+
+// findComment finds the comment which has the marker and is posted by the author
+func (c *PullRequestCommenter) findComment(ctx context.Context, scmClient *scm.Client, author, marker string) (target *scm.Comment, err error) {
+	opts := &scm.ListOptions{Page: 1, Size: 100}
+	for {
+		var (
+			comments []*scm.Comment
+			response *scm.Response
+		)
+		if comments, response, err = scmClient.PullRequests.ListComments(ctx, c.repo, c.pr, opts); err != nil {
+			err = fmt.Errorf("failed to list the comments of pull request %d, error: %v", c.pr, err)
+			return
+		}
+		for _, comment := range comments {
+			if comment.Author.Login == author && strings.HasPrefix(comment.Body, marker) {
+				target = comment
+				return
+			}
+		}
+		if response == nil || response.Page.Next <= opts.Page {
+			return
+		}
+		opts.Page = response.Page.Next
+	}
+}
+
+// getCommentMarker returns an invisible marker which identifies the comment of a Pipeline
+func getCommentMarker(pipelineRun *v1alpha3.PipelineRun) string {
+	var pipeline string
+	if pipelineRun.Spec.PipelineRef != nil {
+		pipeline = pipelineRun.Spec.PipelineRef.Name
+	}
+	return fmt.Sprintf("<!-- ks-devops pipeline: %s/%s -->", pipelineRun.Namespace, pipeline)
+}
+
+// getPullRequestCommentBody returns a markdown summary of the phase, duration and failed stage of a PipelineRun
+func getPullRequestCommentBody(pipelineRun *v1alpha3.PipelineRun, target string) string {
+	name := pipelineRun.Name
+	if target != "" {
+		name = fmt.Sprintf("[%s](%s)", pipelineRun.Name, target)
+	}
+
+	var duration string
+	if startTime, completionTime := pipelineRun.Status.StartTime, pipelineRun.Status.CompletionTime; startTime != nil && completionTime != nil {
+		duration = completionTime.Sub(startTime.Time).Round(time.Second).String()
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "**%s**: %s\n\n", statusLabel, pipelineRun.Status.Phase)
+	buf.WriteString("| PipelineRun | Phase | Duration | Failed stage |\n| --- | --- | --- | --- |\n")
+	fmt.Fprintf(buf, "| %s | %s | %s | %s |\n\n", name, pipelineRun.Status.Phase, duration,
+		escapeMarkdownTable(getFailedStage(pipelineRun)))
+	buf.WriteString("Comment `/retest` to run it again, or `/cancel` to cancel the running PipelineRuns of this pull request.\n")
+	return buf.String()
+}
+
+// getFailedStage returns the stage of the failed step, or the first failed stage
+func getFailedStage(pipelineRun *v1alpha3.PipelineRun) string {
+	summary := pipelineRun.Status.Summary
+	if summary == nil {
+		return ""
+	}
+	if summary.FailedStep != nil {
+		return summary.FailedStep.Stage
+	}
+	for _, stage := range summary.Stages {
+		if stage.Phase == v1alpha3.Failed {
+			return stage.Name
+		}
+	}
+	return ""
+}
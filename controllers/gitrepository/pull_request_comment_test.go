@@ -0,0 +1,168 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func newCommentPipelineRun() *v1alpha3.PipelineRun {
+	pipelineRun := newCheckRunPipelineRun()
+	pipelineRun.Spec.PipelineRef = &corev1.ObjectReference{Name: "fake"}
+	return pipelineRun
+}
+
+func Test_getPullRequestCommentBody(t *testing.T) {
+	pipelineRun := newCommentPipelineRun()
+	assert.Equal(t, "<!-- ks-devops pipeline: ns/fake -->", getCommentMarker(pipelineRun))
+	assert.Equal(t, "**KubeSphere DevOps**: Failed\n\n"+
+		"| PipelineRun | Phase | Duration | Failed stage |\n| --- | --- | --- | --- |\n"+
+		"| [fake-abcde](https://ks.example.com/run) | Failed | 1m0s | test\\|unit |\n\n"+
+		"Comment `/retest` to run it again, or `/cancel` to cancel the running PipelineRuns of this pull request.\n",
+		getPullRequestCommentBody(pipelineRun, "https://ks.example.com/run"))
+
+	// the first failed stage is used if the failed step is unknown
+	pipelineRun.Status.Summary.FailedStep = nil
+	assert.Equal(t, "test|unit", getFailedStage(pipelineRun))
+
+	pipelineRun.Status.Phase = v1alpha3.Succeeded
+	pipelineRun.Status.Summary = nil
+	pipelineRun.Status.CompletionTime = nil
+	assert.Equal(t, "**KubeSphere DevOps**: Succeeded\n\n"+
+		"| PipelineRun | Phase | Duration | Failed stage |\n| --- | --- | --- | --- |\n"+
+		"| fake-abcde | Succeeded |  |  |\n\n"+
+		"Comment `/retest` to run it again, or `/cancel` to cancel the running PipelineRuns of this pull request.\n",
+		getPullRequestCommentBody(pipelineRun, ""))
+}
+
+func TestPullRequestCommenter_CreateOrUpdate(t *testing.T) {
+	pipelineRun := newCommentPipelineRun()
+	body, _ := json.Marshal(getCommentMarker(pipelineRun) + "\n" + getPullRequestCommentBody(pipelineRun, ""))
+	mockUser := func() {
+		gock.New("https://api.github.com").
+			Get("/user").
+			Reply(200).
+			Type("application/json").
+			BodyString(`{"id":1,"login":"ks-bot"}`)
+	}
+	mockList := func(body string) {
+		mockUser()
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/issues/1347/comments").
+			Reply(200).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			BodyString(body)
+	}
+
+	tests := []struct {
+		name    string
+		pr      int
+		prepare func()
+		wantErr bool
+	}{{
+		name:    "no pull request",
+		wantErr: true,
+	}, {
+		name: "create a comment",
+		pr:   1347,
+		prepare: func() {
+			mockList(`[{"id":1,"body":"<!-- ks-devops pipeline: ns/other -->","user":{"login":"ks-bot"}}]`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/issues/1347/comments").
+				BodyString(`ks-devops pipeline: ns/fake`).
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":2}`)
+		},
+	}, {
+		name: "update the existing comment",
+		pr:   1347,
+		prepare: func() {
+			mockList(`[{"id":1,"body":"<!-- ks-devops pipeline: ns/fake -->\nRunning","user":{"login":"ks-bot"}}]`)
+			gock.New("https://api.github.com").
+				Patch("/repos/octocat/hello-world/issues/comments/1").
+				BodyString(`Failed`).
+				Reply(200).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+	}, {
+		name: "the existing comment is up to date",
+		pr:   1347,
+		prepare: func() {
+			mockList(`[{"id":1,"body":` + string(body) + `,"user":{"login":"ks-bot"}}]`)
+		},
+	}, {
+		name: "the comment with the marker of another user is not updated",
+		pr:   1347,
+		prepare: func() {
+			mockList(`[{"id":1,"body":"<!-- ks-devops pipeline: ns/fake -->\nRunning","user":{"login":"alice"}}]`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/issues/1347/comments").
+				BodyString(`ks-devops pipeline: ns/fake`).
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":2}`)
+		},
+	}, {
+		name: "failed to find the user of the token",
+		pr:   1347,
+		prepare: func() {
+			gock.New("https://api.github.com").
+				Get("/user").
+				Reply(403).
+				Type("application/json").
+				BodyString(`{"message":"Resource not accessible by integration"}`)
+		},
+		wantErr: true,
+	}, {
+		name: "failed to list the comments",
+		pr:   1347,
+		prepare: func() {
+			mockUser()
+			gock.New("https://api.github.com").
+				Get("/repos/octocat/hello-world/issues/1347/comments").
+				Reply(404).
+				Type("application/json").
+				BodyString(`{"message":"Not Found"}`)
+		},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			maker := NewStatusMaker("octocat/hello-world", "token")
+			maker.WithProvider("github").WithPR(tt.pr)
+			err := NewPullRequestCommenter(maker).CreateOrUpdate(context.Background(), newCommentPipelineRun())
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
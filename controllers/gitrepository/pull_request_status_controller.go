@@ -15,10 +15,15 @@ package gitrepository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/kubesphere/ks-devops/pkg/utils/stringutils"
 
@@ -26,6 +31,8 @@ import (
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
 	"github.com/kubesphere/ks-devops/pkg/utils/net"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,18 +42,38 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// PullRequestStatusReconciler reconciles a Pipeline build status to the Pull Requests
+// statusLabel is the context of the commit status of a PipelineRun, the stages are reported as "<statusLabel> / <stage>"
+const statusLabel = "KubeSphere DevOps"
+
+// checkRunForbiddenReason is the reason of the Event when the token is not allowed to write the Check Runs
+const checkRunForbiddenReason = "CheckRunForbidden"
+
+// scmAnnotationKey is the Git URL of a regular Pipeline which is triggered by the SCM webhook
+const scmAnnotationKey = "scm.devops.kubesphere.io"
+
+// PullRequestStatusReconciler reconciles a Pipeline build status to the commit of the Pull Requests, branches and tags
 type PullRequestStatusReconciler struct {
 	client.Client
 	ExternalAddress string
 	ClusterName     string
+	// StageStatus indicates if the status of each stage is reported as a separate context
+	StageStatus bool
+	// CheckRun indicates if a Check Run is reported along with the commit status, it only applies to GitHub
+	CheckRun bool
+	// PullRequestComment indicates if a summary comment is posted to the pull request when a PipelineRun finishes
+	PullRequestComment bool
 
 	log      logr.Logger
 	recorder record.EventRecorder
+	// checkRunForbiddenTokens are the hashes of the tokens which are not allowed to write the Check Runs
+	checkRunForbiddenTokens sync.Map
 }
 
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=webhooks,verbs=get;list;update;patch
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=secrets,verbs=get
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines;gitrepositories,verbs=get;list
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 
 // Reconcile is the main entry of this reconciler
 func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
@@ -57,21 +84,35 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return
 	}
 
-	if !pipelinerun.Spec.IsMultiBranchPipeline() || pipelinerun.Spec.SCM == nil || pipelinerun.Status.Phase == "" {
+	if pipelinerun.Status.Phase == "" {
 		return
 	}
 
-	r.log.Info(fmt.Sprintf("start to reconcile %s", req.NamespacedName))
-	var prNumber int
-	if prNumber, err = getPRNumber(pipelinerun.Spec.SCM.RefName); err != nil {
-		err = nil
-		return
+	// the commit is only known if the PipelineRun is triggered by the SCM webhook
+	commit := pipelinerun.Annotations[v1alpha3.PipelineRunCommitAnnoKey]
+	var repoInfo repoInformation
+	if pipelinerun.Spec.IsMultiBranchPipeline() {
+		if pipelinerun.Spec.SCM == nil {
+			return
+		}
+		if commit == "" && pipelinerun.Status.Summary != nil {
+			// the commit checked out by the Jenkins build, the pull request or reference might move during the build
+			commit = pipelinerun.Status.Summary.CommitSHA
+		}
+		if commit == "" {
+			r.log.Info(fmt.Sprintf("skip sending status of %s since its commit is not checked out yet", req.NamespacedName))
+			return
+		}
+		repoInfo = getRepoInfo(pipelinerun.Spec.PipelineSpec.MultiBranchPipeline)
+	} else if commit != "" {
+		if repoInfo, err = r.getWebhookRepoInfo(ctx, pipelinerun); err != nil {
+			return
+		}
 	}
-
-	repoInfo := getRepoInfo(pipelinerun.Spec.PipelineSpec.MultiBranchPipeline)
 	if repoInfo.isInvalid() {
 		return
 	}
+	r.log.Info(fmt.Sprintf("start to reconcile %s", req.NamespacedName))
 
 	var (
 		token    string
@@ -79,14 +120,14 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	)
 	if username, token, err = r.getTokenFromSecret(&v1.SecretReference{
 		Name:      repoInfo.tokenId,
-		Namespace: pipelinerun.Namespace,
-	}, ""); err != nil {
+		Namespace: repoInfo.tokenNamespace,
+	}, pipelinerun.Namespace); err != nil {
 		err = fmt.Errorf("failed to get token, error %v", err)
 		return
 	}
 
 	repo := repoInfo.getRepoPath()
-	r.log.Info(fmt.Sprintf("start sending status to %s with pr %d", repo, prNumber))
+	r.log.Info(fmt.Sprintf("start sending status to %s", repo))
 
 	var target string
 	if target, err = r.getExternalPipelineRunAddress(ctx, pipelinerun); err != nil {
@@ -94,9 +135,16 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 
 	maker := NewStatusMaker(repo, token)
-	maker.WithTarget(target).WithPR(prNumber).WithProvider(repoInfo.provider).WithUsername(username)
+	maker.WithTarget(target).WithProvider(repoInfo.provider).WithUsername(username)
+	maker.WithServer(repoInfo.server).WithSHA(commit)
+	if scmRef := pipelinerun.Spec.SCM; scmRef != nil {
+		if prNumber, prErr := getPRNumber(scmRef.RefName); prErr == nil {
+			maker.WithPR(prNumber)
+		} else {
+			maker.WithRef(scmRef.RefType, scmRef.RefName)
+		}
+	}
 	maker.WithExpirationCheck(createExpirationCheckFunc(ctx, r, pipelinerun.DeepCopy()))
-
 	var desc string
 	switch pipelinerun.Status.Phase {
 	case v1alpha3.Succeeded:
@@ -108,13 +156,92 @@ func (r *PullRequestStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		desc = string(pipelinerun.Status.Phase)
 	}
 
-	err = maker.CreateWithPipelinePhase(ctx, pipelinerun.Status.Phase, "KubeSphere DevOps", desc)
+	err = maker.CreateWithPipelinePhase(ctx, pipelinerun.Status.Phase, statusLabel, desc)
+	if err == nil && r.StageStatus {
+		err = r.createStageStatuses(ctx, maker, pipelinerun)
+	}
+	if err == nil && r.CheckRun && repoInfo.provider == "github" {
+		err = r.createCheckRun(ctx, maker, pipelinerun)
+	}
+	if err == nil && r.PullRequestComment && maker.pr > 0 && pipelinerun.HasCompleted() {
+		err = NewPullRequestCommenter(maker).CreateOrUpdate(ctx, pipelinerun)
+	}
 	if err != nil {
 		r.log.Error(err, "failed to send status")
 	}
 	return
 }
 
+// createCheckRun creates or updates the Check Run of a PipelineRun. Only the commit statuses are reported if the token
+// is not allowed to write the Check Runs, it's detected once for each token instead of failing in every reconciling.
+func (r *PullRequestStatusReconciler) createCheckRun(ctx context.Context, maker *StatusMaker, pipelineRun *v1alpha3.PipelineRun) (err error) {
+	tokenHash := sha256.Sum256([]byte(maker.token))
+	if _, forbidden := r.checkRunForbiddenTokens.Load(tokenHash); forbidden {
+		return
+	}
+
+	if err = NewCheckRunMaker(maker).CreateOrUpdate(ctx, pipelineRun); isCheckRunForbidden(err) {
+		r.checkRunForbiddenTokens.Store(tokenHash, true)
+		r.recorder.Eventf(pipelineRun, v1.EventTypeWarning, checkRunForbiddenReason,
+			"The token of %s is not a GitHub App token which is able to write the Check Runs, "+
+				"only the commit statuses are reported: %v", maker.repo, err)
+		err = nil
+	}
+	return
+}
+
+// createStageStatuses reports the status of each stage as a separate context, the stages not built are ignored
+func (r *PullRequestStatusReconciler) createStageStatuses(ctx context.Context, maker *StatusMaker, pipelineRun *v1alpha3.PipelineRun) (err error) {
+	stagesJSON := pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]
+	if stagesJSON == "" && pipelineRun.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] != "s3" {
+		store, storeErr := cmstore.NewConfigMapStore(ctx, client.ObjectKeyFromObject(pipelineRun), r.Client)
+		if storeErr != nil {
+			err = storeErr
+			return
+		}
+		stagesJSON = store.GetStages()
+	}
+	if stagesJSON == "" {
+		return
+	}
+
+	var stages []pipelinerun.NodeDetail
+	if err = json.Unmarshal([]byte(stagesJSON), &stages); err != nil {
+		err = fmt.Errorf("failed to parse the stages of PipelineRun %s, error: %v", pipelineRun.Name, err)
+		return
+	}
+	for _, stage := range stages {
+		status, desc := convertStageToSCMStatus(stage.State, stage.Result)
+		if status == scm.StateUnknown || stage.DisplayName == "" {
+			continue
+		}
+		if err = maker.Create(ctx, status, statusLabel+" / "+stage.DisplayName, desc); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// convertStageToSCMStatus converts the state and result of a Jenkins stage, it returns the unknown state if the stage is not built
+func convertStageToSCMStatus(state, result string) (status scm.State, desc string) {
+	switch state {
+	case "QUEUED":
+		return scm.StatePending, "Pending"
+	case "RUNNING", "PAUSED":
+		return scm.StateRunning, "Running"
+	case "FINISHED":
+		switch result {
+		case "SUCCESS":
+			return scm.StateSuccess, "Successful"
+		case "FAILURE", "UNSTABLE":
+			return scm.StateFailure, "Failed"
+		case "ABORTED":
+			return scm.StateCanceled, "Aborted"
+		}
+	}
+	return scm.StateUnknown, ""
+}
+
 // createExpirationCheckFunc checks the start time of the PipelineRun
 func createExpirationCheckFunc(ctx context.Context, k8sClient client.Client, currentPipelineRun *v1alpha3.PipelineRun) expirationCheckFunc {
 	return func(previousStatus *scm.Status, currentStatus *scm.StatusInput) bool {
@@ -161,9 +288,12 @@ func getPipelineRunNameAndNsFromURL(link string) (name, ns string, err error) {
 
 type repoInformation struct {
 	provider string
+	server   string
 	owner    string
 	repo     string
 	tokenId  string
+	// tokenNamespace is the namespace of the token secret, it is the namespace of the PipelineRun if it is empty
+	tokenNamespace string
 }
 
 func (r repoInformation) getRepoPath() string {
@@ -204,16 +334,83 @@ func getRepoInfo(repo *v1alpha3.MultiBranchPipeline) (info repoInformation) {
 			info.repo = strings.TrimPrefix(repo.GitlabSource.Repo, repo.GitlabSource.Owner+"/")
 			info.tokenId = repo.GitlabSource.CredentialId
 		}
+	case v1alpha3.SourceTypeGitea:
+		if repo.GiteaSource != nil {
+			info.provider = "gitea"
+			info.server = repo.GiteaSource.ServerUrl
+			info.owner = repo.GiteaSource.Owner
+			info.repo = repo.GiteaSource.Repo
+			info.tokenId = repo.GiteaSource.CredentialId
+		}
 	}
 	return
 }
 
+// getWebhookRepoInfo returns the repository of a regular Pipeline which is triggered by the SCM webhook,
+// the token comes from the GitRepository which has the same URL
+func (r *PullRequestStatusReconciler) getWebhookRepoInfo(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (info repoInformation, err error) {
+	if pipelineRun.Spec.PipelineRef == nil {
+		return
+	}
+	pipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: pipelineRun.Namespace, Name: pipelineRun.Spec.PipelineRef.Name}, pipeline); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
+	if gitURL == "" {
+		return
+	}
+
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err = r.List(ctx, repoList, client.InNamespace(pipelineRun.Namespace)); err != nil {
+		return
+	}
+	for i := range repoList.Items {
+		repo := &repoList.Items[i]
+		if repo.Spec.Secret == nil || !isSameGitURL(repo.Spec.URL, gitURL) {
+			continue
+		}
+
+		info.provider = strings.ToLower(repo.Spec.Provider)
+		if isGiteaProvider(info.provider) {
+			info.provider = "gitea"
+		}
+		info.server = repo.Spec.Server
+		info.owner, info.repo = repo.Spec.Owner, repo.Spec.Repo
+		if info.owner == "" || info.repo == "" {
+			repoPath := strings.TrimSuffix(getRepo(repo), ".git")
+			if index := strings.LastIndex(repoPath, "/"); index > 0 {
+				info.owner, info.repo = repoPath[:index], repoPath[index+1:]
+			}
+		}
+		info.tokenId = repo.Spec.Secret.Name
+		info.tokenNamespace = repo.Spec.Secret.Namespace
+		return
+	}
+	return
+}
+
+// isSameGitURL compares two Git URLs, the suffix ".git" and the letter case are ignored
+func isSameGitURL(a, b string) bool {
+	normalize := func(gitURL string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(gitURL), "/"), ".git")
+	}
+	return a != "" && normalize(a) == normalize(b)
+}
+
 func (r *PullRequestStatusReconciler) getExternalPipelineRunAddress(ctx context.Context, pipelineRun *v1alpha3.PipelineRun) (target string, err error) {
 	var ws string
 	if ws, err = r.getWorkspace(ctx, pipelineRun.GetNamespace()); err == nil {
-		target = fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/branch/%s/run/%s/task-status",
-			net.ParseURL(r.ExternalAddress), ws, r.ClusterName,
-			pipelineRun.Namespace, pipelineRun.Spec.PipelineRef.Name, pipelineRun.Spec.SCM.RefName, pipelineRun.Name)
+		if pipelineRun.Spec.IsMultiBranchPipeline() && pipelineRun.Spec.SCM != nil {
+			target = fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/branch/%s/run/%s/task-status",
+				net.ParseURL(r.ExternalAddress), ws, r.ClusterName,
+				pipelineRun.Namespace, pipelineRun.Spec.PipelineRef.Name, pipelineRun.Spec.SCM.RefName, pipelineRun.Name)
+		} else {
+			target = fmt.Sprintf("%s/%s/clusters/%s/devops/%s/pipelines/%s/run/%s/task-status",
+				net.ParseURL(r.ExternalAddress), ws, r.ClusterName,
+				pipelineRun.Namespace, pipelineRun.Spec.PipelineRef.Name, pipelineRun.Name)
+		}
 	}
 	return
 }
@@ -315,12 +512,15 @@ func (r *PullRequestStatusReconciler) getTimeSinceFinished(time *metav1.Time) (s
 	return
 }
 
-// StatusMaker responsible for Pull Requests status creating
+// StatusMaker responsible for the commit status creating of Pull Requests, branches and tags
 type StatusMaker struct {
 	provider string
 	server   string
 	repo     string
 	pr       int
+	refType  v1alpha3.RefType
+	ref      string
+	sha      string
 	token    string
 	username string
 	target   string
@@ -378,20 +578,33 @@ func (s *StatusMaker) WithPR(pr int) *StatusMaker {
 	return s
 }
 
+// WithRef sets the branch or tag, the name is the same as the Jenkins job which might be escaped
+func (s *StatusMaker) WithRef(refType v1alpha3.RefType, ref string) *StatusMaker {
+	s.refType = refType
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	s.ref = ref
+	return s
+}
+
+// WithSHA sets the commit SHA, it takes precedence over the pr number and the reference
+func (s *StatusMaker) WithSHA(sha string) *StatusMaker {
+	s.sha = sha
+	return s
+}
+
 // Create creates a generic status
 func (s *StatusMaker) Create(ctx context.Context, status scm.State, label, desc string) (err error) {
 	var scmClient *scm.Client
-	scmClient, err = factory.NewClient(s.provider, s.server, s.token, func(c *scm.Client) {
-		c.Username = s.username
-	})
-	if err != nil {
+	if scmClient, err = s.newClient(); err != nil {
 		return
 	}
 
-	var pullRequest *scm.PullRequest
-	if pullRequest, _, err = scmClient.PullRequests.Find(ctx, s.repo, s.pr); err == nil {
+	var sha string
+	if sha, err = s.getSHA(ctx, scmClient); err == nil {
 		var previousStatus *scm.Status
-		if previousStatus, err = s.FindPreviousStatus(ctx, scmClient, pullRequest.Sha, label); err != nil {
+		if previousStatus, err = s.FindPreviousStatus(ctx, scmClient, sha, label); err != nil {
 			return
 		}
 
@@ -403,12 +616,50 @@ func (s *StatusMaker) Create(ctx context.Context, status scm.State, label, desc
 		}
 		// avoid the previous building status override newer one
 		if !s.expirationCheck(previousStatus, currentStatus) {
-			_, _, err = scmClient.Repositories.CreateStatus(ctx, s.repo, pullRequest.Sha, currentStatus)
+			_, _, err = scmClient.Repositories.CreateStatus(ctx, s.repo, sha, currentStatus)
 		}
 	}
 	return
 }
 
+func (s *StatusMaker) newClient() (*scm.Client, error) {
+	return factory.NewClient(s.provider, s.server, s.token, func(c *scm.Client) {
+		c.Username = s.username
+	})
+}
+
+// getSHA returns the commit SHA, it is resolved from the pull request, tag or branch if it is not set.
+// It's the current head of the reference, which might be different from the commit of a PipelineRun.
+func (s *StatusMaker) getSHA(ctx context.Context, scmClient *scm.Client) (sha string, err error) {
+	if s.sha != "" {
+		return s.sha, nil
+	}
+
+	switch {
+	case s.pr > 0:
+		var pullRequest *scm.PullRequest
+		if pullRequest, _, err = scmClient.PullRequests.Find(ctx, s.repo, s.pr); err == nil {
+			sha = pullRequest.Sha
+		}
+	case s.ref != "" && s.refType == v1alpha3.Tag:
+		// the commit API accepts a tag name, and returns the tagged commit rather than the tag object
+		var commit *scm.Commit
+		if commit, _, err = scmClient.Git.FindCommit(ctx, s.repo, s.ref); err == nil {
+			sha = commit.Sha
+		}
+	case s.ref != "":
+		var ref *scm.Reference
+		if ref, _, err = scmClient.Git.FindBranch(ctx, s.repo, s.ref); err == nil {
+			sha = ref.Sha
+		}
+	default:
+		err = errors.New("no commit, pull request or reference is specified")
+	}
+	// the statuses of stages are reported against the same commit
+	s.sha = sha
+	return
+}
+
 // FindPreviousStatus finds the existing status by sha and label
 func (s *StatusMaker) FindPreviousStatus(ctx context.Context, scmClient *scm.Client, sha, label string) (target *scm.Status, err error) {
 	var exists []*scm.Status
@@ -240,13 +240,67 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 		"kubesphere.io/workspace": "ws",
 	}
 
+	runningRun := pipRun.DeepCopy()
+	runningRun.Status.Phase = v1alpha3.Running
+	runningRun.Status.CompletionTime = nil
+	// the commit is known once Jenkins checks out the SCM
+	runningRun.Status.Summary = &v1alpha3.PipelineRunSummary{CommitSHA: "6dcb09b5b57875f334f61aebed695e2e4193db5e"}
+	branchRun := runningRun.DeepCopy()
+	branchRun.Spec.SCM = &v1alpha3.SCM{RefType: v1alpha3.Branch, RefName: "feature%2Fa"}
+	uncheckedBranchRun := branchRun.DeepCopy()
+	uncheckedBranchRun.Status.Summary = nil
+
+	pipeline := &v1alpha3.Pipeline{}
+	pipeline.SetName("pipeline")
+	pipeline.SetNamespace(defaultReq.namespace)
+	pipeline.SetAnnotations(map[string]string{scmAnnotationKey: "https://github.com/octocat/hello-world"})
+	gitRepo := &v1alpha3.GitRepository{}
+	gitRepo.SetName("hello-world")
+	gitRepo.SetNamespace(defaultReq.namespace)
+	gitRepo.Spec = v1alpha3.GitRepositorySpec{
+		Provider: "github",
+		URL:      "https://github.com/octocat/hello-world.git",
+		Secret:   &v1.SecretReference{Name: "token"},
+	}
+	webhookRun := pipRun.DeepCopy()
+	webhookRun.Spec.SCM = nil
+	webhookRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType}
+	webhookRun.Annotations = map[string]string{
+		v1alpha3.PipelineRunCommitAnnoKey: "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey: `[{"displayName":"build","state":"FINISHED","result":"SUCCESS"},` +
+			`{"displayName":"test","state":"RUNNING"},{"displayName":"deploy","state":"NOT_BUILT","result":"NOT_BUILT"}]`,
+	}
+	mockStatuses := func(times int) {
+		gock.New("https://api.github.com").
+			Post("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+			Times(times).
+			Reply(201).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			File("testdata/status.json")
+
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
+			MatchParam("page", "1").
+			MatchParam("per_page", "100").
+			Times(times).
+			Reply(200).
+			Type("application/json").
+			SetHeaders(mockHeaders).
+			SetHeaders(mockPageHeaders).
+			File("testdata/statuses.json")
+	}
+
 	tests := []struct {
-		name       string
-		request    request
-		prepare    func(*testing.T)
-		k8sClient  client.Client
-		wantResult ctrl.Result
-		wantErr    bool
+		name        string
+		request     request
+		prepare     func(*testing.T)
+		k8sClient   client.Client
+		stageStatus bool
+		checkRun    bool
+		wantResult  ctrl.Result
+		wantErr     bool
+		wantDone    bool
 	}{{
 		name:      "not found pipelinerun",
 		request:   defaultReq,
@@ -257,13 +311,6 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 		name:    "pipeline with github",
 		request: defaultReq,
 		prepare: func(t *testing.T) {
-			gock.New("https://api.github.com").
-				Get("/repos/octocat/hello-world/pulls/1347").
-				Reply(200).
-				Type("application/json").
-				SetHeaders(mockHeaders).
-				File("testdata/pr.json")
-
 			gock.New("https://api.github.com").
 				Post("/repos/octocat/hello-world/statuses/6dcb09b5b57875f334f61aebed695e2e4193db5e").
 				Reply(201).
@@ -281,8 +328,61 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 				SetHeaders(mockPageHeaders).
 				File("testdata/statuses.json")
 		},
-		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(pipRun.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(runningRun.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
 		wantErr:   false,
+	}, {
+		name:    "multi-branch pipeline with a branch",
+		request: defaultReq,
+		prepare: func(t *testing.T) {
+			// the status is reported against the commit built by Jenkins, the head of the branch is not looked up
+			mockStatuses(1)
+		},
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(branchRun, secret.DeepCopy(), project.DeepCopy()).Build(),
+		wantDone:  true,
+	}, {
+		name:      "multi-branch pipeline before checking out the commit",
+		request:   defaultReq,
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(uncheckedBranchRun, secret.DeepCopy(), project.DeepCopy()).Build(),
+		wantDone:  true,
+	}, {
+		name:    "webhook triggered pipeline without GitRepository",
+		request: defaultReq,
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).
+			WithRuntimeObjects(webhookRun.DeepCopy(), pipeline.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		wantDone: true,
+	}, {
+		name:    "webhook triggered pipeline with stage statuses",
+		request: defaultReq,
+		prepare: func(t *testing.T) {
+			// the overall status, and the statuses of the stages build and test
+			mockStatuses(3)
+		},
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).
+			WithRuntimeObjects(webhookRun.DeepCopy(), pipeline.DeepCopy(), gitRepo.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		stageStatus: true,
+		wantDone:    true,
+	}, {
+		name:    "webhook triggered pipeline with a check run",
+		request: defaultReq,
+		prepare: func(t *testing.T) {
+			mockStatuses(1)
+			gock.New("https://api.github.com").
+				Get("/repos/octocat/hello-world/commits/6dcb09b5b57875f334f61aebed695e2e4193db5e/check-runs").
+				Reply(200).
+				Type("application/json").
+				SetHeaders(mockHeaders).
+				BodyString(`{"total_count":0,"check_runs":[]}`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/check-runs").
+				BodyString(`"external_id":"ns/fake"`).
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+		k8sClient: fake.NewClientBuilder().WithScheme(schema).
+			WithRuntimeObjects(webhookRun.DeepCopy(), pipeline.DeepCopy(), gitRepo.DeepCopy(), secret.DeepCopy(), project.DeepCopy()).Build(),
+		checkRun: true,
+		wantDone: true,
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -292,8 +392,10 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 				tt.prepare(t)
 			}
 			recon := &PullRequestStatusReconciler{
-				log:    logr.New(log.NullLogSink{}),
-				Client: tt.k8sClient,
+				log:         logr.New(log.NullLogSink{}),
+				Client:      tt.k8sClient,
+				StageStatus: tt.stageStatus,
+				CheckRun:    tt.checkRun,
 			}
 
 			result, err := recon.Reconcile(context.Background(), ctrl.Request{
@@ -309,10 +411,47 @@ func TestPullRequestStatusReconciler(t *testing.T) {
 			} else {
 				assert.Nil(t, err, "should not have error in case [%s]-[%d]", tt.name, i)
 			}
+			if tt.wantDone {
+				assert.True(t, gock.IsDone(), "all the mocked requests should be sent in case [%s]-[%d]", tt.name, i)
+			}
 		})
 	}
 }
 
+func Test_convertStageToSCMStatus(t *testing.T) {
+	tests := []struct {
+		state, result string
+		wantStatus    scm.State
+	}{
+		{state: "QUEUED", wantStatus: scm.StatePending},
+		{state: "RUNNING", wantStatus: scm.StateRunning},
+		{state: "PAUSED", wantStatus: scm.StateRunning},
+		{state: "FINISHED", result: "SUCCESS", wantStatus: scm.StateSuccess},
+		{state: "FINISHED", result: "UNSTABLE", wantStatus: scm.StateFailure},
+		{state: "FINISHED", result: "ABORTED", wantStatus: scm.StateCanceled},
+		{state: "SKIPPED", result: "NOT_BUILT", wantStatus: scm.StateUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state+"-"+tt.result, func(t *testing.T) {
+			status, _ := convertStageToSCMStatus(tt.state, tt.result)
+			assert.Equal(t, tt.wantStatus, status)
+		})
+	}
+}
+
+func Test_isSameGitURL(t *testing.T) {
+	assert.True(t, isSameGitURL("https://github.com/octocat/hello-world.git", "https://github.com/Octocat/hello-world"))
+	assert.True(t, isSameGitURL("https://github.com/octocat/hello-world/", "https://github.com/octocat/hello-world"))
+	assert.False(t, isSameGitURL("https://github.com/octocat/hello", "https://github.com/octocat/hello-world"))
+	assert.False(t, isSameGitURL("", ""))
+}
+
+func TestStatusMaker_WithRef(t *testing.T) {
+	maker := NewStatusMaker("octocat/hello-world", "").WithRef(v1alpha3.Branch, "feature%2Fa")
+	assert.Equal(t, "feature/a", maker.ref)
+	assert.Equal(t, v1alpha3.Branch, maker.refType)
+}
+
 func Test(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -436,6 +575,18 @@ func TestGetRepoInfo(t *testing.T) {
 			},
 		},
 		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "bitbucketcloud"},
+	}, {
+		name: "gitea",
+		repo: &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGitea,
+			GiteaSource: &v1alpha3.GiteaSource{
+				ServerUrl:    "https://gitea.com",
+				Owner:        "owner",
+				Repo:         "repo",
+				CredentialId: "token",
+			},
+		},
+		wantInfo: repoInformation{owner: "owner", repo: "repo", tokenId: "token", provider: "gitea", server: "https://gitea.com"},
 	}}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -534,3 +685,42 @@ func TestCreateExpirationCheckFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestPullRequestStatusReconciler_createCheckRun(t *testing.T) {
+	defer gock.Off()
+	// only the first reconciling tries to create the Check Run with a personal access token
+	gock.New("https://api.github.com").
+		Get("/repos/octocat/hello-world/commits/" + checkRunSHA + "/check-runs").
+		Reply(200).
+		Type("application/json").
+		BodyString(`{"total_count":0,"check_runs":[]}`)
+	gock.New("https://api.github.com").
+		Post("/repos/octocat/hello-world/check-runs").
+		Reply(403).
+		Type("application/json").
+		BodyString(`{"message":"You must authenticate via a GitHub App."}`)
+
+	recorder := record.NewFakeRecorder(10)
+	recon := &PullRequestStatusReconciler{log: logr.New(log.NullLogSink{}), recorder: recorder}
+	maker := NewStatusMaker("octocat/hello-world", "token")
+	maker.WithProvider("github").WithSHA(checkRunSHA)
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, recon.createCheckRun(context.Background(), maker, newCheckRunPipelineRun()))
+	}
+	assert.True(t, gock.IsDone())
+	if assert.Equal(t, 1, len(recorder.Events)) {
+		assert.Contains(t, <-recorder.Events, checkRunForbiddenReason)
+	}
+
+	// other errors are not ignored
+	gock.New("https://api.github.com").
+		Get("/repos/octocat/hello-world/commits/" + checkRunSHA + "/check-runs").
+		Reply(404).
+		Type("application/json").
+		BodyString(`{"message":"Not Found"}`)
+	maker = NewStatusMaker("octocat/hello-world", "another-token")
+	maker.WithProvider("github").WithSHA(checkRunSHA)
+	assert.NotNil(t, recon.createCheckRun(context.Background(), maker, newCheckRunPipelineRun()))
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, 0, len(recorder.Events))
+}
@@ -0,0 +1,541 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/git"
+	"github.com/kubesphere/ks-devops/pkg/utils/net"
+)
+
+const (
+	// FailedRegisterWebhook indicates the controller fails to register the repository webhook of a Pipeline
+	FailedRegisterWebhook = "FailedRegisterWebhook"
+	// FailedRemoveWebhook indicates the controller fails to remove the repository webhook of a Pipeline
+	FailedRemoveWebhook = "FailedRemoveWebhook"
+)
+
+// scmWebhookPath is the path of the SCM webhook API
+const scmWebhookPath = "/kapis/devops.kubesphere.io/v1alpha3/webhooks/scm"
+
+// WebhookReconciler registers the repository webhook of the multi-branch Pipelines which point to the SCM webhook API.
+// The webhook is removed once the last Pipeline of the repository is deleted.
+type WebhookReconciler struct {
+	client.Client
+	// WebhookAddress is the external address of the API server which receives the SCM webhooks
+	WebhookAddress string
+
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=gitrepositories;webhooks,verbs=get;list
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// webhookRegistration is the webhook registered for a Pipeline. It's kept in an annotation of the Pipeline,
+// so the webhook can be removed even if the source of the Pipeline is changed.
+type webhookRegistration struct {
+	Provider   string `json:"provider"`
+	Server     string `json:"server,omitempty"`
+	Repo       string `json:"repo"`
+	Credential string `json:"credential"`
+	// HookID is the ID of the webhook created by this controller, it's empty if the webhook belongs to a GitRepository
+	HookID string `json:"hookID,omitempty"`
+}
+
+// webhookSource is the repository of a multi-branch Pipeline whose webhook could be registered
+type webhookSource struct {
+	webhookRegistration
+	gitURL string
+}
+
+// Reconcile makes sure the webhook of the repository exists, or removes it if the Pipeline is deleted
+func (r *WebhookReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	pipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	registered := pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey]
+	registration := getWebhookRegistration(pipeline)
+	var source *webhookSource
+	var gitURL string
+	if pipeline.DeletionTimestamp.IsZero() {
+		if source = getWebhookSource(pipeline); source != nil {
+			gitURL = source.gitURL
+		}
+	}
+
+	if registered != "" && registered != gitURL {
+		// a stale webhook is harmless, it should not block the deletion of the Pipeline
+		if removeErr := r.removeWebhook(ctx, pipeline, registered, registration); removeErr != nil {
+			r.log.Error(removeErr, "failed to remove the webhook", "Pipeline", req.NamespacedName, "repository", registered)
+			r.recorder.Eventf(pipeline, v1.EventTypeWarning, FailedRemoveWebhook,
+				"Failed to remove the webhook of repository %s, err = %v", registered, removeErr)
+		}
+		registration = nil
+	}
+	// the webhooks registered before the registration is recorded are signed by the credential, they are taken over
+	if gitURL != "" && (gitURL != registered || registration == nil || registration.Credential != source.Credential) {
+		if registration, err = r.registerWebhook(ctx, pipeline, source); err != nil {
+			r.recorder.Eventf(pipeline, v1.EventTypeWarning, FailedRegisterWebhook,
+				"Failed to register the webhook of repository %s, err = %v", gitURL, err)
+			return
+		}
+	}
+	err = r.updateRegistration(ctx, req.NamespacedName, gitURL, registration)
+	return
+}
+
+// registerWebhook creates the webhook of a repository, or updates it if it exists. The webhook is signed by
+// a random secret of the repository, the credential of the Pipeline is never sent as the secret.
+// A webhook which belongs to a GitRepository is left as it is, it's signed by the secret of the GitRepository.
+func (r *WebhookReconciler) registerWebhook(ctx context.Context, pipeline *v1alpha3.Pipeline, source *webhookSource) (
+	registration *webhookRegistration, err error) {
+	var (
+		gitClient *scm.Client
+		hooks     []*scm.Hook
+	)
+	if gitClient, err = r.getGitClient(pipeline.Namespace, &source.webhookRegistration); err != nil {
+		return
+	}
+	if hooks, _, err = gitClient.Repositories.ListHooks(ctx, source.Repo, &scm.ListOptions{
+		Page: 1,
+		Size: 100, // assume this list has not too many items
+	}); err != nil {
+		err = fmt.Errorf("failed to list the existing webhooks, error: %v", err)
+		return
+	}
+
+	target := r.getWebhookTarget()
+	hook := findHook(hooks, target)
+	if hook != nil {
+		var belongsToGitRepo bool
+		if belongsToGitRepo, err = r.isGitRepositoryWebhook(ctx, source.gitURL, target); err != nil || belongsToGitRepo {
+			registration = &source.webhookRegistration
+			return
+		}
+	}
+
+	var secret string
+	if secret, err = r.ensureWebhookSecret(ctx, pipeline, source.gitURL); err != nil {
+		return
+	}
+	hookInput := &scm.HookInput{
+		Name:   "ks-devops",
+		Target: target,
+		Secret: secret,
+		Events: scm.HookEvents{
+			Branch:             true,
+			IssueComment:       true,
+			PullRequest:        true,
+			PullRequestComment: true,
+			Push:               true,
+			Tag:                true,
+		},
+	}
+	if hook != nil {
+		// go-scm takes the name as the ID of the webhook to update
+		hookInput.Name = hook.ID
+		_, _, err = gitClient.Repositories.UpdateHook(ctx, source.Repo, hookInput)
+	} else {
+		hook, _, err = gitClient.Repositories.CreateHook(ctx, source.Repo, hookInput)
+	}
+	if err == nil {
+		registration = &source.webhookRegistration
+		registration.HookID = hook.ID
+	}
+	return
+}
+
+// removeWebhook removes the webhook of a repository if no other Pipelines use it, otherwise the webhook is
+// registered again by another Pipeline. Only the webhook created by this controller is removed,
+// and it's kept if a GitRepository refers to it.
+func (r *WebhookReconciler) removeWebhook(ctx context.Context, pipeline *v1alpha3.Pipeline, gitURL string,
+	registration *webhookRegistration) (err error) {
+	var others []v1alpha3.Pipeline
+	if others, err = r.getPipelinesOfRepository(ctx, pipeline, gitURL); err != nil {
+		return
+	}
+	for i := range others {
+		other := &others[i]
+		if source := getWebhookSource(other); source != nil && source.gitURL == gitURL {
+			var otherRegistration *webhookRegistration
+			if otherRegistration, err = r.registerWebhook(ctx, other, source); err == nil {
+				err = r.updateRegistration(ctx, client.ObjectKeyFromObject(other), gitURL, otherRegistration)
+			}
+			if err == nil {
+				err = r.releaseWebhookSecret(ctx, pipeline, gitURL)
+			}
+			return
+		}
+	}
+
+	if registration != nil && registration.HookID != "" {
+		var (
+			gitClient        *scm.Client
+			belongsToGitRepo bool
+			response         *scm.Response
+		)
+		if belongsToGitRepo, err = r.isGitRepositoryWebhook(ctx, gitURL, r.getWebhookTarget()); err != nil || belongsToGitRepo {
+			return
+		}
+		if gitClient, err = r.getGitClient(pipeline.Namespace, registration); err != nil {
+			return
+		}
+		if response, err = gitClient.Repositories.DeleteHook(ctx, registration.Repo, registration.HookID); err != nil &&
+			response != nil && response.Status == http.StatusNotFound {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = r.releaseWebhookSecret(ctx, pipeline, gitURL)
+	}
+	return
+}
+
+// isGitRepositoryWebhook checks if the webhook of a repository is registered by a GitRepository through its Webhooks
+func (r *WebhookReconciler) isGitRepositoryWebhook(ctx context.Context, gitURL, target string) (found bool, err error) {
+	gitRepoList := &v1alpha3.GitRepositoryList{}
+	if err = r.List(ctx, gitRepoList); err != nil {
+		return
+	}
+	for i := range gitRepoList.Items {
+		gitRepo := &gitRepoList.Items[i]
+		if !isSameGitURL(gitRepo.Spec.URL, gitURL) {
+			continue
+		}
+		for _, webhookRef := range gitRepo.Spec.Webhooks {
+			webhook := &v1alpha3.Webhook{}
+			if err = r.Get(ctx, types.NamespacedName{Namespace: gitRepo.Namespace, Name: webhookRef.Name}, webhook); err != nil {
+				if err = client.IgnoreNotFound(err); err != nil {
+					return
+				}
+				continue
+			}
+			if strings.SplitN(webhook.Spec.Server, "?", 2)[0] == target {
+				found = true
+				return
+			}
+		}
+	}
+	return
+}
+
+// ensureWebhookSecret returns the random secret of a repository, it's shared by the Pipelines in the same namespace.
+// The Pipeline becomes one of the owners of the secret.
+func (r *WebhookReconciler) ensureWebhookSecret(ctx context.Context, pipeline *v1alpha3.Pipeline, gitURL string) (
+	token string, err error) {
+	secret := &v1.Secret{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: pipeline.Namespace, Name: getWebhookSecretName(gitURL)}, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		random := make([]byte, 32)
+		if _, err = rand.Read(random); err != nil {
+			return
+		}
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: getWebhookSecretName(gitURL), Namespace: pipeline.Namespace},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{v1.ServiceAccountTokenKey: []byte(hex.EncodeToString(random))},
+		}
+		if err = controllerutil.SetOwnerReference(pipeline, secret, r.Scheme()); err == nil {
+			err = r.Create(ctx, secret)
+		}
+	} else {
+		owners := len(secret.OwnerReferences)
+		if err = controllerutil.SetOwnerReference(pipeline, secret, r.Scheme()); err == nil && owners != len(secret.OwnerReferences) {
+			err = r.Update(ctx, secret)
+		}
+	}
+	token = string(secret.Data[v1.ServiceAccountTokenKey])
+	return
+}
+
+// releaseWebhookSecret removes the Pipeline from the owners of the secret, the secret is deleted along with the last owner
+func (r *WebhookReconciler) releaseWebhookSecret(ctx context.Context, pipeline *v1alpha3.Pipeline, gitURL string) (err error) {
+	secret := &v1.Secret{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: pipeline.Namespace, Name: getWebhookSecretName(gitURL)}, secret); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if controllerutil.RemoveOwnerReference(pipeline, secret, r.Scheme()) != nil {
+		// not an owner
+		return
+	}
+	if len(secret.OwnerReferences) == 0 {
+		err = client.IgnoreNotFound(r.Delete(ctx, secret))
+	} else {
+		err = r.Update(ctx, secret)
+	}
+	return
+}
+
+// getPipelinesOfRepository returns the other Pipelines whose webhook of the repository is registered
+func (r *WebhookReconciler) getPipelinesOfRepository(ctx context.Context, pipeline *v1alpha3.Pipeline, gitURL string) (
+	pipelines []v1alpha3.Pipeline, err error) {
+	pipelineList := &v1alpha3.PipelineList{}
+	if err = r.List(ctx, pipelineList); err != nil {
+		return
+	}
+	for i := range pipelineList.Items {
+		item := pipelineList.Items[i]
+		if (item.Namespace == pipeline.Namespace && item.Name == pipeline.Name) || !item.DeletionTimestamp.IsZero() ||
+			item.Annotations[v1alpha3.PipelineWebhookAnnoKey] != gitURL {
+			continue
+		}
+		pipelines = append(pipelines, item)
+	}
+	return
+}
+
+// updateRegistration records the repository whose webhook is registered, the finalizer exists along with it.
+// The secret is recorded only if the webhook is created by this controller.
+func (r *WebhookReconciler) updateRegistration(ctx context.Context, key client.ObjectKey, gitURL string,
+	registration *webhookRegistration) error {
+	annotations := map[string]string{}
+	if gitURL != "" && registration != nil {
+		data, err := json.Marshal(registration)
+		if err != nil {
+			return err
+		}
+		annotations[v1alpha3.PipelineWebhookAnnoKey] = gitURL
+		annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey] = string(data)
+		if registration.HookID != "" {
+			annotations[v1alpha3.PipelineWebhookSecretAnnoKey] = getWebhookSecretName(gitURL)
+		}
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipeline := &v1alpha3.Pipeline{}
+		if err := r.Get(ctx, key, pipeline); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		changed := controllerutil.ContainsFinalizer(pipeline, v1alpha3.PipelineWebhookFinalizerName) != (len(annotations) > 0)
+		for _, annotationKey := range []string{v1alpha3.PipelineWebhookAnnoKey, v1alpha3.PipelineWebhookRegistrationAnnoKey,
+			v1alpha3.PipelineWebhookSecretAnnoKey} {
+			if value, ok := annotations[annotationKey]; ok && pipeline.Annotations[annotationKey] != value {
+				if pipeline.Annotations == nil {
+					pipeline.Annotations = map[string]string{}
+				}
+				pipeline.Annotations[annotationKey] = value
+				changed = true
+			} else if _, exist := pipeline.Annotations[annotationKey]; !ok && exist {
+				delete(pipeline.Annotations, annotationKey)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		if len(annotations) > 0 {
+			controllerutil.AddFinalizer(pipeline, v1alpha3.PipelineWebhookFinalizerName)
+		} else {
+			controllerutil.RemoveFinalizer(pipeline, v1alpha3.PipelineWebhookFinalizerName)
+		}
+		return r.Update(ctx, pipeline)
+	})
+}
+
+// getGitClient returns the git client with a credential in the namespace of the Pipeline
+func (r *WebhookReconciler) getGitClient(namespace string, registration *webhookRegistration) (gitClient *scm.Client, err error) {
+	factory := git.NewClientFactory(registration.Provider, &v1.SecretReference{
+		Namespace: namespace,
+		Name:      registration.Credential,
+	}, r.Client)
+	factory.Server = registration.Server
+	return factory.GetClient()
+}
+
+func (r *WebhookReconciler) getWebhookTarget() string {
+	return net.ParseURL(r.WebhookAddress) + scmWebhookPath
+}
+
+// findHook finds the webhook by the target, the query is ignored because Bitbucket puts the secret in it
+func findHook(hooks []*scm.Hook, target string) *scm.Hook {
+	for _, hook := range hooks {
+		if strings.SplitN(hook.Target, "?", 2)[0] == target {
+			return hook
+		}
+	}
+	return nil
+}
+
+// getWebhookRegistration returns the registration recorded in the annotation, it's nil if there is none
+func getWebhookRegistration(pipeline *v1alpha3.Pipeline) (registration *webhookRegistration) {
+	if data := pipeline.Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey]; data != "" {
+		registration = &webhookRegistration{}
+		if json.Unmarshal([]byte(data), registration) != nil {
+			registration = nil
+		}
+	}
+	return
+}
+
+// getWebhookSecretName returns the name of the secret which signs the webhook of a repository
+func getWebhookSecretName(gitURL string) string {
+	hash := sha256.Sum256([]byte(gitURL))
+	return "pipeline-webhook-" + hex.EncodeToString(hash[:])[:10]
+}
+
+// getWebhookGitURL returns the Git URL of a multi-branch Pipeline whose webhook could be registered,
+// it returns empty if the provider is not supported or there is no credential
+func getWebhookGitURL(pipeline *v1alpha3.Pipeline) string {
+	if source := getWebhookSource(pipeline); source != nil {
+		return source.gitURL
+	}
+	return ""
+}
+
+// getWebhookSource returns the provider, server, repository and URL of a multi-branch Pipeline from its source,
+// it returns nil if the provider is not supported or there is no credential.
+// The URL is the same as the link of the repository in the webhook payload.
+func getWebhookSource(pipeline *v1alpha3.Pipeline) (source *webhookSource) {
+	if !pipeline.IsMultiBranch() {
+		return
+	}
+	mbp := pipeline.Spec.MultiBranchPipeline
+	credentialID := getCredentialID(mbp)
+	provider, server := mbp.GetSCMProvider()
+	if credentialID == "" || provider == "" {
+		return
+	}
+
+	source = &webhookSource{webhookRegistration: webhookRegistration{
+		Provider:   provider,
+		Server:     server,
+		Credential: credentialID,
+	}}
+	switch mbp.SourceType {
+	case v1alpha3.SourceTypeGithub:
+		source.Repo = mbp.GitHubSource.Owner + "/" + mbp.GitHubSource.Repo
+		host := "https://github.com"
+		if server != "" {
+			host = strings.TrimSuffix(server, "/api/v3")
+		}
+		source.gitURL = host + "/" + source.Repo
+	case v1alpha3.SourceTypeGitlab:
+		// the repository of GitLab might contain the owner
+		source.Repo = mbp.GitlabSource.Owner + "/" + strings.TrimPrefix(mbp.GitlabSource.Repo, mbp.GitlabSource.Owner+"/")
+		host := "https://gitlab.com"
+		if server != "" {
+			host = server
+		}
+		source.gitURL = host + "/" + source.Repo
+	case v1alpha3.SourceTypeBitbucket:
+		owner, repo := mbp.BitbucketServerSource.Owner, mbp.BitbucketServerSource.Repo
+		source.Repo = owner + "/" + repo
+		if server != "" {
+			source.gitURL = fmt.Sprintf("%s/projects/%s/repos/%s/browse", server, owner, repo)
+		} else {
+			source.gitURL = "https://bitbucket.org/" + source.Repo
+		}
+	case v1alpha3.SourceTypeGitea:
+		source.Repo = mbp.GiteaSource.Owner + "/" + mbp.GiteaSource.Repo
+		source.gitURL = server + "/" + source.Repo
+	}
+	return
+}
+
+func getCredentialID(mbp *v1alpha3.MultiBranchPipeline) string {
+	switch {
+	case mbp == nil:
+	case mbp.SourceType == v1alpha3.SourceTypeGithub && mbp.GitHubSource != nil:
+		return mbp.GitHubSource.CredentialId
+	case mbp.SourceType == v1alpha3.SourceTypeGitlab && mbp.GitlabSource != nil:
+		return mbp.GitlabSource.CredentialId
+	case mbp.SourceType == v1alpha3.SourceTypeBitbucket && mbp.BitbucketServerSource != nil:
+		return mbp.BitbucketServerSource.CredentialId
+	case mbp.SourceType == v1alpha3.SourceTypeGitea && mbp.GiteaSource != nil:
+		return mbp.GiteaSource.CredentialId
+	}
+	return ""
+}
+
+// isSameGitURL compares two Git URLs, the suffix ".git" and the case are ignored
+func isSameGitURL(a, b string) bool {
+	normalize := func(gitURL string) string {
+		return strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(gitURL, "/"), ".git"))
+	}
+	return a != "" && normalize(a) == normalize(b)
+}
+
+// GetName returns the name of this controller
+func (r *WebhookReconciler) GetName() string {
+	return "PipelineWebhookController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *WebhookReconciler) GetGroupName() string {
+	return ControllerGroupName
+}
+
+// webhookPredicate only cares about the changes of the repository, the deletion and the registration
+var webhookPredicate = predicate.Funcs{
+	UpdateFunc: func(ue event.UpdateEvent) bool {
+		oldPipeline, okOld := ue.ObjectOld.(*v1alpha3.Pipeline)
+		newPipeline, okNew := ue.ObjectNew.(*v1alpha3.Pipeline)
+		if okOld && okNew {
+			return !reflect.DeepEqual(oldPipeline.Spec.MultiBranchPipeline, newPipeline.Spec.MultiBranchPipeline) ||
+				!oldPipeline.DeletionTimestamp.Equal(newPipeline.DeletionTimestamp) ||
+				oldPipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey] != newPipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey] ||
+				oldPipeline.Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey] != newPipeline.Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey]
+		}
+		return false
+	},
+}
+
+// SetupWithManager setups the reconciler with a manager
+func (r *WebhookReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipeline_webhook_controller").
+		WithEventFilter(webhookPredicate).
+		For(&v1alpha3.Pipeline{}).
+		Complete(r)
+}
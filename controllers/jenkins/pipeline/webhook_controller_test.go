@@ -0,0 +1,448 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/h2non/gock"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+const (
+	webhookGitURL = "https://github.com/octocat/hello-world"
+	webhookTarget = "https://ks.example.com/kapis/devops.kubesphere.io/v1alpha3/webhooks/scm"
+)
+
+const webhookRegistrationJSON = `{"provider":"github","repo":"octocat/hello-world","credential":"github","hookID":"1"}`
+
+func newWebhookPipeline(name string, registered, deleting bool) *v1alpha3.Pipeline {
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1alpha3.PipelineSpec{
+			Type: v1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{
+				SourceType: v1alpha3.SourceTypeGithub,
+				GitHubSource: &v1alpha3.GithubSource{
+					Owner:        "octocat",
+					Repo:         "hello-world",
+					CredentialId: "github",
+				},
+			},
+		},
+	}
+	if registered {
+		pipeline.Annotations = map[string]string{
+			v1alpha3.PipelineWebhookAnnoKey:             webhookGitURL,
+			v1alpha3.PipelineWebhookRegistrationAnnoKey: webhookRegistrationJSON,
+			v1alpha3.PipelineWebhookSecretAnnoKey:       getWebhookSecretName(webhookGitURL),
+		}
+		pipeline.Finalizers = []string{v1alpha3.PipelineWebhookFinalizerName}
+	}
+	if deleting {
+		now := metav1.Now()
+		pipeline.DeletionTimestamp = &now
+	}
+	return pipeline
+}
+
+func TestWebhookReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	credential := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github", Namespace: "default"},
+		Type:       v1.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			v1.BasicAuthUsernameKey: []byte("bot"),
+			v1.BasicAuthPasswordKey: []byte("token"),
+		},
+	}
+	webhookSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            getWebhookSecretName(webhookGitURL),
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: v1alpha3.GroupVersion.String(), Kind: "Pipeline", Name: "fake"}},
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{v1.ServiceAccountTokenKey: []byte("random")},
+	}
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-world", Namespace: "other"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:      webhookGitURL + ".git",
+			Webhooks: []v1.LocalObjectReference{{Name: "ks"}},
+		},
+	}
+	gitRepoWebhook := &v1alpha3.Webhook{
+		ObjectMeta: metav1.ObjectMeta{Name: "ks", Namespace: "other"},
+		Spec:       v1alpha3.WebhookSpec{Server: webhookTarget},
+	}
+	mockListHooks := func(body string) {
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/hooks").
+			Reply(200).
+			Type("application/json").
+			BodyString(body)
+	}
+	// the secret of the webhook is captured to compare with the generated one
+	var hookSecret string
+	captureSecret := func(req *http.Request, _ *gock.Request) (bool, error) {
+		hook := struct {
+			Config struct {
+				Secret string `json:"secret"`
+			} `json:"config"`
+		}{}
+		if req.Body != nil {
+			if data, err := io.ReadAll(req.Body); err == nil {
+				_ = json.Unmarshal(data, &hook)
+			}
+		}
+		hookSecret = hook.Config.Secret
+		return true, nil
+	}
+	getPipeline := func(t *testing.T, c client.Client, name string) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pipeline); err != nil {
+			assert.True(t, client.IgnoreNotFound(err) == nil, err)
+			return nil
+		}
+		return pipeline
+	}
+	getWebhookSecret := func(t *testing.T, c client.Client) *v1.Secret {
+		secret := &v1.Secret{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: getWebhookSecretName(webhookGitURL)}, secret); err != nil {
+			assert.True(t, client.IgnoreNotFound(err) == nil, err)
+			return nil
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		prepare   func()
+		wantErr   bool
+		assertion func(t *testing.T, c client.Client)
+	}{{
+		name:    "not a multi-branch Pipeline",
+		objects: []runtime.Object{&v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Name: "fake", Namespace: "default"}}},
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Empty(t, getPipeline(t, c, "fake").Annotations)
+		},
+	}, {
+		name:    "register a new webhook",
+		objects: []runtime.Object{newWebhookPipeline("fake", false, false), credential.DeepCopy()},
+		prepare: func() {
+			mockListHooks(`[{"id":1,"config":{"url":"https://other.example.com/hook"}}]`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/hooks").
+				AddMatcher(captureSecret).
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":2}`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			pipeline := getPipeline(t, c, "fake")
+			assert.Equal(t, webhookGitURL, pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey])
+			assert.True(t, controllerutil.ContainsFinalizer(pipeline, v1alpha3.PipelineWebhookFinalizerName))
+			assert.Equal(t, "2", getWebhookRegistration(pipeline).HookID)
+
+			// the webhook is signed by a random secret rather than the credential
+			secret := getWebhookSecret(t, c)
+			if assert.NotNil(t, secret) {
+				assert.Equal(t, secret.Name, pipeline.Annotations[v1alpha3.PipelineWebhookSecretAnnoKey])
+				assert.Equal(t, string(secret.Data[v1.ServiceAccountTokenKey]), hookSecret)
+				assert.Equal(t, 64, len(hookSecret))
+				assert.Equal(t, "fake", secret.OwnerReferences[0].Name)
+			}
+		},
+	}, {
+		name:    "update the existing webhook",
+		objects: []runtime.Object{newWebhookPipeline("fake", false, false), credential.DeepCopy(), webhookSecret.DeepCopy()},
+		prepare: func() {
+			mockListHooks(`[{"id":1,"config":{"url":"` + webhookTarget + `"}}]`)
+			gock.New("https://api.github.com").
+				Patch("/repos/octocat/hello-world/hooks/1").
+				BodyString(`"secret":"random"`).
+				Reply(200).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			pipeline := getPipeline(t, c, "fake")
+			assert.Equal(t, webhookGitURL, pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey])
+			assert.Equal(t, webhookRegistrationJSON, pipeline.Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey])
+		},
+	}, {
+		name:    "the webhook is registered already",
+		objects: []runtime.Object{newWebhookPipeline("fake", true, false), credential.DeepCopy()},
+	}, {
+		name: "take over the webhook which was signed by the credential",
+		objects: []runtime.Object{func() *v1alpha3.Pipeline {
+			pipeline := newWebhookPipeline("fake", true, false)
+			delete(pipeline.Annotations, v1alpha3.PipelineWebhookRegistrationAnnoKey)
+			delete(pipeline.Annotations, v1alpha3.PipelineWebhookSecretAnnoKey)
+			return pipeline
+		}(), credential.DeepCopy()},
+		prepare: func() {
+			mockListHooks(`[{"id":1,"config":{"url":"` + webhookTarget + `"}}]`)
+			gock.New("https://api.github.com").
+				Patch("/repos/octocat/hello-world/hooks/1").
+				AddMatcher(captureSecret).
+				Reply(200).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Equal(t, webhookRegistrationJSON, getPipeline(t, c, "fake").Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey])
+			assert.NotEqual(t, "token", hookSecret)
+		},
+	}, {
+		name: "leave the webhook of a GitRepository as it is",
+		objects: []runtime.Object{newWebhookPipeline("fake", false, false), credential.DeepCopy(),
+			gitRepo.DeepCopy(), gitRepoWebhook.DeepCopy()},
+		prepare: func() {
+			mockListHooks(`[{"id":1,"config":{"url":"` + webhookTarget + `"}}]`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			pipeline := getPipeline(t, c, "fake")
+			assert.Equal(t, webhookGitURL, pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey])
+			assert.Empty(t, getWebhookRegistration(pipeline).HookID)
+			assert.Empty(t, pipeline.Annotations[v1alpha3.PipelineWebhookSecretAnnoKey])
+			assert.Nil(t, getWebhookSecret(t, c))
+		},
+	}, {
+		name:    "failed to register the webhook without the credential",
+		objects: []runtime.Object{newWebhookPipeline("fake", false, false)},
+		wantErr: true,
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Empty(t, getPipeline(t, c, "fake").Annotations)
+		},
+	}, {
+		name:    "remove the webhook of the last Pipeline",
+		objects: []runtime.Object{newWebhookPipeline("fake", true, true), credential.DeepCopy(), webhookSecret.DeepCopy()},
+		prepare: func() {
+			gock.New("https://api.github.com").
+				Delete("/repos/octocat/hello-world/hooks/1").
+				Reply(204)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			// the Pipeline is gone once the last finalizer is removed
+			assert.Nil(t, getPipeline(t, c, "fake"))
+			assert.Nil(t, getWebhookSecret(t, c))
+		},
+	}, {
+		name: "keep the webhook which a GitRepository refers to",
+		objects: []runtime.Object{newWebhookPipeline("fake", true, true), credential.DeepCopy(),
+			gitRepo.DeepCopy(), gitRepoWebhook.DeepCopy()},
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Nil(t, getPipeline(t, c, "fake"))
+		},
+	}, {
+		name: "keep the webhook for other Pipelines",
+		objects: []runtime.Object{newWebhookPipeline("fake", true, true), newWebhookPipeline("other", true, false),
+			credential.DeepCopy(), webhookSecret.DeepCopy()},
+		prepare: func() {
+			mockListHooks(`[{"id":1,"config":{"url":"` + webhookTarget + `"}}]`)
+			gock.New("https://api.github.com").
+				Patch("/repos/octocat/hello-world/hooks/1").
+				Reply(200).
+				Type("application/json").
+				BodyString(`{"id":1}`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Nil(t, getPipeline(t, c, "fake"))
+			assert.NotNil(t, getPipeline(t, c, "other"))
+			// the secret is kept for the other Pipeline
+			if secret := getWebhookSecret(t, c); assert.NotNil(t, secret) && assert.Equal(t, 1, len(secret.OwnerReferences)) {
+				assert.Equal(t, "other", secret.OwnerReferences[0].Name)
+			}
+		},
+	}, {
+		name: "remove the stale webhook once the repository is changed",
+		objects: []runtime.Object{func() *v1alpha3.Pipeline {
+			pipeline := newWebhookPipeline("fake", true, false)
+			pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey] = "https://github.com/octocat/old"
+			pipeline.Annotations[v1alpha3.PipelineWebhookRegistrationAnnoKey] =
+				`{"provider":"github","repo":"octocat/old","credential":"github","hookID":"3"}`
+			return pipeline
+		}(), credential.DeepCopy()},
+		prepare: func() {
+			gock.New("https://api.github.com").
+				Delete("/repos/octocat/old/hooks/3").
+				Reply(204)
+			mockListHooks(`[]`)
+			gock.New("https://api.github.com").
+				Post("/repos/octocat/hello-world/hooks").
+				Reply(201).
+				Type("application/json").
+				BodyString(`{"id":4}`)
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			pipeline := getPipeline(t, c, "fake")
+			assert.Equal(t, webhookGitURL, pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey])
+			assert.Equal(t, "4", getWebhookRegistration(pipeline).HookID)
+		},
+	}, {
+		name:    "the deletion is not blocked by the failure of removing the webhook",
+		objects: []runtime.Object{newWebhookPipeline("fake", true, true)},
+		assertion: func(t *testing.T, c client.Client) {
+			assert.Nil(t, getPipeline(t, c, "fake"))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			hookSecret = ""
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			fakeClient := fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(tt.objects...).Build()
+			r := &WebhookReconciler{
+				Client:         fakeClient,
+				WebhookAddress: "ks.example.com",
+				log:            logr.Discard(),
+				recorder:       record.NewFakeRecorder(10),
+			}
+			_, err := r.Reconcile(context.Background(), ctrl.Request{
+				NamespacedName: types.NamespacedName{Namespace: "default", Name: "fake"},
+			})
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient)
+			}
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
+
+func Test_getWebhookSource(t *testing.T) {
+	newPipeline := func(mbp *v1alpha3.MultiBranchPipeline) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType, MultiBranchPipeline: mbp}}
+	}
+	tests := []struct {
+		name                     string
+		mbp                      *v1alpha3.MultiBranchPipeline
+		wantProvider, wantServer string
+		wantRepo, wantGitURL     string
+	}{{
+		name: "github.com",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "octocat", Repo: "hello-world", CredentialId: "token"}},
+		wantProvider: "github", wantRepo: "octocat/hello-world", wantGitURL: "https://github.com/octocat/hello-world",
+	}, {
+		name: "GitHub Enterprise",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "octocat", Repo: "hello-world", CredentialId: "token",
+				ApiUri: "https://ghe.example.com/api/v3"}},
+		wantProvider: "github", wantServer: "https://ghe.example.com/api/v3",
+		wantRepo: "octocat/hello-world", wantGitURL: "https://ghe.example.com/octocat/hello-world",
+	}, {
+		name: "self-managed GitLab",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeGitlab,
+			GitlabSource: &v1alpha3.GitlabSource{Owner: "group", Repo: "group/repo", CredentialId: "token",
+				ApiUri: "https://gitlab.example.com"}},
+		wantProvider: "gitlab", wantServer: "https://gitlab.example.com",
+		wantRepo: "group/repo", wantGitURL: "https://gitlab.example.com/group/repo",
+	}, {
+		name: "bitbucket.org",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeBitbucket,
+			BitbucketServerSource: &v1alpha3.BitbucketServerSource{Owner: "owner", Repo: "repo", CredentialId: "token",
+				ApiUri: "https://bitbucket.org"}},
+		wantProvider: "bitbucketcloud", wantRepo: "owner/repo", wantGitURL: "https://bitbucket.org/owner/repo",
+	}, {
+		name: "Bitbucket Server",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeBitbucket,
+			BitbucketServerSource: &v1alpha3.BitbucketServerSource{Owner: "KEY", Repo: "repo", CredentialId: "token",
+				ApiUri: "https://bitbucket.example.com"}},
+		wantProvider: "bitbucketserver", wantServer: "https://bitbucket.example.com",
+		wantRepo: "KEY/repo", wantGitURL: "https://bitbucket.example.com/projects/KEY/repos/repo/browse",
+	}, {
+		name: "gitea",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeGitea,
+			GiteaSource: &v1alpha3.GiteaSource{Owner: "owner", Repo: "repo", CredentialId: "token",
+				ServerUrl: "https://gitea.example.com/git/"}},
+		wantProvider: "gitea", wantServer: "https://gitea.example.com/git",
+		wantRepo: "owner/repo", wantGitURL: "https://gitea.example.com/git/owner/repo",
+	}, {
+		name: "without credential",
+		mbp: &v1alpha3.MultiBranchPipeline{SourceType: v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "octocat", Repo: "hello-world"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := getWebhookSource(newPipeline(tt.mbp))
+			if tt.wantProvider == "" {
+				assert.Nil(t, source)
+				return
+			}
+			if assert.NotNil(t, source) {
+				assert.Equal(t, tt.wantProvider, source.Provider)
+				assert.Equal(t, tt.wantServer, source.Server)
+				assert.Equal(t, tt.wantRepo, source.Repo)
+				assert.Equal(t, tt.wantGitURL, source.gitURL)
+				assert.Equal(t, "token", source.Credential)
+			}
+		})
+	}
+}
+
+func Test_findHook(t *testing.T) {
+	hooks := []*scm.Hook{
+		{ID: "1", Target: "https://other.example.com/hook"},
+		{ID: "2", Target: webhookTarget + "?secret=token"},
+	}
+	assert.Equal(t, "2", findHook(hooks, webhookTarget).ID)
+	assert.Nil(t, findHook(hooks, "https://none.example.com"))
+	assert.Nil(t, findHook(nil, webhookTarget))
+}
+
+func Test_getWebhookGitURL(t *testing.T) {
+	assert.Equal(t, webhookGitURL, getWebhookGitURL(newWebhookPipeline("fake", false, false)))
+
+	pipeline := newWebhookPipeline("fake", false, false)
+	pipeline.Spec.MultiBranchPipeline.GitHubSource.CredentialId = ""
+	assert.Empty(t, getWebhookGitURL(pipeline))
+
+	pipeline.Spec.MultiBranchPipeline = &v1alpha3.MultiBranchPipeline{
+		SourceType: v1alpha3.SourceTypeGit,
+		GitSource:  &v1alpha3.GitSource{Url: webhookGitURL, CredentialId: "git"},
+	}
+	assert.Empty(t, getWebhookGitURL(pipeline))
+	assert.Empty(t, getWebhookGitURL(&v1alpha3.Pipeline{}))
+}
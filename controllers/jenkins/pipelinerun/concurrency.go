@@ -0,0 +1,223 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// queuedRequeuePeriod is the period of checking if a queued PipelineRun is able to run
+const queuedRequeuePeriod = 10 * time.Second
+
+// concurrencyGroupData is the data to render the template of a concurrency group
+type concurrencyGroupData struct {
+	Namespace  string
+	Pipeline   string
+	Branch     string
+	Parameters map[string]string
+}
+
+// renderConcurrencyGroup renders the concurrency group key of a PipelineRun
+func renderConcurrencyGroup(groupTemplate string, pr *v1alpha3.PipelineRun) (group string, err error) {
+	var tpl *template.Template
+	if tpl, err = template.New("concurrency").Option("missingkey=zero").Parse(groupTemplate); err != nil {
+		return
+	}
+
+	data := concurrencyGroupData{
+		Namespace:  pr.Namespace,
+		Branch:     pr.GetRefName(),
+		Parameters: map[string]string{},
+	}
+	if pr.Spec.PipelineRef != nil {
+		data.Pipeline = pr.Spec.PipelineRef.Name
+	}
+	for _, param := range pr.Spec.Parameters {
+		data.Parameters[param.Name] = param.Value
+	}
+
+	buf := &bytes.Buffer{}
+	if err = tpl.Execute(buf, data); err == nil {
+		group = buf.String()
+	}
+	return
+}
+
+// getConcurrencyGroupLabelValue returns a valid label value of the concurrency group
+func getConcurrencyGroupLabelValue(group string) string {
+	if len(validation.IsValidLabelValue(group)) == 0 {
+		return group
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(group)))[:validation.LabelValueMaxLength]
+}
+
+// checkConcurrency returns true if the PipelineRun is able to be triggered according to its concurrency group.
+// The PipelineRun will be queued, skipped, or it stops the others in the same group.
+func (r *Reconciler) checkConcurrency(ctx context.Context, pr *v1alpha3.PipelineRun) (proceed bool, result ctrl.Result, err error) {
+	concurrency := pr.GetConcurrency()
+	if concurrency == nil || concurrency.Group == "" {
+		proceed = true
+		return
+	}
+
+	var group string
+	if group, err = renderConcurrencyGroup(concurrency.Group, pr); err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to render the concurrency group of PipelineRun %s/%s, and error was %v",
+			pr.Namespace, pr.Name, err)
+		return
+	}
+	if group == "" {
+		proceed = true
+		return
+	}
+
+	groupLabel := getConcurrencyGroupLabelValue(group)
+	if pr.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey] != groupLabel {
+		if pr.Labels == nil {
+			pr.Labels = make(map[string]string)
+		}
+		pr.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey] = groupLabel
+		if err = r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+			return
+		}
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunConcurrencyGroupLabelKey: groupLabel}); err != nil {
+		return
+	}
+
+	// the running ones, and the pending ones which were created earlier than the current one
+	var others []*v1alpha3.PipelineRun
+	for i := range pipelineRuns.Items {
+		item := &pipelineRuns.Items[i]
+		if item.Name == pr.Name || item.HasCompleted() || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if item.HasStarted() || isCreatedEarlier(item, pr) {
+			others = append(others, item)
+		}
+	}
+	if len(others) == 0 {
+		proceed = true
+		return
+	}
+
+	switch concurrency.Policy {
+	case v1alpha3.ConcurrencyPolicySkip:
+		err = r.skipPipelineRun(ctx, pr, group)
+	case v1alpha3.ConcurrencyPolicyCancelInProgress:
+		for _, other := range others {
+			if err = r.stopPipelineRun(ctx, other); err != nil {
+				return
+			}
+			r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Stopped, "Stopped PipelineRun %s/%s in concurrency group %s",
+				other.Namespace, other.Name, group)
+		}
+		proceed = true
+	default:
+		err = r.queuePipelineRun(ctx, pr, group)
+		result = ctrl.Result{RequeueAfter: queuedRequeuePeriod}
+	}
+	return
+}
+
+// isCreatedEarlier returns true if the left PipelineRun was created earlier than the right one
+func isCreatedEarlier(left, right *v1alpha3.PipelineRun) bool {
+	if !left.CreationTimestamp.Equal(&right.CreationTimestamp) {
+		return left.CreationTimestamp.Before(&right.CreationTimestamp)
+	}
+	return left.Name < right.Name
+}
+
+// stopPipelineRun requests to stop a PipelineRun by its action
+func (r *Reconciler) stopPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		prToUpdate := &v1alpha3.PipelineRun{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pr), prToUpdate); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if prToUpdate.Spec.Action != nil && *prToUpdate.Spec.Action == v1alpha3.Stop {
+			return nil
+		}
+		stop := v1alpha3.Stop
+		prToUpdate.Spec.Action = &stop
+		return r.Update(ctx, prToUpdate)
+	})
+}
+
+// queuePipelineRun marks a PipelineRun as queued
+func (r *Reconciler) queuePipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, group string) error {
+	if condition := pr.Status.GetLatestCondition(); condition != nil && condition.Reason == v1alpha3.Queued {
+		return nil
+	}
+
+	now := v1.Now()
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionReady,
+		Status:             v1alpha3.ConditionUnknown,
+		Reason:             v1alpha3.Queued,
+		Message:            fmt.Sprintf("waiting for the other PipelineRuns in concurrency group %s", group),
+		LastTransitionTime: now,
+		LastProbeTime:      now,
+	})
+	pr.Status.Phase = v1alpha3.Pending
+	pr.Status.UpdateTime = &now
+	if err := r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err != nil {
+		return err
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Queued, "Queued PipelineRun %s/%s in concurrency group %s",
+		pr.Namespace, pr.Name, group)
+	return nil
+}
+
+// skipPipelineRun completes a PipelineRun without triggering it
+func (r *Reconciler) skipPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, group string) error {
+	now := v1.Now()
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             v1alpha3.Skipped,
+		Message:            fmt.Sprintf("skipped due to the other PipelineRuns in concurrency group %s", group),
+		LastTransitionTime: now,
+		LastProbeTime:      now,
+	})
+	pr.Status.Phase = v1alpha3.Cancelled
+	pr.Status.CompletionTime = &now
+	pr.Status.UpdateTime = &now
+	if err := r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err != nil {
+		return err
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Skipped, "Skipped PipelineRun %s/%s in concurrency group %s",
+		pr.Namespace, pr.Name, group)
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_renderConcurrencyGroup(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns"},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineRef:  &v1.ObjectReference{Name: "pipeline"},
+			PipelineSpec: &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType},
+			SCM:          &v1alpha3.SCM{RefName: "master"},
+			Parameters: []v1alpha3.Parameter{{
+				Name:  "env",
+				Value: "prod",
+			}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{{
+		name:     "static group",
+		template: "deploy",
+		want:     "deploy",
+	}, {
+		name:     "all the fields",
+		template: "{{.Namespace}}-{{.Pipeline}}-{{.Branch}}-{{.Parameters.env}}",
+		want:     "ns-pipeline-master-prod",
+	}, {
+		name:     "missing parameter",
+		template: "deploy-{{.Parameters.region}}",
+		want:     "deploy-",
+	}, {
+		name:     "invalid template",
+		template: "deploy-{{.Parameters.env",
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderConcurrencyGroup(tt.template, pr)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_getConcurrencyGroupLabelValue(t *testing.T) {
+	assert.Equal(t, "deploy-prod", getConcurrencyGroupLabelValue("deploy-prod"))
+
+	hashed := getConcurrencyGroupLabelValue("deploy/prod")
+	assert.NotEqual(t, "deploy/prod", hashed)
+	assert.Len(t, hashed, 63)
+	assert.Equal(t, hashed, getConcurrencyGroupLabelValue("deploy/prod"))
+	assert.Len(t, getConcurrencyGroupLabelValue(strings.Repeat("a", 100)), 63)
+}
+
+func TestReconciler_checkConcurrency(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Minute))
+	newPipelineRun := func(name string, created metav1.Time, policy v1alpha3.ConcurrencyPolicy) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "ns",
+				CreationTimestamp: created,
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &v1.ObjectReference{Name: name},
+				Parameters:  []v1alpha3.Parameter{{Name: "env", Value: "prod"}},
+				Concurrency: &v1alpha3.Concurrency{
+					Group:  "deploy-{{.Parameters.env}}",
+					Policy: policy,
+				},
+			},
+		}
+	}
+	withRunning := func(pr *v1alpha3.PipelineRun) *v1alpha3.PipelineRun {
+		pr.Labels = map[string]string{v1alpha3.PipelineRunConcurrencyGroupLabelKey: "deploy-prod"}
+		pr.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}
+		return pr
+	}
+	withCompleted := func(pr *v1alpha3.PipelineRun) *v1alpha3.PipelineRun {
+		pr = withRunning(pr)
+		pr.Status.CompletionTime = &now
+		return pr
+	}
+
+	tests := []struct {
+		name        string
+		pipelineRun *v1alpha3.PipelineRun
+		others      []*v1alpha3.PipelineRun
+		wantProceed bool
+		wantRequeue bool
+		wantPhase   v1alpha3.RunPhase
+		wantStopped string
+	}{{
+		name:        "no concurrency",
+		pipelineRun: &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "current", Namespace: "ns"}},
+		wantProceed: true,
+	}, {
+		name:        "no others in the group",
+		pipelineRun: newPipelineRun("current", now, v1alpha3.ConcurrencyPolicyQueue),
+		others:      []*v1alpha3.PipelineRun{withCompleted(newPipelineRun("completed", earlier, ""))},
+		wantProceed: true,
+	}, {
+		name:        "queue",
+		pipelineRun: newPipelineRun("current", now, ""),
+		others:      []*v1alpha3.PipelineRun{withRunning(newPipelineRun("running", earlier, ""))},
+		wantRequeue: true,
+		wantPhase:   v1alpha3.Pending,
+	}, {
+		name:        "skip",
+		pipelineRun: newPipelineRun("current", now, v1alpha3.ConcurrencyPolicySkip),
+		others:      []*v1alpha3.PipelineRun{withRunning(newPipelineRun("running", earlier, ""))},
+		wantPhase:   v1alpha3.Cancelled,
+	}, {
+		name:        "cancel in progress",
+		pipelineRun: newPipelineRun("current", now, v1alpha3.ConcurrencyPolicyCancelInProgress),
+		others:      []*v1alpha3.PipelineRun{withRunning(newPipelineRun("running", earlier, ""))},
+		wantProceed: true,
+		wantStopped: "running",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(schema).
+				WithObjects(tt.pipelineRun.DeepCopy()).WithStatusSubresource(tt.pipelineRun.DeepCopy())
+			for _, other := range tt.others {
+				builder.WithObjects(other.DeepCopy())
+			}
+			r := &Reconciler{
+				Client:   builder.Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: record.NewFakeRecorder(10),
+			}
+
+			proceed, result, err := r.checkConcurrency(context.Background(), tt.pipelineRun.DeepCopy())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantProceed, proceed)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter > 0)
+
+			stored := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "current"}, stored))
+			assert.Equal(t, tt.wantPhase, stored.Status.Phase)
+			if tt.pipelineRun.Spec.Concurrency != nil {
+				assert.Equal(t, "deploy-prod", stored.Labels[v1alpha3.PipelineRunConcurrencyGroupLabelKey])
+			}
+
+			if tt.wantStopped != "" {
+				stopped := &v1alpha3.PipelineRun{}
+				assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: tt.wantStopped}, stopped))
+				if assert.NotNil(t, stopped.Spec.Action) {
+					assert.Equal(t, v1alpha3.Stop, *stopped.Spec.Action)
+				}
+			}
+		})
+	}
+}
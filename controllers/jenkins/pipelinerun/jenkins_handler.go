@@ -18,6 +18,8 @@ package pipelinerun
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -110,6 +112,67 @@ func (handler *jenkinsHandler) getPipelineRunResult(devopsProjectName, pipelineN
 	})
 }
 
+// blueTestSummary is the summary of the test reports of a Jenkins build
+type blueTestSummary struct {
+	ExistingFailed int `json:"existingFailed"`
+	Failed         int `json:"failed"`
+	Fixed          int `json:"fixed"`
+	Passed         int `json:"passed"`
+	Regressions    int `json:"regressions"`
+	Skipped        int `json:"skipped"`
+	Total          int `json:"total"`
+}
+
+// getPipelineRunArtifacts gets the archived artifacts of a PipelineRun
+func (handler *jenkinsHandler) getPipelineRunArtifacts(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun) (
+	artifacts []job.BlueArtifact, err error) {
+	var api string
+	if api, err = getBlueOceanRunAPI(devopsProjectName, pipelineName, pr); err != nil {
+		return
+	}
+	err = handler.RequestWithData(http.MethodGet, api+"artifacts/?start=0&limit=10000", nil, nil, http.StatusOK, &artifacts)
+	return
+}
+
+// getPipelineRunTestSummary gets the summary of the test reports of a PipelineRun
+func (handler *jenkinsHandler) getPipelineRunTestSummary(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun) (
+	summary *blueTestSummary, err error) {
+	var api string
+	if api, err = getBlueOceanRunAPI(devopsProjectName, pipelineName, pr); err != nil {
+		return
+	}
+	summary = &blueTestSummary{}
+	if err = handler.RequestWithData(http.MethodGet, api+"blueTestSummary/", nil, nil, http.StatusOK, summary); err != nil {
+		summary = nil
+	}
+	return
+}
+
+// getPipelineRunLog gets the whole log of a PipelineRun, or the log of a step if both node and step ID are given
+func (handler *jenkinsHandler) getPipelineRunLog(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun,
+	nodeID, stepID string) (log string, err error) {
+	var api string
+	if api, err = getBlueOceanRunAPI(devopsProjectName, pipelineName, pr); err != nil {
+		return
+	}
+	if nodeID != "" && stepID != "" {
+		api = fmt.Sprintf("%snodes/%s/steps/%s/", api, nodeID, stepID)
+	}
+
+	var (
+		statusCode int
+		data       []byte
+	)
+	if statusCode, data, err = handler.Request(http.MethodGet, api+"log/?start=0", nil, nil); err == nil {
+		if statusCode == http.StatusOK {
+			log = string(data)
+		} else {
+			err = handler.ErrorHandle(statusCode, data)
+		}
+	}
+	return
+}
+
 func (handler *jenkinsHandler) triggerJenkinsJob(devopsProjectName, pipelineName string, prSpec *v1alpha3.PipelineRunSpec) (*job.PipelineRun, error) {
 	c := job.BlueOceanClient{JenkinsCore: *handler.JenkinsCore, Organization: "jenkins"}
 
@@ -125,6 +188,32 @@ func (handler *jenkinsHandler) triggerJenkinsJob(devopsProjectName, pipelineName
 	})
 }
 
+// restartJenkinsJobFromStage restarts the Jenkins build of a completed PipelineRun from a stage,
+// it's the same as "Restart from Stage" in Jenkins. The returned run only has the ID and branch.
+func (handler *jenkinsHandler) restartJenkinsJobFromStage(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun,
+	stageID string) (*job.PipelineRun, error) {
+	api, err := getBlueOceanRunAPI(devopsProjectName, pipelineName, pr)
+	if err != nil {
+		return nil, err
+	}
+	branch, err := getSCMRefName(&pr.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	queueItem := &struct {
+		ExpectedBuildNumber int `json:"expectedBuildNumber"`
+	}{}
+	if err = handler.RequestWithData(http.MethodPost, fmt.Sprintf("%snodes/%s/restart/", api, stageID),
+		map[string]string{"Content-Type": "application/json"}, strings.NewReader(`{"restart":true}`), http.StatusOK, queueItem); err != nil {
+		return nil, fmt.Errorf("failed to restart Jenkins job from stage: %s, error: %v", stageID, err)
+	}
+	return &job.PipelineRun{BlueItemRun: job.BlueItemRun{
+		ID:       strconv.Itoa(queueItem.ExpectedBuildNumber),
+		Pipeline: branch,
+	}}, nil
+}
+
 func (handler *jenkinsHandler) deleteJenkinsJobHistory(pipelineRun *v1alpha3.PipelineRun) (err error) {
 	var buildNum int
 	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
@@ -144,6 +233,57 @@ func (handler *jenkinsHandler) deleteJenkinsJobHistory(pipelineRun *v1alpha3.Pip
 	return
 }
 
+// stopJenkinsJob aborts the Jenkins build which related with a PipelineRun
+func (handler *jenkinsHandler) stopJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return
+	}
+
+	jenkinsClient := job.Client{JenkinsCore: *handler.JenkinsCore}
+	jobPath := getJenkinsJobPath(pipelineRun)
+	if err = jenkinsClient.StopJob(jobPath, buildNum); err != nil {
+		err = fmt.Errorf("failed to stop Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+// togglePauseJenkinsJob pauses a running Jenkins build, or resumes a paused one.
+// A paused build holds its executor like waiting for an input, it is the same as the pause/resume link in Jenkins.
+func (handler *jenkinsHandler) togglePauseJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return fmt.Errorf("unable to pause or resume PipelineRun due to not found run ID")
+	}
+
+	jobPath := getJenkinsJobPath(pipelineRun)
+	api := fmt.Sprintf("%s/%d/pause/toggle", jobPath, buildNum)
+	if _, err = handler.RequestWithoutData(http.MethodPost, api, nil, nil, http.StatusOK); err != nil {
+		err = fmt.Errorf("failed to pause or resume Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+// getBlueOceanRunAPI returns the Blue Ocean API path of a PipelineRun, it ends with a slash
+func getBlueOceanRunAPI(devopsProjectName, pipelineName string, pr *v1alpha3.PipelineRun) (api string, err error) {
+	runID, exists := pr.GetPipelineRunID()
+	if !exists {
+		err = fmt.Errorf("unable to get PipelineRun data due to not found run ID")
+		return
+	}
+	var branch string
+	if branch, err = getSCMRefName(&pr.Spec); err != nil {
+		return
+	}
+
+	api = fmt.Sprintf("/blue/rest/organizations/jenkins/pipelines/%s/pipelines/%s/", devopsProjectName, pipelineName)
+	if branch != "" {
+		api = fmt.Sprintf("%sbranches/%s/", api, url.PathEscape(branch))
+	}
+	api = fmt.Sprintf("%sruns/%s/", api, runID)
+	return
+}
+
 // getJenkinsJobPath returns the corresponding Jenkins job path
 // only a regular or multi-branch Pipeline supported
 func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
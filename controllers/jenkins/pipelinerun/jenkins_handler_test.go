@@ -311,3 +311,324 @@ func Test_getJenkinsJobPath(t *testing.T) {
 		})
 	}
 }
+
+var _ = Describe("Test stopJenkinsJob and togglePauseJenkinsJob", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{&core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "project1",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: "testPipeline",
+				},
+			},
+		}
+
+		requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+		responseCrumb := &http.Response{
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Request:    requestCrumb,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil).AnyTimes()
+	})
+
+	It("stop a PipelineRun which has not been triggered", func() {
+		err := jHandler.stopJenkinsJob(&v1alpha3.PipelineRun{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("stop a running PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodPost, "http://localhost/job/project1/job/testPipeline/2/stop", nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		err := jHandler.stopJenkinsJob(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("failed to stop a running PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodPost, "http://localhost/job/project1/job/testPipeline/2/stop", nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusInternalServerError,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		err := jHandler.stopJenkinsJob(pipelineRun)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("pause a PipelineRun which has not been triggered", func() {
+		err := jHandler.togglePauseJenkinsJob(&v1alpha3.PipelineRun{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("pause a running PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodPost, "http://localhost/job/project1/job/testPipeline/2/pause/toggle", nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		err := jHandler.togglePauseJenkinsJob(pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+})
+
+var _ = Describe("Test getPipelineRunArtifacts, getPipelineRunTestSummary and getPipelineRunLog", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{&core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "project1",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: "testPipeline",
+				},
+			},
+		}
+	})
+
+	It("get the artifacts of a PipelineRun which has not been triggered", func() {
+		_, err := jHandler.getPipelineRunArtifacts("project1", "testPipeline", &v1alpha3.PipelineRun{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("get the artifacts of a PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodGet,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/artifacts/?start=0&limit=10000", nil)
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				[{"name":"app.jar","path":"target/app.jar","size":1024,"url":"/job/project1/job/testPipeline/2/artifact/target/app.jar"}]
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		artifacts, err := jHandler.getPipelineRunArtifacts("project1", "testPipeline", pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(artifacts)).To(Equal(1))
+		Expect(artifacts[0].Path).To(Equal("target/app.jar"))
+		Expect(artifacts[0].Size).To(Equal(int64(1024)))
+	})
+
+	It("get the test summary of a PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodGet,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/blueTestSummary/", nil)
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"existingFailed":0,"failed":1,"fixed":0,"passed":8,"regressions":1,"skipped":1,"total":10}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		summary, err := jHandler.getPipelineRunTestSummary("project1", "testPipeline", pipelineRun)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary).To(Equal(&blueTestSummary{Failed: 1, Passed: 8, Regressions: 1, Skipped: 1, Total: 10}))
+	})
+
+	It("get the log of a step", func() {
+		request, _ := http.NewRequest(http.MethodGet,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/nodes/3/steps/4/log/?start=0", nil)
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("hello")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		log, err := jHandler.getPipelineRunLog("project1", "testPipeline", pipelineRun, "3", "4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(log).To(Equal("hello"))
+	})
+
+	It("failed to get the test summary of a PipelineRun", func() {
+		request, _ := http.NewRequest(http.MethodGet,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/blueTestSummary/", nil)
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusNotFound,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		summary, err := jHandler.getPipelineRunTestSummary("project1", "testPipeline", pipelineRun)
+		Expect(err).To(HaveOccurred())
+		Expect(summary).To(BeNil())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+})
+
+var _ = Describe("Test restartJenkinsJobFromStage", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{&core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "project1",
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: "testPipeline",
+				},
+			},
+		}
+
+		requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+		responseCrumb := &http.Response{
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Request:    requestCrumb,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil).AnyTimes()
+	})
+
+	It("restart a PipelineRun which has not been triggered", func() {
+		_, err := jHandler.restartJenkinsJobFromStage("project1", "testPipeline", &v1alpha3.PipelineRun{}, "6")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("restart a PipelineRun from a stage", func() {
+		request, _ := http.NewRequest(http.MethodPost,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/nodes/6/restart/",
+			bytes.NewBufferString(`{"restart":true}`))
+		request.Header.Set("CrumbRequestField", "Crumb")
+		request.Header.Set("Content-Type", "application/json")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"expectedBuildNumber":3}`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		jobRun, err := jHandler.restartJenkinsJobFromStage("project1", "testPipeline", pipelineRun, "6")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobRun.ID).To(Equal("3"))
+	})
+
+	It("failed to restart a PipelineRun from a stage", func() {
+		request, _ := http.NewRequest(http.MethodPost,
+			"http://localhost/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/nodes/6/restart/",
+			bytes.NewBufferString(`{"restart":true}`))
+		request.Header.Set("CrumbRequestField", "Crumb")
+		request.Header.Set("Content-Type", "application/json")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: http.StatusBadRequest,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("")),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+
+		_, err := jHandler.restartJenkinsJobFromStage("project1", "testPipeline", pipelineRun, "6")
+		Expect(err).To(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+})
+
+func Test_getBlueOceanRunAPI(t *testing.T) {
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+			},
+		},
+	}
+	api, err := getBlueOceanRunAPI("project1", "testPipeline", pipelineRun)
+	assert.Nil(t, err)
+	assert.Equal(t, "/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/runs/2/", api)
+
+	pipelineRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+	pipelineRun.Spec.SCM = &v1alpha3.SCM{RefName: "feature/a"}
+	api, err = getBlueOceanRunAPI("project1", "testPipeline", pipelineRun)
+	assert.Nil(t, err)
+	assert.Equal(t, "/blue/rest/organizations/jenkins/pipelines/project1/pipelines/testPipeline/branches/feature%2Fa/runs/2/", api)
+
+	_, err = getBlueOceanRunAPI("project1", "testPipeline", &v1alpha3.PipelineRun{})
+	assert.NotNil(t, err)
+}
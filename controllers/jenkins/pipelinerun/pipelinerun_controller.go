@@ -30,6 +30,7 @@ import (
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
@@ -37,11 +38,16 @@ import (
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	devopsClient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/devops/jenkins"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	s3store "github.com/kubesphere/ks-devops/pkg/store/s3"
 	storeInter "github.com/kubesphere/ks-devops/pkg/store/store"
 	"github.com/kubesphere/ks-devops/pkg/utils/k8sutil"
 	"github.com/kubesphere/ks-devops/pkg/utils/sliceutil"
@@ -50,6 +56,9 @@ import (
 // BuildNotExistMsg indicates the build with pipelinerun-id not exist in jenkins
 const BuildNotExistMsg = "not found resources"
 
+// DefaultSyncPeriod is the default fallback period of synchronizing a running PipelineRun from Jenkins.
+const DefaultSyncPeriod = time.Minute
+
 // Reconciler reconciles a PipelineRun object
 type Reconciler struct {
 	client.Client
@@ -62,6 +71,11 @@ type Reconciler struct {
 	JenkinsCore          core.JenkinsCore
 	recorder             record.EventRecorder
 	PipelineRunDataStore string
+	// S3Client is required when the PipelineRun data store is s3
+	S3Client s3.Interface
+	// SyncPeriod is the fallback period of synchronizing a running PipelineRun from Jenkins.
+	// The events sent from Jenkins trigger the synchronization immediately, so it only covers the missed events.
+	SyncPeriod time.Duration
 }
 
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete
@@ -97,6 +111,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
 		}
 
+		if err = r.deletePipelineRunData(pipelineRunCopied); err != nil {
+			log.Error(err, "unable to delete the PipelineRun data")
+			return ctrl.Result{}, err
+		}
+
 		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			// Get the latest version of PipelineRun
 			if err := r.Get(ctx, req.NamespacedName, pipelineRunCopied); err != nil {
@@ -136,11 +155,23 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		}
 	}
 
+	// create the next attempt if the completed PipelineRun qualifies for a retry
+	if pipelineRunCopied.HasCompleted() {
+		return r.retryPipelineRun(ctx, pipelineRunCopied)
+	}
+
 	// the PipelineRun cannot allow building
 	if !pipelineRunCopied.Buildable() {
 		return ctrl.Result{}, nil
 	}
 
+	// take the action of the PipelineRun, such as: Stop, Pause or Resume
+	if pipelineRunCopied.Spec.Action != nil {
+		if completed, err := r.handleAction(ctx, jHandler, pipelineRunCopied); err != nil || completed {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// check PipelineRef
 	if pipelineRunCopied.Spec.PipelineRef == nil || pipelineRunCopied.Spec.PipelineRef.Name == "" {
 		// make the PipelineRun as orphan
@@ -167,6 +198,15 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	// check PipelineRun status
 	if pipelineRunCopied.HasStarted() {
+		// abort the PipelineRun which runs too long
+		if remaining, ok := getTimeoutRemaining(pipelineRunCopied); ok && remaining <= 0 {
+			if err := r.handleTimeout(ctx, jHandler, pipelineRunCopied); err != nil {
+				log.Error(err, "unable to abort the timed out PipelineRun")
+				return ctrl.Result{}, err
+			}
+			return r.retryPipelineRun(ctx, pipelineRunCopied)
+		}
+
 		log.V(5).Info("pipeline has already started, and we are retrieving run data from Jenkins.")
 		pipelineBuild, err := jHandler.getPipelineRunResult(namespaceName, pipelineName, pipelineRunCopied)
 		if err != nil {
@@ -207,6 +247,28 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		status := pipelineRunCopied.Status.DeepCopy()
 		pbApplier := pipelineBuildApplier{pipelineBuild}
 		pbApplier.apply(status)
+		summaryBuilder := pipelineRunSummaryBuilder{pipelineBuild: pipelineBuild, nodeDetails: nodeDetails}
+		if status.CompletionTime != nil {
+			if err = r.storePipelineRunLog(jHandler, namespaceName, pipelineName, pipelineRunCopied, nodeDetails); err != nil {
+				log.Error(err, "unable to store PipelineRun log")
+			}
+			// the artifacts and test reports are only complete after the build finished
+			if summaryBuilder.artifacts, err = jHandler.getPipelineRunArtifacts(namespaceName, pipelineName, pipelineRunCopied); err != nil {
+				log.Error(err, "unable to get PipelineRun artifacts")
+			}
+			if summaryBuilder.testSummary, err = jHandler.getPipelineRunTestSummary(namespaceName, pipelineName, pipelineRunCopied); err != nil {
+				log.Error(err, "unable to get PipelineRun test summary")
+			}
+		}
+		status.Summary = summaryBuilder.build()
+		if failedStep := status.Summary.FailedStep; failedStep != nil && status.CompletionTime != nil {
+			if stepLog, logErr := jHandler.getPipelineRunLog(namespaceName, pipelineName, pipelineRunCopied,
+				failedStep.StageID, failedStep.StepID); logErr == nil {
+				failedStep.LogExcerpt = getLogExcerpt(stepLog)
+			} else {
+				log.Error(logErr, "unable to get the log of the failed step")
+			}
+		}
 		// Because the status is a subresource of PipelineRun, we have to update status separately.
 		// See also: https://book-v1.book.kubebuilder.io/basics/status_subresource.html
 		if err := r.updateStatus(ctx, status, req.NamespacedName); err != nil {
@@ -221,15 +283,37 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		}
 
 		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeNormal, v1alpha3.Updated, "Updated running data for PipelineRun %s", req.NamespacedName)
-		// until the status is okay
-		// TODO make the RequeueAfter configurable
-		return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		if status.CompletionTime != nil {
+			// the final data will be retrieved if the completed event was missed before the PipelineRun completed
+			pipelineRunCopied.Status = *status
+			return r.retryPipelineRun(ctx, pipelineRunCopied)
+		}
+		// the events from Jenkins drive the synchronization, polling is only a fallback
+		requeueAfter := r.getSyncPeriod()
+		if remaining, ok := getTimeoutRemaining(pipelineRunCopied); ok && remaining < requeueAfter {
+			// make sure the timeout is enforced in time
+			requeueAfter = time.Second
+			if remaining > 0 {
+				requeueAfter += remaining
+			}
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	// control the concurrency of the PipelineRuns in the same group
+	if proceed, result, err := r.checkConcurrency(ctx, pipelineRunCopied); err != nil || !proceed {
+		return result, err
 	}
 
 	// create trigger handler
 	triggerHandler := &jenkinsHandler{&r.JenkinsCore}
 	// first run
-	jobRun, err := triggerHandler.triggerJenkinsJob(namespaceName, pipelineName, &pipelineRunCopied.Spec)
+	var jobRun *job.PipelineRun
+	if stageID, ok := pipelineRunCopied.Annotations[v1alpha3.PipelineRunRerunFromStageAnnoKey]; ok {
+		jobRun, err = r.restartFromStage(ctx, triggerHandler, namespaceName, pipelineName, pipelineRunCopied, stageID)
+	} else {
+		jobRun, err = triggerHandler.triggerJenkinsJob(namespaceName, pipelineName, &pipelineRunCopied.Spec)
+	}
 	if err != nil {
 		log.Error(err, "unable to run pipeline", "namespace", namespaceName, "pipeline", pipeline.Name)
 		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
@@ -276,6 +360,194 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	return ctrl.Result{}, nil
 }
 
+// restartFromStage restarts the Jenkins build of the re-run PipelineRun from a stage
+func (r *Reconciler) restartFromStage(ctx context.Context, jHandler *jenkinsHandler, namespaceName, pipelineName string,
+	pr *v1alpha3.PipelineRun, stageID string) (*job.PipelineRun, error) {
+	original := &v1alpha3.PipelineRun{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Annotations[v1alpha3.PipelineRunRerunOfAnnoKey]}, original); err != nil {
+		return nil, fmt.Errorf("unable to get the original PipelineRun, error: %v", err)
+	}
+	return jHandler.restartJenkinsJobFromStage(namespaceName, pipelineName, original, stageID)
+}
+
+// handleAction takes the action of a PipelineRun, and returns true if the PipelineRun is completed by the action.
+// Stop aborts the Jenkins build, Pause and Resume toggle the pause state of the Jenkins build.
+func (r *Reconciler) handleAction(ctx context.Context, jHandler *jenkinsHandler, pr *v1alpha3.PipelineRun) (completed bool, err error) {
+	now := v1.Now()
+	condition := v1alpha3.Condition{
+		LastTransitionTime: now,
+		LastProbeTime:      now,
+	}
+
+	var reason string
+	action := *pr.Spec.Action
+	switch action {
+	case v1alpha3.Stop:
+		// it's fine to stop a PipelineRun which has not been triggered yet
+		err = jHandler.stopJenkinsJob(pr)
+		condition.Type = v1alpha3.ConditionSucceeded
+		condition.Status = v1alpha3.ConditionFalse
+		condition.Reason = string(v1alpha3.Cancelled)
+		condition.Message = "the PipelineRun was stopped by the action"
+		pr.Status.Phase = v1alpha3.Cancelled
+		pr.Status.CompletionTime = &now
+		reason = v1alpha3.Stopped
+		completed = true
+	case v1alpha3.Pause:
+		if !pr.HasStarted() || pr.IsPaused() {
+			return
+		}
+		err = jHandler.togglePauseJenkinsJob(pr)
+		condition.Type = v1alpha3.ConditionPaused
+		condition.Status = v1alpha3.ConditionTrue
+		condition.Reason = string(action)
+		condition.Message = "the PipelineRun was paused by the action"
+		reason = v1alpha3.Paused
+	case v1alpha3.Resume:
+		if !pr.IsPaused() {
+			return
+		}
+		err = jHandler.togglePauseJenkinsJob(pr)
+		condition.Type = v1alpha3.ConditionPaused
+		condition.Status = v1alpha3.ConditionFalse
+		condition.Reason = string(action)
+		condition.Message = "the PipelineRun was resumed by the action"
+		reason = v1alpha3.Resumed
+	default:
+		r.log.Info("unknown action of PipelineRun", "action", action)
+		return
+	}
+
+	if err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Failed to take action %s on PipelineRun %s/%s, and error was %v",
+			action, pr.Namespace, pr.Name, err)
+		return false, err
+	}
+
+	pr.Status.AddCondition(&condition)
+	pr.Status.UpdateTime = &now
+	if err = r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err != nil {
+		return false, err
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, reason, "%s PipelineRun %s/%s", reason, pr.Namespace, pr.Name)
+	return
+}
+
+// handleTimeout aborts the Jenkins build of a timed out PipelineRun, and marks the PipelineRun as failed.
+func (r *Reconciler) handleTimeout(ctx context.Context, jHandler *jenkinsHandler, pr *v1alpha3.PipelineRun) error {
+	timeout := pr.GetTimeout()
+	if err := jHandler.stopJenkinsJob(pr); err != nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.TimedOut, "Failed to abort the timed out PipelineRun %s/%s, and error was %v",
+			pr.Namespace, pr.Name, err)
+		return err
+	}
+
+	now := v1.Now()
+	pr.Status.AddCondition(&v1alpha3.Condition{
+		Type:               v1alpha3.ConditionSucceeded,
+		Status:             v1alpha3.ConditionFalse,
+		Reason:             v1alpha3.TimedOut,
+		Message:            fmt.Sprintf("the PipelineRun was aborted due to exceeding the timeout %s", timeout),
+		LastTransitionTime: now,
+		LastProbeTime:      now,
+	})
+	pr.Status.Phase = v1alpha3.Failed
+	pr.Status.CompletionTime = &now
+	pr.Status.UpdateTime = &now
+	if err := r.updateStatus(ctx, &pr.Status, client.ObjectKeyFromObject(pr)); err != nil {
+		return err
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.TimedOut, "Aborted PipelineRun %s/%s due to exceeding the timeout %s",
+		pr.Namespace, pr.Name, timeout)
+	return nil
+}
+
+// getTimeoutRemaining returns the remaining duration before the PipelineRun times out,
+// the second returned value is false if there is no timeout or the PipelineRun has not started.
+func getTimeoutRemaining(pr *v1alpha3.PipelineRun) (time.Duration, bool) {
+	timeout := pr.GetTimeout()
+	if timeout <= 0 || pr.Status.StartTime.IsZero() {
+		return 0, false
+	}
+	return time.Until(pr.Status.StartTime.Add(timeout)), true
+}
+
+// retryPipelineRun creates the next attempt of a completed PipelineRun according to its retry policy.
+// All the attempts are linked by the labels, and the completed one is annotated with the name of its retry.
+func (r *Reconciler) retryPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun) (ctrl.Result, error) {
+	policy := pr.GetRetryPolicy()
+	if _, retried := pr.Annotations[v1alpha3.PipelineRunRetriedByAnnoKey]; retried || !policy.Qualify(pr) {
+		return ctrl.Result{}, nil
+	}
+
+	attempt := pr.GetAttempt()
+	if wait := time.Until(pr.Status.CompletionTime.Add(policy.GetBackoff(attempt))); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	retry := newRetryPipelineRun(pr)
+	// the name of the retry is fixed, so there won't be duplicated attempts even if failed to annotate the completed one
+	if err := r.Create(ctx, retry); err != nil && !apierrors.IsAlreadyExists(err) {
+		r.log.Error(err, "unable to create the retry of PipelineRun", "attempt", attempt+1)
+		return ctrl.Result{}, err
+	}
+
+	if pr.Labels == nil {
+		pr.Labels = make(map[string]string)
+	}
+	if pr.Annotations == nil {
+		pr.Annotations = make(map[string]string)
+	}
+	pr.Labels[v1alpha3.PipelineRunAttemptLabelKey] = strconv.Itoa(attempt)
+	pr.Labels[v1alpha3.PipelineRunRetryOfLabelKey] = pr.GetRetryOf()
+	pr.Annotations[v1alpha3.PipelineRunRetriedByAnnoKey] = retry.Name
+	if err := r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+		r.log.Error(err, "unable to update PipelineRun labels and annotations.")
+		return ctrl.Result{}, err
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Retried, "Retried PipelineRun %s/%s by %s, attempt %d of %d",
+		pr.Namespace, pr.Name, retry.Name, attempt+1, policy.MaxAttempts)
+	return ctrl.Result{}, nil
+}
+
+// newRetryPipelineRun returns the next attempt of a PipelineRun, the status and Jenkins related data are not included
+func newRetryPipelineRun(pr *v1alpha3.PipelineRun) *v1alpha3.PipelineRun {
+	attempt := pr.GetAttempt() + 1
+	retryOf := pr.GetRetryOf()
+
+	labels := make(map[string]string, len(pr.Labels)+2)
+	for key, val := range pr.Labels {
+		labels[key] = val
+	}
+	labels[v1alpha3.PipelineRunAttemptLabelKey] = strconv.Itoa(attempt)
+	labels[v1alpha3.PipelineRunRetryOfLabelKey] = retryOf
+
+	annotations := map[string]string{}
+	if creator, ok := pr.Annotations[v1alpha3.PipelineRunCreatorAnnoKey]; ok {
+		annotations[v1alpha3.PipelineRunCreatorAnnoKey] = creator
+	}
+
+	retry := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Name:            fmt.Sprintf("%s-retry-%d", retryOf, attempt),
+			Namespace:       pr.Namespace,
+			OwnerReferences: pr.OwnerReferences,
+			Labels:          labels,
+			Annotations:     annotations,
+		},
+		Spec: *pr.Spec.DeepCopy(),
+	}
+	retry.Spec.Action = nil
+	return retry
+}
+
+func (r *Reconciler) getSyncPeriod() time.Duration {
+	if r.SyncPeriod <= 0 {
+		return DefaultSyncPeriod
+	}
+	return r.SyncPeriod
+}
+
 // match /blue/rest/organizations/jenkins/pipelines/{devops}/{pipeline}/runs/{run}/log/?start=0
 // match /blue/rest/organizations/jenkins/pipelines/%s/pipelines/%s/branches/%s/runs/%s/log/?
 func (r *Reconciler) getAgentInfo(ctx context.Context, pr *v1alpha3.PipelineRun) error {
@@ -358,12 +630,92 @@ func (r *Reconciler) storePipelineRunData(runResultJSON, nodeDetailsJSON string,
 			})
 			err = cmStore.Save()
 		}
+	} else if r.PipelineRunDataStore == "s3" {
+		var objStore storeInter.ObjectStore
+		if objStore, err = s3store.NewS3Store(r.req.NamespacedName, r.S3Client); err == nil {
+			objStore.SetStatus(runResultJSON)
+			objStore.SetStages(nodeDetailsJSON)
+			if err = objStore.Save(); err == nil {
+				err = r.markPipelineRunDataStore(pipelineRunCopied)
+			}
+		}
 	} else {
 		err = fmt.Errorf("unknown pipelineRun data store type: %s", r.PipelineRunDataStore)
 	}
 	return
 }
 
+// storePipelineRunLog keeps the logs of a completed PipelineRun, including the whole log and the step logs.
+// Only the object storage keeps the logs, because the size of a ConfigMap is limited.
+func (r *Reconciler) storePipelineRunLog(jHandler *jenkinsHandler, namespaceName, pipelineName string,
+	pr *v1alpha3.PipelineRun, nodeDetails []pipelinerun.NodeDetail) (err error) {
+	if r.PipelineRunDataStore != "s3" {
+		return
+	}
+
+	var objStore storeInter.ObjectStore
+	if objStore, err = s3store.NewS3Store(client.ObjectKeyFromObject(pr), r.S3Client); err != nil {
+		return
+	}
+
+	var log string
+	if log, err = jHandler.getPipelineRunLog(namespaceName, pipelineName, pr, "", ""); err != nil {
+		return
+	}
+	objStore.SetAllLog(log)
+	for i := range nodeDetails {
+		for j := range nodeDetails[i].Steps {
+			var stepLog string
+			if stepLog, err = jHandler.getPipelineRunLog(namespaceName, pipelineName, pr,
+				nodeDetails[i].ID, nodeDetails[i].Steps[j].ID); err != nil {
+				return
+			}
+			objStore.SetStepLog(i, j, stepLog)
+		}
+	}
+	return objStore.Save()
+}
+
+// markPipelineRunDataStore lets the readers know where the PipelineRun data is
+func (r *Reconciler) markPipelineRunDataStore(pipelineRunCopied *v1alpha3.PipelineRun) error {
+	if pipelineRunCopied.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] == r.PipelineRunDataStore {
+		return nil
+	}
+	if pipelineRunCopied.Annotations == nil {
+		pipelineRunCopied.Annotations = make(map[string]string)
+	}
+	pipelineRunCopied.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] = r.PipelineRunDataStore
+	return r.updateLabelsAndAnnotations(r.ctx, pipelineRunCopied)
+}
+
+// deletePipelineRunData cleans up the PipelineRun data which is not able to be garbage collected by Kubernetes
+func (r *Reconciler) deletePipelineRunData(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	if pipelineRun.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] != "s3" {
+		// the ConfigMap will be deleted along with its owner
+		return
+	}
+	// the finalizer should not block the deletion forever if the data is not reachable anymore,
+	// e.g. the data store was switched away from s3, or the bucket was removed
+	if r.S3Client == nil {
+		r.skipPipelineRunDataCleanup(pipelineRun, "the S3 client is not configured")
+		return
+	}
+	var objStore storeInter.ObjectStore
+	if objStore, err = s3store.NewS3Store(client.ObjectKeyFromObject(pipelineRun), r.S3Client); err == nil {
+		if err = objStore.Delete(); s3store.IsBucketNotFound(err) {
+			r.skipPipelineRunDataCleanup(pipelineRun, "the bucket does not exist")
+			err = nil
+		}
+	}
+	return
+}
+
+func (r *Reconciler) skipPipelineRunDataCleanup(pipelineRun *v1alpha3.PipelineRun, reason string) {
+	r.log.Info("skip cleaning up the PipelineRun data", "PipelineRun", client.ObjectKeyFromObject(pipelineRun), "reason", reason)
+	r.recorder.Eventf(pipelineRun, corev1.EventTypeWarning, v1alpha3.DataCleanupSkipped,
+		"Skipped cleaning up the data of PipelineRun %s/%s from s3, because %s", pipelineRun.Namespace, pipelineRun.Name, reason)
+}
+
 func (r *Reconciler) hasSamePipelineRun(jobRun *job.PipelineRun, pipeline *v1alpha3.Pipeline) (exists bool, err error) {
 	// check if the run ID exists in the PipelineRun
 	pipelineRuns := &v1alpha3.PipelineRunList{}
@@ -460,5 +812,26 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("jenkins_pipelinerun_controller").
 		For(&v1alpha3.PipelineRun{}).
+		WithEventFilter(pipelineRunChangedPredicate()).
 		Complete(r)
 }
+
+// pipelineRunChangedPredicate filters out the updates which only come from the PipelineRun controller itself,
+// like status, so that a running PipelineRun won't be synchronized from Jenkins over and over again.
+func pipelineRunChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			if e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+				!e.ObjectNew.GetDeletionTimestamp().IsZero() {
+				return true
+			}
+			oldAnnotations := e.ObjectOld.GetAnnotations()
+			newAnnotations := e.ObjectNew.GetAnnotations()
+			return oldAnnotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] != newAnnotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] ||
+				oldAnnotations[v1alpha3.JenkinsPipelineRunEventAnnoKey] != newAnnotations[v1alpha3.JenkinsPipelineRunEventAnnoKey]
+		},
+	}
+}
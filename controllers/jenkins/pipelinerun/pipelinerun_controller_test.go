@@ -20,23 +20,30 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
 	"github.com/go-logr/logr"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	ctrlCore "github.com/kubesphere/ks-devops/controllers/core"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha1"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/clientset/versioned/scheme"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	fakes3 "github.com/kubesphere/ks-devops/pkg/client/s3/fake"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	// nolint
@@ -432,4 +439,365 @@ func TestStorePipelineRunData(t *testing.T) {
 		PipelineRunDataStore: "",
 	}
 	assert.Nil(t, r.storePipelineRunData("", "", pipelineRun.DeepCopy()))
+
+	// the s3 client is required
+	r = &Reconciler{
+		Client:               fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
+		log:                  logr.New(log.NullLogSink{}),
+		PipelineRunDataStore: "s3",
+	}
+	assert.NotNil(t, r.storePipelineRunData("", "", pipelineRun.DeepCopy()))
+
+	fakeS3 := fakes3.NewFakeS3()
+	k8sClient := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build()
+	r = &Reconciler{
+		Client: k8sClient,
+		ctx:    context.Background(),
+		log:    logr.New(log.NullLogSink{}),
+		req: ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: "name", Namespace: "ns"},
+		},
+		PipelineRunDataStore: "s3",
+		S3Client:             fakeS3,
+	}
+	assert.Nil(t, r.storePipelineRunData("status", "[]", pipelineRun.DeepCopy()))
+	assert.Len(t, fakeS3.Storage, 2)
+
+	storedPipelineRun := &v1alpha3.PipelineRun{}
+	assert.Nil(t, k8sClient.Get(context.Background(), types.NamespacedName{Name: "name", Namespace: "ns"}, storedPipelineRun))
+	assert.Equal(t, "s3", storedPipelineRun.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey])
+
+	// clean up the data once the PipelineRun is deleted
+	assert.Nil(t, r.deletePipelineRunData(storedPipelineRun))
+	assert.Empty(t, fakeS3.Storage)
+}
+
+// bucketNotFoundS3 is a fake S3 client whose bucket has been removed
+type bucketNotFoundS3 struct {
+	*fakes3.FakeS3
+}
+
+func (s *bucketNotFoundS3) Delete(key string) error {
+	return awserr.New(awss3.ErrCodeNoSuchBucket, "no such bucket", nil)
+}
+
+func TestDeletePipelineRunData(t *testing.T) {
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "name",
+			Annotations: map[string]string{v1alpha3.PipelineRunDataStoreAnnoKey: "s3"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		s3Client  s3.Interface
+		wantErr   bool
+		wantEvent bool
+	}{{
+		name:      "the S3 client is not configured",
+		wantEvent: true,
+	}, {
+		name:      "the bucket does not exist",
+		s3Client:  &bucketNotFoundS3{FakeS3: fakes3.NewFakeS3()},
+		wantEvent: true,
+	}, {
+		name:     "the data is deleted",
+		s3Client: fakes3.NewFakeS3(),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			r := &Reconciler{
+				log:      logr.New(log.NullLogSink{}),
+				recorder: recorder,
+				S3Client: tt.s3Client,
+			}
+			assert.Equal(t, tt.wantErr, r.deletePipelineRunData(pipelineRun) != nil)
+			assert.Equal(t, tt.wantEvent, len(recorder.Events) == 1)
+		})
+	}
+}
+
+func Test_pipelineRunChangedPredicate(t *testing.T) {
+	createPipelineRun := func(generation int64, annotations map[string]string) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "fake",
+				Namespace:   "fake",
+				Generation:  generation,
+				Annotations: annotations,
+			},
+		}
+	}
+	now := metav1.Now()
+	deletingPipelineRun := createPipelineRun(1, nil)
+	deletingPipelineRun.DeletionTimestamp = &now
+
+	tests := []struct {
+		name   string
+		oldObj client.Object
+		newObj client.Object
+		want   bool
+	}{{
+		name:   "only the status changed",
+		oldObj: createPipelineRun(1, nil),
+		newObj: createPipelineRun(1, map[string]string{v1alpha3.JenkinsPipelineRunStatusAnnoKey: "{}"}),
+		want:   false,
+	}, {
+		name:   "the spec changed",
+		oldObj: createPipelineRun(1, nil),
+		newObj: createPipelineRun(2, nil),
+		want:   true,
+	}, {
+		name:   "being deleted",
+		oldObj: createPipelineRun(1, nil),
+		newObj: deletingPipelineRun,
+		want:   true,
+	}, {
+		name:   "the run ID was set",
+		oldObj: createPipelineRun(1, nil),
+		newObj: createPipelineRun(1, map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"}),
+		want:   true,
+	}, {
+		name:   "received an event from Jenkins",
+		oldObj: createPipelineRun(1, map[string]string{v1alpha3.JenkinsPipelineRunEventAnnoKey: "run.started"}),
+		newObj: createPipelineRun(1, map[string]string{v1alpha3.JenkinsPipelineRunEventAnnoKey: "run.completed"}),
+		want:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pipelineRunChangedPredicate()
+			assert.Equal(t, tt.want, p.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}))
+			assert.True(t, p.Create(event.CreateEvent{Object: tt.newObj}))
+		})
+	}
+}
+
+func TestReconciler_handleAction(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(action v1alpha3.Action, paused bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{}
+		pr.SetName("name")
+		pr.SetNamespace("ns")
+		pr.Spec.Action = &action
+		if paused {
+			pr.Status.AddCondition(&v1alpha3.Condition{
+				Type:   v1alpha3.ConditionPaused,
+				Status: v1alpha3.ConditionTrue,
+			})
+		}
+		return pr
+	}
+
+	tests := []struct {
+		name          string
+		pipelineRun   *v1alpha3.PipelineRun
+		wantCompleted bool
+		wantErr       bool
+		wantPhase     v1alpha3.RunPhase
+		wantEvent     string
+	}{{
+		name:          "stop a PipelineRun which has not been triggered",
+		pipelineRun:   newPipelineRun(v1alpha3.Stop, false),
+		wantCompleted: true,
+		wantPhase:     v1alpha3.Cancelled,
+		wantEvent:     v1alpha3.Stopped,
+	}, {
+		name:        "pause a PipelineRun which has not been triggered",
+		pipelineRun: newPipelineRun(v1alpha3.Pause, false),
+	}, {
+		name:        "resume a PipelineRun which is not paused",
+		pipelineRun: newPipelineRun(v1alpha3.Resume, false),
+	}, {
+		name:        "resume a paused PipelineRun without the run ID",
+		pipelineRun: newPipelineRun(v1alpha3.Resume, true),
+		wantErr:     true,
+		wantEvent:   v1alpha3.ActionFailed,
+	}, {
+		name:        "unknown action",
+		pipelineRun: newPipelineRun("fake", false),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pipelineRun.DeepCopy()).WithStatusSubresource(tt.pipelineRun.DeepCopy()).Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: recorder,
+			}
+			completed, err := r.handleAction(context.Background(), &jenkinsHandler{&r.JenkinsCore}, tt.pipelineRun)
+			assert.Equal(t, tt.wantCompleted, completed)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			stored := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(tt.pipelineRun), stored))
+			assert.Equal(t, tt.wantPhase, stored.Status.Phase)
+			if tt.wantCompleted {
+				assert.True(t, stored.HasCompleted())
+			}
+
+			if tt.wantEvent == "" {
+				assert.Empty(t, recorder.Events)
+			} else {
+				assert.Contains(t, <-recorder.Events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestReconciler_retryPipelineRun(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	completedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+	newPipelineRun := func(phase v1alpha3.RunPhase, policy *v1alpha3.RetryPolicy) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{}
+		pr.SetName("name")
+		pr.SetNamespace("ns")
+		pr.SetLabels(map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"})
+		pr.SetAnnotations(map[string]string{
+			v1alpha3.JenkinsPipelineRunIDAnnoKey: "1",
+			v1alpha3.PipelineRunCreatorAnnoKey:   "admin",
+		})
+		pr.Spec.PipelineRef = &v1.ObjectReference{Name: "pipeline"}
+		pr.Spec.RetryPolicy = policy
+		pr.Status.Phase = phase
+		pr.Status.CompletionTime = &completedAt
+		return pr
+	}
+
+	tests := []struct {
+		name         string
+		pipelineRun  *v1alpha3.PipelineRun
+		wantRetry    bool
+		wantRequeued bool
+	}{{
+		name:        "no retry policy",
+		pipelineRun: newPipelineRun(v1alpha3.Failed, nil),
+	}, {
+		name:        "succeeded",
+		pipelineRun: newPipelineRun(v1alpha3.Succeeded, &v1alpha3.RetryPolicy{MaxAttempts: 2}),
+	}, {
+		name: "has been retried",
+		pipelineRun: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun(v1alpha3.Failed, &v1alpha3.RetryPolicy{MaxAttempts: 2})
+			pr.Annotations[v1alpha3.PipelineRunRetriedByAnnoKey] = "name-retry-2"
+			return pr
+		}(),
+	}, {
+		name: "wait for the backoff",
+		pipelineRun: newPipelineRun(v1alpha3.Failed, &v1alpha3.RetryPolicy{
+			MaxAttempts: 2,
+			Backoff:     &metav1.Duration{Duration: time.Hour},
+		}),
+		wantRequeued: true,
+	}, {
+		name:        "failed",
+		pipelineRun: newPipelineRun(v1alpha3.Failed, &v1alpha3.RetryPolicy{MaxAttempts: 2}),
+		wantRetry:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &Reconciler{
+				Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pipelineRun.DeepCopy()).Build(),
+				log:      logr.New(log.NullLogSink{}),
+				recorder: recorder,
+			}
+			result, err := r.retryPipelineRun(context.Background(), tt.pipelineRun.DeepCopy())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequeued, result.RequeueAfter > 0)
+
+			retry := &v1alpha3.PipelineRun{}
+			err = r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "name-retry-2"}, retry)
+			if !tt.wantRetry {
+				assert.True(t, apierrors.IsNotFound(err))
+				assert.Empty(t, recorder.Events)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, 2, retry.GetAttempt())
+			assert.Equal(t, "name", retry.GetRetryOf())
+			assert.Equal(t, "pipeline", retry.Labels[v1alpha3.PipelineNameLabelKey])
+			assert.Equal(t, "admin", retry.Annotations[v1alpha3.PipelineRunCreatorAnnoKey])
+			assert.False(t, retry.HasStarted())
+			assert.False(t, retry.HasCompleted())
+			assert.Equal(t, tt.pipelineRun.Spec.RetryPolicy, retry.Spec.RetryPolicy)
+
+			completed := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "name"}, completed))
+			assert.Equal(t, "name-retry-2", completed.Annotations[v1alpha3.PipelineRunRetriedByAnnoKey])
+			assert.Equal(t, 1, completed.GetAttempt())
+			assert.Equal(t, "name", completed.Labels[v1alpha3.PipelineRunRetryOfLabelKey])
+			assert.Contains(t, <-recorder.Events, v1alpha3.Retried)
+
+			// the next attempt won't be created again
+			_, err = r.retryPipelineRun(context.Background(), completed)
+			assert.Nil(t, err)
+			assert.Empty(t, recorder.Events)
+		})
+	}
+}
+
+func Test_getTimeoutRemaining(t *testing.T) {
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	pr := &v1alpha3.PipelineRun{}
+	_, ok := getTimeoutRemaining(pr)
+	assert.False(t, ok, "no timeout")
+
+	pr.Spec.Timeout = &metav1.Duration{Duration: time.Hour}
+	_, ok = getTimeoutRemaining(pr)
+	assert.False(t, ok, "not started")
+
+	pr.Status.StartTime = &startTime
+	remaining, ok := getTimeoutRemaining(pr)
+	assert.True(t, ok)
+	assert.True(t, remaining > 58*time.Minute && remaining <= 59*time.Minute)
+
+	pr.Spec.Timeout = &metav1.Duration{Duration: time.Second}
+	remaining, ok = getTimeoutRemaining(pr)
+	assert.True(t, ok)
+	assert.True(t, remaining < 0)
+}
+
+func TestReconciler_handleTimeout(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	startTime := metav1.NewTime(time.Now().Add(-time.Hour))
+	pr := &v1alpha3.PipelineRun{}
+	pr.SetName("name")
+	pr.SetNamespace("ns")
+	pr.Spec.Timeout = &metav1.Duration{Duration: time.Minute}
+	pr.Status.StartTime = &startTime
+	pr.Status.Phase = v1alpha3.Running
+
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy()).WithStatusSubresource(pr.DeepCopy()).Build(),
+		log:      logr.New(log.NullLogSink{}),
+		recorder: recorder,
+	}
+	assert.Nil(t, r.handleTimeout(context.Background(), &jenkinsHandler{&r.JenkinsCore}, pr))
+
+	stored := &v1alpha3.PipelineRun{}
+	assert.Nil(t, r.Get(context.Background(), client.ObjectKeyFromObject(pr), stored))
+	assert.Equal(t, v1alpha3.Failed, stored.Status.Phase)
+	assert.True(t, stored.HasCompleted())
+	condition := stored.Status.GetLatestCondition()
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, v1alpha3.ConditionSucceeded, condition.Type)
+		assert.Equal(t, v1alpha3.ConditionFalse, condition.Status)
+		assert.Equal(t, v1alpha3.TimedOut, condition.Reason)
+	}
+	assert.Contains(t, <-recorder.Events, v1alpha3.TimedOut)
 }
@@ -0,0 +1,210 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// PipelineRunPruned is the event reason of pruning PipelineRuns
+const PipelineRunPruned = "PipelineRunPruned"
+
+// GCReconciler prunes the completed PipelineRuns of a Pipeline according to its discarder.
+// The Jenkins build record of a pruned PipelineRun is deleted as well, unless it has the keep-jenkins-record annotation.
+type GCReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+	// DaysToKeep is the default days to keep the PipelineRuns of a Pipeline without discarder, no limit if it's not positive
+	DaysToKeep int
+	// NumToKeep is the default number of the PipelineRuns to keep for a Pipeline without discarder, no limit if it's not positive
+	NumToKeep int
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;delete
+
+// Reconcile prunes the expired PipelineRuns of a Pipeline
+func (r *GCReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("Pipeline", req.NamespacedName)
+	pipeline := &v1alpha3.Pipeline{}
+	if err := r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	retention := r.getRetention(pipeline)
+	if !retention.enabled() {
+		return ctrl.Result{}, nil
+	}
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	expired, requeueAfter := retention.prune(pipelineRuns.Items, time.Now())
+	for _, pr := range expired {
+		if err := r.Delete(ctx, pr); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to prune PipelineRun", "PipelineRun", pr.Name)
+			return ctrl.Result{}, err
+		}
+		r.recorder.Eventf(pipeline, corev1.EventTypeNormal, PipelineRunPruned, "Pruned PipelineRun %s/%s", pr.Namespace, pr.Name)
+	}
+	if len(expired) > 0 {
+		log.V(4).Info("pruned PipelineRuns", "count", len(expired))
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// getRetention returns the retention from the discarder of a Pipeline, or the default one if there is no discarder
+func (r *GCReconciler) getRetention(pipeline *v1alpha3.Pipeline) pipelineRunRetention {
+	var discarder *v1alpha3.DiscarderProperty
+	switch pipeline.Spec.Type {
+	case v1alpha3.NoScmPipelineType:
+		if pipeline.Spec.Pipeline != nil {
+			discarder = pipeline.Spec.Pipeline.Discarder
+		}
+	case v1alpha3.MultiBranchPipelineType:
+		if pipeline.Spec.MultiBranchPipeline != nil {
+			discarder = pipeline.Spec.MultiBranchPipeline.Discarder
+		}
+	}
+
+	if discarder == nil {
+		return pipelineRunRetention{daysToKeep: r.DaysToKeep, numToKeep: r.NumToKeep}
+	}
+	// the same as Jenkins, an empty or invalid value means no limit
+	retention := pipelineRunRetention{}
+	retention.daysToKeep, _ = strconv.Atoi(discarder.DaysToKeep)
+	retention.numToKeep, _ = strconv.Atoi(discarder.NumToKeep)
+	return retention
+}
+
+// pipelineRunRetention decides which PipelineRuns should be pruned, there is no limit if a field is not positive
+type pipelineRunRetention struct {
+	daysToKeep int
+	numToKeep  int
+}
+
+func (retention pipelineRunRetention) enabled() bool {
+	return retention.daysToKeep > 0 || retention.numToKeep > 0
+}
+
+// prune returns the expired PipelineRuns, and the duration after which the next one expires.
+// Only the completed PipelineRuns are counted, and the pinned ones are always kept.
+// The PipelineRuns of a multi-branch Pipeline are counted per branch, the same as Jenkins.
+func (retention pipelineRunRetention) prune(pipelineRuns []v1alpha3.PipelineRun, now time.Time) (
+	expired []*v1alpha3.PipelineRun, requeueAfter time.Duration) {
+	groups := map[string][]*v1alpha3.PipelineRun{}
+	for i := range pipelineRuns {
+		pr := &pipelineRuns[i]
+		if !pr.HasCompleted() || pr.IsPinned() || !pr.DeletionTimestamp.IsZero() {
+			continue
+		}
+		groups[pr.GetRefName()] = append(groups[pr.GetRefName()], pr)
+	}
+
+	for _, group := range groups {
+		// the latest one comes first
+		sort.Slice(group, func(i, j int) bool {
+			return isCreatedEarlier(group[j], group[i])
+		})
+
+		for i, pr := range group {
+			if retention.numToKeep > 0 && i >= retention.numToKeep {
+				expired = append(expired, pr)
+				continue
+			}
+			if retention.daysToKeep <= 0 {
+				continue
+			}
+
+			expireAfter := pr.Status.CompletionTime.Add(time.Duration(retention.daysToKeep) * 24 * time.Hour).Sub(now)
+			if expireAfter <= 0 {
+				expired = append(expired, pr)
+			} else if requeueAfter == 0 || expireAfter < requeueAfter {
+				requeueAfter = expireAfter
+			}
+		}
+	}
+	return
+}
+
+// SetupWithManager setups the reconciler with a manager
+func (r *GCReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelinerun-gc")
+	r.log = ctrl.Log.WithName("pipelinerun-gc")
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipelinerun_gc").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha3.PipelineRun{}, handler.EnqueueRequestsFromMapFunc(mapPipelineRunToPipeline),
+			builder.WithPredicates(pipelineRunPrunablePredicate())).
+		Complete(r)
+}
+
+func mapPipelineRunToPipeline(_ context.Context, obj client.Object) []reconcile.Request {
+	pipelineName := obj.GetLabels()[v1alpha3.PipelineNameLabelKey]
+	if pipelineName == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: pipelineName},
+	}}
+}
+
+// pipelineRunPrunablePredicate only accepts the PipelineRuns which just became prunable,
+// such as just completed or unpinned
+func pipelineRunPrunablePredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPipelineRun, okOld := e.ObjectOld.(*v1alpha3.PipelineRun)
+			newPipelineRun, okNew := e.ObjectNew.(*v1alpha3.PipelineRun)
+			if !okOld || !okNew {
+				return false
+			}
+			return (!oldPipelineRun.HasCompleted() && newPipelineRun.HasCompleted()) ||
+				(oldPipelineRun.IsPinned() && !newPipelineRun.IsPinned())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func newCompletedPipelineRun(name string, created, completed time.Time) *v1alpha3.PipelineRun {
+	completionTime := metav1.NewTime(completed)
+	return &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "ns",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Status: v1alpha3.PipelineRunStatus{CompletionTime: &completionTime},
+	}
+}
+
+func Test_pipelineRunRetention_prune(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+
+	running := newCompletedPipelineRun("running", now.Add(-10*day), now)
+	running.Status.CompletionTime = nil
+	pinned := newCompletedPipelineRun("pinned", now.Add(-10*day), now.Add(-10*day))
+	pinned.Annotations = map[string]string{v1alpha3.PipelineRunPinnedAnnoKey: "true"}
+	branchRun := newCompletedPipelineRun("branch", now.Add(-5*day), now.Add(-5*day))
+	branchRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+	branchRun.Spec.SCM = &v1alpha3.SCM{RefName: "dev"}
+
+	pipelineRuns := []v1alpha3.PipelineRun{
+		*newCompletedPipelineRun("old", now.Add(-3*day), now.Add(-3*day)),
+		*newCompletedPipelineRun("new", now.Add(-time.Hour), now.Add(-time.Hour)),
+		*newCompletedPipelineRun("middle", now.Add(-day-time.Hour), now.Add(-day-time.Hour)),
+		*running, *pinned, *branchRun,
+	}
+
+	tests := []struct {
+		name         string
+		retention    pipelineRunRetention
+		wantExpired  []string
+		requeueAfter time.Duration
+	}{{
+		name:      "number to keep",
+		retention: pipelineRunRetention{numToKeep: 2},
+		// the multi-branch PipelineRun is counted in its own branch
+		wantExpired: []string{"old"},
+	}, {
+		name:         "days to keep",
+		retention:    pipelineRunRetention{daysToKeep: 2},
+		wantExpired:  []string{"old", "branch"},
+		requeueAfter: day - time.Hour,
+	}, {
+		name:         "both days and number",
+		retention:    pipelineRunRetention{daysToKeep: 2, numToKeep: 1},
+		wantExpired:  []string{"middle", "old", "branch"},
+		requeueAfter: 2*day - time.Hour,
+	}, {
+		name:      "no limit",
+		retention: pipelineRunRetention{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired, requeueAfter := tt.retention.prune(pipelineRuns, now)
+			names := make([]string, 0, len(expired))
+			for _, pr := range expired {
+				names = append(names, pr.Name)
+			}
+			assert.ElementsMatch(t, tt.wantExpired, names)
+			assert.Equal(t, tt.requeueAfter, requeueAfter)
+		})
+	}
+}
+
+func TestGCReconciler_getRetention(t *testing.T) {
+	r := &GCReconciler{DaysToKeep: 7, NumToKeep: 10}
+
+	tests := []struct {
+		name     string
+		pipeline *v1alpha3.Pipeline
+		want     pipelineRunRetention
+	}{{
+		name:     "without discarder",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType, Pipeline: &v1alpha3.NoScmPipeline{}}},
+		want:     pipelineRunRetention{daysToKeep: 7, numToKeep: 10},
+	}, {
+		name: "discarder of a regular Pipeline",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Discarder: &v1alpha3.DiscarderProperty{DaysToKeep: "3", NumToKeep: "-1"}},
+		}},
+		want: pipelineRunRetention{daysToKeep: 3, numToKeep: -1},
+	}, {
+		name: "discarder of a multi-branch Pipeline",
+		pipeline: &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{
+			Type:                v1alpha3.MultiBranchPipelineType,
+			MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{Discarder: &v1alpha3.DiscarderProperty{NumToKeep: "5"}},
+		}},
+		want: pipelineRunRetention{numToKeep: 5},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.getRetention(tt.pipeline))
+		})
+	}
+}
+
+func TestGCReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := time.Now()
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "ns"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Discarder: &v1alpha3.DiscarderProperty{NumToKeep: "1"}},
+		},
+	}
+	oldRun := newCompletedPipelineRun("old", now.Add(-time.Hour), now.Add(-time.Hour))
+	newRun := newCompletedPipelineRun("new", now, now)
+	otherRun := newCompletedPipelineRun("other", now.Add(-time.Hour), now.Add(-time.Hour))
+	otherRun.Labels[v1alpha3.PipelineNameLabelKey] = "other"
+
+	recorder := record.NewFakeRecorder(10)
+	r := &GCReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline, oldRun, newRun, otherRun).Build(),
+		log:      logr.New(log.NullLogSink{}),
+		recorder: recorder,
+	}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: "ns", Name: "pipeline"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, ctrl.Result{}, result)
+
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	assert.Nil(t, r.List(context.Background(), pipelineRuns))
+	names := make([]string, 0, len(pipelineRuns.Items))
+	for _, pr := range pipelineRuns.Items {
+		names = append(names, pr.Name)
+	}
+	assert.ElementsMatch(t, []string{"new", "other"}, names)
+	assert.Equal(t, v1.EventTypeNormal+" "+PipelineRunPruned+" Pruned PipelineRun ns/old", <-recorder.Events)
+
+	// the Pipeline does not exist
+	_, err = r.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: "ns", Name: "fake"},
+	})
+	assert.Nil(t, err)
+}
+
+func Test_mapPipelineRunToPipeline(t *testing.T) {
+	pr := newCompletedPipelineRun("pr", time.Now(), time.Now())
+	requests := mapPipelineRunToPipeline(context.Background(), pr)
+	assert.Equal(t, 1, len(requests))
+	assert.Equal(t, types.NamespacedName{Namespace: "ns", Name: "pipeline"}, requests[0].NamespacedName)
+
+	pr.Labels = nil
+	assert.Nil(t, mapPipelineRunToPipeline(context.Background(), pr))
+}
@@ -17,13 +17,22 @@ limitations under the License.
 package pipelinerun
 
 import (
+	"strings"
 	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// maxLogExcerptLines is the max number of lines of the log excerpt of the failed step
+	maxLogExcerptLines = 30
+	// maxLogExcerptSize is the max size of the log excerpt of the failed step
+	maxLogExcerptSize = 4096
+)
+
 // JenkinsRunState represents current PipelineRun state.
 type JenkinsRunState string
 
@@ -161,3 +170,112 @@ func (converter parameterConverter) convert() []job.Parameter {
 	}
 	return params
 }
+
+// maxSummaryArtifacts is the max number of the artifacts in the summary of a PipelineRun
+const maxSummaryArtifacts = 100
+
+// pipelineRunSummaryBuilder builds the structured summary of a PipelineRun from the Jenkins data.
+type pipelineRunSummaryBuilder struct {
+	pipelineBuild *job.PipelineRun
+	nodeDetails   []pipelinerun.NodeDetail
+	artifacts     []job.BlueArtifact
+	testSummary   *blueTestSummary
+}
+
+func (builder pipelineRunSummaryBuilder) build() *v1alpha3.PipelineRunSummary {
+	summary := &v1alpha3.PipelineRunSummary{}
+	if builder.pipelineBuild != nil {
+		summary.CommitSHA = builder.pipelineBuild.CommitID
+	}
+
+	for i := range builder.nodeDetails {
+		node := &builder.nodeDetails[i]
+		stage := v1alpha3.StageSummary{
+			ID:    node.ID,
+			Name:  node.DisplayName,
+			Phase: getStagePhase(node.State, node.Result),
+		}
+		if node.DurationInMillis > 0 {
+			stage.Duration = &v1.Duration{Duration: time.Duration(node.DurationInMillis) * time.Millisecond}
+		}
+		summary.Stages = append(summary.Stages, stage)
+
+		if summary.FailedStep != nil || stage.Phase != v1alpha3.Failed {
+			continue
+		}
+		for j := range node.Steps {
+			step := &node.Steps[j]
+			if step.Result == Failure.String() {
+				summary.FailedStep = &v1alpha3.FailedStep{
+					StageID:     node.ID,
+					Stage:       node.DisplayName,
+					StepID:      step.ID,
+					Step:        step.DisplayName,
+					Description: step.DisplayDescription,
+				}
+				break
+			}
+		}
+	}
+
+	// a build might archive lots of files, only the first ones are kept to limit the size of the PipelineRun
+	summary.ArtifactCount = len(builder.artifacts)
+	for _, artifact := range builder.artifacts {
+		if len(summary.Artifacts) >= maxSummaryArtifacts {
+			break
+		}
+		summary.Artifacts = append(summary.Artifacts, v1alpha3.Artifact{
+			Name: artifact.Name,
+			Path: artifact.Path,
+			Size: artifact.Size,
+			URL:  artifact.URL,
+		})
+	}
+
+	if builder.testSummary != nil && builder.testSummary.Total > 0 {
+		summary.Tests = &v1alpha3.TestSummary{
+			Total:   builder.testSummary.Total,
+			Passed:  builder.testSummary.Passed,
+			Failed:  builder.testSummary.Failed,
+			Skipped: builder.testSummary.Skipped,
+		}
+	}
+	return summary
+}
+
+// getLogExcerpt returns the last lines of a log, it's limited by both the number of lines and the size
+func getLogExcerpt(log string) string {
+	lines := strings.Split(strings.TrimRight(log, "\n"), "\n")
+	if len(lines) > maxLogExcerptLines {
+		lines = lines[len(lines)-maxLogExcerptLines:]
+	}
+	excerpt := strings.Join(lines, "\n")
+	if len(excerpt) > maxLogExcerptSize {
+		excerpt = excerpt[len(excerpt)-maxLogExcerptSize:]
+		// drop the incomplete line
+		if index := strings.Index(excerpt, "\n"); index >= 0 {
+			excerpt = excerpt[index+1:]
+		}
+	}
+	return excerpt
+}
+
+// getStagePhase returns the phase of a stage according to its state and result in Jenkins
+func getStagePhase(state, result string) v1alpha3.RunPhase {
+	switch state {
+	case "", Queued.String(), Paused.String(), NotBuiltState.String():
+		return v1alpha3.Pending
+	case Running.String():
+		return v1alpha3.Running
+	case Skipped.String():
+		return v1alpha3.Succeeded
+	case Finished.String():
+		switch result {
+		case Success.String():
+			return v1alpha3.Succeeded
+		case Unstable.String(), Failure.String(), Aborted.String():
+			return v1alpha3.Failed
+		}
+	}
+	return v1alpha3.Unknown
+}
@@ -17,12 +17,15 @@ limitations under the License.
 package pipelinerun
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -365,3 +368,156 @@ func Test_parameterConverter_convert(t *testing.T) {
 		})
 	}
 }
+
+func Test_pipelineRunSummaryBuilder_build(t *testing.T) {
+	nodeDetails := []pipelinerun.NodeDetail{{
+		Node: job.Node{
+			ID:               "1",
+			DisplayName:      "build",
+			State:            Finished.String(),
+			Result:           Success.String(),
+			DurationInMillis: 1500,
+		},
+	}, {
+		Node: job.Node{
+			ID:          "2",
+			DisplayName: "test",
+			State:       Finished.String(),
+			Result:      Failure.String(),
+		},
+		Steps: []pipelinerun.Step{{
+			Step: job.Step{ID: "3", DisplayName: "Print Message", Result: Success.String()},
+		}, {
+			Step: job.Step{ID: "4", DisplayName: "Shell Script", DisplayDescription: "make test", Result: Failure.String()},
+		}},
+	}, {
+		Node: job.Node{
+			ID:          "5",
+			DisplayName: "deploy",
+			State:       Skipped.String(),
+			Result:      NotBuiltResult.String(),
+		},
+	}}
+
+	var manyArtifacts []job.BlueArtifact
+	var wantArtifacts []v1alpha3.Artifact
+	for i := 0; i < maxSummaryArtifacts+10; i++ {
+		manyArtifacts = append(manyArtifacts, job.BlueArtifact{Name: fmt.Sprintf("%d.log", i)})
+		if i < maxSummaryArtifacts {
+			wantArtifacts = append(wantArtifacts, v1alpha3.Artifact{Name: fmt.Sprintf("%d.log", i)})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		builder pipelineRunSummaryBuilder
+		want    *v1alpha3.PipelineRunSummary
+	}{{
+		name:    "without any data",
+		builder: pipelineRunSummaryBuilder{},
+		want:    &v1alpha3.PipelineRunSummary{},
+	}, {
+		name: "with all the data",
+		builder: pipelineRunSummaryBuilder{
+			pipelineBuild: &job.PipelineRun{CommitID: "a1b2c3"},
+			nodeDetails:   nodeDetails,
+			artifacts:     []job.BlueArtifact{{Name: "app.jar", Path: "target/app.jar", Size: 1024, URL: "/artifact/target/app.jar"}},
+			testSummary:   &blueTestSummary{Total: 10, Passed: 8, Failed: 1, Skipped: 1},
+		},
+		want: &v1alpha3.PipelineRunSummary{
+			CommitSHA: "a1b2c3",
+			Stages: []v1alpha3.StageSummary{
+				{ID: "1", Name: "build", Phase: v1alpha3.Succeeded, Duration: &v1.Duration{Duration: 1500 * time.Millisecond}},
+				{ID: "2", Name: "test", Phase: v1alpha3.Failed},
+				{ID: "5", Name: "deploy", Phase: v1alpha3.Succeeded},
+			},
+			FailedStep: &v1alpha3.FailedStep{
+				StageID:     "2",
+				Stage:       "test",
+				StepID:      "4",
+				Step:        "Shell Script",
+				Description: "make test",
+			},
+			Tests:         &v1alpha3.TestSummary{Total: 10, Passed: 8, Failed: 1, Skipped: 1},
+			Artifacts:     []v1alpha3.Artifact{{Name: "app.jar", Path: "target/app.jar", Size: 1024, URL: "/artifact/target/app.jar"}},
+			ArtifactCount: 1,
+		},
+	}, {
+		name: "too many artifacts",
+		builder: pipelineRunSummaryBuilder{
+			artifacts: manyArtifacts,
+		},
+		want: &v1alpha3.PipelineRunSummary{
+			Artifacts:     wantArtifacts,
+			ArtifactCount: maxSummaryArtifacts + 10,
+		},
+	}, {
+		name: "without any test reports",
+		builder: pipelineRunSummaryBuilder{
+			testSummary: &blueTestSummary{},
+		},
+		want: &v1alpha3.PipelineRunSummary{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.builder.build())
+		})
+	}
+}
+
+func Test_getLogExcerpt(t *testing.T) {
+	var lines []string
+	for i := 1; i <= maxLogExcerptLines+10; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	longLine := strings.Repeat("a", maxLogExcerptSize)
+
+	tests := []struct {
+		name string
+		log  string
+		want string
+	}{{
+		name: "empty log",
+		log:  "",
+		want: "",
+	}, {
+		name: "short log",
+		log:  "+ make test\nFAIL\n",
+		want: "+ make test\nFAIL",
+	}, {
+		name: "too many lines",
+		log:  strings.Join(lines, "\n"),
+		want: strings.Join(lines[10:], "\n"),
+	}, {
+		name: "too large",
+		log:  longLine + "\nFAIL",
+		want: "FAIL",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getLogExcerpt(tt.log))
+		})
+	}
+}
+
+func Test_getStagePhase(t *testing.T) {
+	tests := []struct {
+		state  string
+		result string
+		want   v1alpha3.RunPhase
+	}{
+		{state: "", want: v1alpha3.Pending},
+		{state: Queued.String(), want: v1alpha3.Pending},
+		{state: Running.String(), result: Unknown.String(), want: v1alpha3.Running},
+		{state: Skipped.String(), result: NotBuiltResult.String(), want: v1alpha3.Succeeded},
+		{state: Finished.String(), result: Success.String(), want: v1alpha3.Succeeded},
+		{state: Finished.String(), result: Unstable.String(), want: v1alpha3.Failed},
+		{state: Finished.String(), result: Aborted.String(), want: v1alpha3.Failed},
+		{state: Finished.String(), result: Unknown.String(), want: v1alpha3.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.state+"/"+tt.result, func(t *testing.T) {
+			assert.Equal(t, tt.want, getStagePhase(tt.state, tt.result))
+		})
+	}
+}
@@ -40,6 +40,27 @@ const (
 	PipelineRunSCMRefNameField = "spec.scm.ref-name"
 	// PipelineRunIdentifierIndexerName is an indexer name of PipelineRun identifier.
 	PipelineRunIdentifierIndexerName = "pipelinerun.identifier"
+	// JenkinsPipelineRunEventAnnoKey is annotation key of the latest Jenkins event received for a PipelineRun.
+	// Any change of its value requests the PipelineRun controller to synchronize running data from Jenkins.
+	JenkinsPipelineRunEventAnnoKey = devops.GroupName + "/jenkins-pipelinerun-event"
+	// PipelineRunDataStoreAnnoKey is annotation key of the data store type which keeps the PipelineRun data.
+	PipelineRunDataStoreAnnoKey = devops.GroupName + "/pipelinerun-data-store"
+	// PipelineRunAttemptLabelKey is label key of the attempt number of a PipelineRun, the first run is 1.
+	PipelineRunAttemptLabelKey = devops.GroupName + "/pipelinerun-attempt"
+	// PipelineRunRetryOfLabelKey is label key of the name of the first PipelineRun of all the attempts.
+	PipelineRunRetryOfLabelKey = devops.GroupName + "/pipelinerun-retry-of"
+	// PipelineRunRetriedByAnnoKey is annotation key of the name of the PipelineRun which retries the current one.
+	PipelineRunRetriedByAnnoKey = devops.GroupName + "/pipelinerun-retried-by"
+	// PipelineRunConcurrencyGroupLabelKey is label key of the concurrency group of a PipelineRun.
+	// The value is hashed if the group key is not a valid label value.
+	PipelineRunConcurrencyGroupLabelKey = devops.GroupName + "/concurrency-group"
+	// PipelineRunRerunOfAnnoKey is annotation key of the name of the PipelineRun which is re-run by the current one.
+	PipelineRunRerunOfAnnoKey = devops.GroupName + "/pipelinerun-rerun-of"
+	// PipelineRunRerunFromStageAnnoKey is annotation key of the stage ID which the re-run starts from.
+	// The Jenkins build of the original PipelineRun will be restarted from this stage instead of building from scratch.
+	PipelineRunRerunFromStageAnnoKey = devops.GroupName + "/pipelinerun-rerun-from-stage"
+	// PipelineRunCommitAnnoKey is annotation key of the commit SHA which triggers a PipelineRun, it's set by the SCM webhook.
+	PipelineRunCommitAnnoKey = devops.GroupName + "/pipelinerun-commit"
 
 	JenkinsAgentPodNameAnnoKey  = devops.GroupName + "/agent-pod-name"
 	JenkinsAgentNodeNameAnnoKey = devops.GroupName + "/agent-node-name"
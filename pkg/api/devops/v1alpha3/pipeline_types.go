@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,6 +29,9 @@ import (
 
 const PipelineFinalizerName = "pipeline.finalizers.kubesphere.io"
 
+// PipelineWebhookFinalizerName is the finalizer of a multi-branch Pipeline whose repository webhook is registered automatically
+const PipelineWebhookFinalizerName = "webhook.finalizers.kubesphere.io"
+
 const (
 	ResourceKindPipeline      = "Pipeline"
 	ResourcePluralPipeline    = "pipelines"
@@ -49,6 +53,12 @@ const (
 	PipelineJenkinsfileEditModeAnnoKey = PipelinePrefix + "jenkinsfile.edit.mode"
 	// PipelineJenkinsfileValidateAnnoKey is the annotation key of the Jenkinsfile validate, success or failure
 	PipelineJenkinsfileValidateAnnoKey = PipelinePrefix + "jenkinsfile.validate"
+	// PipelineWebhookAnnoKey is the annotation key of the Git URL whose webhook is registered automatically for a multi-branch Pipeline
+	PipelineWebhookAnnoKey = PipelinePrefix + "webhook"
+	// PipelineWebhookRegistrationAnnoKey is the annotation key of the webhook registered automatically, including its ID
+	PipelineWebhookRegistrationAnnoKey = PipelinePrefix + "webhook-registration"
+	// PipelineWebhookSecretAnnoKey is the annotation key of the secret name which signs the webhook registered automatically
+	PipelineWebhookSecretAnnoKey = PipelinePrefix + "webhook-secret"
 
 	// PipelineJenkinsfileEditModeJSON indicates the Jenkinsfile editing mode is JSON
 	PipelineJenkinsfileEditModeJSON = "json"
@@ -66,6 +76,9 @@ type PipelineSpec struct {
 	Type                PipelineType         `json:"type" description:"type of devops pipeline, in scm or no scm"`
 	Pipeline            *NoScmPipeline       `json:"pipeline,omitempty" description:"no scm pipeline structs"`
 	MultiBranchPipeline *MultiBranchPipeline `json:"multi_branch_pipeline,omitempty" description:"in scm pipeline structs"`
+	RetryPolicy         *RetryPolicy         `json:"retry_policy,omitempty" description:"the default retry policy of the runs"`
+	Timeout             *metav1.Duration     `json:"timeout,omitempty" description:"the default timeout of the runs"`
+	Concurrency         *Concurrency         `json:"concurrency,omitempty" description:"the default concurrency of the runs"`
 }
 
 // PipelineStatus defines the observed state of Pipeline
@@ -122,6 +135,7 @@ const (
 	SourceTypeGitlab    = "gitlab"
 	SourceTypeGithub    = "github"
 	SourceTypeBitbucket = "bitbucket_server"
+	SourceTypeGitea     = "gitea"
 )
 
 type NoScmPipeline struct {
@@ -148,6 +162,7 @@ type MultiBranchPipeline struct {
 	SvnSource             *SvnSource             `json:"svn_source,omitempty" description:"multi branch svn scm define"`
 	SingleSvnSource       *SingleSvnSource       `json:"single_svn_source,omitempty" description:"single branch svn scm define"`
 	BitbucketServerSource *BitbucketServerSource `json:"bitbucket_server_source,omitempty" description:"bitbucket server scm defile"`
+	GiteaSource           *GiteaSource           `json:"gitea_source,omitempty" description:"gitea scm define, Forgejo is supported as well"`
 	ScriptPath            string                 `json:"script_path" mapstructure:"script_path" description:"script path in scm"`
 	MultiBranchJobTrigger *MultiBranchJobTrigger `json:"multibranch_job_trigger,omitempty" mapstructure:"multibranch_job_trigger" description:"Pipeline tasks that need to be triggered when branch creation/deletion"`
 }
@@ -170,10 +185,54 @@ func (b *MultiBranchPipeline) GetGitURL() string {
 		if b.BitbucketServerSource != nil {
 			return fmt.Sprintf("https://bitbucket.org/%s/%s", b.BitbucketServerSource.Owner, b.BitbucketServerSource.Repo)
 		}
+	case SourceTypeGitea:
+		if b.GiteaSource != nil {
+			return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(b.GiteaSource.ServerUrl, "/"), b.GiteaSource.Owner, b.GiteaSource.Repo)
+		}
 	}
 	return ""
 }
 
+// GetSCMProvider returns the go-scm driver and the server of the source, the server is empty for github.com,
+// gitlab.com and bitbucket.org
+func (b *MultiBranchPipeline) GetSCMProvider() (provider, server string) {
+	switch b.SourceType {
+	case SourceTypeGithub:
+		if b.GitHubSource != nil {
+			provider, server = "github", getSelfHostedServer(b.GitHubSource.ApiUri, "api.github.com", "github.com")
+		}
+	case SourceTypeGitlab:
+		if b.GitlabSource != nil {
+			provider, server = "gitlab", getSelfHostedServer(b.GitlabSource.ApiUri, "gitlab.com")
+		}
+	case SourceTypeBitbucket:
+		if b.BitbucketServerSource != nil {
+			provider = "bitbucketcloud"
+			if server = getSelfHostedServer(b.BitbucketServerSource.ApiUri, "api.bitbucket.org", "bitbucket.org"); server != "" {
+				provider = "bitbucketserver"
+			}
+		}
+	case SourceTypeGitea:
+		if b.GiteaSource != nil {
+			provider, server = "gitea", strings.TrimSuffix(b.GiteaSource.ServerUrl, "/")
+		}
+	}
+	return
+}
+
+// getSelfHostedServer returns the server without the trailing slash, or empty if it's one of the SaaS hosts
+func getSelfHostedServer(server string, saasHosts ...string) string {
+	server = strings.TrimSuffix(server, "/")
+	if u, err := url.Parse(server); err == nil {
+		for _, host := range saasHosts {
+			if u.Host == host {
+				return ""
+			}
+		}
+	}
+	return server
+}
+
 type GitSource struct {
 	ScmId            string          `json:"scm_id,omitempty" description:"uid of scm"`
 	Url              string          `json:"url,omitempty" mapstructure:"url" description:"url of git source"`
@@ -243,6 +302,21 @@ type BitbucketServerSource struct {
 	AcceptJenkinsNotification bool                 `json:"accept_jenkins_notification,omitempty"  mapstructure:"accept_jenkins_notification" description:"Allow Jenkins send build status notification to Bitbucket"`
 }
 
+// GiteaSource is the source of a self-hosted Gitea or Forgejo server
+type GiteaSource struct {
+	ScmId                string               `json:"scm_id,omitempty" description:"uid of scm"`
+	ServerUrl            string               `json:"server_url,omitempty" mapstructure:"server_url" description:"the address of gitea server which was configured in jenkins"`
+	Owner                string               `json:"owner,omitempty" mapstructure:"owner" description:"owner of gitea repo"`
+	Repo                 string               `json:"repo,omitempty" mapstructure:"repo" description:"repo name of gitea repo"`
+	CredentialId         string               `json:"credential_id,omitempty" mapstructure:"credential_id" description:"credential id to access gitea source"`
+	DiscoverBranches     int                  `json:"discover_branches,omitempty" mapstructure:"discover_branches" description:"Discover branch configuration"`
+	DiscoverPRFromOrigin int                  `json:"discover_pr_from_origin,omitempty" mapstructure:"discover_pr_from_origin" description:"Discover origin PR configuration"`
+	DiscoverPRFromForks  *DiscoverPRFromForks `json:"discover_pr_from_forks,omitempty" mapstructure:"discover_pr_from_forks" description:"Discover fork PR configuration"`
+	DiscoverTags         bool                 `json:"discover_tags,omitempty" mapstructure:"discover_tags" description:"Discover tag configuration"`
+	CloneOption          *GitCloneOption      `json:"git_clone_option,omitempty" mapstructure:"git_clone_option" description:"advavced git clone options"`
+	RegexFilter          string               `json:"regex_filter,omitempty" mapstructure:"regex_filter" description:"Regex used to match the name of the branch that needs to be run"`
+}
+
 type MultiBranchJobTrigger struct {
 	CreateActionJobsToTrigger string `json:"create_action_job_to_trigger,omitempty" description:"pipeline name to trigger"`
 	DeleteActionJobsToTrigger string `json:"delete_action_job_to_trigger,omitempty" description:"pipeline name to trigger"`
@@ -70,6 +70,7 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 		GitHubSource          *GithubSource
 		GitlabSource          *GitlabSource
 		BitbucketServerSource *BitbucketServerSource
+		GiteaSource           *GiteaSource
 	}
 	tests := []struct {
 		name   string
@@ -103,6 +104,13 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 			BitbucketServerSource: &BitbucketServerSource{Owner: "linuxsuren", Repo: "tools"},
 		},
 		want: "https://bitbucket.org/linuxsuren/tools",
+	}, {
+		name: "gitea",
+		fields: fields{
+			SourceType:  SourceTypeGitea,
+			GiteaSource: &GiteaSource{ServerUrl: "https://gitea.com/", Owner: "linuxsuren", Repo: "tools"},
+		},
+		want: "https://gitea.com/linuxsuren/tools",
 	}, {
 		name: "fake",
 		fields: fields{
@@ -119,8 +127,60 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 				GitHubSource:          tt.fields.GitHubSource,
 				GitlabSource:          tt.fields.GitlabSource,
 				BitbucketServerSource: tt.fields.BitbucketServerSource,
+				GiteaSource:           tt.fields.GiteaSource,
 			}
 			assert.Equalf(t, tt.want, b.GetGitURL(), "GetGitURL()")
 		})
 	}
 }
+
+func TestMultiBranchPipeline_GetSCMProvider(t *testing.T) {
+	tests := []struct {
+		name         string
+		pipeline     MultiBranchPipeline
+		wantProvider string
+		wantServer   string
+	}{{
+		name:         "github.com",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeGithub, GitHubSource: &GithubSource{ApiUri: "https://api.github.com"}},
+		wantProvider: "github",
+	}, {
+		name:         "GitHub Enterprise",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeGithub, GitHubSource: &GithubSource{ApiUri: "https://ghe.example.com/api/v3/"}},
+		wantProvider: "github",
+		wantServer:   "https://ghe.example.com/api/v3",
+	}, {
+		name:         "gitlab.com",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeGitlab, GitlabSource: &GitlabSource{}},
+		wantProvider: "gitlab",
+	}, {
+		name:         "self-managed GitLab",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeGitlab, GitlabSource: &GitlabSource{ApiUri: "https://gitlab.example.com"}},
+		wantProvider: "gitlab",
+		wantServer:   "https://gitlab.example.com",
+	}, {
+		name:         "bitbucket.org",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeBitbucket, BitbucketServerSource: &BitbucketServerSource{ApiUri: "https://bitbucket.org"}},
+		wantProvider: "bitbucketcloud",
+	}, {
+		name:         "Bitbucket Server",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeBitbucket, BitbucketServerSource: &BitbucketServerSource{ApiUri: "https://bitbucket.example.com"}},
+		wantProvider: "bitbucketserver",
+		wantServer:   "https://bitbucket.example.com",
+	}, {
+		name:         "gitea",
+		pipeline:     MultiBranchPipeline{SourceType: SourceTypeGitea, GiteaSource: &GiteaSource{ServerUrl: "https://gitea.com/"}},
+		wantProvider: "gitea",
+		wantServer:   "https://gitea.com",
+	}, {
+		name:     "git",
+		pipeline: MultiBranchPipeline{SourceType: SourceTypeGit, GitSource: &GitSource{Url: "https://fake.com"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, server := tt.pipeline.GetSCMProvider()
+			assert.Equal(t, tt.wantProvider, provider)
+			assert.Equal(t, tt.wantServer, server)
+		})
+	}
+}
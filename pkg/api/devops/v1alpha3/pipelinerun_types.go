@@ -18,7 +18,9 @@ package v1alpha3
 
 import (
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +32,9 @@ const (
 
 	// PipelineRunKeepJenkinsRecordAnnoKey is the annotation key of keeping Jenkins record
 	PipelineRunKeepJenkinsRecordAnnoKey = "devops.kubesphere.io/keep-jenkins-record"
+
+	// PipelineRunPinnedAnnoKey is the annotation key of pinning a PipelineRun, the pinned one is never pruned
+	PipelineRunPinnedAnnoKey = "devops.kubesphere.io/pinned"
 )
 
 // PipelineRunSpec defines the desired state of PipelineRun
@@ -52,6 +57,62 @@ type PipelineRunSpec struct {
 	// Action indicates what we need to do with current PipelineRun.
 	// +optional
 	Action *Action `json:"action,omitempty"`
+
+	// RetryPolicy indicates how to retry the current PipelineRun once it failed.
+	// It takes precedence over the retry policy of the Pipeline.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Timeout is the maximum duration of the current PipelineRun, the Jenkins build will be aborted once it times out.
+	// It takes precedence over the timeout of the Pipeline.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Concurrency controls the concurrency of the PipelineRuns in the same group, even across different Pipelines.
+	// It takes precedence over the concurrency of the Pipeline.
+	// +optional
+	Concurrency *Concurrency `json:"concurrency,omitempty"`
+}
+
+// Concurrency defines how to run the PipelineRuns in the same concurrency group.
+type Concurrency struct {
+	// Group is the template of the concurrency group key, such as: deploy-{{ .Parameters.env }}.
+	// The available fields are .Namespace, .Pipeline, .Branch and .Parameters.
+	Group string `json:"group"`
+
+	// Policy indicates what to do if there are other PipelineRuns running in the same group. Default is queue.
+	// +optional
+	Policy ConcurrencyPolicy `json:"policy,omitempty"`
+}
+
+// ConcurrencyPolicy is the policy of the PipelineRuns in the same concurrency group.
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyQueue waits until the other PipelineRuns in the same group complete.
+	ConcurrencyPolicyQueue ConcurrencyPolicy = "queue"
+	// ConcurrencyPolicyCancelInProgress stops the other PipelineRuns in the same group.
+	ConcurrencyPolicyCancelInProgress ConcurrencyPolicy = "cancel-in-progress"
+	// ConcurrencyPolicySkip skips the current PipelineRun if there are other PipelineRuns in the same group.
+	ConcurrencyPolicySkip ConcurrencyPolicy = "skip"
+)
+
+// RetryPolicy defines how to retry a completed PipelineRun automatically.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first run, there is no retry if it's less than 2.
+	MaxAttempts int `json:"maxAttempts"`
+
+	// Backoff is the duration to wait before the first retry, it doubles for every next retry.
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty"`
+
+	// Phases are the phases of a completed PipelineRun that qualify for a retry. Default is Failed.
+	// +optional
+	Phases []RunPhase `json:"phases,omitempty"`
+
+	// Reasons are the reasons of the latest condition that qualify for a retry. Any reason qualifies if it's empty.
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
 }
 
 // PipelineRunStatus defines the observed state of PipelineRun
@@ -77,6 +138,105 @@ type PipelineRunStatus struct {
 	// Current phase of PipelineRun.
 	// +optional
 	Phase RunPhase `json:"phase,omitempty"`
+
+	// Summary is the structured result of PipelineRun.
+	// +optional
+	Summary *PipelineRunSummary `json:"summary,omitempty"`
+}
+
+// PipelineRunSummary is the structured result of a PipelineRun.
+type PipelineRunSummary struct {
+	// CommitSHA is the resolved commit SHA of the SCM revision.
+	// +optional
+	CommitSHA string `json:"commitSHA,omitempty"`
+
+	// Stages are the summaries of all the stages.
+	// +optional
+	Stages []StageSummary `json:"stages,omitempty"`
+
+	// FailedStep is the first failed step.
+	// +optional
+	FailedStep *FailedStep `json:"failedStep,omitempty"`
+
+	// Tests is the summary of the test reports.
+	// +optional
+	Tests *TestSummary `json:"tests,omitempty"`
+
+	// Artifacts are the first archived artifacts, at most 100 of them are kept.
+	// +optional
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+
+	// ArtifactCount is the number of all the archived artifacts.
+	// +optional
+	ArtifactCount int `json:"artifactCount,omitempty"`
+}
+
+// StageSummary is the summary of a stage.
+type StageSummary struct {
+	// ID is the node ID of the stage.
+	ID string `json:"id"`
+
+	// Name is the display name of the stage.
+	Name string `json:"name"`
+
+	// Phase is the phase of the stage.
+	// +optional
+	Phase RunPhase `json:"phase,omitempty"`
+
+	// Duration is how long the stage has taken.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// FailedStep locates a failed step of a PipelineRun.
+type FailedStep struct {
+	// StageID is the node ID of the stage which the step belongs to.
+	StageID string `json:"stageID"`
+
+	// Stage is the display name of the stage which the step belongs to.
+	// +optional
+	Stage string `json:"stage,omitempty"`
+
+	// StepID is the ID of the step.
+	StepID string `json:"stepID"`
+
+	// Step is the display name of the step.
+	// +optional
+	Step string `json:"step,omitempty"`
+
+	// Description is the display description of the step, such as the shell script.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// LogExcerpt is the last lines of the log of the step.
+	// +optional
+	LogExcerpt string `json:"logExcerpt,omitempty"`
+}
+
+// TestSummary is the summary of the test reports.
+type TestSummary struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+}
+
+// Artifact is an archived artifact of a PipelineRun.
+type Artifact struct {
+	// Name is the file name of the artifact.
+	Name string `json:"name"`
+
+	// Path is the relative path of the artifact.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Size is the size of the artifact in bytes.
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// URL is the download path of the artifact in Jenkins.
+	// +optional
+	URL string `json:"url,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -147,6 +307,115 @@ func (pr *PipelineRun) HasCompleted() bool {
 	return !pr.Status.CompletionTime.IsZero()
 }
 
+// IsPaused indicates if the PipelineRun has been paused by the action.
+func (pr *PipelineRun) IsPaused() bool {
+	for _, condition := range pr.Status.Conditions {
+		if condition.Type == ConditionPaused {
+			return condition.Status == ConditionTrue
+		}
+	}
+	return false
+}
+
+// IsPinned indicates if the PipelineRun is pinned, and should never be pruned.
+func (pr *PipelineRun) IsPinned() bool {
+	pinned, _ := strconv.ParseBool(pr.Annotations[PipelineRunPinnedAnnoKey])
+	return pinned
+}
+
+// GetRetryPolicy returns the retry policy of the PipelineRun, or the one of the Pipeline if it's absent.
+func (pr *PipelineRun) GetRetryPolicy() *RetryPolicy {
+	if pr.Spec.RetryPolicy != nil {
+		return pr.Spec.RetryPolicy
+	}
+	if pr.Spec.PipelineSpec != nil {
+		return pr.Spec.PipelineSpec.RetryPolicy
+	}
+	return nil
+}
+
+// GetTimeout returns the timeout of the PipelineRun, or the one of the Pipeline if it's absent.
+// There is no timeout if the returned value is not positive.
+func (pr *PipelineRun) GetTimeout() time.Duration {
+	if pr.Spec.Timeout != nil {
+		return pr.Spec.Timeout.Duration
+	}
+	if pr.Spec.PipelineSpec != nil && pr.Spec.PipelineSpec.Timeout != nil {
+		return pr.Spec.PipelineSpec.Timeout.Duration
+	}
+	return 0
+}
+
+// GetConcurrency returns the concurrency of the PipelineRun, or the one of the Pipeline if it's absent.
+func (pr *PipelineRun) GetConcurrency() *Concurrency {
+	if pr.Spec.Concurrency != nil {
+		return pr.Spec.Concurrency
+	}
+	if pr.Spec.PipelineSpec != nil {
+		return pr.Spec.PipelineSpec.Concurrency
+	}
+	return nil
+}
+
+// GetAttempt returns the attempt number of the PipelineRun, the first run is 1.
+func (pr *PipelineRun) GetAttempt() int {
+	if attempt, err := strconv.Atoi(pr.Labels[PipelineRunAttemptLabelKey]); err == nil && attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+// GetRetryOf returns the name of the first PipelineRun of all the attempts.
+func (pr *PipelineRun) GetRetryOf() string {
+	if retryOf := pr.Labels[PipelineRunRetryOfLabelKey]; retryOf != "" {
+		return retryOf
+	}
+	return pr.Name
+}
+
+// Qualify indicates if the completed PipelineRun qualifies for a retry.
+func (policy *RetryPolicy) Qualify(pr *PipelineRun) bool {
+	if policy == nil || !pr.HasCompleted() || pr.GetAttempt() >= policy.MaxAttempts {
+		return false
+	}
+
+	phases := policy.Phases
+	if len(phases) == 0 {
+		phases = []RunPhase{Failed}
+	}
+	matched := false
+	for _, phase := range phases {
+		if phase == pr.Status.Phase {
+			matched = true
+			break
+		}
+	}
+	if !matched || len(policy.Reasons) == 0 {
+		return matched
+	}
+
+	if condition := pr.Status.GetLatestCondition(); condition != nil {
+		for _, reason := range policy.Reasons {
+			if reason == condition.Reason {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetBackoff returns the duration to wait before retrying the given attempt.
+func (policy *RetryPolicy) GetBackoff(attempt int) time.Duration {
+	if policy == nil || policy.Backoff == nil || attempt < 1 {
+		return 0
+	}
+	backoff := policy.Backoff.Duration
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
 // LabelAsAnOrphan labels PipelineRun as an orphan.
 func (pr *PipelineRun) LabelAsAnOrphan() {
 	if pr == nil {
@@ -276,6 +545,10 @@ const (
 	// ConditionSucceeded indicates that the pipeline has finished.
 	// For pipeline which runs to completion
 	ConditionSucceeded ConditionType = "Succeeded"
+
+	// ConditionPaused indicates that the pipeline has been paused.
+	// For pipeline which is paused or resumed by the action
+	ConditionPaused ConditionType = "Paused"
 )
 
 // ConditionStatus is the status of the current condition.
@@ -341,6 +614,24 @@ const (
 	TriggerFailed string = "TriggerFailed"
 	// RetrieveFailed indicates that it failed to retrieve the latest running data
 	RetrieveFailed string = "RetrieveFailed"
+	// Stopped indicates PipelineRun has been stopped
+	Stopped string = "Stopped"
+	// Paused indicates PipelineRun has been paused
+	Paused string = "Paused"
+	// Resumed indicates PipelineRun has been resumed
+	Resumed string = "Resumed"
+	// ActionFailed indicates that it failed to take the action of PipelineRun
+	ActionFailed string = "ActionFailed"
+	// Retried indicates PipelineRun has been retried by a new attempt
+	Retried string = "Retried"
+	// TimedOut indicates PipelineRun has been aborted due to timeout
+	TimedOut string = "TimedOut"
+	// Queued indicates PipelineRun is waiting for the others in the same concurrency group
+	Queued string = "Queued"
+	// Skipped indicates PipelineRun has been skipped due to the others in the same concurrency group
+	Skipped string = "Skipped"
+	// DataCleanupSkipped indicates the PipelineRun data was left in the data store since it's not reachable
+	DataCleanupSkipped string = "DataCleanupSkipped"
 )
 
 func init() {
@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -200,6 +201,45 @@ func TestPipelineRun_HasStarted(t *testing.T) {
 	}
 }
 
+func TestPipelineRun_IsPaused(t *testing.T) {
+	tests := []struct {
+		name   string
+		status PipelineRunStatus
+		want   bool
+	}{{
+		name:   "no conditions",
+		status: PipelineRunStatus{},
+		want:   false,
+	}, {
+		name: "paused",
+		status: PipelineRunStatus{
+			Conditions: []Condition{{
+				Type:   ConditionReady,
+				Status: ConditionUnknown,
+			}, {
+				Type:   ConditionPaused,
+				Status: ConditionTrue,
+			}},
+		},
+		want: true,
+	}, {
+		name: "resumed",
+		status: PipelineRunStatus{
+			Conditions: []Condition{{
+				Type:   ConditionPaused,
+				Status: ConditionFalse,
+			}},
+		},
+		want: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{Status: tt.status}
+			assert.Equalf(t, tt.want, pr.IsPaused(), "IsPaused()")
+		})
+	}
+}
+
 func TestBuildPipelineRunIdentifier(t *testing.T) {
 	type args struct {
 		pipelineName string
@@ -315,3 +355,225 @@ func TestPipelineRun_GetRefName(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineRun_GetRetryPolicy(t *testing.T) {
+	runPolicy := &RetryPolicy{MaxAttempts: 3}
+	pipelinePolicy := &RetryPolicy{MaxAttempts: 2}
+
+	tests := []struct {
+		name string
+		spec PipelineRunSpec
+		want *RetryPolicy
+	}{{
+		name: "no retry policy",
+		spec: PipelineRunSpec{},
+		want: nil,
+	}, {
+		name: "retry policy from Pipeline",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{RetryPolicy: pipelinePolicy},
+		},
+		want: pipelinePolicy,
+	}, {
+		name: "retry policy from PipelineRun takes precedence",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{RetryPolicy: pipelinePolicy},
+			RetryPolicy:  runPolicy,
+		},
+		want: runPolicy,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{Spec: tt.spec}
+			assert.Equal(t, tt.want, pr.GetRetryPolicy())
+		})
+	}
+}
+
+func TestPipelineRun_GetAttempt(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		wantAttempt int
+		wantRetryOf string
+	}{{
+		name:        "the first run",
+		wantAttempt: 1,
+		wantRetryOf: "name",
+	}, {
+		name: "invalid attempt number",
+		labels: map[string]string{
+			PipelineRunAttemptLabelKey: "a",
+		},
+		wantAttempt: 1,
+		wantRetryOf: "name",
+	}, {
+		name: "a retry",
+		labels: map[string]string{
+			PipelineRunAttemptLabelKey: "3",
+			PipelineRunRetryOfLabelKey: "first",
+		},
+		wantAttempt: 3,
+		wantRetryOf: "first",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{ObjectMeta: v1.ObjectMeta{Name: "name", Labels: tt.labels}}
+			assert.Equal(t, tt.wantAttempt, pr.GetAttempt())
+			assert.Equal(t, tt.wantRetryOf, pr.GetRetryOf())
+		})
+	}
+}
+
+func TestRetryPolicy_Qualify(t *testing.T) {
+	now := v1.Now()
+	newPipelineRun := func(phase RunPhase, reason string, attempt string) *PipelineRun {
+		pr := &PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Labels: map[string]string{PipelineRunAttemptLabelKey: attempt},
+			},
+			Status: PipelineRunStatus{
+				Phase:          phase,
+				CompletionTime: &now,
+			},
+		}
+		pr.Status.AddCondition(&Condition{Type: ConditionSucceeded, Reason: reason})
+		return pr
+	}
+
+	tests := []struct {
+		name        string
+		policy      *RetryPolicy
+		pipelineRun *PipelineRun
+		want        bool
+	}{{
+		name:        "no retry policy",
+		pipelineRun: newPipelineRun(Failed, "FINISHED", "1"),
+		want:        false,
+	}, {
+		name:        "not completed",
+		policy:      &RetryPolicy{MaxAttempts: 2},
+		pipelineRun: &PipelineRun{Status: PipelineRunStatus{Phase: Running}},
+		want:        false,
+	}, {
+		name:        "failed with the default phases",
+		policy:      &RetryPolicy{MaxAttempts: 2},
+		pipelineRun: newPipelineRun(Failed, "FINISHED", "1"),
+		want:        true,
+	}, {
+		name:        "succeeded with the default phases",
+		policy:      &RetryPolicy{MaxAttempts: 2},
+		pipelineRun: newPipelineRun(Succeeded, "FINISHED", "1"),
+		want:        false,
+	}, {
+		name:        "reached the max attempts",
+		policy:      &RetryPolicy{MaxAttempts: 2},
+		pipelineRun: newPipelineRun(Failed, "FINISHED", "2"),
+		want:        false,
+	}, {
+		name:        "cancelled with the specific phases",
+		policy:      &RetryPolicy{MaxAttempts: 2, Phases: []RunPhase{Failed, Cancelled}},
+		pipelineRun: newPipelineRun(Cancelled, "Cancelled", "1"),
+		want:        true,
+	}, {
+		name:        "matched reason",
+		policy:      &RetryPolicy{MaxAttempts: 2, Reasons: []string{"FINISHED"}},
+		pipelineRun: newPipelineRun(Failed, "FINISHED", "1"),
+		want:        true,
+	}, {
+		name:        "mismatched reason",
+		policy:      &RetryPolicy{MaxAttempts: 2, Reasons: []string{"SKIPPED"}},
+		pipelineRun: newPipelineRun(Failed, "FINISHED", "1"),
+		want:        false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Qualify(tt.pipelineRun))
+		})
+	}
+}
+
+func TestRetryPolicy_GetBackoff(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	assert.Equal(t, time.Duration(0), nilPolicy.GetBackoff(1))
+	assert.Equal(t, time.Duration(0), (&RetryPolicy{}).GetBackoff(1))
+
+	policy := &RetryPolicy{Backoff: &v1.Duration{Duration: time.Minute}}
+	assert.Equal(t, time.Duration(0), policy.GetBackoff(0))
+	assert.Equal(t, time.Minute, policy.GetBackoff(1))
+	assert.Equal(t, 2*time.Minute, policy.GetBackoff(2))
+	assert.Equal(t, 4*time.Minute, policy.GetBackoff(3))
+	assert.NotNil(t, policy.DeepCopy())
+}
+
+func TestPipelineRun_GetTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		spec PipelineRunSpec
+		want time.Duration
+	}{{
+		name: "no timeout",
+		spec: PipelineRunSpec{PipelineSpec: &PipelineSpec{}},
+		want: 0,
+	}, {
+		name: "timeout from Pipeline",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{Timeout: &v1.Duration{Duration: time.Hour}},
+		},
+		want: time.Hour,
+	}, {
+		name: "timeout from PipelineRun takes precedence",
+		spec: PipelineRunSpec{
+			PipelineSpec: &PipelineSpec{Timeout: &v1.Duration{Duration: time.Hour}},
+			Timeout:      &v1.Duration{Duration: time.Minute},
+		},
+		want: time.Minute,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{Spec: tt.spec}
+			assert.Equal(t, tt.want, pr.GetTimeout())
+			assert.Equal(t, tt.spec.Timeout, pr.DeepCopy().Spec.Timeout)
+		})
+	}
+}
+
+func TestPipelineRun_GetConcurrency(t *testing.T) {
+	runConcurrency := &Concurrency{Group: "run"}
+	pipelineConcurrency := &Concurrency{Group: "pipeline", Policy: ConcurrencyPolicySkip}
+
+	pr := &PipelineRun{}
+	assert.Nil(t, pr.GetConcurrency())
+
+	pr.Spec.PipelineSpec = &PipelineSpec{Concurrency: pipelineConcurrency}
+	assert.Equal(t, pipelineConcurrency, pr.GetConcurrency())
+
+	pr.Spec.Concurrency = runConcurrency
+	assert.Equal(t, runConcurrency, pr.GetConcurrency())
+	assert.Equal(t, runConcurrency, pr.DeepCopy().Spec.Concurrency)
+}
+
+func TestPipelineRun_IsPinned(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{{
+		name: "no annotations",
+		want: false,
+	}, {
+		name:        "pinned",
+		annotations: map[string]string{PipelineRunPinnedAnnoKey: "true"},
+		want:        true,
+	}, {
+		name:        "invalid value",
+		annotations: map[string]string{PipelineRunPinnedAnnoKey: "yes"},
+		want:        false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{ObjectMeta: v1.ObjectMeta{Annotations: tt.annotations}}
+			assert.Equal(t, tt.want, pr.IsPinned())
+		})
+	}
+}
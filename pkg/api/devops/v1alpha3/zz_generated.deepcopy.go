@@ -294,6 +294,21 @@ func (in *Argo) DeepCopy() *Argo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Artifact) DeepCopyInto(out *Artifact) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Artifact.
+func (in *Artifact) DeepCopy() *Artifact {
+	if in == nil {
+		return nil
+	}
+	out := new(Artifact)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BitbucketServerSource) DeepCopyInto(out *BitbucketServerSource) {
 	*out = *in
@@ -437,6 +452,21 @@ func (in *ClusterTemplateList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Concurrency) DeepCopyInto(out *Concurrency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Concurrency.
+func (in *Concurrency) DeepCopy() *Concurrency {
+	if in == nil {
+		return nil
+	}
+	out := new(Concurrency)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -578,6 +608,21 @@ func (in *DiscoverPRFromForks) DeepCopy() *DiscoverPRFromForks {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedStep) DeepCopyInto(out *FailedStep) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedStep.
+func (in *FailedStep) DeepCopy() *FailedStep {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GenericVariable) DeepCopyInto(out *GenericVariable) {
 	*out = *in
@@ -752,6 +797,31 @@ func (in *GitSource) DeepCopy() *GitSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GiteaSource) DeepCopyInto(out *GiteaSource) {
+	*out = *in
+	if in.DiscoverPRFromForks != nil {
+		in, out := &in.DiscoverPRFromForks, &out.DiscoverPRFromForks
+		*out = new(DiscoverPRFromForks)
+		**out = **in
+	}
+	if in.CloneOption != nil {
+		in, out := &in.CloneOption, &out.CloneOption
+		*out = new(GitCloneOption)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GiteaSource.
+func (in *GiteaSource) DeepCopy() *GiteaSource {
+	if in == nil {
+		return nil
+	}
+	out := new(GiteaSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GithubSource) DeepCopyInto(out *GithubSource) {
 	*out = *in
@@ -875,6 +945,11 @@ func (in *MultiBranchPipeline) DeepCopyInto(out *MultiBranchPipeline) {
 		*out = new(BitbucketServerSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.GiteaSource != nil {
+		in, out := &in.GiteaSource, &out.GiteaSource
+		*out = new(GiteaSource)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.MultiBranchJobTrigger != nil {
 		in, out := &in.MultiBranchJobTrigger, &out.MultiBranchJobTrigger
 		*out = new(MultiBranchJobTrigger)
@@ -1178,6 +1253,21 @@ func (in *PipelineRunSpec) DeepCopyInto(out *PipelineRunSpec) {
 		*out = new(Action)
 		**out = **in
 	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(Concurrency)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineRunSpec.
@@ -1212,6 +1302,11 @@ func (in *PipelineRunStatus) DeepCopyInto(out *PipelineRunStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(PipelineRunSummary)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineRunStatus.
@@ -1224,6 +1319,43 @@ func (in *PipelineRunStatus) DeepCopy() *PipelineRunStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineRunSummary) DeepCopyInto(out *PipelineRunSummary) {
+	*out = *in
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]StageSummary, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FailedStep != nil {
+		in, out := &in.FailedStep, &out.FailedStep
+		*out = new(FailedStep)
+		**out = **in
+	}
+	if in.Tests != nil {
+		in, out := &in.Tests, &out.Tests
+		*out = new(TestSummary)
+		**out = **in
+	}
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]Artifact, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineRunSummary.
+func (in *PipelineRunSummary) DeepCopy() *PipelineRunSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineRunSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PipelineSpec) DeepCopyInto(out *PipelineSpec) {
 	*out = *in
@@ -1237,6 +1369,21 @@ func (in *PipelineSpec) DeepCopyInto(out *PipelineSpec) {
 		*out = new(MultiBranchPipeline)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(RetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(Concurrency)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineSpec.
@@ -1309,6 +1456,36 @@ func (in *RemoteTrigger) DeepCopy() *RemoteTrigger {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
+	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Phases != nil {
+		in, out := &in.Phases, &out.Phases
+		*out = make([]RunPhase, len(*in))
+		copy(*out, *in)
+	}
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryPolicy.
+func (in *RetryPolicy) DeepCopy() *RetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SCM) DeepCopyInto(out *SCM) {
 	*out = *in
@@ -1376,6 +1553,26 @@ func (in *SingleSvnSource) DeepCopy() *SingleSvnSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageSummary) DeepCopyInto(out *StageSummary) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageSummary.
+func (in *StageSummary) DeepCopy() *StageSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(StageSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StepTemplateSpec) DeepCopyInto(out *StepTemplateSpec) {
 	*out = *in
@@ -1599,6 +1796,21 @@ func (in *TemplateStatus) DeepCopy() *TemplateStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TestSummary) DeepCopyInto(out *TestSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TestSummary.
+func (in *TestSummary) DeepCopy() *TestSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(TestSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TimerTrigger) DeepCopyInto(out *TimerTrigger) {
 	*out = *in
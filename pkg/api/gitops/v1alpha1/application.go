@@ -27,6 +27,18 @@ import (
 // FluxApplication is an abstraction of FluxCD HelmRelease and FluxCD Kustomization
 type FluxApplication struct {
 	Spec FluxApplicationSpec `json:"spec,omitempty"`
+	// Rollback records the last rollback of the FluxCD Application
+	Rollback *FluxApplicationRollback `json:"rollback,omitempty"`
+}
+
+// FluxApplicationRollback records a rollback of the FluxCD Application
+type FluxApplicationRollback struct {
+	// Revision is the chart version of the HelmRelease, or the source revision of the Kustomization
+	Revision string `json:"revision"`
+	// InitiatedBy contains information about who initiated the rollback
+	InitiatedBy OperationInitiator `json:"initiatedBy,omitempty"`
+	// StartedAt is the time of the rollback
+	StartedAt metav1.Time `json:"startedAt,omitempty"`
 }
 
 // FluxApplicationSpec contains three important elements that a GitOps Application needs.
@@ -244,6 +256,28 @@ type FluxApplicationStatus struct {
 	// KustomizationStatus represent the status of each Kustomization
 	// the key is the Kustomization's name and the value is the Kustomization's status
 	KustomizationStatus map[string]*kusv1.KustomizationStatus `json:"kustomizationStatus,omitempty"`
+	// SyncWindow represents the state of the sync windows which the FluxApp is assigned to
+	SyncWindow *FluxSyncWindowStatus `json:"syncWindow,omitempty"`
+}
+
+// SyncWindowState is the state of the sync windows
+type SyncWindowState string
+
+const (
+	// SyncWindowStateAllowed indicates the syncs are allowed by the sync windows
+	SyncWindowStateAllowed SyncWindowState = "Allowed"
+	// SyncWindowStateBlocked indicates the syncs are blocked by the sync windows,
+	// the HelmReleases and Kustomizations are suspended
+	SyncWindowStateBlocked SyncWindowState = "Blocked"
+)
+
+// FluxSyncWindowStatus represents the state of the sync windows of a FluxApp
+type FluxSyncWindowStatus struct {
+	State SyncWindowState `json:"state"`
+	// Message describes the sync windows which allow or block the syncs
+	Message string `json:"message,omitempty"`
+	// NextTransitionTime is the time when a sync window starts or ends
+	NextTransitionTime *metav1.Time `json:"nextTransitionTime,omitempty"`
 }
 
 // ApplicationSpec is the specification of the Application
@@ -594,11 +628,221 @@ type Application struct {
 	Status ApplicationStatus `json:"status,omitempty"`
 }
 
+// SyncStatusCode is a type which represents possible comparison results
+type SyncStatusCode string
+
+// Possible comparison results
+const (
+	// SyncStatusCodeUnknown indicates that the status of a sync could not be reliably determined
+	SyncStatusCodeUnknown SyncStatusCode = "Unknown"
+	// SyncStatusCodeSynced indicates that desired and live states match
+	SyncStatusCodeSynced SyncStatusCode = "Synced"
+	// SyncStatusCodeOutOfSync indicates that there is a drift between desired and live states
+	SyncStatusCodeOutOfSync SyncStatusCode = "OutOfSync"
+)
+
+// HealthStatusCode is a type which represents the health of a resource or an application
+type HealthStatusCode string
+
+// Possible health statuses
+const (
+	// HealthStatusUnknown indicates that the health assessment failed and actual health status is unknown
+	HealthStatusUnknown HealthStatusCode = "Unknown"
+	// HealthStatusProgressing indicates that the resource is not healthy yet but still have a chance to reach healthy state
+	HealthStatusProgressing HealthStatusCode = "Progressing"
+	// HealthStatusHealthy indicates that the resource is 100% healthy
+	HealthStatusHealthy HealthStatusCode = "Healthy"
+	// HealthStatusSuspended indicates that the resource is suspended and waiting for some external event to resume
+	HealthStatusSuspended HealthStatusCode = "Suspended"
+	// HealthStatusDegraded indicates that the resource status is degraded
+	HealthStatusDegraded HealthStatusCode = "Degraded"
+	// HealthStatusMissing indicates that the resource is missing in the cluster
+	HealthStatusMissing HealthStatusCode = "Missing"
+)
+
+// OperationPhase is a type which represents the phase of an operation
+type OperationPhase string
+
+// Possible operation phases
+const (
+	OperationRunning     OperationPhase = "Running"
+	OperationTerminating OperationPhase = "Terminating"
+	OperationFailed      OperationPhase = "Failed"
+	OperationError       OperationPhase = "Error"
+	OperationSucceeded   OperationPhase = "Succeeded"
+)
+
+// HealthStatus contains information about the health status of a resource or an application
+type HealthStatus struct {
+	// Status holds the status code of the resource or the application
+	Status HealthStatusCode `json:"status,omitempty"`
+	// Message is a human-readable informational message describing the health status
+	Message string `json:"message,omitempty"`
+}
+
+// ComparedTo contains application source and target which was used for resources comparison
+type ComparedTo struct {
+	// Source is a reference to the application's source used for comparison
+	Source ApplicationSource `json:"source,omitempty"`
+	// Destination is a reference to the application's destination used for comparison
+	Destination ApplicationDestination `json:"destination,omitempty"`
+}
+
+// SyncStatus contains information about the currently observed live and desired states of an application
+type SyncStatus struct {
+	// Status is the sync state of the comparison
+	Status SyncStatusCode `json:"status,omitempty"`
+	// ComparedTo contains information about what has been compared
+	ComparedTo ComparedTo `json:"comparedTo,omitempty"`
+	// Revision contains information about the revision the comparison has been performed to
+	Revision string `json:"revision,omitempty"`
+}
+
+// ResourceStatus holds the current sync and health status of a resource
+type ResourceStatus struct {
+	Group           string         `json:"group,omitempty"`
+	Version         string         `json:"version,omitempty"`
+	Kind            string         `json:"kind,omitempty"`
+	Namespace       string         `json:"namespace,omitempty"`
+	Name            string         `json:"name,omitempty"`
+	Status          SyncStatusCode `json:"status,omitempty"`
+	Health          *HealthStatus  `json:"health,omitempty"`
+	Hook            bool           `json:"hook,omitempty"`
+	RequiresPruning bool           `json:"requiresPruning,omitempty"`
+	SyncWave        int64          `json:"syncWave,omitempty"`
+}
+
+// RevisionHistory contains history information about a previous sync
+type RevisionHistory struct {
+	// Revision holds the revision the sync was performed against
+	Revision string `json:"revision,omitempty"`
+	// DeployedAt holds the time the sync operation completed
+	DeployedAt metav1.Time `json:"deployedAt"`
+	// ID is an auto incrementing identifier of the RevisionHistory
+	ID int64 `json:"id"`
+	// Source is a reference to the application source used for the sync operation
+	Source ApplicationSource `json:"source,omitempty"`
+	// DeployStartedAt holds the time the sync operation started
+	DeployStartedAt *metav1.Time `json:"deployStartedAt,omitempty"`
+	// InitiatedBy contains information about who initiated the operations
+	InitiatedBy OperationInitiator `json:"initiatedBy,omitempty"`
+}
+
+// ApplicationCondition contains details about an application condition, which is usually an error or warning
+type ApplicationCondition struct {
+	// Type is an application condition type
+	Type string `json:"type"`
+	// Message contains human-readable message indicating details about condition
+	Message string `json:"message"`
+	// LastTransitionTime is the time the condition was last observed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// ResourceResult holds the operation result details of a specific resource
+type ResourceResult struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Status holds the final result of the sync, such as Synced, SyncFailed, Pruned and PruneSkipped
+	Status string `json:"status,omitempty"`
+	// Message contains an informational or error message for the last sync OR operation
+	Message string `json:"message,omitempty"`
+	// HookType specifies the type of the hook, it's empty for a non-hook resource
+	HookType string `json:"hookType,omitempty"`
+	// HookPhase contains the state of any operation associated with this resource OR hook
+	HookPhase OperationPhase `json:"hookPhase,omitempty"`
+	// SyncPhase indicates the particular phase of the sync that this result was acquired in
+	SyncPhase string `json:"syncPhase,omitempty"`
+}
+
+// IsHook returns true if the result belongs to a hook
+func (r *ResourceResult) IsHook() bool {
+	return r.HookType != ""
+}
+
+// SyncOperationResult represent result of sync operation
+type SyncOperationResult struct {
+	// Resources contains a list of sync result items for each individual resource in a sync operation
+	Resources []*ResourceResult `json:"resources,omitempty"`
+	// Revision holds the revision this sync operation was performed to
+	Revision string `json:"revision"`
+	// Source records the application source information of the sync, used for comparing auto-sync
+	Source ApplicationSource `json:"source,omitempty"`
+}
+
+// OperationState contains information about any ongoing operations, such as a sync
+type OperationState struct {
+	// Operation is the original requested operation
+	Operation Operation `json:"operation"`
+	// Phase is the current phase of the operation
+	Phase OperationPhase `json:"phase"`
+	// Message holds any pertinent messages when attempting to perform operation (typically errors).
+	Message string `json:"message,omitempty"`
+	// SyncResult is the result of a Sync operation
+	SyncResult *SyncOperationResult `json:"syncResult,omitempty"`
+	// StartedAt contains time of operation start
+	StartedAt metav1.Time `json:"startedAt"`
+	// FinishedAt contains time of operation completion
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// RetryCount contains time of operation retries
+	RetryCount int64 `json:"retryCount,omitempty"`
+}
+
+// ApplicationSummary contains the external URLs and images of an application
+type ApplicationSummary struct {
+	// ExternalURLs holds all external URLs of application child resources.
+	ExternalURLs []string `json:"externalURLs,omitempty"`
+	// Images holds all images of application child resources.
+	Images []string `json:"images,omitempty"`
+}
+
+// ArgoApplicationStatus is the status of an Argo CD Application.
+// Those fields simply are copied from the argo-cd project
+type ArgoApplicationStatus struct {
+	// Resources is a list of Kubernetes resources managed by this application
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// Sync contains information about the application's current sync status
+	Sync SyncStatus `json:"sync,omitempty"`
+	// Health contains information about the application's current health status
+	Health HealthStatus `json:"health,omitempty"`
+	// History contains information about the application's sync history
+	History []RevisionHistory `json:"history,omitempty"`
+	// Conditions is a list of currently observed application conditions
+	Conditions []ApplicationCondition `json:"conditions,omitempty"`
+	// ReconciledAt indicates when the application state was reconciled using the latest git version
+	ReconciledAt *metav1.Time `json:"reconciledAt,omitempty"`
+	// OperationState contains information about any ongoing operations, such as a sync
+	OperationState *OperationState `json:"operationState,omitempty"`
+	// SourceType specifies the type of this application
+	SourceType string `json:"sourceType,omitempty"`
+	// Summary contains a list of URLs and container images used by this application
+	Summary ApplicationSummary `json:"summary,omitempty"`
+}
+
+// GetFailedHooks returns the hooks of the last operation which are failed
+func (s *ArgoApplicationStatus) GetFailedHooks() (hooks []*ResourceResult) {
+	if s.OperationState == nil || s.OperationState.SyncResult == nil {
+		return
+	}
+	for _, result := range s.OperationState.SyncResult.Resources {
+		if result != nil && result.IsHook() && (result.HookPhase == OperationFailed || result.HookPhase == OperationError) {
+			hooks = append(hooks, result)
+		}
+	}
+	return
+}
+
 // ApplicationStatus represents the status of the Application
 type ApplicationStatus struct {
-	Kind    Engine                `json:"kind,omitempty"`
+	Kind Engine `json:"kind,omitempty"`
+	// ArgoApp is the raw JSON of the Argo CD Application status, it's only set if the status cannot be parsed.
+	// Deprecated: use ArgoAppStatus instead, it will be removed in a future release
 	ArgoApp string                `json:"argoApp,omitempty"`
 	FluxApp FluxApplicationStatus `json:"fluxApp,omitempty"`
+	// ArgoAppStatus is the status of the Argo CD Application, only the latest 10 history and the first 500 resources are kept
+	ArgoAppStatus *ArgoApplicationStatus `json:"argoAppStatus,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
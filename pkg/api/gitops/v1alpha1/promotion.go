@@ -0,0 +1,122 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromotionSpec is the specification of a promotion
+type PromotionSpec struct {
+	// Source is the Application which the revision is promoted from
+	Source v1.LocalObjectReference `json:"source"`
+	// Target is the Application which the revision is promoted to
+	Target v1.LocalObjectReference `json:"target"`
+	// Revision is the commit SHA, or the image tag if the image is set.
+	// It's the current revision of the source Application if it's empty.
+	Revision string `json:"revision,omitempty"`
+	// Image is the name of the image whose tag is promoted, such as ghcr.io/kubesphere/ks-devops
+	Image string `json:"image,omitempty"`
+	// RequireHealthy requires the source Application to be healthy before promoting
+	RequireHealthy bool `json:"requireHealthy,omitempty"`
+	// RequireApproval requires the promotion to be approved manually
+	RequireApproval bool `json:"requireApproval,omitempty"`
+	// GitWriteBack commits the revision to a git repository instead of updating the target Application
+	GitWriteBack *PromotionGitWriteBack `json:"gitWriteBack,omitempty"`
+}
+
+// PromotionGitWriteBack describes the field of a file in a git repository which the revision is written to
+type PromotionGitWriteBack struct {
+	// Repository is the name of the GitRepository in the same namespace
+	Repository string `json:"repository"`
+	// Branch is the branch which the change is committed to
+	Branch string `json:"branch"`
+	// File is the path of a YAML file in the repository, such as envs/prod/values.yaml
+	File string `json:"file"`
+	// Key is the dot-separated path of the field in the file, such as image.tag
+	Key string `json:"key"`
+}
+
+// PromotionPhase is the phase of a promotion
+type PromotionPhase string
+
+const (
+	// PromotionPhaseWaitingForApproval indicates the promotion is waiting for a manual approval
+	PromotionPhaseWaitingForApproval PromotionPhase = "WaitingForApproval"
+	// PromotionPhasePromoting indicates the promotion has been claimed, and the revision is being promoted
+	PromotionPhasePromoting PromotionPhase = "Promoting"
+	// PromotionPhaseSucceeded indicates the revision has been promoted to the target
+	PromotionPhaseSucceeded PromotionPhase = "Succeeded"
+	// PromotionPhaseFailed indicates the promotion failed
+	PromotionPhaseFailed PromotionPhase = "Failed"
+	// PromotionPhaseRejected indicates the promotion was rejected
+	PromotionPhaseRejected PromotionPhase = "Rejected"
+)
+
+// PromotionStatus is the status of a promotion
+type PromotionStatus struct {
+	Phase   PromotionPhase `json:"phase,omitempty"`
+	Message string         `json:"message,omitempty"`
+	// Revision is the revision which is promoted
+	Revision string `json:"revision,omitempty"`
+	// Commit is the commit of the git write-back
+	Commit string `json:"commit,omitempty"`
+	// RequestedBy is the user who created the promotion, the same user cannot approve it
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// ReviewedBy is the user who approved or rejected the promotion
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+	// FinishedAt is the time when the promotion is finished
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+}
+
+// IsFinished returns true if the promotion is no longer pending
+func (s *PromotionStatus) IsFinished() bool {
+	return s.Phase == PromotionPhaseSucceeded || s.Phase == PromotionPhaseFailed || s.Phase == PromotionPhaseRejected
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +k8s:openapi-gen=true
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source.name`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.target.name`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.revision`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+
+// Promotion represents a request to promote a revision from an Application to another one
+type Promotion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PromotionSpec   `json:"spec"`
+	Status PromotionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PromotionList represents a set of the promotions
+type PromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Promotion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Promotion{}, &PromotionList{})
+}
@@ -57,6 +57,25 @@ func (in *Application) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationCondition) DeepCopyInto(out *ApplicationCondition) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationCondition.
+func (in *ApplicationCondition) DeepCopy() *ApplicationCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationDestination) DeepCopyInto(out *ApplicationDestination) {
 	*out = *in
@@ -329,6 +348,11 @@ func (in *ApplicationSpec) DeepCopy() *ApplicationSpec {
 func (in *ApplicationStatus) DeepCopyInto(out *ApplicationStatus) {
 	*out = *in
 	in.FluxApp.DeepCopyInto(&out.FluxApp)
+	if in.ArgoAppStatus != nil {
+		in, out := &in.ArgoAppStatus, &out.ArgoAppStatus
+		*out = new(ArgoApplicationStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationStatus.
@@ -341,6 +365,31 @@ func (in *ApplicationStatus) DeepCopy() *ApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationSummary) DeepCopyInto(out *ApplicationSummary) {
+	*out = *in
+	if in.ExternalURLs != nil {
+		in, out := &in.ExternalURLs, &out.ExternalURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationSummary.
+func (in *ApplicationSummary) DeepCopy() *ApplicationSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArgoApplication) DeepCopyInto(out *ArgoApplication) {
 	*out = *in
@@ -401,6 +450,54 @@ func (in *ArgoApplicationSpec) DeepCopy() *ArgoApplicationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArgoApplicationStatus) DeepCopyInto(out *ArgoApplicationStatus) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Sync.DeepCopyInto(&out.Sync)
+	out.Health = in.Health
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]RevisionHistory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ApplicationCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReconciledAt != nil {
+		in, out := &in.ReconciledAt, &out.ReconciledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.OperationState != nil {
+		in, out := &in.OperationState, &out.OperationState
+		*out = new(OperationState)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Summary.DeepCopyInto(&out.Summary)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArgoApplicationStatus.
+func (in *ArgoApplicationStatus) DeepCopy() *ArgoApplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ArgoApplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ArgoImageUpdater) DeepCopyInto(out *ArgoImageUpdater) {
 	*out = *in
@@ -472,6 +569,23 @@ func (in *Backoff) DeepCopy() *Backoff {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComparedTo) DeepCopyInto(out *ComparedTo) {
+	*out = *in
+	in.Source.DeepCopyInto(&out.Source)
+	out.Destination = in.Destination
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComparedTo.
+func (in *ComparedTo) DeepCopy() *ComparedTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ComparedTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Deploy) DeepCopyInto(out *Deploy) {
 	*out = *in
@@ -590,6 +704,11 @@ func (in *EnvEntry) DeepCopy() *EnvEntry {
 func (in *FluxApplication) DeepCopyInto(out *FluxApplication) {
 	*out = *in
 	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Rollback != nil {
+		in, out := &in.Rollback, &out.Rollback
+		*out = new(FluxApplicationRollback)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxApplication.
@@ -653,6 +772,23 @@ func (in *FluxApplicationDestination) DeepCopy() *FluxApplicationDestination {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FluxApplicationRollback) DeepCopyInto(out *FluxApplicationRollback) {
+	*out = *in
+	out.InitiatedBy = in.InitiatedBy
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxApplicationRollback.
+func (in *FluxApplicationRollback) DeepCopy() *FluxApplicationRollback {
+	if in == nil {
+		return nil
+	}
+	out := new(FluxApplicationRollback)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FluxApplicationSource) DeepCopyInto(out *FluxApplicationSource) {
 	*out = *in
@@ -727,6 +863,11 @@ func (in *FluxApplicationStatus) DeepCopyInto(out *FluxApplicationStatus) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.SyncWindow != nil {
+		in, out := &in.SyncWindow, &out.SyncWindow
+		*out = new(FluxSyncWindowStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxApplicationStatus.
@@ -739,6 +880,40 @@ func (in *FluxApplicationStatus) DeepCopy() *FluxApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FluxSyncWindowStatus) DeepCopyInto(out *FluxSyncWindowStatus) {
+	*out = *in
+	if in.NextTransitionTime != nil {
+		in, out := &in.NextTransitionTime, &out.NextTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FluxSyncWindowStatus.
+func (in *FluxSyncWindowStatus) DeepCopy() *FluxSyncWindowStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FluxSyncWindowStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthStatus) DeepCopyInto(out *HealthStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthStatus.
+func (in *HealthStatus) DeepCopy() *HealthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HelmChartTemplateSpec) DeepCopyInto(out *HelmChartTemplateSpec) {
 	*out = *in
@@ -1077,6 +1252,147 @@ func (in *OperationInitiator) DeepCopy() *OperationInitiator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationState) DeepCopyInto(out *OperationState) {
+	*out = *in
+	in.Operation.DeepCopyInto(&out.Operation)
+	if in.SyncResult != nil {
+		in, out := &in.SyncResult, &out.SyncResult
+		*out = new(SyncOperationResult)
+		(*in).DeepCopyInto(*out)
+	}
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.FinishedAt != nil {
+		in, out := &in.FinishedAt, &out.FinishedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationState.
+func (in *OperationState) DeepCopy() *OperationState {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Promotion) DeepCopyInto(out *Promotion) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Promotion.
+func (in *Promotion) DeepCopy() *Promotion {
+	if in == nil {
+		return nil
+	}
+	out := new(Promotion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Promotion) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PromotionGitWriteBack) DeepCopyInto(out *PromotionGitWriteBack) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromotionGitWriteBack.
+func (in *PromotionGitWriteBack) DeepCopy() *PromotionGitWriteBack {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotionGitWriteBack)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PromotionList) DeepCopyInto(out *PromotionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Promotion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromotionList.
+func (in *PromotionList) DeepCopy() *PromotionList {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PromotionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PromotionSpec) DeepCopyInto(out *PromotionSpec) {
+	*out = *in
+	out.Source = in.Source
+	out.Target = in.Target
+	if in.GitWriteBack != nil {
+		in, out := &in.GitWriteBack, &out.GitWriteBack
+		*out = new(PromotionGitWriteBack)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromotionSpec.
+func (in *PromotionSpec) DeepCopy() *PromotionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PromotionStatus) DeepCopyInto(out *PromotionStatus) {
+	*out = *in
+	if in.FinishedAt != nil {
+		in, out := &in.FinishedAt, &out.FinishedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PromotionStatus.
+func (in *PromotionStatus) DeepCopy() *PromotionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PromotionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceIgnoreDifferences) DeepCopyInto(out *ResourceIgnoreDifferences) {
 	*out = *in
@@ -1107,6 +1423,41 @@ func (in *ResourceIgnoreDifferences) DeepCopy() *ResourceIgnoreDifferences {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceResult) DeepCopyInto(out *ResourceResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceResult.
+func (in *ResourceResult) DeepCopy() *ResourceResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceStatus) DeepCopyInto(out *ResourceStatus) {
+	*out = *in
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(HealthStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatus.
+func (in *ResourceStatus) DeepCopy() *ResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetryStrategy) DeepCopyInto(out *RetryStrategy) {
 	*out = *in
@@ -1127,6 +1478,28 @@ func (in *RetryStrategy) DeepCopy() *RetryStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RevisionHistory) DeepCopyInto(out *RevisionHistory) {
+	*out = *in
+	in.DeployedAt.DeepCopyInto(&out.DeployedAt)
+	in.Source.DeepCopyInto(&out.Source)
+	if in.DeployStartedAt != nil {
+		in, out := &in.DeployStartedAt, &out.DeployStartedAt
+		*out = (*in).DeepCopy()
+	}
+	out.InitiatedBy = in.InitiatedBy
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RevisionHistory.
+func (in *RevisionHistory) DeepCopy() *RevisionHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(RevisionHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SyncOperation) DeepCopyInto(out *SyncOperation) {
 	*out = *in
@@ -1182,6 +1555,33 @@ func (in *SyncOperationResource) DeepCopy() *SyncOperationResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncOperationResult) DeepCopyInto(out *SyncOperationResult) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]*ResourceResult, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(ResourceResult)
+				**out = **in
+			}
+		}
+	}
+	in.Source.DeepCopyInto(&out.Source)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncOperationResult.
+func (in *SyncOperationResult) DeepCopy() *SyncOperationResult {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncOperationResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in SyncOptions) DeepCopyInto(out *SyncOptions) {
 	{
@@ -1246,6 +1646,22 @@ func (in *SyncPolicyAutomated) DeepCopy() *SyncPolicyAutomated {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncStatus) DeepCopyInto(out *SyncStatus) {
+	*out = *in
+	in.ComparedTo.DeepCopyInto(&out.ComparedTo)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncStatus.
+func (in *SyncStatus) DeepCopy() *SyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SyncStrategy) DeepCopyInto(out *SyncStrategy) {
 	*out = *in
@@ -145,9 +145,10 @@ func (s *APIServer) InstallDevOpsAPIs() {
 		s.S3Client,
 		s.Config.JenkinsOptions.Host,
 		s.KubernetesClient,
+		s.Client,
 		jenkinsCore)
 	utilruntime.Must(err)
-	devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, s.RuntimeCache, jenkinsCore, s.Config)
+	devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, s.RuntimeCache, jenkinsCore, s.S3Client, s.Config)
 	oauth.AddToContainer(s.container,
 		auth.NewTokenOperator(
 			s.CacheClient,
@@ -199,18 +200,23 @@ func (s *APIServer) Run(stopCh context.Context) (err error) {
 }
 
 func (s *APIServer) buildHandlerChain(stopCh <-chan struct{}) {
+	handler := s.Server.Handler
+	handler = filters.WithKubeAPIServer(handler, s.KubernetesClient.Config(), &errorResponder{})
+	s.Server.Handler = withAuthentication(handler, s.Config.AuthMode)
+}
+
+// withAuthentication authenticates the requests. The requests without the header Authorization are anonymous,
+// such as the webhooks which are verified by their own secrets or tokens.
+func withAuthentication(handler http.Handler, authMode apiserverconfig.AuthMode) http.Handler {
 	requestInfoResolver := &request.RequestInfoFactory{
 		APIPrefixes:          sets.NewString("api", "apis", "kapis", "kapi"),
 		GrouplessAPIPrefixes: sets.NewString("api", "kapi"),
 	}
 
-	handler := s.Server.Handler
-	handler = filters.WithKubeAPIServer(handler, s.KubernetesClient.Config(), &errorResponder{})
-
 	authenticators := make([]authenticator.Request, 0)
 	authenticators = append(authenticators, anonymous.NewAuthenticator())
 
-	switch s.Config.AuthMode {
+	switch authMode {
 	case apiserverconfig.AuthModeToken:
 		authenticators = append(authenticators, bearertoken.New(devopsbearertoken.New()))
 	default:
@@ -218,9 +224,7 @@ func (s *APIServer) buildHandlerChain(stopCh <-chan struct{}) {
 	}
 
 	handler = filters.WithAuthentication(handler, unionauth.New(authenticators...))
-	handler = filters.WithRequestInfo(handler, requestInfoResolver)
-
-	s.Server.Handler = handler
+	return filters.WithRequestInfo(handler, requestInfoResolver)
 }
 
 func (s *APIServer) waitForResourceSync(stopCh context.Context) error {
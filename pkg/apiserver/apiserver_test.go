@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverruntime "github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+	apiserverconfig "github.com/kubesphere/ks-devops/pkg/config"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/webhook"
+	"github.com/stretchr/testify/assert"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// the generic webhooks are called by the third parties, they must pass the authentication of the apiserver
+func TestWithAuthentication_triggerPipeline(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{}
+	pipeline.SetName("webhook")
+	pipeline.SetNamespace("default")
+	pipeline.Spec.Type = v1alpha3.NoScmPipelineType
+	pipeline.Spec.Pipeline = &v1alpha3.NoScmPipeline{
+		Name:           "webhook",
+		GenericWebhook: &v1alpha3.GenericWebhook{Enable: true, Token: "secret"},
+	}
+
+	tests := []struct {
+		name          string
+		uri           string
+		header        map[string]string
+		status        int
+		wantTriggered bool
+	}{{
+		name:          "token in query",
+		uri:           "/namespaces/default/pipelines/webhook/trigger?token=secret",
+		status:        http.StatusOK,
+		wantTriggered: true,
+	}, {
+		name:          "token in header",
+		uri:           "/namespaces/default/pipelines/webhook/trigger",
+		header:        map[string]string{"token": "secret"},
+		status:        http.StatusOK,
+		wantTriggered: true,
+	}, {
+		name:   "bearer token is rejected by the authentication",
+		uri:    "/namespaces/default/pipelines/webhook/trigger",
+		header: map[string]string{"Authorization": "Bearer secret"},
+		status: http.StatusUnauthorized,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pipeline.DeepCopy()).Build()
+
+			container := restful.NewContainer()
+			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+			webhook.RegisterWebhooks(fakeClient, wsWithGroup, core.JenkinsCore{})
+			container.Add(wsWithGroup)
+			handler := withAuthentication(container, apiserverconfig.AuthModeToken)
+
+			httpRequest, _ := http.NewRequest(http.MethodPost,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.uri, strings.NewReader(`{}`))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			for k, v := range tt.header {
+				httpRequest.Header.Set(k, v)
+			}
+			httpWriter := httptest.NewRecorder()
+			handler.ServeHTTP(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code, httpWriter.Body.String())
+
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, fakeClient.List(context.TODO(), pipelineRuns, client.InNamespace("default")))
+			assert.Equal(t, tt.wantTriggered, len(pipelineRuns.Items) == 1)
+		})
+	}
+}
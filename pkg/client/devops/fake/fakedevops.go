@@ -431,6 +431,10 @@ func (d *Devops) DeleteCredentialInProject(projectId, id string) (string, error)
 	return "", nil
 }
 
+func (d *Devops) GetKubeConfigCredentialStoreType() string {
+	return ""
+}
+
 // BuildGetter
 func (d *Devops) GetProjectPipelineBuildByType(projectId, pipelineId string, status string) (*devops.Build, error) {
 	return nil, nil
@@ -481,6 +481,8 @@ func createMultiBranchPipelineConfigXml(projectName string, pipeline *devopsv1al
 		internal.AppendGithubSourceToEtree(source, pipeline.GitHubSource)
 	case devopsv1alpha3.SourceTypeGitlab:
 		internal.AppendGitlabSourceToEtree(source, pipeline.GitlabSource)
+	case devopsv1alpha3.SourceTypeGitea:
+		internal.AppendGiteaSourceToEtree(source, pipeline.GiteaSource)
 	case devopsv1alpha3.SourceTypeSVN:
 		internal.AppendSvnSourceToEtree(source, pipeline.SvnSource)
 	case devopsv1alpha3.SourceTypeSingleSVN:
@@ -557,6 +559,9 @@ func parseMultiBranchPipelineConfigXml(config string) (*devopsv1alpha3.MultiBran
 				case "io.jenkins.plugins.gitlabbranchsource.GitLabSCMSource":
 					pipeline.GitlabSource = internal.GetGitlabSourceFromEtree(source)
 					pipeline.SourceType = devopsv1alpha3.SourceTypeGitlab
+				case "org.jenkinsci.plugin.gitea.GiteaSCMSource":
+					pipeline.GiteaSource = internal.GetGiteaSourceFromEtree(source)
+					pipeline.SourceType = devopsv1alpha3.SourceTypeGitea
 
 				case "jenkins.plugins.git.GitSCMSource":
 					pipeline.SourceType = devopsv1alpha3.SourceTypeGit
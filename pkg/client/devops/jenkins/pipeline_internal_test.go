@@ -443,6 +443,33 @@ func Test_MultiBranchPipelineConfig_Source(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name:        "",
+			Description: "for test",
+			ScriptPath:  "Jenkinsfile",
+			SourceType:  "gitea",
+			TimerTrigger: &devopsv1alpha3.TimerTrigger{
+				Interval: "12345566",
+			},
+			GiteaSource: &devopsv1alpha3.GiteaSource{
+				ServerUrl:            "https://gitea.com",
+				Owner:                "kubesphere",
+				Repo:                 "devops",
+				CredentialId:         "gitea",
+				DiscoverBranches:     1,
+				DiscoverPRFromOrigin: 2,
+				DiscoverTags:         true,
+				DiscoverPRFromForks: &devopsv1alpha3.DiscoverPRFromForks{
+					Strategy: 1,
+					Trust:    3,
+				},
+				CloneOption: &devopsv1alpha3.GitCloneOption{
+					Timeout: 10,
+					Depth:   10,
+				},
+				RegexFilter: "*-dev",
+			},
+		},
 		{
 			Name:        "",
 			Description: "for test",
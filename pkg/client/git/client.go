@@ -54,6 +54,9 @@ func (c *ClientFactory) GetClient() (client *goscm.Client, err error) {
 		provider = "bitbucketcloud"
 	case "bitbucket-server":
 		provider = "bitbucketserver"
+	case "forgejo":
+		// Forgejo is compatible with the API of Gitea
+		provider = "gitea"
 	}
 
 	if c.Server == "https://api.bitbucket.org" || c.Server == "https://bitbucket.org" {
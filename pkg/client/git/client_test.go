@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha1"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/stretchr/testify/assert"
@@ -183,6 +184,15 @@ func TestGetClient(t *testing.T) {
 			assert.Nil(t, err)
 			return false
 		},
+	}, {
+		name: "forgejo without server",
+		fields: fields{
+			provider: "forgejo",
+		},
+		wantErr: func(tt assert.TestingT, err error, i ...interface{}) bool {
+			assert.Equal(t, factory.ErrMissingGitServerURL, err)
+			return false
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
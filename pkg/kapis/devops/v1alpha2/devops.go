@@ -17,9 +17,11 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -33,6 +35,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/client/devops/jenkins"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/webhook"
 	"github.com/kubesphere/ks-devops/pkg/models/devops"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 )
@@ -853,7 +856,24 @@ func (h *ProjectPipelineHandler) GithubWebhook(req *restful.Request, resp *restf
 }
 
 func (h *ProjectPipelineHandler) genericWebhook(req *restful.Request, resp *restful.Response) {
-	if res, err := h.devopsOperator.GenericWebhook(req.Request); err != nil {
+	var delivery *webhook.Delivery
+	if h.deliveries != nil {
+		delivery = webhook.NewDelivery(req.Request, webhook.ProviderGeneric)
+		if payload, err := io.ReadAll(req.Request.Body); err == nil {
+			delivery.SetPayload(payload)
+			req.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		}
+	}
+
+	res, err := h.devopsOperator.GenericWebhook(req.Request)
+	if delivery != nil {
+		// the token is verified by Jenkins, only the deliveries which triggered some Pipelines are kept
+		if delivery.SetGenericWebhookResult(res, err); len(delivery.Pipelines) > 0 {
+			delivery.Verified = true
+			h.deliveries.Record(req.Request.Context(), delivery)
+		}
+	}
+	if err != nil {
 		parseErr(err, resp)
 		return
 	} else {
@@ -23,6 +23,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/client/k8s"
 	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/client/sonarqube"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/webhook"
 	"github.com/kubesphere/ks-devops/pkg/models/devops"
 )
 
@@ -30,6 +31,8 @@ type ProjectPipelineHandler struct {
 	k8sClient               k8s.Client
 	devopsOperator          devops.DevopsOperator
 	projectCredentialGetter devops.ProjectCredentialGetter
+	// deliveries keeps the deliveries of the generic webhook trigger
+	deliveries *webhook.DeliveryStore
 }
 
 type PipelineSonarHandler struct {
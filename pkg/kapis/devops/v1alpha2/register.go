@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubesphere/ks-devops/pkg/api"
 	apidevops "github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha1"
@@ -41,6 +42,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/client/sonarqube"
 	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/webhook"
 )
 
 // TODO perhaps we can find a better way to declaim the permission needs of the apiserver
@@ -53,12 +55,12 @@ var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha2"
 
 func AddToContainer(container *restful.Container, ksInformers externalversions.SharedInformerFactory,
 	devopsClient devops.Interface, sonarqubeClient sonarqube.SonarInterface, ksClient versioned.Interface,
-	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore) (wss []*restful.WebService, err error) {
+	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, genericClient client.Client, jenkinsClient core.JenkinsCore) (wss []*restful.WebService, err error) {
 	wsWithGroup := runtime.NewWebService(GroupVersion)
 	wss = append(wss, wsWithGroup)
 	// the API endpoint with group version will be removed in the future release
 	if err = addToContainerWithWebService(container, ksInformers, devopsClient, sonarqubeClient, ksClient,
-		s3Client, endpoint, k8sClient, jenkinsClient, wsWithGroup); err != nil {
+		s3Client, endpoint, k8sClient, genericClient, jenkinsClient, wsWithGroup); err != nil {
 		return
 	}
 
@@ -67,8 +69,8 @@ func AddToContainer(container *restful.Container, ksInformers externalversions.S
 
 func addToContainerWithWebService(container *restful.Container, ksInformers externalversions.SharedInformerFactory,
 	devopsClient devops.Interface, sonarqubeClient sonarqube.SonarInterface, ksClient versioned.Interface,
-	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore, ws *restful.WebService) error {
-	err := AddPipelineToWebService(ws, devopsClient, k8sClient)
+	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, genericClient client.Client, jenkinsClient core.JenkinsCore, ws *restful.WebService) error {
+	err := AddPipelineToWebService(ws, devopsClient, k8sClient, genericClient)
 	if err != nil {
 		return err
 	}
@@ -92,8 +94,11 @@ func addToContainerWithWebService(container *restful.Container, ksInformers exte
 	return nil
 }
 
-func AddPipelineToWebService(webservice *restful.WebService, devopsClient devops.Interface, k8sClient k8s.Client) error {
+func AddPipelineToWebService(webservice *restful.WebService, devopsClient devops.Interface, k8sClient k8s.Client, genericClient client.Client) error {
 	projectPipelineHandler := NewProjectPipelineHandler(devopsClient, k8sClient)
+	if genericClient != nil {
+		projectPipelineHandler.deliveries = webhook.NewDeliveryStore(genericClient)
+	}
 
 	webservice.Route(webservice.GET("/namespaces/{devops}/credentials/{credential}/usage").
 		To(projectPipelineHandler.GetProjectCredentialUsage).
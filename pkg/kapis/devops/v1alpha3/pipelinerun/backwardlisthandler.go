@@ -20,19 +20,19 @@ import (
 	"context"
 
 	"github.com/kubesphere/ks-devops/pkg/api"
-	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 	resourcesv1alpha3 "kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
 )
 
 type backwardListHandler struct {
-	client client.Client
+	client   client.Client
+	s3Client s3.Interface
 }
 
 func (b backwardListHandler) Comparator() resourcesv1alpha3.CompareFunc {
@@ -65,13 +65,10 @@ func (b backwardListHandler) backwardFilter(object runtime.Object) bool {
 	if pr, valid := checkPipelineRun(object); valid {
 		statusJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]
 		if !ok {
-			if pipelineRunStore, err := cmstore.NewConfigMapStore(context.Background(), types.NamespacedName{
-				Namespace: pr.Namespace,
-				Name:      pr.Name,
-			}, b.client); err == nil {
+			if pipelineRunStore, err := newPipelineRunDataStore(context.Background(), pr, b.client, b.s3Client); err == nil {
 				statusJSON = pipelineRunStore.GetStatus()
 			} else {
-				klog.Error(err, "failed to get status from configmap store")
+				klog.Error(err, "failed to get status from PipelineRun data store")
 			}
 		}
 
@@ -26,9 +26,9 @@ import (
 	"net/url"
 	"strconv"
 
-	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 
 	"github.com/kubesphere/ks-devops/pkg/kapis"
 
@@ -39,6 +39,7 @@ import (
 	apiserverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	devopsClient "github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 	resourcesv1alpha3 "kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
@@ -48,6 +49,7 @@ import (
 type apiHandlerOption struct {
 	devopsClient devopsClient.Interface
 	client       client.Client
+	s3Client     s3.Interface
 }
 
 // apiHandler contains functions to handle coming request and give a response.
@@ -86,6 +88,15 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 		kapis.HandleError(request, response, err)
 		return
 	}
+	// only list the attempts of the specific PipelineRun
+	if retryOf := request.QueryParameter("retryOf"); retryOf != "" {
+		rq, err := labels.NewRequirement(v1alpha3.PipelineRunRetryOfLabelKey, selection.Equals, []string{retryOf})
+		if err != nil {
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+		labelSelector = labelSelector.Add(*rq)
+	}
 
 	opts := make([]client.ListOption, 0, 3)
 	opts = append(opts, client.InNamespace(pipeline.Namespace))
@@ -101,12 +112,12 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 		return
 	}
 
-	lh := listHandler{ctx: request.Request.Context(), client: h.client}
+	lh := listHandler{ctx: request.Request.Context(), client: h.client, s3Client: h.s3Client}
 	compareFunc := lh.Comparator()
 	filterFunc := lh.Filter()
 	transformFunc := lh.Transformer()
 	if backward {
-		blh := backwardListHandler{client: h.client}
+		blh := backwardListHandler{client: h.client, s3Client: h.s3Client}
 		compareFunc = blh.Comparator()
 		filterFunc = blh.Filter()
 		transformFunc = blh.Transformer()
@@ -162,6 +173,89 @@ func (h *apiHandler) createPipelineRun(request *restful.Request, response *restf
 	_ = response.WriteEntity(pr)
 }
 
+// RerunPayload is the payload of re-running a PipelineRun
+type RerunPayload struct {
+	// Parameters override the parameters with the same names of the original PipelineRun
+	Parameters []devops.Parameter `json:"parameters,omitempty"`
+	// Branch overrides the SCM reference name of a multi-branch Pipeline
+	Branch string `json:"branch,omitempty"`
+	// FromFailedStage restarts the Jenkins build of the original PipelineRun from the failed stage.
+	// The inputs cannot be overridden in this mode.
+	FromFailedStage bool `json:"fromFailedStage,omitempty"`
+}
+
+// rerunPipelineRun creates a new PipelineRun with the same or overridden inputs of the given one
+func (h *apiHandler) rerunPipelineRun(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+	payload := RerunPayload{}
+	if err := request.ReadEntity(&payload); err != nil && err != io.EOF {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	original := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, original); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if original.Spec.PipelineRef == nil {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("cannot re-run the PipelineRun '%s/%s' without Pipeline", nsName, prName))
+		return
+	}
+	pipeline := &v1alpha3.Pipeline{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: original.Spec.PipelineRef.Name}, pipeline); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	var stageID string
+	if payload.FromFailedStage {
+		var err error
+		if stageID, err = getFailedStageID(original); err == nil && (len(payload.Parameters) > 0 || payload.Branch != "") {
+			err = fmt.Errorf("cannot override the inputs when re-running from the failed stage")
+		}
+		if err != nil {
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+	}
+
+	scm := original.Spec.SCM.DeepCopy()
+	if payload.Branch != "" {
+		var err error
+		if scm, err = CreateScm(&pipeline.Spec, payload.Branch); err != nil {
+			kapis.HandleBadRequest(response, request, err)
+			return
+		}
+	}
+
+	user, ok := apiserverrequest.UserFrom(ctx)
+	if !ok || user == nil {
+		// should never happen
+		err := fmt.Errorf("unauthenticated user entered to re-run PipelineRun '%s/%s'", nsName, prName)
+		kapis.HandleUnauthorized(response, request, err)
+		return
+	}
+
+	pr := CreateBarePipelineRun(pipeline, overrideParameters(original.Spec.Parameters, convertParameters(&devops.RunPayload{
+		Parameters: payload.Parameters,
+	})), scm)
+	pr.Annotations[v1alpha3.PipelineRunRerunOfAnnoKey] = original.Name
+	if stageID != "" {
+		pr.Annotations[v1alpha3.PipelineRunRerunFromStageAnnoKey] = stageID
+	}
+	if user.GetName() != "" {
+		pr.Annotations[v1alpha3.PipelineRunCreatorAnnoKey] = user.GetName()
+	}
+	if err := h.client.Create(ctx, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteHeaderAndEntity(http.StatusCreated, pr)
+}
+
 func (h *apiHandler) getPipelineRun(request *restful.Request, response *restful.Response) {
 	nsName := request.PathParameter("namespace")
 	prName := request.PathParameter("pipelinerun")
@@ -176,8 +270,7 @@ func (h *apiHandler) getPipelineRun(request *restful.Request, response *restful.
 
 	// get status
 	if _, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]; !ok {
-		pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
-			Namespace: nsName, Name: prName}, h.client)
+		pipelineRunStore, err := newPipelineRunDataStore(ctx, &pr, h.client, h.s3Client)
 		if err != nil && !errors.IsNotFound(err) {
 			kapis.HandleError(request, response, err)
 			return
@@ -205,10 +298,7 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 	// get stage status
 	stagesJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]
 	if !ok {
-		if pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
-			Namespace: namespaceName,
-			Name:      pipelineRunName,
-		}, h.client); err != nil {
+		if pipelineRunStore, err := newPipelineRunDataStore(ctx, pr, h.client, h.s3Client); err != nil {
 			// If the stages status does not exist, set it as an empty array
 			stagesJSON = "[]"
 		} else {
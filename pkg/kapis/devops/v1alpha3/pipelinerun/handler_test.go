@@ -51,7 +51,7 @@ func TestApis(t *testing.T) {
 		Spec: v1alpha3.PipelineSpec{
 			Type: v1alpha3.NoScmPipelineType,
 		},
-	}).Build())
+	}).Build(), nil)
 	restful.DefaultContainer.Add(wsWithGroup)
 
 	type args struct {
@@ -178,3 +178,101 @@ func TestGetNodeDetails(t *testing.T) {
  }
 ]`, string(body))
 }
+
+func TestRerunPipelineRun(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	completionTime := metav1.Now()
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipeline", Namespace: "ns"},
+		Spec:       v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType},
+	}
+	original := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "original",
+			Namespace:   "ns",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+		},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineRef: &v1.ObjectReference{Name: "pipeline"},
+			Parameters:  []v1alpha3.Parameter{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		},
+		Status: v1alpha3.PipelineRunStatus{
+			Phase:          v1alpha3.Failed,
+			CompletionTime: &completionTime,
+			Summary:        &v1alpha3.PipelineRunSummary{FailedStep: &v1alpha3.FailedStep{StageID: "12"}},
+		},
+	}
+	bob := request.WithUser(request.NewContext(), &user.DefaultInfo{Name: "bob"})
+
+	tests := []struct {
+		name    string
+		payload *RerunPayload
+		ctx     context.Context
+		status  int
+		verify  func(t *testing.T, pr *v1alpha3.PipelineRun)
+	}{{
+		name:   "unauthenticated user",
+		ctx:    request.NewContext(),
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "rerun with the same inputs",
+		ctx:    bob,
+		status: http.StatusCreated,
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, original.Spec.Parameters, pr.Spec.Parameters)
+			assert.Equal(t, "original", pr.Annotations[v1alpha3.PipelineRunRerunOfAnnoKey])
+			assert.Equal(t, "bob", pr.Annotations[v1alpha3.PipelineRunCreatorAnnoKey])
+			assert.Empty(t, pr.Annotations[v1alpha3.PipelineRunRerunFromStageAnnoKey])
+		},
+	}, {
+		name:    "rerun with overridden parameters",
+		payload: &RerunPayload{Parameters: []devops.Parameter{{Name: "b", Value: "3"}}},
+		ctx:     bob,
+		status:  http.StatusCreated,
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, []v1alpha3.Parameter{{Name: "a", Value: "1"}, {Name: "b", Value: "3"}}, pr.Spec.Parameters)
+		},
+	}, {
+		name:    "rerun from the failed stage",
+		payload: &RerunPayload{FromFailedStage: true},
+		ctx:     bob,
+		status:  http.StatusCreated,
+		verify: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, "12", pr.Annotations[v1alpha3.PipelineRunRerunFromStageAnnoKey])
+		},
+	}, {
+		name:    "override the inputs when rerun from the failed stage",
+		payload: &RerunPayload{FromFailedStage: true, Parameters: []devops.Parameter{{Name: "b", Value: "3"}}},
+		ctx:     bob,
+		status:  http.StatusBadRequest,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy(), original.DeepCopy()).Build()
+			ws := runtime.NewWebService(v1alpha3.GroupVersion)
+			RegisterRoutes(ws, fakedevops.NewFakeDevops(nil), c, nil)
+			container := restful.NewContainer()
+			container.Add(ws)
+
+			body := io.Reader(http.NoBody)
+			if tt.payload != nil {
+				data, _ := json.Marshal(tt.payload)
+				body = bytes.NewBuffer(data)
+			}
+			httpRequest, _ := http.NewRequestWithContext(tt.ctx, http.MethodPost,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/pipelineruns/original/rerun", body)
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code)
+
+			if tt.verify != nil {
+				pr := &v1alpha3.PipelineRun{}
+				assert.Nil(t, json.Unmarshal(httpWriter.Body.Bytes(), pr))
+				tt.verify(t, pr)
+			}
+		})
+	}
+}
@@ -22,9 +22,8 @@ import (
 
 	"github.com/kubesphere/ks-devops/pkg/api"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
-	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 	resourcesv1alpha3 "kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
@@ -33,8 +32,9 @@ import (
 
 // listHandler is default implementation for PipelineRun.
 type listHandler struct {
-	ctx    context.Context
-	client client.Client
+	ctx      context.Context
+	client   client.Client
+	s3Client s3.Interface
 }
 
 // Comparator compares times first, which is from start time and creation time(only when start time is nil or zero).
@@ -79,12 +79,11 @@ func (b listHandler) Transformer() resourcesv1alpha3.TransformFunc {
 
 		// get status
 		if _, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]; !ok {
-			pipelineRunStore, err := cmstore.NewConfigMapStore(b.ctx, types.NamespacedName{
-				Namespace: pr.Namespace, Name: pr.Name}, b.client)
+			pipelineRunStore, err := newPipelineRunDataStore(b.ctx, pr, b.client, b.s3Client)
 			if err == nil {
 				pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey] = pipelineRunStore.GetStatus()
 			} else {
-				klog.Error(err, "failed to get status from configmap store")
+				klog.Error(err, "failed to get status from PipelineRun data store")
 			}
 		}
 
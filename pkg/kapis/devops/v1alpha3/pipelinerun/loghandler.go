@@ -0,0 +1,190 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+// logPollPeriod is the period of polling the log of a running PipelineRun in the follow mode
+var logPollPeriod = 2 * time.Second
+
+// logOption selects which part of the PipelineRun log to read
+type logOption struct {
+	// nodeID and stepID select the log of a step, the whole log will be read if they are empty
+	nodeID string
+	stepID string
+	// start is the byte offset of the log
+	start int64
+}
+
+// getPipelineRunLog writes the log of a PipelineRun as a chunked plain text stream.
+// It keeps writing the new log until the PipelineRun completed if follow mode is on.
+func (h *apiHandler) getPipelineRunLog(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	follow, _ := strconv.ParseBool(request.QueryParameter("follow"))
+	opt := logOption{
+		nodeID: request.QueryParameter("node"),
+		stepID: request.QueryParameter("step"),
+	}
+	if (opt.nodeID == "") != (opt.stepID == "") {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("both node and step are required to get the log of a step"))
+		return
+	}
+	if start := request.QueryParameter("start"); start != "" {
+		var err error
+		if opt.start, err = strconv.ParseInt(start, 10, 64); err != nil || opt.start < 0 {
+			kapis.HandleBadRequest(response, request, fmt.Errorf("invalid start offset: %s", start))
+			return
+		}
+	}
+
+	ctx := request.Request.Context()
+	key := client.ObjectKey{Namespace: nsName, Name: prName}
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, key, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	for wroteHeader := false; ; {
+		// the log must be complete if the PipelineRun has completed before reading
+		completed := pr.HasCompleted()
+		log, err := h.readPipelineRunLog(ctx, pr, opt)
+		if err != nil {
+			if !wroteHeader {
+				kapis.HandleError(request, response, err)
+			} else {
+				klog.Errorf("failed to read the log of PipelineRun %s, error: %v", key, err)
+			}
+			return
+		}
+
+		if !wroteHeader {
+			response.AddHeader("Content-Type", "text/plain; charset=utf-8")
+			response.AddHeader("X-Content-Type-Options", "nosniff")
+			response.WriteHeader(http.StatusOK)
+			wroteHeader = true
+		}
+		if len(log) > 0 {
+			if _, err = response.Write(log); err != nil {
+				return
+			}
+			response.Flush()
+			opt.start += int64(len(log))
+		}
+
+		if !follow || completed {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logPollPeriod):
+		}
+		if err = h.client.Get(ctx, key, pr); err != nil {
+			klog.Errorf("failed to get PipelineRun %s, error: %v", key, err)
+			return
+		}
+	}
+}
+
+// readPipelineRunLog reads the log from the start offset.
+// The log of a completed PipelineRun comes from the data store if it was kept there, otherwise from Jenkins.
+func (h *apiHandler) readPipelineRunLog(ctx context.Context, pr *v1alpha3.PipelineRun, opt logOption) ([]byte, error) {
+	if pr.HasCompleted() {
+		if log, ok := h.readStoredPipelineRunLog(ctx, pr, opt); ok {
+			if opt.start >= int64(len(log)) {
+				return nil, nil
+			}
+			return []byte(log[opt.start:]), nil
+		}
+	}
+
+	buildID, exists := pr.GetPipelineRunID()
+	if !exists {
+		// the PipelineRun has not been triggered yet
+		return nil, nil
+	}
+	pipelineName := pr.Labels[v1alpha3.PipelineNameLabelKey]
+	httpParameters := &devops.HttpParameters{
+		Method: http.MethodGet,
+		Header: http.Header{},
+		Url:    &url.URL{RawQuery: fmt.Sprintf("start=%d", opt.start)},
+	}
+
+	if pr.Spec.IsMultiBranchPipeline() {
+		branchName := pr.GetRefName()
+		if opt.nodeID != "" {
+			log, _, err := h.devopsClient.GetBranchStepLog(pr.Namespace, pipelineName, branchName, buildID, opt.nodeID, opt.stepID, httpParameters)
+			return log, err
+		}
+		return h.devopsClient.GetBranchRunLog(pr.Namespace, pipelineName, branchName, buildID, httpParameters)
+	}
+	if opt.nodeID != "" {
+		log, _, err := h.devopsClient.GetStepLog(pr.Namespace, pipelineName, buildID, opt.nodeID, opt.stepID, httpParameters)
+		return log, err
+	}
+	return h.devopsClient.GetRunLog(pr.Namespace, pipelineName, buildID, httpParameters)
+}
+
+// readStoredPipelineRunLog reads the whole log or the step log from the PipelineRun data store
+func (h *apiHandler) readStoredPipelineRunLog(ctx context.Context, pr *v1alpha3.PipelineRun, opt logOption) (log string, ok bool) {
+	pipelineRunStore, err := newPipelineRunDataStore(ctx, pr, h.client, h.s3Client)
+	if err != nil {
+		return
+	}
+
+	if opt.nodeID == "" {
+		log = pipelineRunStore.GetAllLog()
+		return log, log != ""
+	}
+
+	// the step logs are indexed by the position of the stage and step
+	var stages []pipelinerun.NodeDetail
+	if err = json.Unmarshal([]byte(pipelineRunStore.GetStages()), &stages); err != nil {
+		return
+	}
+	for i := range stages {
+		if stages[i].ID != opt.nodeID {
+			continue
+		}
+		for j := range stages[i].Steps {
+			if stages[i].Steps[j].ID == opt.stepID {
+				log = pipelineRunStore.GetStepLog(i, j)
+				return log, log != ""
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	fakedevops "github.com/kubesphere/ks-devops/pkg/client/devops/fake"
+	fakes3 "github.com/kubesphere/ks-devops/pkg/client/s3/fake"
+)
+
+func TestGetPipelineRunLog(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := metav1.Now()
+	pipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "pr1",
+			Namespace: "ns",
+			Annotations: map[string]string{
+				v1alpha3.JenkinsPipelineRunIDAnnoKey: "1",
+				v1alpha3.PipelineRunDataStoreAnnoKey: "s3",
+			},
+		},
+		Status: v1alpha3.PipelineRunStatus{CompletionTime: &now},
+	}
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		wantStatus int
+		wantLog    string
+	}{{
+		name:       "the whole log",
+		wantStatus: http.StatusOK,
+		wantLog:    "hello\nworld\n",
+	}, {
+		name:       "the whole log from an offset",
+		query:      url.Values{"start": []string{"6"}},
+		wantStatus: http.StatusOK,
+		wantLog:    "world\n",
+	}, {
+		name:       "the offset is out of range",
+		query:      url.Values{"start": []string{"100"}},
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "the log of a step",
+		query:      url.Values{"node": []string{"3"}, "step": []string{"5"}},
+		wantStatus: http.StatusOK,
+		wantLog:    "world\n",
+	}, {
+		name:       "the log of a step which is not stored",
+		query:      url.Values{"node": []string{"3"}, "step": []string{"6"}},
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "without the step ID",
+		query:      url.Values{"node": []string{"3"}},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "invalid offset",
+		query:      url.Values{"start": []string{"-1"}},
+		wantStatus: http.StatusBadRequest,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3Client := fakes3.NewFakeS3(&fakes3.Object{
+				Key:  "pipelineruns/ns/pr1/log-all",
+				Body: bytes.NewBufferString("hello\nworld\n"),
+			}, &fakes3.Object{
+				Key:  "pipelineruns/ns/pr1/stage",
+				Body: bytes.NewBufferString(`[{"id":"3","steps":[{"id":"4"},{"id":"5"}]}]`),
+			}, &fakes3.Object{
+				Key:  "pipelineruns/ns/pr1/log-step-0-1",
+				Body: bytes.NewBufferString("world\n"),
+			})
+			handler := newAPIHandler(apiHandlerOption{
+				devopsClient: fakedevops.NewFakeDevops(nil),
+				client:       fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
+				s3Client:     s3Client,
+			})
+
+			httpRequest, _ := http.NewRequest(http.MethodGet, "/namespaces/ns/pipelineruns/pr1/log?"+tt.query.Encode(), nil)
+			req := restful.NewRequest(httpRequest)
+			req.PathParameters()["namespace"] = "ns"
+			req.PathParameters()["pipelinerun"] = "pr1"
+			recorder := httptest.NewRecorder()
+			handler.getPipelineRunLog(req, restful.NewResponse(recorder))
+
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantLog, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -28,15 +28,17 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	dclient "github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 )
 
 // RegisterRoutes register routes into web service.
-func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c client.Client) {
+func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c client.Client, s3Client s3.Interface) {
 	handler := newAPIHandler(apiHandlerOption{
 		devopsClient: devopsClient,
 		client:       c,
+		s3Client:     s3Client,
 	})
 
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/pipelineruns").
@@ -48,6 +50,7 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 		Param(ws.QueryParameter(query.ParameterPage, "page").Required(false).DataFormat("page=%d").DefaultValue("page=1")).
 		Param(ws.QueryParameter(query.ParameterLimit, "limit").Required(false)).
 		Param(ws.QueryParameter("branch", "The name of SCM reference")).
+		Param(ws.QueryParameter("retryOf", "The name of the first PipelineRun, only list the attempts of it")).
 		Param(ws.QueryParameter("backward", "Backward compatibility for v1alpha2 API "+
 			"`/devops/{devops}/pipelines/{pipeline}/runs`. By default, the backward is true. If you want to list "+
 			"full data of PipelineRuns, just set the parameters to false.").
@@ -73,6 +76,15 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
 
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelineruns/{pipelinerun}/rerun").
+		To(handler.rerunPipelineRun).
+		Doc("Re-run a PipelineRun with the same or overridden inputs").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Reads(RerunPayload{}).
+		Returns(http.StatusCreated, api.StatusOK, v1alpha3.PipelineRun{}))
+
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodedetails").
 		To(handler.getNodeDetails).
 		Doc("Get node details including steps and approvable for a given Pipeline").
@@ -81,6 +93,23 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, []pipelinerun.NodeDetail{}))
 
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/log").
+		To(handler.getPipelineRunLog).
+		Doc("Stream the log of a PipelineRun, or the log of a step").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the PipelineRun completed").
+			DataType("boolean").
+			DefaultValue("false")).
+		Param(ws.QueryParameter("start", "The byte offset of the log to start from, it's useful to resume the stream").
+			DataType("integer").
+			DefaultValue("0")).
+		Param(ws.QueryParameter("node", "The node ID of the step, only get the log of a step if it's not empty")).
+		Param(ws.QueryParameter("step", "The step ID, only get the log of a step if it's not empty")).
+		Produces("text/plain").
+		Returns(http.StatusOK, api.StatusOK, nil))
+
 	// download PipelineRun artifact
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/artifacts/download").
 		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
@@ -36,7 +36,7 @@ func TestAPIsExist(t *testing.T) {
 	schema, err := v1alpha1.SchemeBuilder.Register().Build()
 	assert.Nil(t, err)
 
-	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), fake.NewClientBuilder().WithScheme(schema).Build())
+	RegisterRoutes(wsWithGroup, fakedevops.NewFakeDevops(nil), fake.NewClientBuilder().WithScheme(schema).Build(), nil)
 	restful.DefaultContainer.Add(wsWithGroup)
 
 	type args struct {
@@ -64,12 +64,24 @@ func TestAPIsExist(t *testing.T) {
 			method: http.MethodGet,
 			uri:    "/namespaces/fake/pipelineruns/fake",
 		},
+	}, {
+		name: "rerun a pipelinerun",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/namespaces/fake/pipelineruns/fake/rerun",
+		},
 	}, {
 		name: "get node details",
 		args: args{
 			method: http.MethodGet,
 			uri:    "/namespaces/fake/pipelineruns/fake/nodedetails",
 		},
+	}, {
+		name: "get the log",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/namespaces/fake/pipelineruns/fake/log",
+		},
 	}, {
 		name: "receive pipeline event",
 		args: args{
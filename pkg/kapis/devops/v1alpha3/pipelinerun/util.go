@@ -17,18 +17,24 @@ limitations under the License.
 package pipelinerun
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	s3store "github.com/kubesphere/ks-devops/pkg/store/s3"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 func buildLabelSelector(queryParam *query.Query, pipelineName string) (labels.Selector, error) {
@@ -42,6 +48,15 @@ func buildLabelSelector(queryParam *query.Query, pipelineName string) (labels.Se
 	return labelSelector, nil
 }
 
+// newPipelineRunDataStore returns the data store which keeps the data of the given PipelineRun.
+func newPipelineRunDataStore(ctx context.Context, pr *v1alpha3.PipelineRun, c client.Client, s3Client s3.Interface) (store.PipelineRunDataStore, error) {
+	key := client.ObjectKeyFromObject(pr)
+	if pr.Annotations[v1alpha3.PipelineRunDataStoreAnnoKey] == "s3" {
+		return s3store.NewS3Store(key, s3Client)
+	}
+	return cmstore.NewConfigMapStore(ctx, key, c)
+}
+
 func convertPipelineRunsToObject(prs []v1alpha3.PipelineRun) []runtime.Object {
 	var result []runtime.Object
 	for i := range prs {
@@ -67,6 +82,46 @@ func convertParameters(payload *devops.RunPayload) []v1alpha3.Parameter {
 	return parameters
 }
 
+// overrideParameters returns the parameters which are overridden by the ones with the same names
+func overrideParameters(parameters, overrides []v1alpha3.Parameter) []v1alpha3.Parameter {
+	result := make([]v1alpha3.Parameter, 0, len(parameters)+len(overrides))
+	indexes := make(map[string]int, len(parameters))
+	for _, parameter := range parameters {
+		indexes[parameter.Name] = len(result)
+		result = append(result, parameter)
+	}
+	for _, parameter := range overrides {
+		if index, ok := indexes[parameter.Name]; ok {
+			result[index] = parameter
+		} else {
+			indexes[parameter.Name] = len(result)
+			result = append(result, parameter)
+		}
+	}
+	return result
+}
+
+// getFailedStageID returns the ID of the failed stage of a completed PipelineRun
+func getFailedStageID(pr *v1alpha3.PipelineRun) (string, error) {
+	if !pr.HasCompleted() || pr.Status.Phase != v1alpha3.Failed {
+		return "", fmt.Errorf("only a failed PipelineRun can be re-run from the failed stage")
+	}
+	if _, ok := pr.GetPipelineRunID(); !ok {
+		return "", fmt.Errorf("the PipelineRun was never triggered")
+	}
+	if summary := pr.Status.Summary; summary != nil {
+		if summary.FailedStep != nil {
+			return summary.FailedStep.StageID, nil
+		}
+		for _, stage := range summary.Stages {
+			if stage.Phase == v1alpha3.Failed {
+				return stage.ID, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no failed stage found in the PipelineRun")
+}
+
 // CreateScm creates SCM for multi-branch Pipeline.
 func CreateScm(ps *v1alpha3.PipelineSpec, branch string) (*v1alpha3.SCM, error) {
 	branch = strings.TrimPrefix(branch, "refs/heads/")
@@ -227,3 +227,70 @@ func TestCreatePipelineRun(t *testing.T) {
 	assert.Equal(t, pipelineRun.Namespace, pipeline.Namespace)
 	assert.NotNil(t, pipelineRun.Annotations)
 }
+
+func Test_overrideParameters(t *testing.T) {
+	parameters := []v1alpha3.Parameter{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+	assert.Equal(t, parameters, overrideParameters(parameters, nil))
+	assert.Equal(t, []v1alpha3.Parameter{{Name: "a", Value: "1"}, {Name: "b", Value: "3"}, {Name: "c", Value: "4"}},
+		overrideParameters(parameters, []v1alpha3.Parameter{{Name: "b", Value: "3"}, {Name: "c", Value: "4"}}))
+	assert.Equal(t, []v1alpha3.Parameter{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}, parameters)
+}
+
+func Test_getFailedStageID(t *testing.T) {
+	completionTime := v1.Now()
+	newFailedPipelineRun := func(summary *v1alpha3.PipelineRunSummary) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+			},
+			Status: v1alpha3.PipelineRunStatus{
+				Phase:          v1alpha3.Failed,
+				CompletionTime: &completionTime,
+				Summary:        summary,
+			},
+		}
+	}
+	running := newFailedPipelineRun(nil)
+	running.Status.CompletionTime = nil
+	notTriggered := newFailedPipelineRun(nil)
+	notTriggered.Annotations = nil
+
+	tests := []struct {
+		name    string
+		pr      *v1alpha3.PipelineRun
+		want    string
+		wantErr bool
+	}{{
+		name:    "running",
+		pr:      running,
+		wantErr: true,
+	}, {
+		name:    "not triggered",
+		pr:      notTriggered,
+		wantErr: true,
+	}, {
+		name:    "without summary",
+		pr:      newFailedPipelineRun(nil),
+		wantErr: true,
+	}, {
+		name: "from the failed step",
+		pr: newFailedPipelineRun(&v1alpha3.PipelineRunSummary{
+			FailedStep: &v1alpha3.FailedStep{StageID: "12"},
+			Stages:     []v1alpha3.StageSummary{{ID: "6", Phase: v1alpha3.Failed}},
+		}),
+		want: "12",
+	}, {
+		name: "from the failed stage",
+		pr: newFailedPipelineRun(&v1alpha3.PipelineRunSummary{
+			Stages: []v1alpha3.StageSummary{{ID: "6", Phase: v1alpha3.Succeeded}, {ID: "9", Phase: v1alpha3.Failed}},
+		}),
+		want: "9",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getFailedStageID(tt.pr)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
 	dclient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/k8s"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipeline"
@@ -58,7 +59,7 @@ var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"
 
 // AddToContainer adds web service into container.
 func AddToContainer(container *restful.Container, devopsClient dclient.Interface, k8sClient k8s.Client,
-	client client.Client, runtimeCache cache.Cache, jenkins core.JenkinsCore, cfg *config.Config) (wss []*restful.WebService) {
+	client client.Client, runtimeCache cache.Cache, jenkins core.JenkinsCore, s3Client s3.Interface, cfg *config.Config) (wss []*restful.WebService) {
 
 	services := []*restful.WebService{
 		runtime.NewWebService(v1alpha3.GroupVersion),
@@ -66,7 +67,7 @@ func AddToContainer(container *restful.Container, devopsClient dclient.Interface
 
 	for _, service := range services {
 		registerRoutes(cfg, devopsClient, k8sClient, client, runtimeCache, service)
-		pipelinerun.RegisterRoutes(service, devopsClient, client)
+		pipelinerun.RegisterRoutes(service, devopsClient, client, s3Client)
 		pipeline.RegisterRoutes(service, client)
 		template.RegisterRoutes(service, &common.Options{
 			GenericClient: client,
@@ -64,7 +64,7 @@ func TestAPIsExist(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "fake", Namespace: "fake",
 		},
-	}).Build(), nil, core.JenkinsCore{}, cfg)
+	}).Build(), nil, core.JenkinsCore{}, nil, cfg)
 
 	type args struct {
 		method string
@@ -276,7 +276,7 @@ func TestGetDevOpsProject(t *testing.T) {
 				},
 			},
 		}))
-	AddToContainer(container, fakedevops.NewFakeDevops(nil), k8sClient, fake.NewClientBuilder().WithScheme(schema).Build(), nil, core.JenkinsCore{}, cfg)
+	AddToContainer(container, fakedevops.NewFakeDevops(nil), k8sClient, fake.NewClientBuilder().WithScheme(schema).Build(), nil, core.JenkinsCore{}, nil, cfg)
 
 	type args struct {
 		method string
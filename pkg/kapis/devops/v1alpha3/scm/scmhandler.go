@@ -149,11 +149,15 @@ func (h *handler) getOrganizations(scm, server, secret, namespace string, page,
 		}
 
 		if includeUser {
-			var user string
-			if user, err = h.getCurrentUsername(c); err == nil {
+			var user *goscm.User
+			if user, err = h.getCurrentUser(c); err == nil {
+				avatar := user.Avatar
+				if avatar == "" {
+					avatar = fmt.Sprintf("https://avatars.githubusercontent.com/%s", user.Login)
+				}
 				orgs = append(orgs, &goscm.Organization{
-					Name:   user,
-					Avatar: fmt.Sprintf("https://avatars.githubusercontent.com/%s", user),
+					Name:   user.Login,
+					Avatar: avatar,
 				})
 			}
 		}
@@ -235,12 +239,15 @@ func (h *handler) listRepositories(req *restful.Request, rsp *restful.Response)
 
 func (h *handler) getCurrentUsername(c *goscm.Client) (username string, err error) {
 	var user *goscm.User
-	user, _, err = c.Users.Find(context.Background())
-	if err != nil {
-		return
+	if user, err = h.getCurrentUser(c); err == nil {
+		username = user.Login
 	}
+	return
+}
 
-	username = user.Login
+// getCurrentUser returns the current user, the avatar of it is provided by the self-hosted providers, such as Gitea
+func (h *handler) getCurrentUser(c *goscm.Client) (user *goscm.User, err error) {
+	user, _, err = c.Users.Find(context.Background())
 	return
 }
 
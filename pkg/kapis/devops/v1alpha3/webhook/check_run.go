@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+)
+
+// checkRunRerunAction is the identifier of the "Re-run" button of the Check Runs reported by the controller
+const checkRunRerunAction = "rerun"
+
+// checkRunEvent is the GitHub check_run payload, go-scm does not parse the Check Run and the requested action
+type checkRunEvent struct {
+	Action   string `json:"action"`
+	CheckRun struct {
+		// ExternalID is the namespace and name of a PipelineRun, such as: ns/name
+		ExternalID string `json:"external_id"`
+	} `json:"check_run"`
+	RequestedAction *struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+}
+
+// handleCheckRunEvent creates a new PipelineRun when the "Re-run" button of a Check Run is clicked.
+// Only the PipelineRun of a Pipeline which has the same repository as the webhook, and is in one of the namespaces,
+// could be re-run.
+func (h *SCMHandler) handleCheckRunEvent(ctx context.Context, payload []byte, repo scm.Repository, namespaces map[string]bool,
+	delivery *Delivery) (status int, message string) {
+	event := &checkRunEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	if event.Action != "requested_action" || event.RequestedAction == nil ||
+		event.RequestedAction.Identifier != checkRunRerunAction {
+		return http.StatusOK, "ignored check run event"
+	}
+
+	ns, name, ok := strings.Cut(event.CheckRun.ExternalID, "/")
+	if !ok || !namespaces[ns] {
+		return http.StatusOK, "no pipeline matched"
+	}
+	original := &v1alpha3.PipelineRun{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, original); err != nil {
+		if apierrors.IsNotFound(err) {
+			return http.StatusOK, "no pipeline matched"
+		}
+		return http.StatusInternalServerError, err.Error()
+	}
+	if original.Spec.PipelineRef == nil {
+		return http.StatusOK, "no pipeline matched"
+	}
+	pipeline := &v1alpha3.Pipeline{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: ns, Name: original.Spec.PipelineRef.Name}, pipeline); err != nil {
+		if apierrors.IsNotFound(err) {
+			return http.StatusOK, "no pipeline matched"
+		}
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
+	if pipeline.IsMultiBranch() {
+		gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
+	}
+	if gitURL == "" || !gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
+		return http.StatusOK, "no pipeline matched"
+	}
+	delivery.AddPipeline(pipeline.Namespace, pipeline.Name)
+
+	run := pipelinerun.CreateBarePipelineRun(pipeline, original.Spec.Parameters, original.Spec.SCM.DeepCopy())
+	run.Annotations[triggerAnnotationKey] = "webhook"
+	run.Annotations[v1alpha3.PipelineRunRerunOfAnnoKey] = original.Name
+	// the new Check Run is reported to the same commit
+	if commit := original.Annotations[v1alpha3.PipelineRunCommitAnnoKey]; commit != "" {
+		run.Annotations[v1alpha3.PipelineRunCommitAnnoKey] = commit
+	}
+	if err := h.Create(ctx, run); err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	delivery.AddPipelineRun(run.Namespace, run.Name)
+	return http.StatusOK, "ok"
+}
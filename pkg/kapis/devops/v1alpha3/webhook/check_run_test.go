@@ -0,0 +1,182 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverruntime "github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+)
+
+func newCheckRunPayload(action, externalID, identifier string) string {
+	return fmt.Sprintf(`{"action":%q,"check_run":{"id":1,"external_id":%q,"head_sha":"a1b2c3"},`+
+		`"requested_action":{"identifier":%q},`+
+		`"repository":{"id":1,"name":"hello-world","full_name":"octocat/hello-world",`+
+		`"owner":{"login":"octocat"},"html_url":"https://github.com/octocat/hello-world",`+
+		`"clone_url":"https://github.com/octocat/hello-world.git"},"sender":{"login":"octocat"}}`,
+		action, externalID, identifier)
+}
+
+func TestCheckRunWebhook(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{}
+	pipeline.SetName("fake")
+	pipeline.SetNamespace("default")
+	pipeline.SetAnnotations(map[string]string{scmAnnotationKey: "https://github.com/octocat/hello-world"})
+	otherPipeline := pipeline.DeepCopy()
+	otherPipeline.SetName("other")
+	otherPipeline.SetAnnotations(map[string]string{scmAnnotationKey: "https://github.com/octocat/other"})
+
+	newPipelineRun := func(name, pipelineName string) *v1alpha3.PipelineRun {
+		run := &v1alpha3.PipelineRun{}
+		run.SetName(name)
+		run.SetNamespace("default")
+		run.SetAnnotations(map[string]string{v1alpha3.PipelineRunCommitAnnoKey: "a1b2c3"})
+		run.Spec.PipelineRef = &corev1.ObjectReference{Name: pipelineName}
+		run.Spec.Parameters = []v1alpha3.Parameter{{Name: "env", Value: "test"}}
+		return run
+	}
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "hello-world", Namespace: "default"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:    "https://github.com/octocat/hello-world",
+			Secret: &corev1.SecretReference{Name: "secret"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "secret", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret")},
+	}
+
+	countPipelineRuns := func(t *testing.T, c client.Client) (runs []v1alpha3.PipelineRun) {
+		pipelineRuns := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+		for i := range pipelineRuns.Items {
+			if pipelineRuns.Items[i].Annotations[v1alpha3.PipelineRunRerunOfAnnoKey] != "" {
+				runs = append(runs, pipelineRuns.Items[i])
+			}
+		}
+		return
+	}
+
+	tests := []struct {
+		name      string
+		payload   string
+		secret    string
+		status    int
+		assertion func(t *testing.T, c client.Client, body string)
+	}{{
+		name:    "invalid signature",
+		payload: newCheckRunPayload("requested_action", "default/fake-abcde", checkRunRerunAction),
+		secret:  "fake",
+		status:  http.StatusUnauthorized,
+	}, {
+		name:    "not a requested action",
+		payload: newCheckRunPayload("completed", "default/fake-abcde", ""),
+		secret:  "secret",
+		status:  http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ignored check run event", body)
+			assert.Empty(t, countPipelineRuns(t, c))
+		},
+	}, {
+		name:    "non-existing PipelineRun",
+		payload: newCheckRunPayload("requested_action", "default/fake-fake", checkRunRerunAction),
+		secret:  "secret",
+		status:  http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+		},
+	}, {
+		name:    "PipelineRun of another repository",
+		payload: newCheckRunPayload("requested_action", "default/other-abcde", checkRunRerunAction),
+		secret:  "secret",
+		status:  http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+			assert.Empty(t, countPipelineRuns(t, c))
+		},
+	}, {
+		name:    "re-run",
+		payload: newCheckRunPayload("requested_action", "default/fake-abcde", checkRunRerunAction),
+		secret:  "secret",
+		status:  http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ok", body)
+			runs := countPipelineRuns(t, c)
+			if assert.Equal(t, 1, len(runs)) {
+				assert.Equal(t, "fake-abcde", runs[0].Annotations[v1alpha3.PipelineRunRerunOfAnnoKey])
+				assert.Equal(t, "a1b2c3", runs[0].Annotations[v1alpha3.PipelineRunCommitAnnoKey])
+				assert.Equal(t, []v1alpha3.Parameter{{Name: "env", Value: "test"}}, runs[0].Spec.Parameters)
+			}
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(deliveries)) {
+				assert.Equal(t, "check_run", deliveries[0].Event)
+				assert.Equal(t, []string{"fake"}, deliveries[0].Pipelines)
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				pipeline.DeepCopy(), otherPipeline.DeepCopy(), newPipelineRun("fake-abcde", "fake"),
+				newPipelineRun("other-abcde", "other"), gitRepo.DeepCopy(), secret.DeepCopy()).Build()
+
+			container := restful.NewContainer()
+			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+			RegisterWebhooks(fakeClient, wsWithGroup, core.JenkinsCore{})
+			container.Add(wsWithGroup)
+
+			mac := hmac.New(sha256.New, []byte(tt.secret))
+			_, _ = mac.Write([]byte(tt.payload))
+			httpRequest, _ := http.NewRequest(http.MethodPost,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3/webhooks/scm", strings.NewReader(tt.payload))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpRequest.Header.Set("X-GitHub-Event", "check_run")
+			httpRequest.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			httpRequest.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code)
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient, httpWriter.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+)
+
+const (
+	// ProviderGeneric is the provider of the deliveries from the generic webhook trigger
+	ProviderGeneric = "generic"
+	// ProviderTrigger is the provider of the deliveries from the generic webhook of a Pipeline, it does not rely on Jenkins
+	ProviderTrigger = "trigger"
+
+	// deliveryConfigMapName is the name of the ConfigMap which keeps the deliveries of a namespace
+	deliveryConfigMapName = "devops-webhook-deliveries"
+	// maxDeliveries is the max number of deliveries kept in a namespace
+	maxDeliveries = 30
+	// maxDeliveriesSize is the max size of the deliveries in a namespace, the limit of a ConfigMap is 1MiB
+	maxDeliveriesSize = 768 * 1024
+	// maxDeliveryPayloadSize is the max size of a payload to keep, a delivery with a larger payload cannot be replayed
+	maxDeliveryPayloadSize = 256 * 1024
+)
+
+// sensitiveHeaders carry the secrets of webhooks, they are not kept
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Token", "X-Gitlab-Token"}
+
+// sensitiveQueries carry the secrets of webhooks, they are not kept
+var sensitiveQueries = []string{"secret", "token"}
+
+// Delivery is a webhook request received by ks-devops, it is kept for troubleshooting and replaying
+type Delivery struct {
+	ID string `json:"id"`
+	// Provider is the SCM provider, "generic" for the Jenkins generic webhook trigger, or "trigger" for the generic webhook of a Pipeline
+	Provider   string `json:"provider,omitempty"`
+	Event      string `json:"event,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// Pipelines are the matched Pipelines in the same namespace of the delivery
+	Pipelines []string `json:"pipelines,omitempty"`
+	// PipelineRuns are the created PipelineRuns in the same namespace of the delivery
+	PipelineRuns []string `json:"pipelineRuns,omitempty"`
+	// Status is the HTTP status code of the response
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Verified indicates the signature or the token of the delivery was verified, only a verified delivery can be replayed
+	Verified bool `json:"verified,omitempty"`
+	// ReplayOf is the ID of the replayed delivery
+	ReplayOf  string           `json:"replayOf,omitempty"`
+	Timestamp metav1.MicroTime `json:"timestamp"`
+	Header    http.Header      `json:"header,omitempty"`
+	Query     string           `json:"query,omitempty"`
+	// Payload is empty if it is too large to keep
+	Payload []byte `json:"payload,omitempty"`
+
+	// namespaces are the namespaces where the delivery is kept
+	namespaces map[string]bool
+}
+
+// NewDelivery creates a delivery from a webhook request, the secrets in the header and query are removed
+func NewDelivery(request *http.Request, provider string) *Delivery {
+	header := request.Header.Clone()
+	for _, key := range sensitiveHeaders {
+		header.Del(key)
+	}
+	query := url.Values{}
+	if request.URL != nil {
+		query = request.URL.Query()
+	}
+	for _, key := range sensitiveQueries {
+		query.Del(key)
+	}
+
+	return &Delivery{
+		ID:         fmt.Sprintf("%d-%s", time.Now().Unix(), rand.String(5)),
+		Provider:   provider,
+		Timestamp:  metav1.NowMicro(),
+		Header:     header,
+		Query:      query.Encode(),
+		namespaces: map[string]bool{},
+	}
+}
+
+// SetPayload keeps the payload if it is not too large
+func (d *Delivery) SetPayload(payload []byte) {
+	if len(payload) <= maxDeliveryPayloadSize {
+		d.Payload = payload
+	}
+}
+
+// AddNamespace adds a namespace where the delivery will be kept
+func (d *Delivery) AddNamespace(namespace string) {
+	if d.namespaces == nil {
+		d.namespaces = map[string]bool{}
+	}
+	d.namespaces[namespace] = true
+}
+
+// AddPipeline adds a matched Pipeline, the delivery will be kept in the namespace of it
+func (d *Delivery) AddPipeline(namespace, name string) {
+	d.AddNamespace(namespace)
+	d.Pipelines = append(d.Pipelines, types.NamespacedName{Namespace: namespace, Name: name}.String())
+}
+
+// AddPipelineRun adds a created PipelineRun
+func (d *Delivery) AddPipelineRun(namespace, name string) {
+	d.AddNamespace(namespace)
+	d.PipelineRuns = append(d.PipelineRuns, types.NamespacedName{Namespace: namespace, Name: name}.String())
+}
+
+// Replayable returns an error if the delivery cannot be replayed, the replay does not verify the signature again
+func (d *Delivery) Replayable() error {
+	if !d.Verified || d.Status < http.StatusOK || d.Status >= http.StatusMultipleChoices {
+		return fmt.Errorf("delivery %s was not verified or not succeeded, it cannot be replayed", d.ID)
+	}
+	if len(d.Payload) == 0 {
+		return fmt.Errorf("the payload of delivery %s is not kept, it cannot be replayed", d.ID)
+	}
+	return nil
+}
+
+// SetResult sets the response of the delivery, the message is taken as an error unless the status is OK
+func (d *Delivery) SetResult(status int, message string) {
+	d.Status = status
+	d.Error = ""
+	if status != http.StatusOK || message != "ok" {
+		d.Error = message
+	}
+}
+
+// inNamespace returns a copy of the delivery, which only keeps the Pipelines and PipelineRuns in the namespace
+func (d *Delivery) inNamespace(namespace string) *Delivery {
+	result := *d
+	result.Pipelines = filterByNamespace(d.Pipelines, namespace)
+	result.PipelineRuns = filterByNamespace(d.PipelineRuns, namespace)
+	result.namespaces = nil
+	return &result
+}
+
+func filterByNamespace(names []string, namespace string) (result []string) {
+	for _, name := range names {
+		if strings.HasPrefix(name, namespace+"/") {
+			result = append(result, strings.TrimPrefix(name, namespace+"/"))
+		}
+	}
+	return
+}
+
+// DeliveryStore keeps the latest webhook deliveries of each namespace in a ConfigMap
+type DeliveryStore struct {
+	k8sClient client.Client
+}
+
+// NewDeliveryStore creates a store for webhook deliveries
+func NewDeliveryStore(genericClient client.Client) *DeliveryStore {
+	return &DeliveryStore{k8sClient: genericClient}
+}
+
+// Record keeps a delivery in its namespaces, or in the system namespace if it does not belong to any namespace.
+// The callers should only record the verified deliveries, because every record is a write to the API server.
+// It never fails a webhook, the errors are logged only.
+func (s *DeliveryStore) Record(ctx context.Context, delivery *Delivery) {
+	namespaces := make([]string, 0, len(delivery.namespaces))
+	for ns := range delivery.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	if len(namespaces) == 0 {
+		namespaces = append(namespaces, constants.DevOpsSystemNamespace)
+	}
+
+	for _, ns := range namespaces {
+		if err := s.save(ctx, ns, delivery.inNamespace(ns)); err != nil {
+			klog.Errorf("failed to record webhook delivery %s in namespace %s, error: %v", delivery.ID, ns, err)
+		}
+	}
+}
+
+func (s *DeliveryStore) save(ctx context.Context, namespace string, delivery *Delivery) (err error) {
+	var data []byte
+	if data, err = json.Marshal(delivery); err != nil {
+		return
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		cm := &v1.ConfigMap{}
+		if err = s.k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deliveryConfigMapName}, cm); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return
+			}
+			cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: deliveryConfigMapName}}
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[delivery.ID] = string(data)
+		evictDeliveries(cm.Data)
+
+		if cm.ResourceVersion == "" {
+			err = s.k8sClient.Create(ctx, cm)
+		} else {
+			err = s.k8sClient.Update(ctx, cm)
+		}
+		return
+	})
+}
+
+// evictDeliveries removes the oldest deliveries until the number and size of them are in limits
+func evictDeliveries(data map[string]string) {
+	size := 0
+	for _, delivery := range data {
+		size += len(delivery)
+	}
+
+	ids := sortedDeliveryIDs(data)
+	for i := len(ids) - 1; i > 0 && (len(data) > maxDeliveries || size > maxDeliveriesSize); i-- {
+		size -= len(data[ids[i]])
+		delete(data, ids[i])
+	}
+}
+
+// sortedDeliveryIDs returns the IDs of deliveries from the newest to the oldest
+func sortedDeliveryIDs(data map[string]string) (ids []string) {
+	timestamps := map[string]time.Time{}
+	for id, value := range data {
+		delivery := &Delivery{}
+		if err := json.Unmarshal([]byte(value), delivery); err == nil {
+			timestamps[id] = delivery.Timestamp.Time
+		}
+		ids = append(ids, id)
+	}
+	sort.SliceStable(ids, func(i, j int) bool {
+		if timestamps[ids[i]].Equal(timestamps[ids[j]]) {
+			return ids[i] > ids[j]
+		}
+		return timestamps[ids[i]].After(timestamps[ids[j]])
+	})
+	return
+}
+
+// List returns the deliveries in a namespace, from the newest to the oldest
+func (s *DeliveryStore) List(ctx context.Context, namespace string) (deliveries []Delivery, err error) {
+	deliveries = []Delivery{}
+	cm := &v1.ConfigMap{}
+	if err = s.k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deliveryConfigMapName}, cm); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+
+	for _, id := range sortedDeliveryIDs(cm.Data) {
+		delivery := Delivery{}
+		if err = json.Unmarshal([]byte(cm.Data[id]), &delivery); err != nil {
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return
+}
+
+// Get returns a delivery in a namespace
+func (s *DeliveryStore) Get(ctx context.Context, namespace, id string) (delivery *Delivery, err error) {
+	cm := &v1.ConfigMap{}
+	if err = s.k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: deliveryConfigMapName}, cm); err != nil {
+		return
+	}
+
+	value, ok := cm.Data[id]
+	if !ok {
+		err = apierrors.NewNotFound(v1alpha3.Resource("webhookdeliveries"), id)
+		return
+	}
+	delivery = &Delivery{}
+	err = json.Unmarshal([]byte(value), delivery)
+	return
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+)
+
+// genericWebhookPath is the API of the Jenkins generic-webhook-trigger plugin
+const genericWebhookPath = "/generic-webhook-trigger/invoke"
+
+// genericWebhookResult is the response of the Jenkins generic-webhook-trigger plugin
+type genericWebhookResult struct {
+	Jobs map[string]struct {
+		Triggered bool `json:"triggered"`
+	} `json:"jobs"`
+	Message string `json:"message"`
+}
+
+// SetGenericWebhookResult sets the result of the generic webhook trigger, the triggered jobs are the matched Pipelines
+func (d *Delivery) SetGenericWebhookResult(data []byte, err error) {
+	if err != nil {
+		d.SetResult(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := &genericWebhookResult{}
+	if err = json.Unmarshal(data, result); err != nil {
+		d.SetResult(http.StatusOK, string(data))
+		return
+	}
+	for job, status := range result.Jobs {
+		// the full name of a Jenkins job is: namespace/pipeline
+		if ns, name, ok := strings.Cut(job, "/"); ok && status.Triggered {
+			d.AddPipeline(ns, name)
+		}
+	}
+	if len(d.Pipelines) > 0 {
+		d.SetResult(http.StatusOK, "ok")
+	} else {
+		d.SetResult(http.StatusOK, result.Message)
+	}
+}
+
+// deliveryHandler provides the APIs of webhook deliveries
+type deliveryHandler struct {
+	*SCMHandler
+}
+
+func (h *deliveryHandler) listDeliveries(request *restful.Request, response *restful.Response) {
+	deliveries, err := h.deliveries.List(request.Request.Context(), request.PathParameter("namespace"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(deliveries)
+}
+
+func (h *deliveryHandler) getDelivery(request *restful.Request, response *restful.Response) {
+	delivery, err := h.deliveries.Get(request.Request.Context(),
+		request.PathParameter("namespace"), request.PathParameter("delivery"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(delivery)
+}
+
+// replayDelivery processes the payload of a delivery again, only the Pipelines in the same namespace are affected.
+// Only the verified and succeeded deliveries can be replayed, because the signature is not verified again.
+func (h *deliveryHandler) replayDelivery(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	namespace := request.PathParameter("namespace")
+	delivery, err := h.deliveries.Get(ctx, namespace, request.PathParameter("delivery"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if err = delivery.Replayable(); err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	replayRequest, err := http.NewRequest(http.MethodPost, "/?"+delivery.Query, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	replayRequest.Header = delivery.Header.Clone()
+
+	replay := NewDelivery(replayRequest, delivery.Provider)
+	replay.ReplayOf = delivery.ID
+	replay.Verified = true
+	replay.AddNamespace(namespace)
+	switch delivery.Provider {
+	case ProviderGeneric:
+		replay.SetPayload(delivery.Payload)
+		h.replayGenericWebhook(ctx, replayRequest, namespace, delivery, replay)
+	case ProviderTrigger:
+		replay.SetPayload(delivery.Payload)
+		h.replayTrigger(ctx, replayRequest, namespace, delivery, replay)
+	default:
+		replay.SetResult(h.handleSCMWebhook(replayRequest, replay, namespace))
+	}
+	h.deliveries.Record(ctx, replay)
+	_ = response.WriteEntity(replay)
+}
+
+// replayGenericWebhook triggers the matched Pipelines of a delivery with their tokens of the generic webhook
+func (h *deliveryHandler) replayGenericWebhook(ctx context.Context, request *http.Request, namespace string, delivery, replay *Delivery) {
+	tokens := map[string]bool{}
+	for _, name := range delivery.Pipelines {
+		pipeline := &v1alpha3.Pipeline{}
+		if err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pipeline); err != nil {
+			continue
+		}
+		if noScm := pipeline.Spec.Pipeline; noScm != nil && noScm.GenericWebhook != nil && noScm.GenericWebhook.Token != "" {
+			tokens[noScm.GenericWebhook.Token] = true
+		}
+	}
+	if len(tokens) == 0 {
+		replay.SetResult(http.StatusOK, "no pipeline matched")
+		return
+	}
+
+	headers := map[string]string{}
+	for key := range request.Header {
+		headers[key] = request.Header.Get(key)
+	}
+	for token := range tokens {
+		query := request.URL.Query()
+		query.Set("token", token)
+		_, data, err := h.jenkins.Request(http.MethodPost, genericWebhookPath+"?"+query.Encode(),
+			headers, bytes.NewReader(delivery.Payload))
+		replay.SetGenericWebhookResult(data, err)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// replayTrigger triggers the matched Pipelines of a delivery by their generic webhooks, the token is not required
+func (h *deliveryHandler) replayTrigger(ctx context.Context, request *http.Request, namespace string, delivery, replay *Delivery) {
+	replay.SetResult(http.StatusOK, "no pipeline matched")
+	for _, name := range delivery.Pipelines {
+		pipeline := &v1alpha3.Pipeline{}
+		if err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pipeline); err != nil {
+			continue
+		}
+		result, err := h.trigger(ctx, pipeline, request, delivery.Payload, replay)
+		if err != nil {
+			replay.SetResult(http.StatusBadRequest, err.Error())
+			return
+		}
+		replay.SetResult(http.StatusOK, result.Message)
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/constants"
+)
+
+func TestNewDelivery(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm?secret=secret&foo=bar", nil)
+	request.Header.Set("X-Gitlab-Event", "Push Hook")
+	request.Header.Set("X-Gitlab-Token", "secret")
+	request.Header.Set("Authorization", "Bearer token")
+
+	delivery := NewDelivery(request, "gitlab")
+	assert.NotEmpty(t, delivery.ID)
+	assert.Equal(t, "gitlab", delivery.Provider)
+	assert.Equal(t, "foo=bar", delivery.Query)
+	assert.Equal(t, "Push Hook", delivery.Header.Get("X-Gitlab-Event"))
+	assert.Empty(t, delivery.Header.Get("X-Gitlab-Token"))
+	assert.Empty(t, delivery.Header.Get("Authorization"))
+	// the original request is not changed
+	assert.Equal(t, "secret", request.Header.Get("X-Gitlab-Token"))
+
+	delivery.SetPayload([]byte("{}"))
+	assert.Equal(t, []byte("{}"), delivery.Payload)
+	delivery.SetPayload(make([]byte, maxDeliveryPayloadSize+1))
+	assert.Equal(t, []byte("{}"), delivery.Payload)
+}
+
+func TestDelivery_SetResult(t *testing.T) {
+	delivery := &Delivery{}
+	delivery.SetResult(http.StatusOK, "no pipeline matched")
+	assert.Equal(t, http.StatusOK, delivery.Status)
+	assert.Equal(t, "no pipeline matched", delivery.Error)
+
+	delivery.SetResult(http.StatusOK, "ok")
+	assert.Equal(t, http.StatusOK, delivery.Status)
+	assert.Empty(t, delivery.Error)
+
+	delivery.SetResult(http.StatusUnauthorized, "unsigned")
+	assert.Equal(t, http.StatusUnauthorized, delivery.Status)
+	assert.Equal(t, "unsigned", delivery.Error)
+}
+
+func TestDelivery_SetGenericWebhookResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		err           error
+		wantStatus    int
+		wantError     string
+		wantPipelines []string
+	}{{
+		name:          "triggered",
+		data:          `{"jobs":{"ns/fake":{"triggered":true},"ns/other":{"triggered":false}},"message":"Triggered jobs."}`,
+		wantStatus:    http.StatusOK,
+		wantPipelines: []string{"ns/fake"},
+	}, {
+		name:       "no jobs",
+		data:       `{"jobs":null,"message":"Did not find any jobs"}`,
+		wantStatus: http.StatusOK,
+		wantError:  "Did not find any jobs",
+	}, {
+		name:       "not JSON",
+		data:       "not found",
+		wantStatus: http.StatusOK,
+		wantError:  "not found",
+	}, {
+		name:       "error",
+		err:        errors.New("connection refused"),
+		wantStatus: http.StatusInternalServerError,
+		wantError:  "connection refused",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &Delivery{}
+			delivery.SetGenericWebhookResult([]byte(tt.data), tt.err)
+			assert.Equal(t, tt.wantStatus, delivery.Status)
+			assert.Equal(t, tt.wantError, delivery.Error)
+			assert.Equal(t, tt.wantPipelines, delivery.Pipelines)
+		})
+	}
+}
+
+func TestDeliveryStore(t *testing.T) {
+	ctx := context.TODO()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	store := NewDeliveryStore(fakeClient)
+
+	// a delivery which does not belong to any namespace
+	unknown := &Delivery{ID: "unknown", Timestamp: metav1.NowMicro()}
+	store.Record(ctx, unknown)
+	deliveries, err := store.List(ctx, constants.DevOpsSystemNamespace)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(deliveries))
+
+	// a delivery which affects two namespaces
+	delivery := &Delivery{ID: "push", Provider: "github", Timestamp: metav1.NewMicroTime(time.Now().Add(time.Second))}
+	delivery.AddPipeline("ns1", "fake")
+	delivery.AddPipelineRun("ns1", "fake-abcde")
+	delivery.AddPipeline("ns2", "fake")
+	store.Record(ctx, delivery)
+
+	var result *Delivery
+	result, err = store.Get(ctx, "ns1", "push")
+	assert.Nil(t, err)
+	assert.Equal(t, "github", result.Provider)
+	assert.Equal(t, []string{"fake"}, result.Pipelines)
+	assert.Equal(t, []string{"fake-abcde"}, result.PipelineRuns)
+	result, err = store.Get(ctx, "ns2", "push")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fake"}, result.Pipelines)
+	assert.Nil(t, result.PipelineRuns)
+
+	_, err = store.Get(ctx, "ns1", "fake")
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = store.Get(ctx, "ns3", "push")
+	assert.True(t, apierrors.IsNotFound(err))
+	deliveries, err = store.List(ctx, "ns3")
+	assert.Nil(t, err)
+	assert.Empty(t, deliveries)
+
+	// only the latest deliveries are kept
+	now := time.Now()
+	for i := 0; i < maxDeliveries+5; i++ {
+		store.Record(ctx, &Delivery{
+			ID:        fmt.Sprintf("delivery-%d", i),
+			Timestamp: metav1.NewMicroTime(now.Add(time.Duration(i) * time.Minute)),
+			namespaces: map[string]bool{
+				"ns1": true,
+			},
+		})
+	}
+	deliveries, err = store.List(ctx, "ns1")
+	assert.Nil(t, err)
+	assert.Equal(t, maxDeliveries, len(deliveries))
+	assert.Equal(t, fmt.Sprintf("delivery-%d", maxDeliveries+4), deliveries[0].ID)
+	assert.Equal(t, "delivery-5", deliveries[maxDeliveries-1].ID)
+}
+
+func Test_evictDeliveries(t *testing.T) {
+	newDeliveryData := func(id string, minutes int, payloadSize int) string {
+		data, _ := json.Marshal(&Delivery{
+			ID:        id,
+			Timestamp: metav1.NewMicroTime(time.Unix(0, 0).Add(time.Duration(minutes) * time.Minute)),
+			Payload:   []byte(strings.Repeat("a", payloadSize)),
+		})
+		return string(data)
+	}
+
+	data := map[string]string{
+		"old":    newDeliveryData("old", 1, maxDeliveryPayloadSize),
+		"middle": newDeliveryData("middle", 2, maxDeliveryPayloadSize),
+		"new":    newDeliveryData("new", 3, maxDeliveryPayloadSize),
+	}
+	evictDeliveries(data)
+	// the payloads are encoded in base64, only two of them are in the size limit
+	assert.Equal(t, []string{"new", "middle"}, sortedDeliveryIDs(data))
+
+	data = map[string]string{
+		"old": newDeliveryData("old", 1, 0),
+		"new": newDeliveryData("new", 2, 0),
+	}
+	evictDeliveries(data)
+	assert.Equal(t, []string{"new", "old"}, sortedDeliveryIDs(data))
+}
+
+func TestDeliveryStore_RecordError(t *testing.T) {
+	ctx := context.TODO()
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: deliveryConfigMapName},
+		Data:       map[string]string{"invalid": "invalid"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cm).Build()
+	store := NewDeliveryStore(fakeClient)
+
+	// an invalid delivery does not block recording
+	delivery := &Delivery{ID: "valid", Timestamp: metav1.NowMicro()}
+	delivery.AddNamespace("ns")
+	store.Record(ctx, delivery)
+
+	result := &v1.ConfigMap{}
+	assert.Nil(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: "ns", Name: deliveryConfigMapName}, result))
+	assert.Contains(t, result.Data, "valid")
+	_, err := store.List(ctx, "ns")
+	assert.NotNil(t, err)
+}
@@ -52,11 +52,11 @@ func (handler *Handler) ReceiveEventsFromJenkins(request *restful.Request, respo
 	var errs []error
 	workflowRunHandlers := workflowrun.Handlers{
 		HandleInitialize: handler.handleWorkflowRunInitialize,
+		HandleStarted:    handler.handleWorkflowRunEvent(common.RunStarted),
+		HandleFinalized:  handler.handleWorkflowRunEvent(common.RunFinalized),
+		HandleCompleted:  handler.handleWorkflowRunEvent(common.RunCompleted),
 		// TODO Handler others
-		HandleStarted:   nil,
-		HandleFinalized: nil,
-		HandleCompleted: nil,
-		HandleDeleted:   nil,
+		HandleDeleted: nil,
 	}
 	if err := workflowRunHandlers.Handle(event); err != nil {
 		errs = append(errs, err)
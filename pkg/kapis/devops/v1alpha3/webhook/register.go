@@ -27,6 +27,7 @@ import (
 
 	"github.com/kubesphere/ks-devops/pkg/api"
 	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
 )
 
 // RegisterWebhooks registers all webhooks into web service.
@@ -41,8 +42,44 @@ func RegisterWebhooks(genericClient client.Client, ws *restful.WebService, jenki
 
 	scmHandler := NewSCMHandler(genericClient, jenkins)
 	ws.Route(ws.POST("/webhooks/scm").
+		Doc("Webhook for receiving events from SCM providers, it must be signed with the secret of a GitRepository, its Webhook, or the annotation of a Pipeline").
 		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsWebhookTags).
 		Reads(json.RawMessage{}).
 		Returns(http.StatusOK, api.StatusOK, json.RawMessage{}).
 		To(scmHandler.scmWebhook))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelines/{pipeline}/trigger").
+		To(scmHandler.triggerPipeline).
+		Param(common.NamespacePathParameter).
+		Param(ws.PathParameter("pipeline", "The name of a Pipeline")).
+		Param(ws.QueryParameter("token", "The token of the generic webhook, it can be in the header token as well")).
+		Doc("Trigger a Pipeline by its generic webhook, the variables are resolved from the request as the parameters of the PipelineRun").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsWebhookTags).
+		Reads(json.RawMessage{}).
+		Returns(http.StatusOK, api.StatusOK, TriggerResult{}))
+
+	deliveryHandler := &deliveryHandler{SCMHandler: scmHandler}
+	deliveryPathParameter := ws.PathParameter("delivery", "The ID of a webhook delivery")
+	ws.Route(ws.GET("/namespaces/{namespace}/webhookdeliveries").
+		To(deliveryHandler.listDeliveries).
+		Param(common.NamespacePathParameter).
+		Doc("List the latest webhook deliveries which are related to the namespace, from the newest to the oldest").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsWebhookTags).
+		Returns(http.StatusOK, api.StatusOK, []Delivery{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/webhookdeliveries/{delivery}").
+		To(deliveryHandler.getDelivery).
+		Param(common.NamespacePathParameter).
+		Param(deliveryPathParameter).
+		Doc("Get a webhook delivery").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsWebhookTags).
+		Returns(http.StatusOK, api.StatusOK, Delivery{}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/webhookdeliveries/{delivery}/replay").
+		To(deliveryHandler.replayDelivery).
+		Param(common.NamespacePathParameter).
+		Param(deliveryPathParameter).
+		Doc("Replay a webhook delivery, only the Pipelines in the namespace are affected").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsWebhookTags).
+		Returns(http.StatusOK, api.StatusOK, Delivery{}))
 }
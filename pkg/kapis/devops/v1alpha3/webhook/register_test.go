@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	corev1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/util/retry"
@@ -155,7 +156,8 @@ func TestJenkinsWebhook(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
-			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.args.initObject...).Build()
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.args.initObject...).
+				WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, extractPipelineRunIdentifierIndex).Build()
 
 			container := restful.NewContainer()
 			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
@@ -188,6 +190,36 @@ func TestSCMWebhook(t *testing.T) {
 		scmRefAnnotationKey: `["master"]`,
 		scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
 	})
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:      "https://gitlab.com/linuxsuren/test",
+			Webhooks: []corev1.LocalObjectReference{{Name: "test"}},
+		},
+	}
+	webhook := &v1alpha3.Webhook{
+		ObjectMeta: v1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       v1alpha3.WebhookSpec{Secret: &corev1.SecretReference{Name: "webhook"}},
+	}
+	webhookSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "webhook", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret")},
+	}
+	pathFilteredPipeline := defaultPipeline.DeepCopy()
+	pathFilteredPipeline.Annotations[scmIncludePathsAnnotationKey] = `["services/**"]`
+	invalidPathsPipeline := defaultPipeline.DeepCopy()
+	invalidPathsPipeline.Annotations[scmIncludePathsAnnotationKey] = `services/**`
+	// another tenant registers its own secret for the same repository
+	tenantPipeline := defaultPipeline.DeepCopy()
+	tenantPipeline.SetNamespace("tenant")
+	tenantGitRepo := gitRepo.DeepCopy()
+	tenantGitRepo.SetNamespace("tenant")
+	tenantWebhook := webhook.DeepCopy()
+	tenantWebhook.SetNamespace("tenant")
+	tenantSecret := webhookSecret.DeepCopy()
+	tenantSecret.SetNamespace("tenant")
+	tenantSecret.Data = map[string][]byte{corev1.ServiceAccountTokenKey: []byte("tenant")}
 
 	type args struct {
 		method     string
@@ -199,6 +231,7 @@ func TestSCMWebhook(t *testing.T) {
 	tests := []struct {
 		name      string
 		args      args
+		status    int
 		assertion func(t *testing.T, c client.Client, body string)
 	}{{
 		name: "unknown SCM webhook",
@@ -210,39 +243,158 @@ func TestSCMWebhook(t *testing.T) {
 		assertion: func(t *testing.T, c client.Client, body string) {
 			assert.Equal(t, "unknown SCM type", body)
 		},
+	}, {
+		name: "unsigned gitlab webhook",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		status: http.StatusUnauthorized,
+	}, {
+		name: "gitlab webhook with a mismatched token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "fake",
+			},
+		},
+		status: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			// the unverified deliveries are not recorded
+			configMaps := &corev1.ConfigMapList{}
+			assert.Nil(t, c.List(context.TODO(), configMaps))
+			assert.Equal(t, 0, len(configMaps.Items))
+		},
 	}, {
 		name: "gitlab webhook with no pipeline matched",
 		args: args{
 			method:     http.MethodPost,
 			uri:        "/webhooks/scm",
-			initObject: []client.Object{},
+			initObject: []client.Object{gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "secret",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+		},
+	}, {
+		name: "gitlab webhook with no changed files matched",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{pathFilteredPipeline, gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
 			bodyJSON:   gitlabWebhookBody,
 			header: map[string]string{
 				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "secret",
 			},
 		},
 		assertion: func(t *testing.T, c client.Client, body string) {
 			assert.Equal(t, "no pipeline matched", body)
 		},
+	}, {
+		name: "gitlab webhook with invalid path rules",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{invalidPathsPipeline, gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "secret",
+			},
+		},
+		status: http.StatusBadRequest,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, "invalid path rules in the annotation "+scmIncludePathsAnnotationKey)
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(deliveries)) {
+				assert.Empty(t, deliveries[0].PipelineRuns)
+			}
+		},
 	}, {
 		name: "gitlab webhook",
 		args: args{
 			method:     http.MethodPost,
 			uri:        "/webhooks/scm",
-			initObject: []client.Object{defaultPipeline.DeepCopy()},
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy()},
 			bodyJSON:   gitlabWebhookBody,
 			header: map[string]string{
 				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "secret",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ok", body)
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(deliveries)) {
+				assert.Equal(t, "gitlab", deliveries[0].Provider)
+				assert.Equal(t, "linuxsuren/test", deliveries[0].Repository)
+				assert.Equal(t, []string{"fake"}, deliveries[0].Pipelines)
+				assert.Equal(t, 1, len(deliveries[0].PipelineRuns))
+				assert.Empty(t, deliveries[0].Header.Get("X-Gitlab-Token"))
+				assert.NotEmpty(t, deliveries[0].Payload)
+			}
+
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Nil(t, pipelineRuns.Items[0].Spec.SCM)
+				assert.Equal(t, "bd4f171cec5c6f9b8b184107ce318bf9a54dce26",
+					pipelineRuns.Items[0].Annotations[v1alpha3.PipelineRunCommitAnnoKey])
+			}
+		},
+	}, {
+		name: "gitlab webhook only affects the namespaces whose secrets signed it",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), webhook.DeepCopy(), webhookSecret.DeepCopy(),
+				tenantPipeline, tenantGitRepo, tenantWebhook, tenantSecret},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "tenant",
 			},
 		},
 		assertion: func(t *testing.T, c client.Client, body string) {
 			assert.Equal(t, "ok", body)
+
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Equal(t, "tenant", pipelineRuns.Items[0].Namespace)
+			}
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			assert.Equal(t, 0, len(deliveries))
+			deliveries, err = NewDeliveryStore(c).List(context.TODO(), "tenant")
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(deliveries))
 		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
-			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.args.initObject...).Build()
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.args.initObject...).
+				WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, extractPipelineRunIdentifierIndex).Build()
 
 			container := restful.NewContainer()
 			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
@@ -262,7 +414,10 @@ func TestSCMWebhook(t *testing.T) {
 			}
 			httpWriter := httptest.NewRecorder()
 			container.Dispatch(httpWriter, httpRequest)
-			assert.Equal(t, 200, httpWriter.Code)
+			if tt.status == 0 {
+				tt.status = http.StatusOK
+			}
+			assert.Equal(t, tt.status, httpWriter.Code)
 			if tt.assertion != nil {
 				body := httpWriter.Body
 				var bodyResponse string
@@ -275,6 +430,135 @@ func TestSCMWebhook(t *testing.T) {
 	}
 }
 
+func TestWebhookDeliveries(t *testing.T) {
+	defaultPipeline := &v1alpha3.Pipeline{}
+	defaultPipeline.SetName("fake")
+	defaultPipeline.SetNamespace("default")
+	defaultPipeline.SetAnnotations(map[string]string{
+		scmRefAnnotationKey: `["master"]`,
+		scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
+	})
+	otherPipeline := defaultPipeline.DeepCopy()
+	otherPipeline.SetNamespace("other")
+
+	newDelivery := func(id string, payload string, status int) *Delivery {
+		delivery := &Delivery{
+			ID:        id,
+			Provider:  "gitlab",
+			Status:    status,
+			Verified:  status != http.StatusUnauthorized,
+			Timestamp: v1.NowMicro(),
+			Header:    http.Header{"X-Gitlab-Event": []string{"Push Hook"}},
+			Payload:   []byte(payload),
+		}
+		delivery.AddPipeline("default", "fake")
+		return delivery
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		uri       string
+		status    int
+		assertion func(t *testing.T, c client.Client, body string)
+	}{{
+		name:   "list deliveries",
+		method: http.MethodGet,
+		uri:    "/namespaces/default/webhookdeliveries",
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `"id": "push"`)
+			assert.Contains(t, body, `"id": "large"`)
+		},
+	}, {
+		name:   "list deliveries in an empty namespace",
+		method: http.MethodGet,
+		uri:    "/namespaces/empty/webhookdeliveries",
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "[]", strings.TrimSpace(body))
+		},
+	}, {
+		name:   "get a delivery",
+		method: http.MethodGet,
+		uri:    "/namespaces/default/webhookdeliveries/push",
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `"provider": "gitlab"`)
+		},
+	}, {
+		name:   "get a non-existing delivery",
+		method: http.MethodGet,
+		uri:    "/namespaces/default/webhookdeliveries/fake",
+		status: http.StatusNotFound,
+	}, {
+		name:   "replay a delivery without payload",
+		method: http.MethodPost,
+		uri:    "/namespaces/default/webhookdeliveries/large/replay",
+		status: http.StatusBadRequest,
+	}, {
+		name:   "replay an unverified delivery",
+		method: http.MethodPost,
+		uri:    "/namespaces/default/webhookdeliveries/unsigned/replay",
+		status: http.StatusBadRequest,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			assert.Equal(t, 0, len(pipelineRuns.Items))
+		},
+	}, {
+		name:   "replay a delivery",
+		method: http.MethodPost,
+		uri:    "/namespaces/default/webhookdeliveries/push/replay",
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `"replayOf": "push"`)
+
+			// only the PipelineRuns in the same namespace are created
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Equal(t, "default", pipelineRuns.Items[0].Namespace)
+			}
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 4, len(deliveries)) {
+				assert.Equal(t, "push", deliveries[0].ReplayOf)
+				assert.Equal(t, http.StatusOK, deliveries[0].Status)
+				assert.Equal(t, 1, len(deliveries[0].PipelineRuns))
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(defaultPipeline.DeepCopy(), otherPipeline.DeepCopy()).
+				WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, extractPipelineRunIdentifierIndex).Build()
+			store := NewDeliveryStore(fakeClient)
+			store.Record(context.TODO(), newDelivery("push", gitlabWebhookBody, http.StatusOK))
+			store.Record(context.TODO(), newDelivery("large", "", http.StatusOK))
+			store.Record(context.TODO(), newDelivery("unsigned", gitlabWebhookBody, http.StatusUnauthorized))
+
+			container := restful.NewContainer()
+			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+			RegisterWebhooks(fakeClient, wsWithGroup, core.JenkinsCore{})
+			container.Add(wsWithGroup)
+
+			httpRequest, _ := http.NewRequest(tt.method,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.uri, nil)
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code)
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient, httpWriter.Body.String())
+			}
+		})
+	}
+}
+
 const gitlabWebhookBody = `{
   "object_kind": "push",
   "event_name": "push",
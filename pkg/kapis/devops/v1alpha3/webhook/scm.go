@@ -17,12 +17,14 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/emicklei/go-restful/v3"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
+	"github.com/jenkins-x/go-scm/scm/driver/gitea"
 	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
@@ -30,9 +32,13 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+	"io"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"net/http"
+	"path"
 	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	"strings"
 	"time"
 )
@@ -41,19 +47,27 @@ import (
 const tokenExpireIn time.Duration = 5 * time.Minute
 const scmAnnotationKey = "scm.devops.kubesphere.io"
 const scmRefAnnotationKey = "scm.devops.kubesphere.io/ref"
+const scmIncludePathsAnnotationKey = "scm.devops.kubesphere.io/include-paths"
+const scmExcludePathsAnnotationKey = "scm.devops.kubesphere.io/exclude-paths"
+const scmSecretAnnotationKey = "scm.devops.kubesphere.io/secret"
 const triggerAnnotationKey = "devops.kubesphere.io/trigger"
 
+// maxPayloadSize is the max size of the webhook payload, the same as go-scm
+const maxPayloadSize = 10000000
+
 // SCMHandler handles requests from webhooks.
 type SCMHandler struct {
 	client.Client
-	jenkins core.JenkinsCore
+	jenkins    core.JenkinsCore
+	deliveries *DeliveryStore
 }
 
 // NewSCMHandler creates a new handler for handling webhooks.
 func NewSCMHandler(genericClient client.Client, jenkins core.JenkinsCore) *SCMHandler {
 	return &SCMHandler{
-		Client:  genericClient,
-		jenkins: jenkins,
+		Client:     genericClient,
+		jenkins:    jenkins,
+		deliveries: NewDeliveryStore(genericClient),
 	}
 }
 
@@ -69,48 +83,130 @@ func getSCMClient(request *http.Request) *scm.Client {
 	if strings.HasPrefix(request.Header.Get("User-Agent"), "Bitbucket-Webhooks") {
 		return bitbucket.NewDefault()
 	}
+
+	if isGiteaWebhook(request) {
+		// Forgejo is a fork of Gitea, it might only send its own headers
+		for _, key := range []string{"Event", "Delivery", "Signature"} {
+			if request.Header.Get("X-Gitea-"+key) == "" {
+				request.Header.Set("X-Gitea-"+key, request.Header.Get("X-Forgejo-"+key))
+			}
+		}
+		return &scm.Client{Driver: scm.DriverGitea, Webhooks: gitea.NewWebHookService()}
+	}
 	return nil
 }
 
+func isGiteaWebhook(request *http.Request) bool {
+	return request.Header.Get("X-Gitea-Event") != "" || request.Header.Get("X-Forgejo-Event") != ""
+}
+
 func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Response) {
-	scmClient := getSCMClient(request.Request)
+	delivery := NewDelivery(request.Request, "")
+	status, message := h.handleSCMWebhook(request.Request, delivery, "")
+	delivery.SetResult(status, message)
+	if delivery.Verified {
+		// anyone is able to send a webhook, recording the unverified ones costs the API server for nothing
+		h.deliveries.Record(context.TODO(), delivery)
+	}
+
+	if status == http.StatusOK {
+		_, _ = response.Write([]byte(message))
+	} else {
+		_ = response.WriteErrorString(status, message)
+	}
+}
+
+// handleSCMWebhook processes a webhook, and returns the status and message of the response.
+// Only the Pipelines in the namespaces whose secrets signed the webhook are matched. The signature is not verified
+// when replaying a verified delivery in a namespace, only the Pipelines in it are matched.
+func (h *SCMHandler) handleSCMWebhook(request *http.Request, delivery *Delivery, namespace string) (status int, message string) {
+	scmClient := getSCMClient(request)
 	if scmClient == nil {
-		_, _ = response.Write([]byte("unknown SCM type"))
-		return
+		return http.StatusOK, "unknown SCM type"
 	}
+	delivery.Provider = scmClient.Driver.String()
 
-	webhook, err := scmClient.Webhooks.Parse(request.Request, func(webhook scm.Webhook) (string, error) {
+	payload, err := io.ReadAll(io.LimitReader(request.Body, maxPayloadSize))
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	delivery.SetPayload(payload)
+	// the signature is verified below, because the secret depends on the repository of the webhook
+	request.Body = io.NopCloser(bytes.NewReader(payload))
+	webhook, err := scmClient.Webhooks.Parse(request, func(webhook scm.Webhook) (string, error) {
 		return "", nil
 	})
 	if err != nil {
-		_, _ = response.Write([]byte(err.Error()))
-		return
+		return http.StatusOK, err.Error()
+	} else if webhook == nil {
+		return http.StatusOK, "unknown webhook event"
 	}
+	repo := webhook.Repository()
+	delivery.Event = string(webhook.Kind())
+	delivery.Repository = repo.FullName
 
 	ctx := context.TODO()
-	found := false
-	if webhook.Kind() == scm.WebhookKindPush {
-		repo := webhook.Repository()
-		pushHook := webhook.(*scm.PushHook)
+	namespaces := map[string]bool{namespace: true}
+	if namespace == "" {
+		pipelines := h.getRegisteredPipelines(ctx, repo)
+		secrets := append(h.getWebhookSecrets(ctx, h.getGitRepositories(ctx, repo)), h.getPipelineWebhookSecrets(ctx, pipelines)...)
+		secrets = append(secrets, h.getAnnotatedWebhookSecrets(ctx, repo)...)
+		if namespaces = getVerifiedNamespaces(request, payload, secrets); len(namespaces) == 0 {
+			return http.StatusUnauthorized, "the webhook is unsigned or the signature is invalid"
+		}
+	}
+	// the delivery is kept in the verified namespaces only
+	for ns := range namespaces {
+		delivery.AddNamespace(ns)
+	}
+	delivery.Verified = true
+	if webhook.Kind() == scm.WebhookKindCheckRun {
+		return h.handleCheckRunEvent(ctx, payload, repo, namespaces, delivery)
+	}
+	if comment := getPullRequestComment(webhook); comment != nil {
+		return h.handlePullRequestComment(ctx, comment, repo, scmClient.Driver, namespaces, delivery)
+	}
 
-		pipelineList := &v1alpha3.PipelineList{}
-		if err = h.List(ctx, pipelineList); err == nil {
-			for i := range pipelineList.Items {
-				pipeline := pipelineList.Items[i]
-				if !branchMatch(pipeline, pushHook.Ref) {
+	found := false
+	var pathErrs []error
+	if event := newSCMEvent(webhook, scmClient.Driver); event != nil {
+		var pipelines []v1alpha3.Pipeline
+		if pipelines, err = h.listPipelines(ctx, namespaces); err == nil {
+			for i := range pipelines {
+				pipeline := pipelines[i]
+				if !branchMatch(pipeline, event.ref) {
 					continue
 				}
+				// Jenkins builds the branches of a multi-branch Pipeline by scanning, the path rules do not apply to it
+				if !pipeline.IsMultiBranch() {
+					if ok, matchErr := pathMatch(pipeline, event.changedFiles); matchErr != nil {
+						// the Pipeline is not triggered, the invalid path rules are reported to the provider
+						found = true
+						pathErrs = append(pathErrs, fmt.Errorf("the Pipeline %s/%s has %v", pipeline.Namespace, pipeline.Name, matchErr))
+						continue
+					} else if !ok {
+						continue
+					}
+				}
 				found = true
 
 				gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
 				if pipeline.IsMultiBranch() {
 					gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
 					if gitURL != "" && gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins)
+						delivery.AddPipeline(pipeline.Namespace, pipeline.Name)
+						if event.scanMultiBranchPipeline {
+							err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins)
+						}
+						if err == nil && event.runMultiBranchPipeline {
+							err = h.createPipelineRun(pipeline, event.scm, event.commit, delivery)
+						}
 					}
-				} else if gitURL != "" {
+				} else if gitURL != "" && event.runPipeline {
 					if gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = h.createPipelineRun(pipeline, pushHook)
+						delivery.AddPipeline(pipeline.Namespace, pipeline.Name)
+						// a regular Pipeline is a single Jenkins job, the SCM reference is not part of its PipelineRun
+						err = h.createPipelineRun(pipeline, nil, event.commit, delivery)
 					} else {
 						err = fmt.Errorf("expect URL: %s, got: %v", gitURL, []string{repo.Link, repo.Clone, repo.CloneSSH})
 					}
@@ -119,24 +215,42 @@ func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Resp
 		}
 	}
 
+	if err == nil && len(pathErrs) > 0 {
+		err = utilerrors.NewAggregate(pathErrs)
+	}
 	if !found {
-		_ = response.WriteErrorString(http.StatusOK, "no pipeline matched")
-		return
+		return http.StatusOK, "no pipeline matched"
 	} else if err != nil {
-		_ = response.WriteError(http.StatusBadRequest, err)
-	} else {
-		_, _ = response.Write([]byte("ok"))
+		return http.StatusBadRequest, err.Error()
 	}
+	return http.StatusOK, "ok"
 }
 
-func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, hook *scm.PushHook) (err error) {
-	branch := strings.TrimPrefix(hook.Ref, "refs/heads/")
+// listPipelines returns the Pipelines in the namespaces
+func (h *SCMHandler) listPipelines(ctx context.Context, namespaces map[string]bool) (pipelines []v1alpha3.Pipeline, err error) {
+	names := make([]string, 0, len(namespaces))
+	for ns := range namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	for _, ns := range names {
+		pipelineList := &v1alpha3.PipelineList{}
+		if err = h.List(ctx, pipelineList, client.InNamespace(ns)); err != nil {
+			return
+		}
+		pipelines = append(pipelines, pipelineList.Items...)
+	}
+	return
+}
 
-	var scmObj *v1alpha3.SCM
-	if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err == nil {
-		run := pipelinerun.CreatePipelineRun(&pipeline, &devops.RunPayload{}, scmObj)
-		run.Annotations[triggerAnnotationKey] = "webhook"
-		err = h.Create(context.Background(), run)
+func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, scmObj *v1alpha3.SCM, commit string, delivery *Delivery) (err error) {
+	run := pipelinerun.CreatePipelineRun(&pipeline, &devops.RunPayload{}, scmObj.DeepCopy())
+	run.Annotations[triggerAnnotationKey] = "webhook"
+	if commit != "" {
+		run.Annotations[v1alpha3.PipelineRunCommitAnnoKey] = commit
+	}
+	if err = h.Create(context.Background(), run); err == nil {
+		delivery.AddPipelineRun(run.Namespace, run.Name)
 	}
 	return
 }
@@ -171,6 +285,73 @@ func branchMatch(pipeline v1alpha3.Pipeline, branch string) (ok bool) {
 	return
 }
 
+// pathMatch matches the changed files with the path rules from annotations.
+// It returns true if any file is included and not excluded, or no annotations or changed files found.
+// The path rules are glob patterns, "**" matches any directories, and a pattern matches all files under a matched directory.
+func pathMatch(pipeline v1alpha3.Pipeline, changedFiles []string) (ok bool, err error) {
+	var includes, excludes []string
+	if includes, err = getPathRules(pipeline.Annotations, scmIncludePathsAnnotationKey); err != nil {
+		return
+	}
+	if excludes, err = getPathRules(pipeline.Annotations, scmExcludePathsAnnotationKey); err != nil {
+		return
+	}
+	if len(changedFiles) == 0 || (len(includes) == 0 && len(excludes) == 0) {
+		// the provider might not send the changed files, such as Bitbucket
+		ok = true
+		return
+	}
+
+	for _, file := range changedFiles {
+		if (len(includes) == 0 || globMatchAny(includes, file)) && !globMatchAny(excludes, file) {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// getPathRules returns the path rules of the annotation, which is a JSON array of strings
+func getPathRules(annotations map[string]string, key string) (paths []string, err error) {
+	if rules := annotations[key]; rules != "" {
+		if err = json.Unmarshal([]byte(rules), &paths); err != nil {
+			err = fmt.Errorf("invalid path rules in the annotation %s: %v", key, err)
+		}
+	}
+	return
+}
+
+func globMatchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if globMatch(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(file, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches the path segments with the pattern segments
+func globMatch(patterns, segments []string) bool {
+	for ; len(patterns) > 0; patterns, segments = patterns[1:], segments[1:] {
+		if patterns[0] == "**" {
+			for i := range segments {
+				if globMatch(patterns[1:], segments[i:]) {
+					return true
+				}
+			}
+			return len(patterns) == 1
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(patterns[0], segments[0]); !ok {
+			return false
+		}
+	}
+	// the rest segments are the files under a matched directory
+	return true
+}
+
 // gitRepoMatch if the source matches target
 func gitRepoMatch(source string, targets ...string) (ok bool) {
 	for _, target := range targets {
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// emptyCommit is the commit SHA of a deleted or a new reference
+const emptyCommit = "0000000000000000000000000000000000000000"
+
+// scmEvent describes how a webhook affects the Pipelines
+type scmEvent struct {
+	// ref is the git reference which is matched with the branch rules of a Pipeline,
+	// it is the target branch for a pull request
+	ref string
+	// scm is the SCM of the PipelineRuns to create for multi-branch Pipelines
+	scm *v1alpha3.SCM
+	// commit is the SHA of the commit to build, it is used to report the commit status
+	commit string
+	// changedFiles are the files changed by the pushed commits, it is empty if the provider does not send them
+	changedFiles []string
+	// runPipeline indicates if a PipelineRun should be created for a regular Pipeline
+	runPipeline bool
+	// runMultiBranchPipeline indicates if a PipelineRun should be created for a multi-branch Pipeline
+	runMultiBranchPipeline bool
+	// scanMultiBranchPipeline indicates if a multi-branch Pipeline should be scanned,
+	// Jenkins discovers the new branches, pull requests and tags, and removes the deleted ones by scanning.
+	// The jobs of DeleteActionJobsToTrigger are triggered by Jenkins once a branch is removed.
+	scanMultiBranchPipeline bool
+}
+
+// newSCMEvent returns the event of a webhook, or nil if the webhook is not supported
+func newSCMEvent(webhook scm.Webhook, driver scm.Driver) *scmEvent {
+	switch hook := webhook.(type) {
+	case *scm.PushHook:
+		if hook.Deleted || hook.After == emptyCommit {
+			if !strings.HasPrefix(hook.Ref, "refs/heads/") {
+				// nothing to do with a deleted tag
+				return nil
+			}
+			return &scmEvent{ref: hook.Ref, scanMultiBranchPipeline: true}
+		}
+
+		if scm.IsTag(hook.Ref) {
+			return &scmEvent{
+				ref:                     hook.Ref,
+				scm:                     &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: scm.TrimRef(hook.Ref)},
+				commit:                  getPushCommit(hook),
+				runPipeline:             true,
+				runMultiBranchPipeline:  true,
+				scanMultiBranchPipeline: true,
+			}
+		}
+		return &scmEvent{
+			ref:                     hook.Ref,
+			commit:                  getPushCommit(hook),
+			changedFiles:            getChangedFiles(hook.Commits),
+			runPipeline:             true,
+			scanMultiBranchPipeline: true,
+		}
+	case *scm.BranchHook:
+		// the branch creation comes with a push event
+		if hook.Action == scm.ActionDelete {
+			return &scmEvent{ref: scm.ExpandRef(hook.Ref.Name, "refs/heads/"), scanMultiBranchPipeline: true}
+		}
+	case *scm.PullRequestHook:
+		event := &scmEvent{ref: scm.ExpandRef(hook.PullRequest.Target, "refs/heads/")}
+		switch hook.Action {
+		case scm.ActionOpen, scm.ActionReopen:
+			event.scanMultiBranchPipeline = true
+			fallthrough
+		case scm.ActionSync:
+			event.runPipeline = true
+			event.runMultiBranchPipeline = true
+			event.scm = getPullRequestSCM(hook.PullRequest.Number, driver)
+			event.commit = hook.PullRequest.Sha
+		case scm.ActionClose, scm.ActionMerge:
+			event.scanMultiBranchPipeline = true
+		default:
+			return nil
+		}
+		return event
+	}
+	return nil
+}
+
+// getPushCommit returns the SHA of the pushed commit, it is the head commit rather than the tag object for an annotated tag
+func getPushCommit(hook *scm.PushHook) string {
+	if hook.Commit.Sha != "" {
+		return hook.Commit.Sha
+	}
+	return hook.After
+}
+
+// maxPushCommits is the number of the commits which GitHub and GitLab send in a push event at most
+const maxPushCommits = 20
+
+// getChangedFiles returns the distinct files which are added, modified or removed by the commits.
+// It returns nothing if the commits might be truncated by the provider, then all the path rules are matched.
+func getChangedFiles(commits []scm.PushCommit) (files []string) {
+	if len(commits) >= maxPushCommits {
+		return
+	}
+	found := map[string]bool{}
+	for _, commit := range commits {
+		for _, changes := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range changes {
+				if !found[file] {
+					found[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return
+}
+
+// getPullRequestSCM returns the SCM of a pull request, the reference name is the same as the Jenkins job name
+func getPullRequestSCM(number int, driver scm.Driver) *v1alpha3.SCM {
+	if driver == scm.DriverGitlab {
+		return &v1alpha3.SCM{RefType: v1alpha3.MergeRequest, RefName: fmt.Sprintf("MR-%d", number)}
+	}
+	return &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: fmt.Sprintf("PR-%d", number)}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_newSCMEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook scm.Webhook
+		driver  scm.Driver
+		want    *scmEvent
+	}{{
+		name:    "branch push",
+		webhook: &scm.PushHook{Ref: "refs/heads/master", After: "bd4f171"},
+		want:    &scmEvent{ref: "refs/heads/master", commit: "bd4f171", runPipeline: true, scanMultiBranchPipeline: true},
+	}, {
+		name: "branch push with changed files",
+		webhook: &scm.PushHook{Ref: "refs/heads/master", After: "bd4f171", Commits: []scm.PushCommit{
+			{Added: []string{"a.go"}, Modified: []string{"b.go"}},
+			{Modified: []string{"a.go"}, Removed: []string{"c.go"}},
+		}},
+		want: &scmEvent{
+			ref:                     "refs/heads/master",
+			commit:                  "bd4f171",
+			changedFiles:            []string{"a.go", "b.go", "c.go"},
+			runPipeline:             true,
+			scanMultiBranchPipeline: true,
+		},
+	}, {
+		name: "branch push with truncated commits",
+		webhook: &scm.PushHook{Ref: "refs/heads/master", After: "bd4f171",
+			Commits: make([]scm.PushCommit, maxPushCommits)},
+		want: &scmEvent{ref: "refs/heads/master", commit: "bd4f171", runPipeline: true, scanMultiBranchPipeline: true},
+	}, {
+		name:    "tag push",
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0", After: "a5f2c3e", Commit: scm.Commit{Sha: "bd4f171"}},
+		want: &scmEvent{
+			ref:                     "refs/tags/v1.0.0",
+			scm:                     &v1alpha3.SCM{RefType: v1alpha3.Tag, RefName: "v1.0.0"},
+			commit:                  "bd4f171",
+			runPipeline:             true,
+			runMultiBranchPipeline:  true,
+			scanMultiBranchPipeline: true,
+		},
+	}, {
+		name:    "branch deletion by push",
+		webhook: &scm.PushHook{Ref: "refs/heads/feature", Deleted: true},
+		want:    &scmEvent{ref: "refs/heads/feature", scanMultiBranchPipeline: true},
+	}, {
+		name:    "tag deletion by push",
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0", After: emptyCommit},
+	}, {
+		name:    "branch deletion",
+		webhook: &scm.BranchHook{Ref: scm.Reference{Name: "feature"}, Action: scm.ActionDelete},
+		want:    &scmEvent{ref: "refs/heads/feature", scanMultiBranchPipeline: true},
+	}, {
+		name:    "branch creation",
+		webhook: &scm.BranchHook{Ref: scm.Reference{Name: "feature"}, Action: scm.ActionCreate},
+	}, {
+		name:    "pull request opened",
+		webhook: &scm.PullRequestHook{Action: scm.ActionOpen, PullRequest: scm.PullRequest{Number: 1, Target: "master", Sha: "6dcb09b"}},
+		driver:  scm.DriverGithub,
+		want: &scmEvent{
+			ref:                     "refs/heads/master",
+			scm:                     &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-1"},
+			commit:                  "6dcb09b",
+			runPipeline:             true,
+			runMultiBranchPipeline:  true,
+			scanMultiBranchPipeline: true,
+		},
+	}, {
+		name:    "merge request synchronized",
+		webhook: &scm.PullRequestHook{Action: scm.ActionSync, PullRequest: scm.PullRequest{Number: 2, Target: "master"}},
+		driver:  scm.DriverGitlab,
+		want: &scmEvent{
+			ref:                    "refs/heads/master",
+			scm:                    &v1alpha3.SCM{RefType: v1alpha3.MergeRequest, RefName: "MR-2"},
+			runPipeline:            true,
+			runMultiBranchPipeline: true,
+		},
+	}, {
+		name:    "pull request closed",
+		webhook: &scm.PullRequestHook{Action: scm.ActionClose, PullRequest: scm.PullRequest{Number: 1, Target: "master"}},
+		want:    &scmEvent{ref: "refs/heads/master", scanMultiBranchPipeline: true},
+	}, {
+		name:    "pull request labeled",
+		webhook: &scm.PullRequestHook{Action: scm.ActionLabel},
+	}, {
+		name:    "unsupported event",
+		webhook: &scm.IssueHook{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newSCMEvent(tt.webhook, tt.driver))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package webhook
 import (
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
+	"github.com/jenkins-x/go-scm/scm/driver/gitea"
 	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
@@ -69,6 +70,28 @@ func Test_getSCMClient(t *testing.T) {
 			},
 		},
 		want: bitbucket.NewDefault(),
+	}, {
+		name: "gitea",
+		args: args{
+			request: func() *http.Request {
+				defaultRequest := &http.Request{}
+				defaultRequest.Header = map[string][]string{}
+				defaultRequest.Header.Add("X-Gitea-Event", "push")
+				return defaultRequest
+			},
+		},
+		want: &scm.Client{Driver: scm.DriverGitea, Webhooks: gitea.NewWebHookService()},
+	}, {
+		name: "forgejo",
+		args: args{
+			request: func() *http.Request {
+				defaultRequest := &http.Request{}
+				defaultRequest.Header = map[string][]string{}
+				defaultRequest.Header.Add("X-Forgejo-Event", "push")
+				return defaultRequest
+			},
+		},
+		want: &scm.Client{Driver: scm.DriverGitea, Webhooks: gitea.NewWebHookService()},
 	}, {
 		name: "unknown SCM provider",
 		args: args{
@@ -134,3 +157,83 @@ func Test_branchMatch(t *testing.T) {
 		})
 	}
 }
+
+func Test_pathMatch(t *testing.T) {
+	pipelineWithPaths := func(includes, excludes string) v1alpha3.Pipeline {
+		annotations := map[string]string{}
+		if includes != "" {
+			annotations[scmIncludePathsAnnotationKey] = includes
+		}
+		if excludes != "" {
+			annotations[scmExcludePathsAnnotationKey] = excludes
+		}
+		return v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name         string
+		pipeline     v1alpha3.Pipeline
+		changedFiles []string
+		wantOk       bool
+		wantErr      bool
+	}{{
+		name:         "no any annotations",
+		pipeline:     v1alpha3.Pipeline{},
+		changedFiles: []string{"README.md"},
+		wantOk:       true,
+	}, {
+		name:     "no changed files",
+		pipeline: pipelineWithPaths(`["services/api"]`, ""),
+		wantOk:   true,
+	}, {
+		name:         "file under an included directory",
+		pipeline:     pipelineWithPaths(`["services/api"]`, ""),
+		changedFiles: []string{"README.md", "services/api/main.go"},
+		wantOk:       true,
+	}, {
+		name:         "file out of the included directories",
+		pipeline:     pipelineWithPaths(`["services/api", "services/web/**"]`, ""),
+		changedFiles: []string{"README.md", "services/worker/main.go"},
+		wantOk:       false,
+	}, {
+		name:         "included by a wildcard",
+		pipeline:     pipelineWithPaths(`["services/*/Dockerfile"]`, ""),
+		changedFiles: []string{"services/web/Dockerfile"},
+		wantOk:       true,
+	}, {
+		name:         "all changed files are excluded",
+		pipeline:     pipelineWithPaths("", `["**/*.md", "docs/"]`),
+		changedFiles: []string{"README.md", "services/api/README.md", "docs/install/index.html"},
+		wantOk:       false,
+	}, {
+		name:         "some changed files are not excluded",
+		pipeline:     pipelineWithPaths("", `["**/*.md"]`),
+		changedFiles: []string{"README.md", "services/api/main.go"},
+		wantOk:       true,
+	}, {
+		name:         "included files are excluded",
+		pipeline:     pipelineWithPaths(`["services/api/**"]`, `["**/*_test.go"]`),
+		changedFiles: []string{"services/api/main_test.go", "services/web/main.go"},
+		wantOk:       false,
+	}, {
+		name:         "invalid include annotation",
+		pipeline:     pipelineWithPaths(`services/api`, ""),
+		changedFiles: []string{"README.md"},
+		wantErr:      true,
+	}, {
+		name:     "invalid exclude annotation",
+		pipeline: pipelineWithPaths("", `{"paths": "docs"}`),
+		wantErr:  true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := pathMatch(tt.pipeline, tt.changedFiles)
+			assert.Equal(t, tt.wantOk, ok)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
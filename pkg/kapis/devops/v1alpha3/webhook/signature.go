@@ -0,0 +1,227 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// webhookSecret is a secret which signs the webhooks, and the namespace where it comes from
+type webhookSecret struct {
+	namespace string
+	token     string
+}
+
+// getGitRepositories returns the GitRepositories which match the repository of a webhook
+func (h *SCMHandler) getGitRepositories(ctx context.Context, repo scm.Repository) (gitRepos []v1alpha3.GitRepository) {
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err := h.List(ctx, repoList); err != nil {
+		return
+	}
+
+	for i := range repoList.Items {
+		gitRepo := repoList.Items[i]
+		if gitRepo.Spec.URL != "" && gitRepoMatch(strings.TrimSuffix(gitRepo.Spec.URL, ".git"),
+			repo.Link, strings.TrimSuffix(repo.Clone, ".git"), repo.CloneSSH) {
+			gitRepos = append(gitRepos, gitRepo)
+		}
+	}
+	return
+}
+
+// getWebhookSecrets returns the secrets of the GitRepositories which match the repository of a webhook.
+// The secret of a Webhook takes precedence over the one of its GitRepository, the same as registering the webhook.
+func (h *SCMHandler) getWebhookSecrets(ctx context.Context, gitRepos []v1alpha3.GitRepository) (secrets []webhookSecret) {
+	for i := range gitRepos {
+		gitRepo := &gitRepos[i]
+		for _, webhookRef := range gitRepo.Spec.Webhooks {
+			webhook := &v1alpha3.Webhook{}
+			if err := h.Get(ctx, types.NamespacedName{Namespace: gitRepo.Namespace, Name: webhookRef.Name}, webhook); err != nil ||
+				webhook.Spec.Secret == nil {
+				continue
+			}
+			if secret := h.getTokenFromSecret(ctx, webhook.Spec.Secret, gitRepo.Namespace); secret != "" {
+				secrets = append(secrets, webhookSecret{namespace: gitRepo.Namespace, token: secret})
+			}
+		}
+		if gitRepo.Spec.Secret != nil {
+			if secret := h.getTokenFromSecret(ctx, gitRepo.Spec.Secret, gitRepo.Namespace); secret != "" {
+				secrets = append(secrets, webhookSecret{namespace: gitRepo.Namespace, token: secret})
+			}
+		}
+	}
+	return
+}
+
+// getRegisteredPipelines returns the multi-branch Pipelines whose webhook of the repository is registered automatically
+func (h *SCMHandler) getRegisteredPipelines(ctx context.Context, repo scm.Repository) (pipelines []v1alpha3.Pipeline) {
+	pipelineList := &v1alpha3.PipelineList{}
+	if err := h.List(ctx, pipelineList); err != nil {
+		return
+	}
+
+	for i := range pipelineList.Items {
+		pipeline := pipelineList.Items[i]
+		if gitURL := pipeline.Annotations[v1alpha3.PipelineWebhookAnnoKey]; gitURL != "" && gitRepoMatch(gitURL,
+			repo.Link, strings.TrimSuffix(repo.Clone, ".git"), repo.CloneSSH) {
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+	return
+}
+
+// getPipelineWebhookSecrets returns the random secrets of the registered webhooks, they are never the Pipeline credentials
+func (h *SCMHandler) getPipelineWebhookSecrets(ctx context.Context, pipelines []v1alpha3.Pipeline) (secrets []webhookSecret) {
+	for i := range pipelines {
+		pipeline := &pipelines[i]
+		if secretName := pipeline.Annotations[v1alpha3.PipelineWebhookSecretAnnoKey]; secretName != "" {
+			if secret := h.getTokenFromSecret(ctx, &v1.SecretReference{Name: secretName}, pipeline.Namespace); secret != "" {
+				secrets = append(secrets, webhookSecret{namespace: pipeline.Namespace, token: secret})
+			}
+		}
+	}
+	return
+}
+
+// getAnnotatedWebhookSecrets returns the secrets which are referred by the annotation of the Pipelines of a repository.
+// It's for the Pipelines which are matched by the annotation or the source, but without a GitRepository.
+func (h *SCMHandler) getAnnotatedWebhookSecrets(ctx context.Context, repo scm.Repository) (secrets []webhookSecret) {
+	pipelineList := &v1alpha3.PipelineList{}
+	if err := h.List(ctx, pipelineList); err != nil {
+		return
+	}
+
+	for i := range pipelineList.Items {
+		pipeline := &pipelineList.Items[i]
+		secretName := pipeline.Annotations[scmSecretAnnotationKey]
+		if secretName == "" {
+			continue
+		}
+		gitURL := pipeline.Annotations[scmAnnotationKey]
+		if pipeline.IsMultiBranch() {
+			gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
+		}
+		if gitURL == "" || !gitRepoMatch(strings.TrimSuffix(gitURL, ".git"),
+			repo.Link, strings.TrimSuffix(repo.Clone, ".git"), repo.CloneSSH) {
+			continue
+		}
+		if secret := h.getTokenFromSecret(ctx, &v1.SecretReference{Name: secretName}, pipeline.Namespace); secret != "" {
+			secrets = append(secrets, webhookSecret{namespace: pipeline.Namespace, token: secret})
+		}
+	}
+	return
+}
+
+// getTokenFromSecret returns the token of a secret, taking the default namespace if it is empty
+func (h *SCMHandler) getTokenFromSecret(ctx context.Context, ref *v1.SecretReference, defaultNamespace string) (token string) {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	secret := &v1.Secret{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, secret); err != nil {
+		return
+	}
+
+	switch secret.Type {
+	case v1.SecretTypeBasicAuth, v1alpha3.SecretTypeBasicAuth:
+		token = string(secret.Data[v1.BasicAuthPasswordKey])
+	case v1.SecretTypeOpaque:
+		token = string(secret.Data[v1.ServiceAccountTokenKey])
+	case v1alpha3.SecretTypeSecretText:
+		token = string(secret.Data[v1alpha3.SecretTextSecretKey])
+	}
+	return
+}
+
+// getVerifiedNamespaces returns the namespaces whose secrets signed the payload. Anyone is able to set a secret
+// for the URL of a public repository, so a webhook only affects the namespaces whose secrets signed it.
+func getVerifiedNamespaces(request *http.Request, payload []byte, secrets []webhookSecret) (namespaces map[string]bool) {
+	namespaces = map[string]bool{}
+	for _, secret := range secrets {
+		if !namespaces[secret.namespace] && verifySignature(request, payload, []string{secret.token}) {
+			namespaces[secret.namespace] = true
+		}
+	}
+	return
+}
+
+// verifySignature checks if the payload was signed by one of the secrets.
+// GitHub, Gitea and Bitbucket Server sign the payload with HMAC, GitLab sends the secret as a token,
+// and Bitbucket Cloud can only carry the secret in the query of the webhook URL.
+func verifySignature(request *http.Request, payload []byte, secrets []string) bool {
+	for _, secret := range secrets {
+		var ok bool
+		switch {
+		case request.Header.Get("X-Gitlab-Event") != "":
+			ok = tokenEqual(request.Header.Get("X-Gitlab-Token"), secret)
+		case request.Header.Get("X-GitHub-Event") != "":
+			if signature := request.Header.Get("X-Hub-Signature-256"); signature != "" {
+				ok = hmacEqual(sha256.New, "sha256=", signature, payload, secret)
+			} else {
+				ok = hmacEqual(sha1.New, "sha1=", request.Header.Get("X-Hub-Signature"), payload, secret)
+			}
+		case strings.HasPrefix(request.Header.Get("User-Agent"), "Bitbucket-Webhooks"):
+			if signature := request.Header.Get("X-Hub-Signature"); signature != "" {
+				ok = hmacEqual(sha256.New, "sha256=", signature, payload, secret)
+			} else {
+				ok = tokenEqual(request.URL.Query().Get("secret"), secret)
+			}
+		case isGiteaWebhook(request):
+			signature := request.Header.Get("X-Gitea-Signature")
+			if signature == "" {
+				signature = request.Header.Get("X-Forgejo-Signature")
+			}
+			ok = hmacEqual(sha256.New, "", signature, payload, secret)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hmacEqual(newHash func() hash.Hash, prefix, signature string, payload []byte, secret string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	_, _ = mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func tokenEqual(token, secret string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_verifySignature(t *testing.T) {
+	payload := []byte(`{"ref":"refs/heads/master"}`)
+	// the HMAC of the payload with the key "secret"
+	const sha256Signature = "sha256=18bd702ca7dab5713101db346ec6cd6768820c090515db9744deff53bc95ff52"
+
+	tests := []struct {
+		name    string
+		url     string
+		header  map[string]string
+		secrets []string
+		want    bool
+	}{{
+		name:    "no secrets",
+		header:  map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "secret"},
+		secrets: nil,
+		want:    false,
+	}, {
+		name:    "gitlab token",
+		header:  map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "secret"},
+		secrets: []string{"other", "secret"},
+		want:    true,
+	}, {
+		name:    "gitlab without token",
+		header:  map[string]string{"X-Gitlab-Event": "Push Hook"},
+		secrets: []string{"secret"},
+		want:    false,
+	}, {
+		name:    "github sha256 signature",
+		header:  map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": sha256Signature},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "github sha1 signature",
+		header:  map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature": "sha1=acb0be542e7d080e7e0253bfaa88c5fc95e28fe2"},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "github mismatched signature",
+		header:  map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": sha256Signature},
+		secrets: []string{"other"},
+		want:    false,
+	}, {
+		name:    "github unsigned",
+		header:  map[string]string{"X-GitHub-Event": "push"},
+		secrets: []string{"secret"},
+		want:    false,
+	}, {
+		name:    "bitbucket server signature",
+		header:  map[string]string{"User-Agent": "Bitbucket-Webhooks/2.0", "X-Hub-Signature": sha256Signature},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "bitbucket cloud secret in query",
+		url:     "http://fake.com/webhooks/scm?secret=secret",
+		header:  map[string]string{"User-Agent": "Bitbucket-Webhooks/2.0"},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "bitbucket unsigned",
+		header:  map[string]string{"User-Agent": "Bitbucket-Webhooks/2.0"},
+		secrets: []string{"secret"},
+		want:    false,
+	}, {
+		name:    "gitea signature",
+		header:  map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sha256Signature[len("sha256="):]},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "forgejo signature",
+		header:  map[string]string{"X-Forgejo-Event": "push", "X-Forgejo-Signature": sha256Signature[len("sha256="):]},
+		secrets: []string{"secret"},
+		want:    true,
+	}, {
+		name:    "gitea unsigned",
+		header:  map[string]string{"X-Gitea-Event": "push"},
+		secrets: []string{"secret"},
+		want:    false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := tt.url
+			if url == "" {
+				url = "http://fake.com/webhooks/scm"
+			}
+			request, _ := http.NewRequest(http.MethodPost, url, nil)
+			for k, v := range tt.header {
+				request.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, verifySignature(request, payload, tt.secrets))
+		})
+	}
+}
+
+func Test_getVerifiedNamespaces(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodPost, "http://fake.com/webhooks/scm", nil)
+	request.Header.Set("X-Gitlab-Event", "Push Hook")
+	request.Header.Set("X-Gitlab-Token", "secret")
+
+	namespaces := getVerifiedNamespaces(request, nil, []webhookSecret{
+		{namespace: "ns1", token: "secret"},
+		{namespace: "ns2", token: "other"},
+		{namespace: "ns3", token: "other"},
+		{namespace: "ns3", token: "secret"},
+	})
+	assert.Equal(t, map[string]bool{"ns1": true, "ns3": true}, namespaces)
+	assert.Empty(t, getVerifiedNamespaces(request, nil, []webhookSecret{{namespace: "ns1", token: "other"}}))
+}
+
+func TestSCMHandler_getPipelineWebhookSecrets(t *testing.T) {
+	newPipeline := func(name, namespace, registered string) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace}}
+		pipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+		pipeline.Spec.MultiBranchPipeline = &v1alpha3.MultiBranchPipeline{
+			SourceType:   v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{Owner: "octocat", Repo: "hello-world", CredentialId: "github"},
+		}
+		if registered != "" {
+			pipeline.Annotations = map[string]string{
+				v1alpha3.PipelineWebhookAnnoKey:       registered,
+				v1alpha3.PipelineWebhookSecretAnnoKey: "webhook",
+			}
+		}
+		return pipeline
+	}
+	newSecret := func(name, namespace string, data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		}
+	}
+
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newPipeline("registered", "ns1", "https://github.com/octocat/hello-world"),
+		newPipeline("registered", "ns2", "https://github.com/octocat/hello-world"),
+		newPipeline("not-registered", "ns1", ""),
+		newPipeline("other", "ns1", "https://github.com/octocat/other"),
+		newSecret("webhook", "ns1", map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret1")}),
+		newSecret("webhook", "ns2", map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret2")}),
+		// the credentials are never taken as the secrets of the webhooks
+		newSecret("github", "ns1", map[string][]byte{corev1.ServiceAccountTokenKey: []byte("token1")}),
+	).Build()
+
+	handler := NewSCMHandler(fakeClient, core.JenkinsCore{})
+	pipelines := handler.getRegisteredPipelines(context.TODO(), scm.Repository{
+		Link:  "https://github.com/octocat/hello-world",
+		Clone: "https://github.com/octocat/hello-world.git",
+	})
+	if assert.Equal(t, 2, len(pipelines)) {
+		assert.ElementsMatch(t, []webhookSecret{{namespace: "ns1", token: "secret1"}, {namespace: "ns2", token: "secret2"}},
+			handler.getPipelineWebhookSecrets(context.TODO(), pipelines))
+	}
+}
+
+func TestSCMHandler_getAnnotatedWebhookSecrets(t *testing.T) {
+	newPipeline := func(name, gitURL, secretName string) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{
+			Name:        name,
+			Namespace:   "ns",
+			Annotations: map[string]string{scmAnnotationKey: gitURL},
+		}}
+		if secretName != "" {
+			pipeline.Annotations[scmSecretAnnotationKey] = secretName
+		}
+		return pipeline
+	}
+	newSecret := func(name, token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "ns"},
+			Type:       v1alpha3.SecretTypeSecretText,
+			Data:       map[string][]byte{v1alpha3.SecretTextSecretKey: []byte(token)},
+		}
+	}
+
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newPipeline("annotated", "https://github.com/octocat/hello-world.git", "webhook-secret"),
+		newPipeline("without-secret", "https://github.com/octocat/hello-world", ""),
+		newPipeline("other", "https://github.com/octocat/other", "other-secret"),
+		newSecret("webhook-secret", "secret1"),
+		newSecret("other-secret", "secret2"),
+	).Build()
+
+	handler := NewSCMHandler(fakeClient, core.JenkinsCore{})
+	assert.Equal(t, []webhookSecret{{namespace: "ns", token: "secret1"}}, handler.getAnnotatedWebhookSecrets(context.TODO(), scm.Repository{
+		Link:  "https://github.com/octocat/hello-world",
+		Clone: "https://github.com/octocat/hello-world.git",
+	}))
+}
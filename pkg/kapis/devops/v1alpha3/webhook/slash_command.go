@@ -0,0 +1,301 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+const (
+	// retestCommand creates a new PipelineRun for the pull request
+	retestCommand = "/retest"
+	// cancelCommand stops the running PipelineRuns of the pull request
+	cancelCommand = "/cancel"
+)
+
+// the trust values of DiscoverPRFromForks, they are different between the providers,
+// see also the trust types in package pkg/client/devops/jenkins/internal
+const (
+	// the trust values of GitHub and GitLab
+	trustMembers    = 1
+	trustEveryone   = 2
+	trustPermission = 3
+	trustNobody     = 4
+
+	// the trust values of Bitbucket
+	bitbucketTrustEveryone  = 1
+	bitbucketTrustTeamForks = 2
+	bitbucketTrustNobody    = 3
+
+	// the trust values of Gitea
+	giteaTrustContributors = 1
+	giteaTrustEveryone     = 2
+	giteaTrustNobody       = 3
+)
+
+// permissionLevels ranks the permissions of a user in a repository
+var permissionLevels = map[string]int{
+	scm.NoPermission:    0,
+	scm.ReadPermission:  1,
+	scm.WritePermission: 2,
+	scm.AdminPermission: 3,
+}
+
+// pullRequestComment is a new comment of a pull request which might carry the slash commands
+type pullRequestComment struct {
+	number int
+	author string
+	body   string
+}
+
+// getPullRequestComment returns the comment of a webhook, or nil if it is not a new comment of a pull request
+func getPullRequestComment(webhook scm.Webhook) *pullRequestComment {
+	switch hook := webhook.(type) {
+	case *scm.IssueCommentHook:
+		// GitHub sends the comments of pull requests as issue comments
+		if hook.Action == scm.ActionCreate && hook.Issue.PullRequest != nil {
+			return newPullRequestComment(hook.Issue.Number, hook.Comment, hook.Sender)
+		}
+	case *scm.PullRequestCommentHook:
+		if hook.Action == scm.ActionCreate {
+			return newPullRequestComment(hook.PullRequest.Number, hook.Comment, hook.Sender)
+		}
+	}
+	return nil
+}
+
+func newPullRequestComment(number int, comment scm.Comment, sender scm.User) *pullRequestComment {
+	author := comment.Author.Login
+	if author == "" {
+		author = sender.Login
+	}
+	return &pullRequestComment{number: number, author: author, body: comment.Body}
+}
+
+// parseSlashCommands returns the distinct slash commands of a comment, each command takes a separate line
+func parseSlashCommands(body string) (commands []string) {
+	found := map[string]bool{}
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch command := fields[0]; command {
+		case retestCommand, cancelCommand:
+			if !found[command] {
+				found[command] = true
+				commands = append(commands, command)
+			}
+		}
+	}
+	return
+}
+
+// handlePullRequestComment runs the slash commands of a pull request comment against the matched multi-branch Pipelines
+// in the namespaces.
+// The commenter is authorized against the trust setting of the forked pull requests of each Pipeline.
+func (h *SCMHandler) handlePullRequestComment(ctx context.Context, comment *pullRequestComment, repo scm.Repository,
+	driver scm.Driver, namespaces map[string]bool, delivery *Delivery) (status int, message string) {
+	commands := parseSlashCommands(comment.body)
+	if len(commands) == 0 {
+		return http.StatusOK, "no slash command found"
+	}
+
+	pipelines, err := h.listPipelines(ctx, namespaces)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	found := false
+	prSCM := getPullRequestSCM(comment.number, driver)
+	for i := range pipelines {
+		pipeline := pipelines[i]
+		if !pipeline.IsMultiBranch() {
+			continue
+		}
+		gitURL := pipeline.Spec.MultiBranchPipeline.GetGitURL()
+		if gitURL == "" || !gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
+			continue
+		}
+		found = true
+		delivery.AddPipeline(pipeline.Namespace, pipeline.Name)
+
+		if err := h.authorizeCommenter(ctx, &pipeline, repo.FullName, comment.author); err != nil {
+			return http.StatusForbidden, err.Error()
+		}
+		for _, command := range commands {
+			var err error
+			switch command {
+			case retestCommand:
+				// the commit is resolved from the pull request when reporting the status
+				err = h.createPipelineRun(pipeline, prSCM, "", delivery)
+			case cancelCommand:
+				err = h.cancelPipelineRuns(ctx, &pipeline, prSCM.RefName)
+			}
+			if err != nil {
+				return http.StatusBadRequest, err.Error()
+			}
+		}
+	}
+
+	if !found {
+		return http.StatusOK, "no pipeline matched"
+	}
+	return http.StatusOK, "ok"
+}
+
+// cancelPipelineRuns requests to stop the uncompleted PipelineRuns of a pull request
+func (h *SCMHandler) cancelPipelineRuns(ctx context.Context, pipeline *v1alpha3.Pipeline, refName string) (err error) {
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = h.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return
+	}
+
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if pipelineRun.HasCompleted() || pipelineRun.Spec.SCM == nil || pipelineRun.Spec.SCM.RefName != refName {
+			continue
+		}
+		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			toUpdate := &v1alpha3.PipelineRun{}
+			if err := h.Get(ctx, client.ObjectKeyFromObject(pipelineRun), toUpdate); err != nil {
+				return client.IgnoreNotFound(err)
+			}
+			if toUpdate.Spec.Action != nil && *toUpdate.Spec.Action == v1alpha3.Stop {
+				return nil
+			}
+			stop := v1alpha3.Stop
+			toUpdate.Spec.Action = &stop
+			return h.Update(ctx, toUpdate)
+		}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// authorizeCommenter checks if the permission of the commenter in the repository satisfies the trust setting of a Pipeline
+func (h *SCMHandler) authorizeCommenter(ctx context.Context, pipeline *v1alpha3.Pipeline, repo, user string) (err error) {
+	provider, server, credentialID, trust := getMultiBranchSource(pipeline.Spec.MultiBranchPipeline)
+	required := getRequiredPermission(pipeline.Spec.MultiBranchPipeline.SourceType, trust)
+	if required == scm.NoPermission {
+		return
+	}
+
+	var permission string
+	if user != "" && credentialID != "" {
+		permission, err = h.getUserPermission(ctx, provider, server, &v1.SecretReference{
+			Namespace: pipeline.Namespace,
+			Name:      credentialID,
+		}, repo, user)
+	}
+	if err != nil || permissionLevels[permission] < permissionLevels[required] {
+		err = fmt.Errorf("user %q is not allowed to run the commands of Pipeline %s/%s, the %s permission is required",
+			user, pipeline.Namespace, pipeline.Name, required)
+	}
+	return
+}
+
+// getUserPermission returns the permission of a user in a repository, the credential of the Pipeline is used
+func (h *SCMHandler) getUserPermission(ctx context.Context, provider, server string, ref *v1.SecretReference,
+	repo, user string) (permission string, err error) {
+	secret := &v1.Secret{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return
+	}
+
+	var username, token string
+	switch secret.Type {
+	case v1.SecretTypeBasicAuth, v1alpha3.SecretTypeBasicAuth:
+		username = string(secret.Data[v1.BasicAuthUsernameKey])
+		token = string(secret.Data[v1.BasicAuthPasswordKey])
+	case v1.SecretTypeOpaque:
+		token = string(secret.Data[v1.ServiceAccountTokenKey])
+	case v1alpha3.SecretTypeSecretText:
+		token = string(secret.Data[v1alpha3.SecretTextSecretKey])
+	}
+
+	var scmClient *scm.Client
+	if scmClient, err = factory.NewClient(provider, server, token, func(c *scm.Client) {
+		c.Username = username
+	}); err == nil {
+		permission, _, err = scmClient.Repositories.FindUserPermission(ctx, repo, user)
+	}
+	return
+}
+
+// getRequiredPermission returns the permission which is required to run the slash commands.
+// The providers report the read permission for anyone on a public repository, so it cannot tell the members or
+// contributors apart from others, only the users who are able to write the repository are trusted unless everyone is.
+func getRequiredPermission(sourceType string, trust int) string {
+	switch sourceType {
+	case v1alpha3.SourceTypeGithub, v1alpha3.SourceTypeGitlab:
+		if trust == trustEveryone {
+			return scm.NoPermission
+		}
+	case v1alpha3.SourceTypeBitbucket:
+		if trust == bitbucketTrustEveryone {
+			return scm.NoPermission
+		}
+	case v1alpha3.SourceTypeGitea:
+		if trust == giteaTrustEveryone {
+			return scm.NoPermission
+		}
+	}
+	return scm.WritePermission
+}
+
+// getMultiBranchSource returns the provider, server, credential and trust setting of a multi-branch Pipeline
+func getMultiBranchSource(mbp *v1alpha3.MultiBranchPipeline) (provider, server, credentialID string, trust int) {
+	var forks *v1alpha3.DiscoverPRFromForks
+	provider, server = mbp.GetSCMProvider()
+	switch mbp.SourceType {
+	case v1alpha3.SourceTypeGithub:
+		if mbp.GitHubSource != nil {
+			credentialID, forks = mbp.GitHubSource.CredentialId, mbp.GitHubSource.DiscoverPRFromForks
+		}
+	case v1alpha3.SourceTypeGitlab:
+		if mbp.GitlabSource != nil {
+			credentialID, forks = mbp.GitlabSource.CredentialId, mbp.GitlabSource.DiscoverPRFromForks
+		}
+	case v1alpha3.SourceTypeBitbucket:
+		if mbp.BitbucketServerSource != nil {
+			credentialID, forks = mbp.BitbucketServerSource.CredentialId, mbp.BitbucketServerSource.DiscoverPRFromForks
+		}
+	case v1alpha3.SourceTypeGitea:
+		if mbp.GiteaSource != nil {
+			credentialID, forks = mbp.GiteaSource.CredentialId, mbp.GiteaSource.DiscoverPRFromForks
+		}
+	}
+	if forks != nil {
+		trust = forks.Trust
+	}
+	return
+}
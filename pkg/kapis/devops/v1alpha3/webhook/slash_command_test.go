@@ -0,0 +1,310 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/h2non/gock"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverruntime "github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+)
+
+func Test_parseSlashCommands(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "LGTM"},
+		{body: "please /retest"},
+		{body: "/retests"},
+		{body: "/retest", want: []string{retestCommand}},
+		{body: "  /cancel  \r\n/retest it\n/cancel", want: []string{cancelCommand, retestCommand}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseSlashCommands(tt.body))
+		})
+	}
+}
+
+func Test_getRequiredPermission(t *testing.T) {
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGithub, 0))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGithub, trustMembers))
+	assert.Equal(t, scm.NoPermission, getRequiredPermission(v1alpha3.SourceTypeGithub, trustEveryone))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGithub, 3))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGithub, 4))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGitlab, trustMembers))
+
+	// the trust values of Bitbucket are different
+	assert.Equal(t, scm.NoPermission, getRequiredPermission(v1alpha3.SourceTypeBitbucket, 1))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeBitbucket, 2))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeBitbucket, 3))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeBitbucket, 4))
+
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGitea, 1))
+	assert.Equal(t, scm.NoPermission, getRequiredPermission(v1alpha3.SourceTypeGitea, 2))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGitea, 3))
+	assert.Equal(t, scm.WritePermission, getRequiredPermission(v1alpha3.SourceTypeGit, 2))
+}
+
+func Test_getMultiBranchSource(t *testing.T) {
+	provider, server, credentialID, trust := getMultiBranchSource(&v1alpha3.MultiBranchPipeline{
+		SourceType: v1alpha3.SourceTypeGithub,
+		GitHubSource: &v1alpha3.GithubSource{
+			ApiUri:              "https://ghe.example.com/api/v3",
+			CredentialId:        "token",
+			DiscoverPRFromForks: &v1alpha3.DiscoverPRFromForks{Trust: trustMembers},
+		},
+	})
+	assert.Equal(t, "github", provider)
+	assert.Equal(t, "https://ghe.example.com/api/v3", server)
+	assert.Equal(t, "token", credentialID)
+	assert.Equal(t, trustMembers, trust)
+
+	provider, server, _, _ = getMultiBranchSource(&v1alpha3.MultiBranchPipeline{
+		SourceType:            v1alpha3.SourceTypeBitbucket,
+		BitbucketServerSource: &v1alpha3.BitbucketServerSource{ApiUri: "https://bitbucket.example.com"},
+	})
+	assert.Equal(t, "bitbucketserver", provider)
+	assert.Equal(t, "https://bitbucket.example.com", server)
+}
+
+func newIssueCommentPayload(body string) string {
+	return fmt.Sprintf(`{"action":"created","issue":{"number":1,"pull_request":{"url":"https://api.github.com/repos/octocat/hello-world/pulls/1"}},`+
+		`"comment":{"id":1,"body":%q,"user":{"login":"alice"}},`+
+		`"repository":{"id":1,"name":"hello-world","full_name":"octocat/hello-world",`+
+		`"owner":{"login":"octocat"},"html_url":"https://github.com/octocat/hello-world",`+
+		`"clone_url":"https://github.com/octocat/hello-world.git"},"sender":{"login":"alice"}}`, body)
+}
+
+func TestSlashCommandWebhook(t *testing.T) {
+	newPipeline := func(trust int) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{}
+		pipeline.SetName("fake")
+		pipeline.SetNamespace("default")
+		pipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+		pipeline.Spec.MultiBranchPipeline = &v1alpha3.MultiBranchPipeline{
+			SourceType: v1alpha3.SourceTypeGithub,
+			GitHubSource: &v1alpha3.GithubSource{
+				Owner:               "octocat",
+				Repo:                "hello-world",
+				CredentialId:        "github",
+				DiscoverPRFromForks: &v1alpha3.DiscoverPRFromForks{Strategy: 1, Trust: trust},
+			},
+		}
+		return pipeline
+	}
+	newPipelineRun := func(name, refName string, completed bool) *v1alpha3.PipelineRun {
+		run := &v1alpha3.PipelineRun{}
+		run.SetName(name)
+		run.SetNamespace("default")
+		run.SetLabels(map[string]string{v1alpha3.PipelineNameLabelKey: "fake"})
+		run.Spec.PipelineRef = &corev1.ObjectReference{Name: "fake"}
+		run.Spec.SCM = &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: refName}
+		if completed {
+			now := v1.Now()
+			run.Status.CompletionTime = &now
+		}
+		return run
+	}
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "hello-world", Namespace: "default"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:    "https://github.com/octocat/hello-world",
+			Secret: &corev1.SecretReference{Name: "secret"},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "secret", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{corev1.ServiceAccountTokenKey: []byte("secret")},
+	}
+	credential := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "github", Namespace: "default"},
+		Type:       v1alpha3.SecretTypeBasicAuth,
+		Data: map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte("bot"),
+			corev1.BasicAuthPasswordKey: []byte("token"),
+		},
+	}
+	mockPermission := func(permission string) {
+		gock.New("https://api.github.com").
+			Get("/repos/octocat/hello-world/collaborators/alice/permission").
+			Reply(200).
+			Type("application/json").
+			BodyString(fmt.Sprintf(`{"permission":%q}`, permission))
+	}
+	getPipelineRuns := func(t *testing.T, c client.Client) map[string]v1alpha3.PipelineRun {
+		pipelineRuns := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+		runs := map[string]v1alpha3.PipelineRun{}
+		for _, item := range pipelineRuns.Items {
+			runs[item.Name] = item
+		}
+		return runs
+	}
+	isStopped := func(run v1alpha3.PipelineRun) bool {
+		return run.Spec.Action != nil && *run.Spec.Action == v1alpha3.Stop
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		trust     int
+		prepare   func()
+		status    int
+		assertion func(t *testing.T, c client.Client, body string)
+	}{{
+		name:   "no slash command",
+		body:   "LGTM",
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no slash command found", body)
+			assert.Equal(t, 3, len(getPipelineRuns(t, c)))
+		},
+	}, {
+		name: "retest by a writer",
+		body: "/retest",
+		prepare: func() {
+			mockPermission(scm.WritePermission)
+		},
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ok", body)
+			runs := getPipelineRuns(t, c)
+			assert.Equal(t, 4, len(runs))
+			for name, run := range runs {
+				if strings.HasPrefix(name, "fake-") {
+					assert.Equal(t, &v1alpha3.SCM{RefType: v1alpha3.PullRequest, RefName: "PR-1"}, run.Spec.SCM)
+					assert.Equal(t, "webhook", run.Annotations[triggerAnnotationKey])
+				}
+			}
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(deliveries)) {
+				assert.Equal(t, "issue_comment", deliveries[0].Event)
+				assert.Equal(t, []string{"fake"}, deliveries[0].Pipelines)
+				assert.Equal(t, 1, len(deliveries[0].PipelineRuns))
+			}
+		},
+	}, {
+		name:  "retest by a reader who is not trusted",
+		body:  "/retest",
+		trust: 3,
+		prepare: func() {
+			mockPermission(scm.ReadPermission)
+		},
+		status: http.StatusForbidden,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `user "alice" is not allowed`)
+			assert.Equal(t, 3, len(getPipelineRuns(t, c)))
+		},
+	}, {
+		// anyone is able to read a public repository, it does not make a user a member or contributor
+		name:  "retest by a reader when the members are trusted",
+		body:  "/retest",
+		trust: trustMembers,
+		prepare: func() {
+			mockPermission(scm.ReadPermission)
+		},
+		status: http.StatusForbidden,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, "the write permission is required")
+			assert.Equal(t, 3, len(getPipelineRuns(t, c)))
+		},
+	}, {
+		name:  "retest by a writer when the members are trusted",
+		body:  "/retest",
+		trust: trustMembers,
+		prepare: func() {
+			mockPermission(scm.WritePermission)
+		},
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, 4, len(getPipelineRuns(t, c)))
+		},
+	}, {
+		name:   "cancel by everyone",
+		body:   "/cancel",
+		trust:  trustEveryone,
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ok", body)
+			runs := getPipelineRuns(t, c)
+			assert.True(t, isStopped(runs["running-pr-1"]))
+			assert.False(t, isStopped(runs["running-pr-2"]))
+			assert.False(t, isStopped(runs["completed-pr-1"]))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer gock.Off()
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				newPipeline(tt.trust), newPipelineRun("running-pr-1", "PR-1", false),
+				newPipelineRun("running-pr-2", "PR-2", false), newPipelineRun("completed-pr-1", "PR-1", true),
+				gitRepo.DeepCopy(), secret.DeepCopy(), credential.DeepCopy()).Build()
+
+			container := restful.NewContainer()
+			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+			RegisterWebhooks(fakeClient, wsWithGroup, core.JenkinsCore{})
+			container.Add(wsWithGroup)
+
+			payload := newIssueCommentPayload(tt.body)
+			mac := hmac.New(sha256.New, []byte("secret"))
+			_, _ = mac.Write([]byte(payload))
+			httpRequest, _ := http.NewRequest(http.MethodPost,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3/webhooks/scm", strings.NewReader(payload))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpRequest.Header.Set("X-GitHub-Event", "issue_comment")
+			httpRequest.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			httpRequest.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code, httpWriter.Body.String())
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient, httpWriter.Body.String())
+			}
+			assert.True(t, gock.IsDone())
+		})
+	}
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+)
+
+// TriggerResult is the result of triggering a Pipeline by its generic webhook
+type TriggerResult struct {
+	Triggered bool `json:"triggered"`
+	// PipelineRun is the name of the created PipelineRun
+	PipelineRun string `json:"pipelineRun,omitempty"`
+	// Variables are the resolved variables, they are the parameters of the PipelineRun
+	Variables map[string]string `json:"variables,omitempty"`
+	Message   string            `json:"message,omitempty"`
+}
+
+var invalidVariableNameChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// triggerPipeline triggers a Pipeline by its generic webhook, the token of the webhook is required
+func (h *SCMHandler) triggerPipeline(request *restful.Request, response *restful.Response) {
+	ctx := request.Request.Context()
+	pipeline := &v1alpha3.Pipeline{}
+	if err := h.Get(ctx, types.NamespacedName{
+		Namespace: request.PathParameter("namespace"),
+		Name:      request.PathParameter("pipeline"),
+	}, pipeline); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	webhook := getGenericWebhook(pipeline)
+	if webhook == nil {
+		kapis.HandleNotFound(response, request, fmt.Errorf("the generic webhook of Pipeline %s is not enabled", pipeline.Name))
+		return
+	}
+	if !verifyTriggerToken(request.Request, webhook.Token) {
+		kapis.HandleUnauthorized(response, request, errors.New("the token of the generic webhook is missing or invalid"))
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(request.Request.Body, maxPayloadSize))
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	request.Request.Body = io.NopCloser(bytes.NewReader(payload))
+
+	delivery := NewDelivery(request.Request, ProviderTrigger)
+	delivery.Verified = true
+	delivery.SetPayload(payload)
+	result, err := h.trigger(ctx, pipeline, request.Request, payload, delivery)
+	if err != nil {
+		delivery.SetResult(http.StatusBadRequest, err.Error())
+	} else {
+		delivery.SetResult(http.StatusOK, result.Message)
+	}
+	h.deliveries.Record(ctx, delivery)
+
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	_ = response.WriteEntity(result)
+}
+
+// trigger resolves the variables from a request, and creates a PipelineRun with them if the filter is matched
+func (h *SCMHandler) trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, request *http.Request, payload []byte,
+	delivery *Delivery) (result *TriggerResult, err error) {
+	webhook := getGenericWebhook(pipeline)
+	if webhook == nil {
+		err = fmt.Errorf("the generic webhook of Pipeline %s is not enabled", pipeline.Name)
+		return
+	}
+	delivery.AddPipeline(pipeline.Namespace, pipeline.Name)
+
+	result = &TriggerResult{}
+	if result.Variables, err = resolveVariables(webhook, request, payload); err != nil {
+		return
+	}
+
+	var matched bool
+	if matched, err = filterMatch(webhook, result.Variables); err != nil {
+		return
+	} else if !matched {
+		result.Message = "the filter text does not match the filter expression"
+		return
+	}
+
+	run := pipelinerun.CreateBarePipelineRun(pipeline, toParameters(result.Variables), nil)
+	run.Annotations[triggerAnnotationKey] = "webhook"
+	if err = h.Create(ctx, run); err != nil {
+		return
+	}
+	delivery.AddPipelineRun(run.Namespace, run.Name)
+	result.Triggered = true
+	result.PipelineRun = run.Name
+	result.Message = "ok"
+	return
+}
+
+// getGenericWebhook returns the generic webhook of a Pipeline, or nil if it is not enabled
+func getGenericWebhook(pipeline *v1alpha3.Pipeline) *v1alpha3.GenericWebhook {
+	if noScm := pipeline.Spec.Pipeline; noScm != nil && noScm.GenericWebhook != nil && noScm.GenericWebhook.Enable {
+		return noScm.GenericWebhook
+	}
+	return nil
+}
+
+// verifyTriggerToken checks the token from the query or the header "token". The header Authorization is not supported,
+// the apiserver authenticates the requests which have it before they reach here.
+// A generic webhook without a token cannot be triggered.
+func verifyTriggerToken(request *http.Request, expected string) bool {
+	if expected == "" {
+		return false
+	}
+
+	token := request.URL.Query().Get("token")
+	if token == "" {
+		token = request.Header.Get("token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// resolveVariables resolves the variables of a generic webhook.
+// The key of a request variable is a query parameter, or a JSONPath of the JSON payload if there is no such parameter.
+// The key of a header variable is a header, its variable name is in lower case and the hyphens are replaced with underscores.
+// The values which match the regexp filter of a variable are removed.
+func resolveVariables(webhook *v1alpha3.GenericWebhook, request *http.Request, payload []byte) (variables map[string]string, err error) {
+	var data interface{}
+	if len(payload) > 0 {
+		// the payload might not be JSON, only the query parameters are available in this case
+		_ = json.Unmarshal(payload, &data)
+	}
+
+	variables = map[string]string{}
+	query := request.URL.Query()
+	for _, item := range webhook.RequestVariables {
+		var value string
+		if query.Has(item.Key) {
+			value = query.Get(item.Key)
+		} else if data != nil {
+			if value, err = evaluateJSONPath(data, item.Key); err != nil {
+				return
+			}
+		}
+		if variables[getVariableName(item.Key)], err = filterValue(value, item.RegexpFilter); err != nil {
+			return
+		}
+	}
+
+	for _, item := range webhook.HeaderVariables {
+		name := strings.ReplaceAll(strings.ToLower(item.Key), "-", "_")
+		if variables[name], err = filterValue(request.Header.Get(item.Key), item.RegexpFilter); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// evaluateJSONPath evaluates a JSONPath expression, such as: $.repository.name or repository.name
+func evaluateJSONPath(data interface{}, expression string) (value string, err error) {
+	expression = strings.TrimSuffix(strings.TrimPrefix(expression, "{"), "}")
+	if !strings.HasPrefix(expression, "$") && !strings.HasPrefix(expression, ".") {
+		expression = "." + expression
+	}
+
+	parser := jsonpath.New("variable").AllowMissingKeys(true)
+	if err = parser.Parse("{" + expression + "}"); err != nil {
+		err = fmt.Errorf("invalid JSONPath %q, error: %v", expression, err)
+		return
+	}
+	results, err := parser.FindResults(data)
+	if err != nil {
+		return
+	}
+
+	var values []string
+	for _, result := range results {
+		for _, item := range result {
+			if str, ok := item.Interface().(string); ok {
+				values = append(values, str)
+			} else if itemData, marshalErr := json.Marshal(item.Interface()); marshalErr == nil {
+				values = append(values, string(itemData))
+			}
+		}
+	}
+	value = strings.Join(values, ",")
+	return
+}
+
+// getVariableName returns a valid parameter name from the key of a variable, e.g. $.repository.name is repository_name
+func getVariableName(key string) string {
+	key = strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(key, "{"), "}"), "$")
+	return strings.Trim(invalidVariableNameChars.ReplaceAllString(key, "_"), "_")
+}
+
+func filterValue(value, regexpFilter string) (string, error) {
+	if regexpFilter == "" {
+		return value, nil
+	}
+	reg, err := regexp.Compile(regexpFilter)
+	if err != nil {
+		return "", fmt.Errorf("invalid regexp filter %q, error: %v", regexpFilter, err)
+	}
+	return reg.ReplaceAllString(value, ""), nil
+}
+
+// filterMatch checks if the filter text matches the filter expression entirely, the variables in the text are expanded.
+// It's always matched if there is no filter expression.
+func filterMatch(webhook *v1alpha3.GenericWebhook, variables map[string]string) (bool, error) {
+	if webhook.FilterExpression == "" {
+		return true, nil
+	}
+	reg, err := regexp.Compile("^(?:" + webhook.FilterExpression + ")$")
+	if err != nil {
+		return false, fmt.Errorf("invalid filter expression %q, error: %v", webhook.FilterExpression, err)
+	}
+	return reg.MatchString(os.Expand(webhook.FilterText, variableMapping(variables))), nil
+}
+
+func variableMapping(variables map[string]string) func(string) string {
+	return func(name string) string {
+		return variables[name]
+	}
+}
+
+func toParameters(variables map[string]string) (parameters []v1alpha3.Parameter) {
+	for name, value := range variables {
+		if name != "" {
+			parameters = append(parameters, v1alpha3.Parameter{Name: name, Value: value})
+		}
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i].Name < parameters[j].Name
+	})
+	return
+}
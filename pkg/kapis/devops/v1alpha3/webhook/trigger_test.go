@@ -0,0 +1,295 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	"github.com/stretchr/testify/assert"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverruntime "github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+)
+
+const triggerBody = `{"ref":"refs/heads/master","repository":{"name":"test"},"commits":[{"id":"a"},{"id":"b"}]}`
+
+func Test_resolveVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook *v1alpha3.GenericWebhook
+		uri     string
+		header  map[string]string
+		payload string
+		want    map[string]string
+		wantErr bool
+	}{{
+		name:    "no variables",
+		webhook: &v1alpha3.GenericWebhook{},
+		uri:     "/",
+		want:    map[string]string{},
+	}, {
+		name: "from query, payload and header",
+		webhook: &v1alpha3.GenericWebhook{
+			RequestVariables: []v1alpha3.GenericVariable{
+				{Key: "env"},
+				{Key: "ref", RegexpFilter: "^refs/heads/"},
+				{Key: "$.repository.name"},
+				{Key: "commits[*].id"},
+				{Key: "missing"},
+			},
+			HeaderVariables: []v1alpha3.GenericVariable{{Key: "X-Event-Type"}},
+		},
+		uri:     "/?env=test&ref=refs/heads/dev",
+		header:  map[string]string{"X-Event-Type": "push"},
+		payload: triggerBody,
+		want: map[string]string{
+			"env":             "test",
+			"ref":             "dev",
+			"repository_name": "test",
+			"commits_id":      "a,b",
+			"missing":         "",
+			"x_event_type":    "push",
+		},
+	}, {
+		name: "payload is not JSON",
+		webhook: &v1alpha3.GenericWebhook{
+			RequestVariables: []v1alpha3.GenericVariable{{Key: "ref"}},
+		},
+		uri:     "/",
+		payload: "ref=master",
+		want:    map[string]string{"ref": ""},
+	}, {
+		name: "invalid JSONPath",
+		webhook: &v1alpha3.GenericWebhook{
+			RequestVariables: []v1alpha3.GenericVariable{{Key: "commits[.id"}},
+		},
+		uri:     "/",
+		payload: triggerBody,
+		wantErr: true,
+	}, {
+		name: "invalid regexp filter",
+		webhook: &v1alpha3.GenericWebhook{
+			HeaderVariables: []v1alpha3.GenericVariable{{Key: "X-Event-Type", RegexpFilter: "("}},
+		},
+		uri:     "/",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodPost, tt.uri, nil)
+			for k, v := range tt.header {
+				request.Header.Set(k, v)
+			}
+			variables, err := resolveVariables(tt.webhook, request, []byte(tt.payload))
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, variables)
+		})
+	}
+}
+
+func Test_filterMatch(t *testing.T) {
+	variables := map[string]string{"ref": "master", "action": "push"}
+	tests := []struct {
+		name    string
+		webhook *v1alpha3.GenericWebhook
+		want    bool
+		wantErr bool
+	}{{
+		name:    "no filter expression",
+		webhook: &v1alpha3.GenericWebhook{FilterText: "$ref"},
+		want:    true,
+	}, {
+		name:    "matched",
+		webhook: &v1alpha3.GenericWebhook{FilterText: "${action}-$ref", FilterExpression: "push-(master|main)"},
+		want:    true,
+	}, {
+		name:    "partially matched",
+		webhook: &v1alpha3.GenericWebhook{FilterText: "$ref", FilterExpression: "mast"},
+		want:    false,
+	}, {
+		name:    "invalid filter expression",
+		webhook: &v1alpha3.GenericWebhook{FilterText: "$ref", FilterExpression: "("},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := filterMatch(tt.webhook, variables)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, matched)
+		})
+	}
+}
+
+func Test_verifyTriggerToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		uri      string
+		header   map[string]string
+		expected string
+		want     bool
+	}{{
+		name:     "token in query",
+		uri:      "/?token=secret",
+		expected: "secret",
+		want:     true,
+	}, {
+		name:     "token in header",
+		uri:      "/",
+		header:   map[string]string{"token": "secret"},
+		expected: "secret",
+		want:     true,
+	}, {
+		name:     "bearer token is not supported",
+		uri:      "/",
+		header:   map[string]string{"Authorization": "Bearer secret"},
+		expected: "secret",
+		want:     false,
+	}, {
+		name:     "mismatched token",
+		uri:      "/?token=fake",
+		expected: "secret",
+		want:     false,
+	}, {
+		name: "no token configured",
+		uri:  "/",
+		want: false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, _ := http.NewRequest(http.MethodPost, tt.uri, nil)
+			for k, v := range tt.header {
+				request.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, verifyTriggerToken(request, tt.expected))
+		})
+	}
+}
+
+func TestTriggerPipeline(t *testing.T) {
+	newPipeline := func(name string, webhook *v1alpha3.GenericWebhook) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{}
+		pipeline.SetName(name)
+		pipeline.SetNamespace("default")
+		pipeline.Spec.Type = v1alpha3.NoScmPipelineType
+		pipeline.Spec.Pipeline = &v1alpha3.NoScmPipeline{Name: name, GenericWebhook: webhook}
+		return pipeline
+	}
+	webhook := &v1alpha3.GenericWebhook{
+		Enable:           true,
+		Token:            "secret",
+		RequestVariables: []v1alpha3.GenericVariable{{Key: "ref", RegexpFilter: "^refs/heads/"}},
+		FilterText:       "$ref",
+		FilterExpression: "master|main",
+	}
+	disabledWebhook := webhook.DeepCopy()
+	disabledWebhook.Enable = false
+
+	tests := []struct {
+		name      string
+		uri       string
+		body      string
+		status    int
+		assertion func(t *testing.T, c client.Client, body string)
+	}{{
+		name:   "non-existing Pipeline",
+		uri:    "/namespaces/default/pipelines/fake/trigger?token=secret",
+		status: http.StatusNotFound,
+	}, {
+		name:   "generic webhook is not enabled",
+		uri:    "/namespaces/default/pipelines/disabled/trigger?token=secret",
+		status: http.StatusNotFound,
+	}, {
+		name:   "invalid token",
+		uri:    "/namespaces/default/pipelines/webhook/trigger?token=fake",
+		body:   triggerBody,
+		status: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			assert.Empty(t, deliveries)
+		},
+	}, {
+		name:   "filter is not matched",
+		uri:    "/namespaces/default/pipelines/webhook/trigger?token=secret",
+		body:   strings.ReplaceAll(triggerBody, "master", "dev"),
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `"triggered": false`)
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			assert.Empty(t, pipelineRuns.Items)
+		},
+	}, {
+		name:   "triggered",
+		uri:    "/namespaces/default/pipelines/webhook/trigger?token=secret",
+		body:   triggerBody,
+		status: http.StatusOK,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Contains(t, body, `"triggered": true`)
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.TODO(), pipelineRuns))
+			if assert.Equal(t, 1, len(pipelineRuns.Items)) {
+				assert.Equal(t, []v1alpha3.Parameter{{Name: "ref", Value: "master"}}, pipelineRuns.Items[0].Spec.Parameters)
+				assert.Equal(t, "webhook", pipelineRuns.Items[0].Annotations[triggerAnnotationKey])
+			}
+
+			deliveries, err := NewDeliveryStore(c).List(context.TODO(), "default")
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(deliveries)) {
+				assert.Equal(t, ProviderTrigger, deliveries[0].Provider)
+				assert.Equal(t, []string{"webhook"}, deliveries[0].Pipelines)
+				assert.Empty(t, deliveries[0].Query)
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(newPipeline("webhook", webhook), newPipeline("disabled", disabledWebhook)).Build()
+
+			container := restful.NewContainer()
+			wsWithGroup := apiserverruntime.NewWebService(v1alpha3.GroupVersion)
+			RegisterWebhooks(fakeClient, wsWithGroup, core.JenkinsCore{})
+			container.Add(wsWithGroup)
+
+			httpRequest, _ := http.NewRequest(http.MethodPost,
+				"http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.uri, strings.NewReader(tt.body))
+			httpRequest.Header.Set("Content-Type", "application/json")
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+			assert.Equal(t, tt.status, httpWriter.Code)
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient, httpWriter.Body.String())
+			}
+		})
+	}
+}
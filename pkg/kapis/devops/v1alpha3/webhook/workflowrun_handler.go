@@ -125,6 +125,51 @@ func (handler *Handler) handleWorkflowRunInitialize(workflowRunData *workflowrun
 	return nil
 }
 
+// handleWorkflowRunEvent returns a handler which notifies the PipelineRun controller of the event,
+// then the controller synchronizes the running data from Jenkins instead of polling it frequently.
+func (handler *Handler) handleWorkflowRunEvent(eventType string) workflowrun.Handler {
+	return func(workflowRunData *workflowrun.Data) error {
+		identifier := extractPipelineRunIdentifier(workflowRunData)
+		if identifier == nil {
+			// we should skip this event if the Pipeline is not a standard Pipeline in ks-devops.
+			return nil
+		}
+
+		pipelineRunList := &v1alpha3.PipelineRunList{}
+		if err := handler.List(context.Background(), pipelineRunList,
+			client.InNamespace(identifier.namespaceName),
+			client.MatchingFields{v1alpha3.PipelineRunIdentifierIndexerName: identifier.String()}); err != nil {
+			return err
+		}
+
+		for i := range pipelineRunList.Items {
+			key := client.ObjectKeyFromObject(&pipelineRunList.Items[i])
+			if err := handler.notifyPipelineRun(key, eventType); err != nil {
+				return err
+			}
+			klog.V(4).Infof("notified PipelineRun %s of the Jenkins event: %s", key, eventType)
+		}
+		return nil
+	}
+}
+
+func (handler *Handler) notifyPipelineRun(key client.ObjectKey, eventType string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pipelineRun := &v1alpha3.PipelineRun{}
+		if err := handler.Get(context.Background(), key, pipelineRun); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = make(map[string]string)
+		}
+		if pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunEventAnnoKey] == eventType {
+			return nil
+		}
+		pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunEventAnnoKey] = eventType
+		return handler.Update(context.Background(), pipelineRun)
+	})
+}
+
 func (handler *Handler) retryCheckPipelineRunList(id *pipelineRunIdentifier) error {
 	return retry.OnError(retry.DefaultRetry, func(err error) bool {
 		return true
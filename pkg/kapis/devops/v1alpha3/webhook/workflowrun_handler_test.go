@@ -137,6 +137,10 @@ func Test_convertParameters(t *testing.T) {
 		})
 	}
 }
+func extractPipelineRunIdentifierIndex(o client.Object) []string {
+	return []string{o.(*v1alpha3.PipelineRun).GetPipelineRunIdentifier()}
+}
+
 func createWorkflowRun(parentFullName, projectName, buildNumber string, isMultiBranch bool) *workflowrun.Data {
 	return &workflowrun.Data{
 		ParentFullName: parentFullName,
@@ -275,7 +279,8 @@ func TestHandler_handleWorkflowRunInitialize(t *testing.T) {
 	for _, tt := range tests {
 		scheme := runtime.NewScheme()
 		_ = v1alpha3.AddToScheme(scheme)
-		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.args.initObjs...).Build()
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.args.initObjs...).
+			WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, extractPipelineRunIdentifierIndex).Build()
 
 		t.Run(tt.name, func(t *testing.T) {
 			handler := &Handler{
@@ -292,3 +297,82 @@ func TestHandler_handleWorkflowRunInitialize(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_handleWorkflowRunEvent(t *testing.T) {
+	createPipelineRun := func(namespace, name, pipelineName, runID string) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					v1alpha3.PipelineNameLabelKey: pipelineName,
+				},
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: runID,
+				},
+			},
+		}
+	}
+	type args struct {
+		eventType       string
+		workflowRunData *workflowrun.Data
+		initObjs        []client.Object
+	}
+	tests := []struct {
+		name      string
+		args      args
+		wantErr   bool
+		assertion func(*testing.T, client.Client)
+	}{{
+		name: "Should annotate the PipelineRun with the event type",
+		args: args{
+			eventType:       "run.completed",
+			workflowRunData: createWorkflowRun("fake-namespace", "fake-pipeline", "1", false),
+			initObjs: []client.Object{
+				createPipelineRun("fake-namespace", "fake-pipeline-run-1", "fake-pipeline", "1"),
+				createPipelineRun("fake-namespace", "fake-pipeline-run-2", "fake-pipeline", "2"),
+			},
+		},
+		assertion: func(t *testing.T, c client.Client) {
+			pipelineRun := &v1alpha3.PipelineRun{}
+			err := c.Get(context.Background(), client.ObjectKey{Namespace: "fake-namespace", Name: "fake-pipeline-run-1"}, pipelineRun)
+			assert.Nil(t, err)
+			assert.Equal(t, "run.completed", pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunEventAnnoKey])
+
+			err = c.Get(context.Background(), client.ObjectKey{Namespace: "fake-namespace", Name: "fake-pipeline-run-2"}, pipelineRun)
+			assert.Nil(t, err)
+			assert.Empty(t, pipelineRun.Annotations[v1alpha3.JenkinsPipelineRunEventAnnoKey])
+		},
+	}, {
+		name: "Should do nothing if the PipelineRun not found",
+		args: args{
+			eventType:       "run.started",
+			workflowRunData: createWorkflowRun("fake-namespace", "fake-pipeline", "1", false),
+		},
+	}, {
+		name: "Should do nothing if WorkflowRunData is invalid",
+		args: args{
+			eventType:       "run.started",
+			workflowRunData: createWorkflowRun("", "", "", false),
+		},
+	}}
+	for _, tt := range tests {
+		scheme := runtime.NewScheme()
+		_ = v1alpha3.AddToScheme(scheme)
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.args.initObjs...).
+			WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, extractPipelineRunIdentifierIndex).Build()
+
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{
+				Client: fakeClient,
+			}
+			if err := handler.handleWorkflowRunEvent(tt.args.eventType)(tt.args.workflowRunData); (err != nil) != tt.wantErr {
+				t.Errorf("Handler.handleWorkflowRunEvent() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.assertion != nil {
+				tt.assertion(t, fakeClient)
+			}
+		})
+	}
+}
@@ -17,10 +17,13 @@ package argocd
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/emicklei/go-restful/v3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/authentication/user"
 	utilretry "k8s.io/client-go/util/retry"
@@ -41,6 +44,10 @@ var invalidRequestBodyError = restful.NewError(http.StatusBadRequest,
 	"invalid application sync request")
 var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
 	"unauthenticated request")
+var invalidRollbackRequestError = restful.NewError(http.StatusBadRequest,
+	"invalid application rollback request")
+var autoSyncEnabledError = restful.NewError(http.StatusBadRequest,
+	"rollback cannot be initiated when auto-sync is enabled")
 
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
@@ -78,13 +85,23 @@ func (h *handler) populateApplicationSummary(namespace string) (*ApplicationsSum
 
 	for i := range applicationList.Items {
 		app := &applicationList.Items[i]
-		// accumulate health status
 		healthStatus := app.GetLabels()[v1alpha1.HealthStatusLabelKey]
+		syncStatus := app.GetLabels()[v1alpha1.SyncStatusLabelKey]
+		// prefer the typed status, the labels are kept for filtering
+		if argoStatus := app.Status.ArgoAppStatus; argoStatus != nil {
+			if argoStatus.Health.Status != "" {
+				healthStatus = string(argoStatus.Health.Status)
+			}
+			if argoStatus.Sync.Status != "" {
+				syncStatus = string(argoStatus.Sync.Status)
+			}
+		}
+
+		// accumulate health status
 		if healthStatus != "" {
 			summary.HealthStatus[healthStatus]++
 		}
 		// accumulate sync status
-		syncStatus := app.GetLabels()[v1alpha1.SyncStatusLabelKey]
 		if syncStatus != "" {
 			summary.SyncStatus[syncStatus]++
 		}
@@ -159,6 +176,175 @@ func (h *handler) syncApplication(namespace, name string, syncRequest *Applicati
 	return h.updateOperation(namespace, name, operation)
 }
 
+func (h *handler) handleRollbackApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	rollbackRequest := &ApplicationRollbackRequest{}
+	if err := req.ReadEntity(rollbackRequest); err != nil {
+		common.Response(req, res, nil, invalidRollbackRequestError)
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	app, err := h.rollbackApplication(namespace, name, rollbackRequest, currentUser)
+	common.Response(req, res, app, err)
+}
+
+// rollbackApplication syncs the application to the revision and source of a history,
+// it's the same as the rollback of Argo CD which requires the auto-sync to be disabled
+func (h *handler) rollbackApplication(namespace, name string, rollbackRequest *ApplicationRollbackRequest, currentUser user.Info) (*v1alpha1.Application, error) {
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, err
+	}
+	if app.Spec.ArgoApp == nil {
+		return nil, argoAppNotConfiguredError
+	}
+	if syncPolicy := app.Spec.ArgoApp.Spec.SyncPolicy; syncPolicy != nil && syncPolicy.Automated != nil {
+		return nil, autoSyncEnabledError
+	}
+
+	var history *v1alpha1.RevisionHistory
+	if status := app.Status.ArgoAppStatus; status != nil {
+		for i := range status.History {
+			if status.History[i].ID == rollbackRequest.ID {
+				history = &status.History[i]
+				break
+			}
+		}
+	}
+	if history == nil {
+		return nil, restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("application %s does not have the history %d", name, rollbackRequest.ID))
+	}
+
+	source := history.Source
+	operation := &v1alpha1.Operation{
+		Sync: &v1alpha1.SyncOperation{
+			Revision: history.Revision,
+			Prune:    rollbackRequest.Prune,
+			DryRun:   rollbackRequest.DryRun,
+			Source:   &source,
+		},
+		InitiatedBy: v1alpha1.OperationInitiator{Username: currentUser.GetName()},
+	}
+	return h.updateOperation(namespace, name, operation)
+}
+
+func (h *handler) applicationDiff(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	diff, err := h.diffApplication(namespace, name)
+	common.Response(req, res, diff, err)
+}
+
+// diffApplication returns the changes of the latest dry-run sync if it's up to date. Argo CD renders the manifests
+// and compares them with the live objects, so the out-of-sync resources are returned if there is no such dry-run sync.
+// Only the actions are returned, the fields of the changes are kept by the Argo CD API server.
+func (h *handler) diffApplication(namespace, name string) (*gitops.ApplicationDiff, error) {
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, err
+	}
+	if app.Spec.ArgoApp == nil {
+		return nil, argoAppNotConfiguredError
+	}
+
+	diff := &gitops.ApplicationDiff{Resources: []gitops.ResourceDiff{}}
+	status := app.Status.ArgoAppStatus
+	if status == nil {
+		return diff, nil
+	}
+	destination := app.Spec.ArgoApp.Spec.Destination.Name
+	if destination == "" {
+		destination = app.Spec.ArgoApp.Spec.Destination.Server
+	}
+
+	if operationState := status.OperationState; isLatestDryRun(app, operationState) {
+		diff.Revision = operationState.SyncResult.Revision
+		for _, result := range operationState.SyncResult.Resources {
+			if result == nil || result.IsHook() {
+				continue
+			}
+			diff.Resources = append(diff.Resources, gitops.ResourceDiff{
+				Group:       result.Group,
+				Version:     result.Version,
+				Kind:        result.Kind,
+				Namespace:   result.Namespace,
+				Name:        result.Name,
+				Destination: destination,
+				Action:      getDryRunAction(result.Message),
+				Message:     result.Message,
+			})
+		}
+		return diff, nil
+	}
+
+	diff.Revision = status.Sync.Revision
+	for _, resource := range status.Resources {
+		if resource.Hook {
+			continue
+		}
+		action := gitops.DiffActionNone
+		switch {
+		case resource.RequiresPruning:
+			action = gitops.DiffActionDelete
+		case resource.Status == v1alpha1.SyncStatusCodeOutOfSync:
+			action = gitops.DiffActionUpdate
+			if resource.Health != nil && resource.Health.Status == v1alpha1.HealthStatusMissing {
+				action = gitops.DiffActionCreate
+			}
+		case resource.Status != v1alpha1.SyncStatusCodeSynced:
+			action = gitops.DiffActionUnknown
+		}
+		diff.Resources = append(diff.Resources, gitops.ResourceDiff{
+			Group:       resource.Group,
+			Version:     resource.Version,
+			Kind:        resource.Kind,
+			Namespace:   resource.Namespace,
+			Name:        resource.Name,
+			Destination: destination,
+			Action:      action,
+		})
+	}
+	return diff, nil
+}
+
+// isLatestDryRun returns true if the operation is a succeeded dry-run sync of the current source and revision.
+// A dry-run sync of another target revision, or of a revision which is not the latest one, is stale.
+func isLatestDryRun(app *v1alpha1.Application, operationState *v1alpha1.OperationState) bool {
+	if operationState == nil || operationState.Operation.Sync == nil || !operationState.Operation.Sync.DryRun ||
+		operationState.Phase != v1alpha1.OperationSucceeded || operationState.SyncResult == nil {
+		return false
+	}
+	syncResult := operationState.SyncResult
+	return syncResult.Revision == app.Status.ArgoAppStatus.Sync.Revision &&
+		equality.Semantic.DeepEqual(syncResult.Source, app.Spec.ArgoApp.Spec.Source)
+}
+
+// getDryRunAction parses the action from the message of a dry-run result,
+// such as "deployment.apps/guestbook-ui configured (dry run)"
+func getDryRunAction(message string) gitops.DiffAction {
+	switch {
+	case strings.Contains(message, "created"):
+		return gitops.DiffActionCreate
+	case strings.Contains(message, "configured"):
+		return gitops.DiffActionUpdate
+	case strings.Contains(message, "pruned"), strings.Contains(message, "requires pruning"):
+		return gitops.DiffActionDelete
+	case strings.Contains(message, "unchanged"):
+		return gitops.DiffActionNone
+	}
+	return gitops.DiffActionUnknown
+}
+
 func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Operation) (*v1alpha1.Application, error) {
 	var app *v1alpha1.Application
 	return app, utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
@@ -304,3 +304,269 @@ func Test_handler_updateOperation(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_handleRollbackApplication(t *testing.T) {
+	history := []v1alpha1.RevisionHistory{{
+		ID:       1,
+		Revision: "old-revision",
+		Source:   v1alpha1.ApplicationSource{RepoURL: "https://fake.com/repo", Path: "old-path"},
+	}, {
+		ID:       2,
+		Revision: "new-revision",
+		Source:   v1alpha1.ApplicationSource{RepoURL: "https://fake.com/repo", Path: "new-path"},
+	}}
+	createApp := func(name string, syncPolicy *v1alpha1.SyncPolicy, history []v1alpha1.RevisionHistory) *v1alpha1.Application {
+		app := &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "fake-namespace",
+			},
+			Spec: v1alpha1.ApplicationSpec{
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Spec: v1alpha1.ArgoApplicationSpec{SyncPolicy: syncPolicy},
+				},
+			},
+		}
+		if history != nil {
+			app.Status.ArgoAppStatus = &v1alpha1.ArgoApplicationStatus{History: history}
+		}
+		return app
+	}
+	createRequest := func(name, body string, withUser bool) *restful.Request {
+		testReq := httptest.NewRequest(http.MethodPost, "/applications/app/rollback", bytes.NewBufferString(body))
+		testReq.Header.Set(restful.HEADER_ContentType, restful.MIME_JSON)
+		if withUser {
+			testReq = testReq.WithContext(request.WithUser(testReq.Context(), &user.DefaultInfo{Name: "fake-user"}))
+		}
+		req := restful.NewRequest(testReq)
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+		req.PathParameters()[pathParameterApplication.Data().Name] = name
+		return req
+	}
+
+	tests := []struct {
+		name             string
+		app              *v1alpha1.Application
+		req              *restful.Request
+		wantResponseCode int
+		verifyResponse   func(t *testing.T, response string)
+	}{{
+		name:             "invalid request body",
+		app:              createApp("fake-app", nil, history),
+		req:              createRequest("fake-app", "invalid", true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, invalidRollbackRequestError.Error())
+		},
+	}, {
+		name:             "unauthenticated request",
+		app:              createApp("fake-app", nil, history),
+		req:              createRequest("fake-app", `{"id":1}`, false),
+		wantResponseCode: http.StatusUnauthorized,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, unauthenticatedError.Error())
+		},
+	}, {
+		name:             "auto-sync is enabled",
+		app:              createApp("fake-app", &v1alpha1.SyncPolicy{Automated: &v1alpha1.SyncPolicyAutomated{}}, history),
+		req:              createRequest("fake-app", `{"id":1}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, autoSyncEnabledError.Error())
+		},
+	}, {
+		name:             "no status",
+		app:              createApp("fake-app", nil, nil),
+		req:              createRequest("fake-app", `{"id":1}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, "application fake-app does not have the history 1")
+		},
+	}, {
+		name:             "history not found",
+		app:              createApp("fake-app", nil, history),
+		req:              createRequest("fake-app", `{"id":3}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verifyResponse: func(t *testing.T, response string) {
+			assert.Contains(t, response, "application fake-app does not have the history 3")
+		},
+	}, {
+		name:             "rollback to a history",
+		app:              createApp("fake-app", &v1alpha1.SyncPolicy{}, history),
+		req:              createRequest("fake-app", `{"id":1,"prune":true}`, true),
+		wantResponseCode: http.StatusOK,
+		verifyResponse: func(t *testing.T, response string) {
+			gotApp := &v1alpha1.Application{}
+			assert.NoError(t, json.Unmarshal([]byte(response), gotApp))
+			if assert.NotNil(t, gotApp.Spec.ArgoApp.Operation) {
+				gotOp := gotApp.Spec.ArgoApp.Operation
+				assert.Equal(t, "fake-user", gotOp.InitiatedBy.Username)
+				assert.Equal(t, "old-revision", gotOp.Sync.Revision)
+				assert.Equal(t, &history[0].Source, gotOp.Sync.Source)
+				assert.True(t, gotOp.Sync.Prune)
+				assert.False(t, gotOp.Sync.DryRun)
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.app).Build()
+			h := &handler{
+				Handler: &gitops.Handler{Client: fakeClient},
+			}
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.handleRollbackApplication(tt.req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code)
+			tt.verifyResponse(t, recorder.Body.String())
+		})
+	}
+}
+
+func Test_handler_diffApplication(t *testing.T) {
+	createApp := func(name string, status *v1alpha1.ArgoApplicationStatus) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fake-namespace"},
+			Spec: v1alpha1.ApplicationSpec{
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Spec: v1alpha1.ArgoApplicationSpec{
+						Destination: v1alpha1.ApplicationDestination{Server: "https://kubernetes.default.svc"},
+					},
+				},
+			},
+			Status: v1alpha1.ApplicationStatus{ArgoAppStatus: status},
+		}
+	}
+	resourceStatus := &v1alpha1.ArgoApplicationStatus{
+		Sync: v1alpha1.SyncStatus{Status: v1alpha1.SyncStatusCodeOutOfSync, Revision: "fake-revision"},
+		Resources: []v1alpha1.ResourceStatus{
+			{Version: "v1", Kind: "Service", Namespace: "app", Name: "synced", Status: v1alpha1.SyncStatusCodeSynced},
+			{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "app", Name: "changed", Status: v1alpha1.SyncStatusCodeOutOfSync,
+				Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy}},
+			{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "missing", Status: v1alpha1.SyncStatusCodeOutOfSync,
+				Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusMissing}},
+			{Version: "v1", Kind: "Secret", Namespace: "app", Name: "extra", Status: v1alpha1.SyncStatusCodeOutOfSync, RequiresPruning: true},
+			{Version: "v1", Kind: "Pod", Namespace: "app", Name: "hook", Hook: true},
+		},
+	}
+	dryRunStatus := resourceStatus.DeepCopy()
+	dryRunStatus.OperationState = &v1alpha1.OperationState{
+		Operation: v1alpha1.Operation{Sync: &v1alpha1.SyncOperation{DryRun: true}},
+		Phase:     v1alpha1.OperationSucceeded,
+		SyncResult: &v1alpha1.SyncOperationResult{
+			Revision: "fake-revision",
+			Resources: []*v1alpha1.ResourceResult{
+				{Version: "v1", Kind: "Service", Namespace: "app", Name: "synced", Message: "service/synced unchanged (dry run)"},
+				{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "app", Name: "changed",
+					Message: "deployment.apps/changed configured (dry run)"},
+				{Version: "v1", Kind: "ConfigMap", Namespace: "app", Name: "missing", Message: "configmap/missing created (dry run)"},
+				{Version: "v1", Kind: "Secret", Namespace: "app", Name: "extra", Message: "ignored (requires pruning)"},
+				{Version: "v1", Kind: "Pod", Namespace: "app", Name: "hook", HookType: "PreSync"},
+				{Version: "v1", Kind: "Pod", Namespace: "app", Name: "unknown", Message: "fake"},
+			},
+		},
+	}
+	staleRevisionStatus := dryRunStatus.DeepCopy()
+	staleRevisionStatus.OperationState.SyncResult.Revision = "stale-revision"
+	staleSourceStatus := dryRunStatus.DeepCopy()
+	staleSourceStatus.OperationState.SyncResult.Source.TargetRevision = "stale-branch"
+	runningStatus := dryRunStatus.DeepCopy()
+	runningStatus.OperationState.Phase = v1alpha1.OperationRunning
+	outOfSyncActions := map[string]gitops.DiffAction{
+		"Service/synced":     gitops.DiffActionNone,
+		"Deployment/changed": gitops.DiffActionUpdate,
+		"ConfigMap/missing":  gitops.DiffActionCreate,
+		"Secret/extra":       gitops.DiffActionDelete,
+	}
+	syncedStatus := resourceStatus.DeepCopy()
+	syncedStatus.OperationState = &v1alpha1.OperationState{Operation: v1alpha1.Operation{Sync: &v1alpha1.SyncOperation{}}}
+
+	getActions := func(diff *gitops.ApplicationDiff) map[string]gitops.DiffAction {
+		actions := map[string]gitops.DiffAction{}
+		for _, resource := range diff.Resources {
+			assert.Equal(t, "https://kubernetes.default.svc", resource.Destination)
+			actions[resource.Kind+"/"+resource.Name] = resource.Action
+		}
+		return actions
+	}
+
+	tests := []struct {
+		name             string
+		app              *v1alpha1.Application
+		wantResponseCode int
+		wantRevision     string
+		wantActions      map[string]gitops.DiffAction
+	}{{
+		name:             "not an Argo CD application",
+		app:              &v1alpha1.Application{ObjectMeta: metav1.ObjectMeta{Name: "fake-app", Namespace: "fake-namespace"}},
+		wantResponseCode: http.StatusBadRequest,
+	}, {
+		name:             "no status",
+		app:              createApp("fake-app", nil),
+		wantResponseCode: http.StatusOK,
+		wantActions:      map[string]gitops.DiffAction{},
+	}, {
+		name:             "the changes of the dry-run sync",
+		app:              createApp("fake-app", dryRunStatus),
+		wantResponseCode: http.StatusOK,
+		wantRevision:     "fake-revision",
+		wantActions: map[string]gitops.DiffAction{
+			"Service/synced":     gitops.DiffActionNone,
+			"Deployment/changed": gitops.DiffActionUpdate,
+			"ConfigMap/missing":  gitops.DiffActionCreate,
+			"Secret/extra":       gitops.DiffActionDelete,
+			"Pod/unknown":        gitops.DiffActionUnknown,
+		},
+	}, {
+		name:             "the out-of-sync resources without a dry-run sync",
+		app:              createApp("fake-app", syncedStatus),
+		wantResponseCode: http.StatusOK,
+		wantRevision:     "fake-revision",
+		wantActions:      outOfSyncActions,
+	}, {
+		name:             "the dry-run sync of a stale revision",
+		app:              createApp("fake-app", staleRevisionStatus),
+		wantResponseCode: http.StatusOK,
+		wantRevision:     "fake-revision",
+		wantActions:      outOfSyncActions,
+	}, {
+		name:             "the dry-run sync of another target revision",
+		app:              createApp("fake-app", staleSourceStatus),
+		wantResponseCode: http.StatusOK,
+		wantRevision:     "fake-revision",
+		wantActions:      outOfSyncActions,
+	}, {
+		name:             "the dry-run sync is running",
+		app:              createApp("fake-app", runningStatus),
+		wantResponseCode: http.StatusOK,
+		wantRevision:     "fake-revision",
+		wantActions:      outOfSyncActions,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.app.DeepCopy()).Build()
+			h := handler{Handler: &gitops.Handler{Client: fakeClient}}
+
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/applications/fake-app/diff", nil))
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterApplication.Data().Name] = "fake-app"
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.applicationDiff(req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code, recorder.Body.String())
+			if recorder.Code != http.StatusOK {
+				return
+			}
+
+			diff := &gitops.ApplicationDiff{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), diff))
+			assert.Equal(t, tt.wantRevision, diff.Revision)
+			assert.Equal(t, tt.wantActions, getActions(diff))
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 )
 
 var (
@@ -64,6 +65,14 @@ type ApplicationSyncRequest struct {
 	SyncOptions   *v1alpha1.SyncOptions            `json:"syncOptions,omitempty"`
 }
 
+// ApplicationRollbackRequest is a request to sync an application to a previous revision of its history.
+type ApplicationRollbackRequest struct {
+	// ID is the ID of the history which the application rolls back to
+	ID     int64 `json:"id"`
+	DryRun bool  `json:"dryRun"`
+	Prune  bool  `json:"prune"`
+}
+
 // RegisterRoutes is for registering Argo CD Application routes into WebService.
 func RegisterRoutes(service *restful.WebService, options *common.Options, argoOption *config.ArgoCDOption) {
 	handler := newHandler(options, argoOption)
@@ -113,6 +122,25 @@ func RegisterRoutes(service *restful.WebService, options *common.Options, argoOp
 		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
 		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
 
+	service.Route(service.POST("/namespaces/{namespace}/applications/{application}/rollback").
+		To(handler.handleRollbackApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Reads(ApplicationRollbackRequest{}).
+		Doc("Rollback a particular application to a previous revision of its history").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/diff").
+		To(handler.applicationDiff).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Preview the changes of a particular application, please sync it with dryRun to get the latest changes. "+
+			"The dry-run sync of a stale source or revision is ignored. Only the actions are returned, "+
+			"the changed fields are not supported yet, see docs/gitops-diff.md").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, gitops.ApplicationDiff{}))
+
 	service.Route(service.DELETE("/namespaces/{namespace}/applications/{application}").
 		To(handler.DelApplication).
 		Param(common.NamespacePathParameter).
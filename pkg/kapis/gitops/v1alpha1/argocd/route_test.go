@@ -191,6 +191,23 @@ func TestArgoAPIs(t *testing.T) {
 		verify: func(t *testing.T, body []byte) {
 			assert.JSONEq(t, `{"total": 1, "healthStatus": { "Healthy": 1 }, "syncStatus": { "Synced": 1 }}`, string(body))
 		},
+	}, {
+		name: "get applications summary with the typed status",
+		request: request{
+			method: http.MethodGet,
+			uri:    "/namespaces/ns/application-summary",
+		},
+		k8sclient: fake.NewClientBuilder().WithScheme(schema).WithObjects(app.DeepCopy(), &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "typed"},
+			Status: v1alpha1.ApplicationStatus{ArgoAppStatus: &v1alpha1.ArgoApplicationStatus{
+				Health: v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusDegraded},
+				Sync:   v1alpha1.SyncStatus{Status: v1alpha1.SyncStatusCodeSynced},
+			}},
+		}).Build(),
+		responseCode: http.StatusOK,
+		verify: func(t *testing.T, body []byte) {
+			assert.JSONEq(t, `{"total": 2, "healthStatus": { "Healthy": 1, "Degraded": 1 }, "syncStatus": { "Synced": 2 }}`, string(body))
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
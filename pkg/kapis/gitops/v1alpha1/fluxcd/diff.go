@@ -0,0 +1,382 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	serverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories;buckets;ocirepositories,verbs=get
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// the preview of HelmReleases is not implemented, rendering the charts needs the helm library
+var helmReleasePreviewError = restful.NewError(http.StatusNotImplemented,
+	"the preview of a HelmRelease application is not supported")
+
+// variableNamePattern is the valid name of a variable of the post build, the same as FluxCD
+var variableNamePattern = regexp.MustCompile(`^[_a-zA-Z][_a-zA-Z0-9]*$`)
+
+func (h *handler) applicationDiff(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+	diff, err := h.diffApplication(req.Request.Context(), namespace, name, currentUser)
+	common.Response(req, res, diff, err)
+}
+
+// diffApplication renders the Kustomizations of an application from the artifact of its source,
+// then compares the manifests with the live objects in the destination clusters
+func (h *handler) diffApplication(ctx context.Context, namespace, name string, currentUser user.Info) (
+	diff *gitops.ApplicationDiff, err error) {
+	app := &v1alpha1.Application{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return
+	}
+	fluxApp := app.Spec.FluxApp
+	if fluxApp == nil || fluxApp.Spec.Config == nil {
+		return nil, fluxAppNotConfiguredError
+	}
+	if fluxApp.Spec.Config.HelmRelease != nil {
+		return nil, helmReleasePreviewError
+	}
+	if len(fluxApp.Spec.Config.Kustomization) == 0 {
+		return nil, fluxAppNotConfiguredError
+	}
+
+	var source *unstructured.Unstructured
+	if source, err = h.getSource(ctx, app); err != nil {
+		return
+	}
+	url, _, _ := unstructured.NestedString(source.Object, "status", "artifact", "url")
+	if url == "" {
+		return nil, restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("the artifact of the source %s/%s is not ready", source.GetNamespace(), source.GetName()))
+	}
+	var files map[string][]byte
+	if files, err = fetchArtifact(ctx, url); err != nil {
+		return
+	}
+
+	diff = &gitops.ApplicationDiff{Resources: []gitops.ResourceDiff{}}
+	diff.Revision, _, _ = unstructured.NestedString(source.Object, "status", "artifact", "revision")
+	differ := &kustomizationDiffer{handler: h, app: app, currentUser: currentUser, files: files}
+	for _, kustomization := range fluxApp.Spec.Config.Kustomization {
+		var resources []gitops.ResourceDiff
+		if resources, err = differ.diff(ctx, kustomization); err != nil {
+			return nil, err
+		}
+		diff.Resources = append(diff.Resources, resources...)
+	}
+	return
+}
+
+// kustomizationDiffer compares the manifests of the Kustomizations of an application with the live objects.
+// Only the objects in the destination namespaces of the application are read, and the values of the live objects
+// are returned only if the current user is allowed to read them.
+type kustomizationDiffer struct {
+	*handler
+	app         *v1alpha1.Application
+	currentUser user.Info
+	files       map[string][]byte
+	// readable caches the access reviews of the current user, the key is the cluster, resource and namespace
+	readable map[string]bool
+}
+
+func (d *kustomizationDiffer) diff(ctx context.Context, kustomization *v1alpha1.KustomizationSpec) (
+	resources []gitops.ResourceDiff, err error) {
+	app, destination := d.app, kustomization.Destination
+	clusterClient, clusterName := d.Client, "in-cluster"
+	if destination.KubeConfig != nil {
+		clusterName = destination.KubeConfig.SecretRef.Name
+		if clusterClient, err = d.getClusterClient(ctx, app.Namespace, destination.KubeConfig.SecretRef.Name,
+			destination.KubeConfig.SecretRef.Key); err != nil {
+			return
+		}
+	}
+
+	renderer := &manifestRenderer{files: d.files, isNamespaced: clusterClient.IsObjectNamespaced}
+	var objs []*unstructured.Unstructured
+	if objs, err = renderer.render(kustomization.Path); errors.Is(err, errPreviewNotSupported) {
+		return nil, restful.NewError(http.StatusNotImplemented, err.Error())
+	} else if err != nil {
+		return nil, restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	if objs, err = applyPatches(objs, kustomization.Patches); err != nil {
+		return nil, restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	if destination.TargetNamespace != "" {
+		renderer.setNamespace(objs, destination.TargetNamespace, true)
+	} else {
+		renderer.setNamespace(objs, v1.NamespaceDefault, false)
+	}
+	for _, obj := range objs {
+		setImages(obj.Object, kustomization.Images)
+	}
+	if kustomization.PostBuild != nil {
+		var vars map[string]string
+		if vars, err = d.getVariables(ctx, kustomization.PostBuild); err != nil {
+			return
+		}
+		if objs, err = substituteVariables(objs, vars); err != nil {
+			return nil, restful.NewError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	namespaces := d.getDestinationNamespaces(clusterName)
+	targets := map[string]bool{}
+	for _, obj := range objs {
+		var resource gitops.ResourceDiff
+		if obj.GetNamespace() != "" && !namespaces[obj.GetNamespace()] {
+			resource = gitops.DiffResource(obj, nil)
+			resource.Action = gitops.DiffActionUnknown
+			resource.Message = fmt.Sprintf("the namespace %s is not a destination of the application", obj.GetNamespace())
+		} else if live, liveErr := gitops.GetLiveObject(ctx, clusterClient, obj); liveErr != nil {
+			resource = gitops.DiffResource(obj, nil)
+			resource.Action, resource.Message = gitops.DiffActionUnknown, liveErr.Error()
+		} else if resource = gitops.DiffResource(obj, live); !d.canRead(ctx, clusterClient, clusterName, obj) {
+			resource.HideLiveValues()
+		}
+		resource.Destination = clusterName
+		resources = append(resources, resource)
+		targets[inventoryID(resource.Namespace, resource.Name, resource.Group, resource.Kind)] = true
+	}
+
+	// the objects which were applied but are not in the manifests anymore are deleted when the prune is enabled
+	if status := app.Status.FluxApp.KustomizationStatus[getKustomizationName(kustomization)]; kustomization.Prune &&
+		status != nil && status.Inventory != nil {
+		for _, entry := range status.Inventory.Entries {
+			parts := strings.Split(entry.ID, "_")
+			if len(parts) != 4 || targets[entry.ID] {
+				continue
+			}
+			resources = append(resources, gitops.ResourceDiff{
+				Group:       parts[2],
+				Version:     entry.Version,
+				Kind:        parts[3],
+				Namespace:   parts[0],
+				Name:        parts[1],
+				Destination: clusterName,
+				Action:      gitops.DiffActionDelete,
+			})
+		}
+	}
+	return
+}
+
+// getVariables returns the variables of the post build, the inline ones override the ones of the ConfigMaps and Secrets.
+// The ConfigMaps and Secrets are in the namespace of the application, they are read only if the current user is
+// allowed to, otherwise their values might be leaked by the preview.
+func (d *kustomizationDiffer) getVariables(ctx context.Context, postBuild *kusv1.PostBuild) (
+	vars map[string]string, err error) {
+	vars = map[string]string{}
+	for _, ref := range postBuild.SubstituteFrom {
+		var obj client.Object
+		switch ref.Kind {
+		case "ConfigMap":
+			obj = &v1.ConfigMap{}
+		case "Secret":
+			obj = &v1.Secret{}
+		default:
+			return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("unsupported kind %s of the substitution", ref.Kind))
+		}
+
+		review := &unstructured.Unstructured{}
+		review.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind(ref.Kind))
+		review.SetNamespace(d.app.Namespace)
+		if !d.canRead(ctx, d.Client, "in-cluster", review) {
+			return nil, restful.NewError(http.StatusForbidden, fmt.Sprintf("the current user is not allowed to read "+
+				"the %s %s of the substitution", ref.Kind, ref.Name))
+		}
+		if err = d.Get(ctx, types.NamespacedName{Namespace: d.app.Namespace, Name: ref.Name}, obj); apierrors.IsNotFound(err) {
+			if ref.Optional {
+				err = nil
+				continue
+			}
+			return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("the %s %s of the substitution is not found",
+				ref.Kind, ref.Name))
+		} else if err != nil {
+			return
+		}
+
+		switch item := obj.(type) {
+		case *v1.ConfigMap:
+			for key, value := range item.Data {
+				vars[key] = value
+			}
+		case *v1.Secret:
+			for key, value := range item.Data {
+				vars[key] = string(value)
+			}
+		}
+	}
+	for key, value := range postBuild.Substitute {
+		vars[key] = value
+	}
+
+	for key := range vars {
+		if !variableNamePattern.MatchString(key) {
+			return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("the variable name %s of the substitution is "+
+				"invalid, it must match %s", key, variableNamePattern.String()))
+		}
+	}
+	return
+}
+
+// getDestinationNamespaces returns the target namespaces of the Kustomizations in the cluster
+func (d *kustomizationDiffer) getDestinationNamespaces(clusterName string) map[string]bool {
+	namespaces := map[string]bool{}
+	for _, kustomization := range d.app.Spec.FluxApp.Spec.Config.Kustomization {
+		if kustomization == nil || kustomization.Destination.TargetNamespace == "" {
+			continue
+		}
+		if destination := kustomization.Destination; (destination.KubeConfig == nil && clusterName == "in-cluster") ||
+			(destination.KubeConfig != nil && destination.KubeConfig.SecretRef.Name == clusterName) {
+			namespaces[destination.TargetNamespace] = true
+		}
+	}
+	return namespaces
+}
+
+// canRead reviews whether the current user is allowed to get the kind of the object in its namespace of the cluster
+func (d *kustomizationDiffer) canRead(ctx context.Context, clusterClient client.Client, clusterName string,
+	obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	mapping, err := clusterClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false
+	}
+	key := strings.Join([]string{clusterName, mapping.Resource.Group, mapping.Resource.Resource, obj.GetNamespace()}, "/")
+	if readable, ok := d.readable[key]; ok {
+		return readable
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: obj.GetNamespace(),
+				Verb:      "get",
+				Group:     mapping.Resource.Group,
+				Version:   mapping.Resource.Version,
+				Resource:  mapping.Resource.Resource,
+			},
+			User:   d.currentUser.GetName(),
+			Groups: d.currentUser.GetGroups(),
+			UID:    d.currentUser.GetUID(),
+			Extra:  map[string]authorizationv1.ExtraValue{},
+		},
+	}
+	for name, values := range d.currentUser.GetExtra() {
+		review.Spec.Extra[name] = values
+	}
+	if err = clusterClient.Create(ctx, review); err != nil {
+		klog.V(4).Infof("failed to review the access of user %s, error: %v", d.currentUser.GetName(), err)
+	}
+	if d.readable == nil {
+		d.readable = map[string]bool{}
+	}
+	d.readable[key] = err == nil && review.Status.Allowed
+	return d.readable[key]
+}
+
+// getSource returns the FluxCD source which the application refers to, it must be in the namespace of the application
+func (h *handler) getSource(ctx context.Context, app *v1alpha1.Application) (source *unstructured.Unstructured, err error) {
+	sourceRef := app.Spec.FluxApp.Spec.Source
+	if sourceRef == nil {
+		return nil, restful.NewError(http.StatusBadRequest, "the source of the application is not configured")
+	}
+	gv := schema.GroupVersion{Group: "source.toolkit.fluxcd.io", Version: "v1beta2"}
+	if sourceRef.SourceRef.APIVersion != "" {
+		if gv, err = schema.ParseGroupVersion(sourceRef.SourceRef.APIVersion); err != nil {
+			return
+		}
+	}
+	if err = checkSourceNamespace(app); err != nil {
+		return
+	}
+
+	source = &unstructured.Unstructured{}
+	source.SetGroupVersionKind(gv.WithKind(sourceRef.SourceRef.Kind))
+	err = h.Get(ctx, types.NamespacedName{Namespace: app.Namespace, Name: sourceRef.SourceRef.Name}, source)
+	return
+}
+
+// getClusterClient creates a client of the cluster from the kubeconfig secret, the default key is the same as FluxCD
+func (h *handler) getClusterClient(ctx context.Context, namespace, secretName, key string) (clusterClient client.Client, err error) {
+	secret := &v1.Secret{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: secretName}, secret); err != nil {
+		return
+	}
+	var kubeconfig []byte
+	if key != "" {
+		kubeconfig = secret.Data[key]
+	} else if kubeconfig = secret.Data["value"]; len(kubeconfig) == 0 {
+		kubeconfig = secret.Data["value.yaml"]
+	}
+	if len(kubeconfig) == 0 {
+		return nil, restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("no kubeconfig is found in the secret %s/%s", namespace, secretName))
+	}
+	return h.newClusterClient(kubeconfig)
+}
+
+func newClusterClient(kubeconfig []byte) (client.Client, error) {
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{})
+}
+
+// getKustomizationName returns the name of a Kustomization, the same as the FluxCD application controller
+func getKustomizationName(kustomization *v1alpha1.KustomizationSpec) string {
+	if kustomization.Destination.KubeConfig == nil {
+		return kustomization.Destination.TargetNamespace
+	}
+	return kustomization.Destination.KubeConfig.SecretRef.Name + "-" + kustomization.Destination.TargetNamespace
+}
+
+// inventoryID returns the ID of an object in the inventory of a Kustomization
+func inventoryID(namespace, name, group, kind string) string {
+	return strings.Join([]string{namespace, name, group, kind}, "_")
+}
@@ -0,0 +1,367 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_handler_applicationDiff(t *testing.T) {
+	artifact := newArtifact(t, map[string]string{
+		"deploy/app.yaml":    deploymentManifest,
+		"deploy/config.yaml": multiManifests,
+		"patched/app.yaml":   deploymentManifest,
+		"patched/config.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  env: ${env}
+  region: ${region:=us}
+  script: echo $${HOME}`,
+		"generated/kustomization.yaml": `configMapGenerator:
+- name: fake
+  literals:
+  - key=value`,
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(artifact)
+	}))
+	defer server.Close()
+
+	gitRepo := &unstructured.Unstructured{}
+	gitRepo.SetGroupVersionKind(schema.GroupVersionKind{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Kind: "GitRepository"})
+	gitRepo.SetNamespace("fake-namespace")
+	gitRepo.SetName("fluxcd-repo")
+	_ = unstructured.SetNestedField(gitRepo.Object, server.URL+"/artifact.tar.gz", "status", "artifact", "url")
+	_ = unstructured.SetNestedField(gitRepo.Object, "main/fake-commit", "status", "artifact", "revision")
+	notReadyRepo := gitRepo.DeepCopy()
+	notReadyRepo.SetName("not-ready")
+	unstructured.RemoveNestedField(notReadyRepo.Object, "status")
+
+	newKusApp := func(name, repo string, kustomization *v1alpha1.KustomizationSpec) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fake-namespace"},
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.FluxCD,
+				FluxApp: &v1alpha1.FluxApplication{
+					Spec: v1alpha1.FluxApplicationSpec{
+						Source: &v1alpha1.FluxApplicationSource{
+							SourceRef: helmv2.CrossNamespaceObjectReference{Kind: "GitRepository", Name: repo},
+						},
+						Config: &v1alpha1.FluxApplicationConfig{
+							Kustomization: []*v1alpha1.KustomizationSpec{kustomization},
+						},
+					},
+				},
+			},
+		}
+	}
+	kusApp := newKusApp("kus-app", "fluxcd-repo", &v1alpha1.KustomizationSpec{
+		Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "app"},
+		Path:        "./deploy",
+		Prune:       true,
+		Images:      []kusv1.Image{{Name: "nginx", NewTag: "1.25"}},
+	})
+	kusApp.Status.FluxApp.KustomizationStatus = map[string]*kusv1.KustomizationStatus{
+		"app": {Inventory: &kusv1.ResourceInventory{Entries: []kusv1.ResourceRef{
+			{ID: "app_app_apps_Deployment", Version: "v1"},
+			{ID: "app_removed__Secret", Version: "v1"},
+		}}},
+	}
+	remoteApp := newKusApp("remote-app", "fluxcd-repo", &v1alpha1.KustomizationSpec{
+		Destination: v1alpha1.FluxApplicationDestination{
+			KubeConfig:      &helmv2.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "remote"}},
+			TargetNamespace: "app",
+		},
+		Path: "./deploy",
+	})
+	patchedApp := newKusApp("patched-app", "fluxcd-repo", &v1alpha1.KustomizationSpec{
+		Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "app"},
+		Path:        "./patched",
+		Patches: []kusv1.Patch{{
+			Patch: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 3\n",
+		}, {
+			Patch:  `[{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": "nginx:1.26"}]`,
+			Target: kusv1.Selector{Kind: "Deployment"},
+		}},
+		PostBuild: &kusv1.PostBuild{
+			Substitute:     map[string]string{"env": "prod"},
+			SubstituteFrom: []kusv1.SubstituteReference{{Kind: "Secret", Name: "vars"}, {Kind: "ConfigMap", Name: "optional", Optional: true}},
+		},
+	})
+	liveConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "app"},
+		Data:       map[string]string{"env": "dev", "script": "echo ${HOME}"},
+	}
+	varsSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vars", Namespace: "fake-namespace"},
+		Data:       map[string][]byte{"env": []byte("dev"), "region": []byte("eu")},
+	}
+	generatedApp := newKusApp("generated-app", "fluxcd-repo", &v1alpha1.KustomizationSpec{
+		Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "app"},
+		Path:        "./generated",
+	})
+	outsideApp := newKusApp("outside-app", "fluxcd-repo", &v1alpha1.KustomizationSpec{Path: "./deploy"})
+	notReadyApp := newKusApp("not-ready-app", "not-ready", &v1alpha1.KustomizationSpec{Path: "./deploy"})
+	helmApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-app", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Config: &v1alpha1.FluxApplicationConfig{HelmRelease: &v1alpha1.HelmReleaseSpec{Template: "nginx"}},
+				},
+			},
+		},
+	}
+	remoteSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: "fake-namespace"},
+		Data:       map[string][]byte{"value": []byte("fake-kubeconfig")},
+	}
+	liveDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "app"},
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "nginx:1.24"}}},
+			},
+		},
+	}
+
+	restMapper := apimeta.NewDefaultRESTMapper(nil)
+	restMapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), apimeta.RESTScopeNamespace)
+	restMapper.Add(v1.SchemeGroupVersion.WithKind("ConfigMap"), apimeta.RESTScopeNamespace)
+	restMapper.Add(v1.SchemeGroupVersion.WithKind("Secret"), apimeta.RESTScopeNamespace)
+	restMapper.Add(v1.SchemeGroupVersion.WithKind("Namespace"), apimeta.RESTScopeRoot)
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+
+	// the admin can read all the objects, the others can read the objects in the app namespace except Deployments
+	reviewAccess := interceptor.Funcs{Create: func(ctx context.Context, c client.WithWatch, obj client.Object,
+		opts ...client.CreateOption) error {
+		if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+			attributes := review.Spec.ResourceAttributes
+			review.Status.Allowed = review.Spec.User == "admin" ||
+				(attributes.Namespace == "app" && attributes.Resource != "deployments")
+			return nil
+		}
+		return c.Create(ctx, obj, opts...)
+	}}
+
+	tests := []struct {
+		name             string
+		app              string
+		user             user.Info
+		unauthenticated  bool
+		wantResponseCode int
+		verify           func(t *testing.T, diff *gitops.ApplicationDiff, response string)
+	}{{
+		name:             "compare with the live objects",
+		app:              "kus-app",
+		user:             &user.DefaultInfo{Name: "admin"},
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			assert.Equal(t, "main/fake-commit", diff.Revision)
+			if assert.Len(t, diff.Resources, 4) {
+				assert.Equal(t, "Deployment", diff.Resources[0].Kind)
+				assert.Equal(t, "app", diff.Resources[0].Namespace)
+				assert.Equal(t, gitops.DiffActionUpdate, diff.Resources[0].Action)
+				assert.Equal(t, "in-cluster", diff.Resources[0].Destination)
+				assert.Equal(t, []gitops.FieldDiff{{
+					Path: "spec.template.spec.containers[0].image", Live: "nginx:1.24", Target: "nginx:1.25",
+				}}, diff.Resources[0].Fields)
+
+				assert.Equal(t, "ConfigMap", diff.Resources[1].Kind)
+				assert.Equal(t, "app", diff.Resources[1].Namespace)
+				assert.Equal(t, gitops.DiffActionCreate, diff.Resources[1].Action)
+
+				assert.Equal(t, "Namespace", diff.Resources[2].Kind)
+				assert.Empty(t, diff.Resources[2].Namespace)
+				assert.Equal(t, gitops.DiffActionCreate, diff.Resources[2].Action)
+
+				assert.Equal(t, gitops.ResourceDiff{
+					Version: "v1", Kind: "Secret", Namespace: "app", Name: "removed",
+					Destination: "in-cluster", Action: gitops.DiffActionDelete,
+				}, diff.Resources[3])
+			}
+		},
+	}, {
+		name:             "hide the live values which the user cannot read",
+		app:              "kus-app",
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			if assert.Len(t, diff.Resources, 4) {
+				assert.Equal(t, gitops.DiffActionUpdate, diff.Resources[0].Action)
+				assert.Equal(t, []gitops.FieldDiff{{
+					Path: "spec.template.spec.containers[0].image", Target: "nginx:1.25",
+				}}, diff.Resources[0].Fields)
+			}
+		},
+	}, {
+		name:             "do not read the objects out of the destination namespaces",
+		app:              "outside-app",
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			if assert.Len(t, diff.Resources, 3) {
+				assert.Equal(t, "Deployment", diff.Resources[0].Kind)
+				assert.Equal(t, "default", diff.Resources[0].Namespace)
+				assert.Equal(t, gitops.DiffActionUnknown, diff.Resources[0].Action)
+				assert.Equal(t, "the namespace default is not a destination of the application", diff.Resources[0].Message)
+				assert.Empty(t, diff.Resources[0].Fields)
+
+				// the cluster-scoped objects are read
+				assert.Equal(t, "Namespace", diff.Resources[2].Kind)
+				assert.Equal(t, gitops.DiffActionCreate, diff.Resources[2].Action)
+			}
+		},
+	}, {
+		name:             "compare with the live objects in another cluster",
+		app:              "remote-app",
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			if assert.Len(t, diff.Resources, 3) {
+				// the Deployment does not exist in the remote cluster
+				assert.Equal(t, gitops.DiffActionCreate, diff.Resources[0].Action)
+				assert.Equal(t, "remote", diff.Resources[0].Destination)
+			}
+		},
+	}, {
+		name:             "patch and substitute the manifests",
+		app:              "patched-app",
+		user:             &user.DefaultInfo{Name: "admin"},
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			if assert.Len(t, diff.Resources, 2) {
+				assert.Equal(t, gitops.DiffActionUpdate, diff.Resources[0].Action)
+				assert.ElementsMatch(t, []gitops.FieldDiff{
+					{Path: "spec.replicas", Target: float64(3)},
+					{Path: "spec.template.spec.containers[0].image", Live: "nginx:1.24", Target: "nginx:1.26"},
+				}, diff.Resources[0].Fields)
+
+				assert.Equal(t, "ConfigMap", diff.Resources[1].Kind)
+				assert.ElementsMatch(t, []gitops.FieldDiff{
+					{Path: "data.env", Live: "dev", Target: "prod"},
+					{Path: "data.region", Target: "eu"},
+				}, diff.Resources[1].Fields)
+			}
+		},
+	}, {
+		name:             "the variables which the user cannot read",
+		app:              "patched-app",
+		wantResponseCode: http.StatusForbidden,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			assert.Contains(t, response, "the current user is not allowed to read the Secret vars of the substitution")
+		},
+	}, {
+		name:             "the generators are not supported",
+		app:              "generated-app",
+		wantResponseCode: http.StatusNotImplemented,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			assert.Contains(t, response, "the kustomization of generated has the fields which are not supported by the preview: configMapGenerator")
+		},
+	}, {
+		name:             "the artifact is not ready",
+		app:              "not-ready-app",
+		wantResponseCode: http.StatusBadRequest,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			assert.Contains(t, response, "the artifact of the source fake-namespace/not-ready is not ready")
+		},
+	}, {
+		name:             "HelmRelease is not supported",
+		app:              "helm-app",
+		wantResponseCode: http.StatusNotImplemented,
+		verify: func(t *testing.T, diff *gitops.ApplicationDiff, response string) {
+			assert.Contains(t, response, helmReleasePreviewError.Error())
+		},
+	}, {
+		name:             "application not found",
+		app:              "fake",
+		wantResponseCode: http.StatusNotFound,
+		verify:           func(t *testing.T, diff *gitops.ApplicationDiff, response string) {},
+	}, {
+		name:             "unauthenticated request",
+		app:              "kus-app",
+		unauthenticated:  true,
+		wantResponseCode: http.StatusUnauthorized,
+		verify:           func(t *testing.T, diff *gitops.ApplicationDiff, response string) {},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRESTMapper(restMapper).
+				WithObjects(kusApp.DeepCopy(), remoteApp.DeepCopy(), patchedApp.DeepCopy(), generatedApp.DeepCopy(), outsideApp.DeepCopy(),
+					notReadyApp.DeepCopy(), helmApp.DeepCopy(), gitRepo.DeepCopy(), notReadyRepo.DeepCopy(),
+					remoteSecret.DeepCopy(), varsSecret.DeepCopy(), liveDeployment.DeepCopy(), liveConfigMap.DeepCopy()).WithInterceptorFuncs(reviewAccess).Build()
+			remoteClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRESTMapper(restMapper).
+				WithInterceptorFuncs(reviewAccess).Build()
+			h := handler{
+				Handler: &gitops.Handler{Client: fakeClient},
+				newClusterClient: func(kubeconfig []byte) (client.Client, error) {
+					assert.Equal(t, "fake-kubeconfig", string(kubeconfig))
+					return remoteClient, nil
+				},
+			}
+
+			testReq := httptest.NewRequest(http.MethodGet, "/applications/app/diff", nil)
+			if currentUser := tt.user; !tt.unauthenticated {
+				if currentUser == nil {
+					currentUser = &user.DefaultInfo{Name: "fake-user"}
+				}
+				testReq = testReq.WithContext(request.WithUser(testReq.Context(), currentUser))
+			}
+			req := restful.NewRequest(testReq)
+			req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+			req.PathParameters()[pathParameterApplication.Data().Name] = tt.app
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.applicationDiff(req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code, recorder.Body.String())
+
+			diff := &gitops.ApplicationDiff{}
+			if recorder.Code == http.StatusOK {
+				assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), diff))
+			}
+			tt.verify(t, diff, recorder.Body.String())
+		})
+	}
+}
@@ -17,15 +17,53 @@ package fluxcd
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	serverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
 	"github.com/kubesphere/ks-devops/pkg/config"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	utilretry "k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories,verbs=get;update
+//+kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=list
+//+kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=list
+
+var fluxAppNotConfiguredError = restful.NewError(http.StatusBadRequest,
+	"application is not initialized, please confirm you have already configured it")
+var invalidRollbackRequestError = restful.NewError(http.StatusBadRequest,
+	"invalid application rollback request, the revision is required")
+var invalidRollbackBodyError = restful.NewError(http.StatusBadRequest, "invalid application rollback request")
+var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
+	"unauthenticated request")
+
+// sourceConsumers are the FluxCD objects which refer to a source, and the paths of their source references
+var sourceConsumers = []struct {
+	gvk           schema.GroupVersionKind
+	sourceRefPath []string
+}{{
+	gvk:           schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "KustomizationList"},
+	sourceRefPath: []string{"spec", "sourceRef"},
+}, {
+	gvk:           schema.GroupVersionKind{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Kind: "HelmReleaseList"},
+	sourceRefPath: []string{"spec", "chart", "spec", "sourceRef"},
+}}
+
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
@@ -58,12 +96,214 @@ func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
 	common.Response(req, res, fluxClusters, err)
 }
 
+func (h *handler) handleRollbackApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	rollbackRequest := &ApplicationRollbackRequest{}
+	if err := req.ReadEntity(rollbackRequest); err != nil {
+		common.Response(req, res, nil, invalidRollbackBodyError)
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	app, err := h.rollbackApplication(namespace, name, rollbackRequest, currentUser)
+	common.Response(req, res, app, err)
+}
+
+// rollbackApplication pins the chart version of a HelmRelease application, or the source revision of
+// a Kustomization application. Only a source in the namespace of the application can be pinned. The source is shared
+// by the applications and FluxCD objects which refer to it, so it's pinned only if nothing else refers to it or
+// it's forced. An empty revision un-pins the source of a Kustomization application.
+func (h *handler) rollbackApplication(namespace, name string, rollbackRequest *ApplicationRollbackRequest,
+	currentUser user.Info) (app *v1alpha1.Application, err error) {
+	revision := rollbackRequest.Revision
+	ctx := context.Background()
+	err = utilretry.RetryOnConflict(utilretry.DefaultRetry, func() (err error) {
+		app = &v1alpha1.Application{}
+		if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+			return
+		}
+		fluxApp := app.Spec.FluxApp
+		if fluxApp == nil || fluxApp.Spec.Config == nil {
+			return fluxAppNotConfiguredError
+		}
+
+		switch {
+		case fluxApp.Spec.Config.HelmRelease != nil:
+			if fluxApp.Spec.Config.HelmRelease.Chart == nil {
+				return restful.NewError(http.StatusBadRequest, "the chart version of a HelmRelease template cannot be pinned")
+			}
+			if revision == "" {
+				return invalidRollbackRequestError
+			}
+			fluxApp.Spec.Config.HelmRelease.Chart.Version = revision
+		case len(fluxApp.Spec.Config.Kustomization) > 0:
+			// force only applies to the sharing, never to the namespace of the source
+			if err = checkSourceNamespace(app); err != nil {
+				return
+			}
+			if revision != "" && !rollbackRequest.Force {
+				if err = h.checkSharedSource(ctx, app); err != nil {
+					return
+				}
+			}
+			if err = h.pinSourceRevision(ctx, app, revision); err != nil {
+				return
+			}
+		default:
+			return fluxAppNotConfiguredError
+		}
+
+		if revision == "" {
+			fluxApp.Rollback = nil
+			return h.Update(ctx, app)
+		}
+		fluxApp.Rollback = &v1alpha1.FluxApplicationRollback{
+			Revision:    revision,
+			InitiatedBy: v1alpha1.OperationInitiator{Username: currentUser.GetName()},
+			StartedAt:   metav1.Now(),
+		}
+		return h.Update(ctx, app)
+	})
+	return
+}
+
+// checkSourceNamespace returns a forbidden error if the source of the application is out of its namespace.
+// The source is read and updated by the apiserver itself, so a source reference must not reach other namespaces.
+func checkSourceNamespace(app *v1alpha1.Application) error {
+	source := app.Spec.FluxApp.Spec.Source
+	if source != nil && source.SourceRef.Namespace != "" && source.SourceRef.Namespace != app.Namespace {
+		return restful.NewError(http.StatusForbidden, fmt.Sprintf("the source %s/%s is out of the namespace %s of the application",
+			source.SourceRef.Namespace, source.SourceRef.Name, app.Namespace))
+	}
+	return nil
+}
+
+// checkSharedSource returns a conflict error if the source of the application is referred by other applications,
+// or by the FluxCD Kustomizations and HelmReleases which are not created for the applications
+func (h *handler) checkSharedSource(ctx context.Context, app *v1alpha1.Application) (err error) {
+	source := app.Spec.FluxApp.Spec.Source
+	if source == nil {
+		return
+	}
+	appList := &v1alpha1.ApplicationList{}
+	if err = h.List(ctx, appList); err != nil {
+		return
+	}
+
+	var others []string
+	for i := range appList.Items {
+		other := &appList.Items[i]
+		if (other.Namespace == app.Namespace && other.Name == app.Name) || other.Spec.FluxApp == nil ||
+			other.Spec.FluxApp.Spec.Source == nil {
+			continue
+		}
+		if isSameSourceRef(source.SourceRef, app.Namespace, other.Spec.FluxApp.Spec.Source.SourceRef, other.Namespace) {
+			others = append(others, other.Namespace+"/"+other.Name)
+		}
+	}
+	for _, consumer := range sourceConsumers {
+		var items []string
+		if items, err = h.findSourceConsumers(ctx, consumer.gvk, consumer.sourceRefPath, source.SourceRef, app.Namespace); err != nil {
+			return
+		}
+		others = append(others, items...)
+	}
+	if len(others) > 0 {
+		err = restful.NewError(http.StatusConflict, fmt.Sprintf("the source %s is shared by the applications %s, "+
+			"please set force to pin it for all of them", source.SourceRef.Name, strings.Join(others, ", ")))
+	}
+	return
+}
+
+// findSourceConsumers returns the FluxCD objects which refer to the source, the ones created for the applications
+// are skipped because the applications are checked already
+func (h *handler) findSourceConsumers(ctx context.Context, gvk schema.GroupVersionKind, sourceRefPath []string,
+	sourceRef helmv2.CrossNamespaceObjectReference, namespace string) (consumers []string, err error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk)
+	if err = h.List(ctx, list); err != nil {
+		if meta.IsNoMatchError(err) || runtime.IsNotRegisteredError(err) {
+			// FluxCD is not installed
+			err = nil
+		}
+		return
+	}
+
+	kind := strings.TrimSuffix(gvk.Kind, "List")
+	for _, item := range list.Items {
+		if isOwnedByApplication(&item) {
+			continue
+		}
+		ref, _, _ := unstructured.NestedStringMap(item.Object, sourceRefPath...)
+		other := helmv2.CrossNamespaceObjectReference{Kind: ref["kind"], Name: ref["name"], Namespace: ref["namespace"]}
+		if isSameSourceRef(sourceRef, namespace, other, item.GetNamespace()) {
+			consumers = append(consumers, fmt.Sprintf("%s %s/%s", kind, item.GetNamespace(), item.GetName()))
+		}
+	}
+	return
+}
+
+func isOwnedByApplication(obj metav1.Object) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Kind == "Application" && strings.HasPrefix(owner.APIVersion, v1alpha1.GroupName+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSameSourceRef compares two source references, the namespace of a reference is the one of its application by default
+func isSameSourceRef(a helmv2.CrossNamespaceObjectReference, aNamespace string,
+	b helmv2.CrossNamespaceObjectReference, bNamespace string) bool {
+	if a.Namespace != "" {
+		aNamespace = a.Namespace
+	}
+	if b.Namespace != "" {
+		bNamespace = b.Namespace
+	}
+	return a.Kind == b.Kind && a.Name == b.Name && aNamespace == bNamespace
+}
+
+// pinSourceRevision pins the commit of the FluxCD GitRepository which the application refers to,
+// or un-pins it if the revision is empty, then the GitRepository follows its branch or tag again
+func (h *handler) pinSourceRevision(ctx context.Context, app *v1alpha1.Application, revision string) (err error) {
+	source := app.Spec.FluxApp.Spec.Source
+	if source == nil || source.SourceRef.Kind != "GitRepository" {
+		return restful.NewError(http.StatusBadRequest, "only the revision of a GitRepository source can be pinned")
+	}
+
+	return utilretry.RetryOnConflict(utilretry.DefaultRetry, func() (err error) {
+		var gitRepo *unstructured.Unstructured
+		if gitRepo, err = h.getSource(ctx, app); err != nil {
+			return
+		}
+		if commit, _, _ := unstructured.NestedString(gitRepo.Object, "spec", "ref", "commit"); commit == revision {
+			return
+		}
+		if revision == "" {
+			unstructured.RemoveNestedField(gitRepo.Object, "spec", "ref", "commit")
+		} else if err = unstructured.SetNestedField(gitRepo.Object, revision, "spec", "ref", "commit"); err != nil {
+			return fmt.Errorf("failed to pin the revision of %s/%s, error: %v", gitRepo.GetNamespace(), gitRepo.GetName(), err)
+		}
+		return h.Update(ctx, gitRepo)
+	})
+}
+
 type handler struct {
 	*gitops.Handler
+	newClusterClient func(kubeconfig []byte) (client.Client, error)
 }
 
 func newHandler(options *common.Options, fluxOption *config.FluxCDOption) *handler {
 	return &handler{
-		gitops.NewHandler(options),
+		Handler:          gitops.NewHandler(options),
+		newClusterClient: newClusterClient,
 	}
 }
@@ -16,6 +16,8 @@
 package fluxcd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -23,11 +25,18 @@ import (
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -115,3 +124,241 @@ func Test_handler_applicationGet(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_handleRollbackApplication(t *testing.T) {
+	helmApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "helm-app", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Config: &v1alpha1.FluxApplicationConfig{
+						HelmRelease: &v1alpha1.HelmReleaseSpec{
+							Chart: &v1alpha1.HelmChartTemplateSpec{Chart: "nginx", Version: "1.1.0"},
+						},
+					},
+				},
+			},
+		},
+	}
+	templateApp := helmApp.DeepCopy()
+	templateApp.Name = "template-app"
+	templateApp.Spec.FluxApp.Spec.Config.HelmRelease = &v1alpha1.HelmReleaseSpec{Template: "nginx"}
+	kusApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "kus-app", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{
+			Kind: v1alpha1.FluxCD,
+			FluxApp: &v1alpha1.FluxApplication{
+				Spec: v1alpha1.FluxApplicationSpec{
+					Source: &v1alpha1.FluxApplicationSource{
+						SourceRef: helmv2.CrossNamespaceObjectReference{
+							APIVersion: "source.toolkit.fluxcd.io/v1beta2",
+							Kind:       "GitRepository",
+							Name:       "fluxcd-repo",
+						},
+					},
+					Config: &v1alpha1.FluxApplicationConfig{
+						Kustomization: []*v1alpha1.KustomizationSpec{{Path: "./"}},
+					},
+				},
+			},
+		},
+	}
+	argoApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "argo-app", Namespace: "fake-namespace"},
+		Spec:       v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+	}
+	gitRepoGVK := schema.GroupVersionKind{Group: "source.toolkit.fluxcd.io", Version: "v1beta2", Kind: "GitRepository"}
+	fluxGitRepo := &unstructured.Unstructured{}
+	fluxGitRepo.SetGroupVersionKind(gitRepoGVK)
+	fluxGitRepo.SetNamespace("fake-namespace")
+	fluxGitRepo.SetName("fluxcd-repo")
+	_ = unstructured.SetNestedField(fluxGitRepo.Object, "https://fake.com/repo", "spec", "url")
+	pinnedFluxGitRepo := fluxGitRepo.DeepCopy()
+	_ = unstructured.SetNestedField(pinnedFluxGitRepo.Object, "main", "spec", "ref", "branch")
+	_ = unstructured.SetNestedField(pinnedFluxGitRepo.Object, "fake-commit", "spec", "ref", "commit")
+	pinnedKusApp := kusApp.DeepCopy()
+	pinnedKusApp.Name = "pinned-kus-app"
+	pinnedKusApp.Spec.FluxApp.Rollback = &v1alpha1.FluxApplicationRollback{Revision: "fake-commit"}
+	sharedKusApp := kusApp.DeepCopy()
+	sharedKusApp.Name = "shared-kus-app"
+	otherNamespaceKusApp := kusApp.DeepCopy()
+	otherNamespaceKusApp.Name = "other-namespace-kus-app"
+	otherNamespaceKusApp.Spec.FluxApp.Spec.Source.SourceRef.Namespace = "other-namespace"
+	otherGitRepo := fluxGitRepo.DeepCopy()
+	otherGitRepo.SetNamespace("other-namespace")
+	// the FluxCD objects which are not created for the applications
+	sharedKustomization := &unstructured.Unstructured{}
+	sharedKustomization.SetGroupVersionKind(schema.GroupVersionKind{Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "Kustomization"})
+	sharedKustomization.SetNamespace("fake-namespace")
+	sharedKustomization.SetName("shared-kustomization")
+	_ = unstructured.SetNestedStringMap(sharedKustomization.Object, map[string]string{"kind": "GitRepository", "name": "fluxcd-repo"},
+		"spec", "sourceRef")
+	ownedKustomization := sharedKustomization.DeepCopy()
+	ownedKustomization.SetName("owned-kustomization")
+	ownedKustomization.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "gitops.kubesphere.io/v1alpha1",
+		Kind: "Application", Name: "kus-app", UID: "fake"}})
+	sharedHelmRelease := &unstructured.Unstructured{}
+	sharedHelmRelease.SetGroupVersionKind(schema.GroupVersionKind{Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Kind: "HelmRelease"})
+	sharedHelmRelease.SetNamespace("another-namespace")
+	sharedHelmRelease.SetName("shared-helmrelease")
+	_ = unstructured.SetNestedStringMap(sharedHelmRelease.Object, map[string]string{"kind": "GitRepository", "name": "fluxcd-repo",
+		"namespace": "fake-namespace"}, "spec", "chart", "spec", "sourceRef")
+	getCommit := func(t *testing.T, c client.Client) string {
+		gitRepo := &unstructured.Unstructured{}
+		gitRepo.SetGroupVersionKind(gitRepoGVK)
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: "fluxcd-repo"}, gitRepo))
+		commit, _, _ := unstructured.NestedString(gitRepo.Object, "spec", "ref", "commit")
+		return commit
+	}
+
+	createRequest := func(name, body string, withUser bool) *restful.Request {
+		testReq := httptest.NewRequest(http.MethodPost, "/applications/app/rollback", bytes.NewBufferString(body))
+		testReq.Header.Set(restful.HEADER_ContentType, restful.MIME_JSON)
+		if withUser {
+			testReq = testReq.WithContext(request.WithUser(testReq.Context(), &user.DefaultInfo{Name: "fake-user"}))
+		}
+		req := restful.NewRequest(testReq)
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+		req.PathParameters()[pathParameterApplication.Data().Name] = name
+		return req
+	}
+	getRollback := func(t *testing.T, response string) *v1alpha1.FluxApplicationRollback {
+		gotApp := &v1alpha1.Application{}
+		assert.NoError(t, json.Unmarshal([]byte(response), gotApp))
+		return gotApp.Spec.FluxApp.Rollback
+	}
+
+	tests := []struct {
+		name             string
+		req              *restful.Request
+		gitRepo          *unstructured.Unstructured
+		objects          []client.Object
+		wantResponseCode int
+		verify           func(t *testing.T, c client.Client, response string)
+	}{{
+		name:             "the revision is required",
+		req:              createRequest("helm-app", `{}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, invalidRollbackRequestError.Error())
+		},
+	}, {
+		name:             "unauthenticated request",
+		req:              createRequest("helm-app", `{"revision":"1.0.0"}`, false),
+		wantResponseCode: http.StatusUnauthorized,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, unauthenticatedError.Error())
+		},
+	}, {
+		name:             "not a FluxCD application",
+		req:              createRequest("argo-app", `{"revision":"1.0.0"}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, fluxAppNotConfiguredError.Error())
+		},
+	}, {
+		name:             "pin the chart version of a HelmRelease",
+		req:              createRequest("helm-app", `{"revision":"1.0.0"}`, true),
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, response string) {
+			app := &v1alpha1.Application{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: "helm-app"}, app))
+			assert.Equal(t, "1.0.0", app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+			if rollback := getRollback(t, response); assert.NotNil(t, rollback) {
+				assert.Equal(t, "1.0.0", rollback.Revision)
+				assert.Equal(t, "fake-user", rollback.InitiatedBy.Username)
+			}
+		},
+	}, {
+		name:             "the chart of a HelmRelease template",
+		req:              createRequest("template-app", `{"revision":"1.0.0"}`, true),
+		wantResponseCode: http.StatusBadRequest,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, "the chart version of a HelmRelease template cannot be pinned")
+		},
+	}, {
+		name:             "pin the source revision of a Kustomization",
+		req:              createRequest("kus-app", `{"revision":"fake-commit"}`, true),
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, response string) {
+			gitRepo := &unstructured.Unstructured{}
+			gitRepo.SetGroupVersionKind(gitRepoGVK)
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: "fluxcd-repo"}, gitRepo))
+			commit, _, _ := unstructured.NestedString(gitRepo.Object, "spec", "ref", "commit")
+			assert.Equal(t, "fake-commit", commit)
+			if rollback := getRollback(t, response); assert.NotNil(t, rollback) {
+				assert.Equal(t, "fake-commit", rollback.Revision)
+			}
+		},
+	}, {
+		name:             "refuse pinning the source shared by other applications",
+		req:              createRequest("kus-app", `{"revision":"fake-commit"}`, true),
+		objects:          []client.Object{sharedKusApp.DeepCopy()},
+		wantResponseCode: http.StatusConflict,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, "fake-namespace/shared-kus-app")
+			assert.Empty(t, getCommit(t, c))
+		},
+	}, {
+		name:             "pin the shared source by force",
+		req:              createRequest("kus-app", `{"revision":"fake-commit","force":true}`, true),
+		objects:          []client.Object{sharedKusApp.DeepCopy()},
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Equal(t, "fake-commit", getCommit(t, c))
+		},
+	}, {
+		name:             "refuse pinning the source shared by FluxCD objects",
+		req:              createRequest("kus-app", `{"revision":"fake-commit"}`, true),
+		objects:          []client.Object{sharedKustomization.DeepCopy(), ownedKustomization.DeepCopy(), sharedHelmRelease.DeepCopy()},
+		wantResponseCode: http.StatusConflict,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, "Kustomization fake-namespace/shared-kustomization, HelmRelease another-namespace/shared-helmrelease")
+			assert.NotContains(t, response, "owned-kustomization")
+			assert.Empty(t, getCommit(t, c))
+		},
+	}, {
+		name:             "refuse pinning the source of another namespace even by force",
+		req:              createRequest("other-namespace-kus-app", `{"revision":"fake-commit","force":true}`, true),
+		objects:          []client.Object{otherNamespaceKusApp.DeepCopy(), otherGitRepo.DeepCopy()},
+		wantResponseCode: http.StatusForbidden,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Contains(t, response, "the source other-namespace/fluxcd-repo is out of the namespace fake-namespace")
+			gitRepo := otherGitRepo.DeepCopy()
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "other-namespace", Name: "fluxcd-repo"}, gitRepo))
+			commit, _, _ := unstructured.NestedString(gitRepo.Object, "spec", "ref", "commit")
+			assert.Empty(t, commit)
+		},
+	}, {
+		name:             "un-pin the source revision of a Kustomization",
+		req:              createRequest("pinned-kus-app", `{"revision":""}`, true),
+		gitRepo:          pinnedFluxGitRepo,
+		objects:          []client.Object{pinnedKusApp.DeepCopy()},
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, response string) {
+			assert.Empty(t, getCommit(t, c))
+			assert.Nil(t, getRollback(t, response))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+			gitRepo := fluxGitRepo
+			if tt.gitRepo != nil {
+				gitRepo = tt.gitRepo
+			}
+			objects := append([]client.Object{helmApp.DeepCopy(), templateApp.DeepCopy(), kusApp.DeepCopy(),
+				argoApp.DeepCopy(), gitRepo.DeepCopy()}, tt.objects...)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+			h := handler{Handler: &gitops.Handler{Client: fakeClient}}
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.handleRollbackApplication(tt.req, resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code, recorder.Body.String())
+			tt.verify(t, fakeClient, recorder.Body.String())
+		})
+	}
+}
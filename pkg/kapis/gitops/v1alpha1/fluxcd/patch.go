@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// applyPatches applies the patches to the objects like kustomize does. A patch is a JSON 6902 patch if it's a list of
+// operations, otherwise it's a strategic merge patch whose target is the object of the patch by default.
+// The objects which are deleted by the patches are removed from the result.
+func applyPatches(objs []*unstructured.Unstructured, patches []kusv1.Patch) ([]*unstructured.Unstructured, error) {
+	for i, patch := range patches {
+		data, err := yaml.YAMLToJSON([]byte(patch.Patch))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the patch %d, error: %v", i, err)
+		}
+
+		var apply func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+		target := patch.Target
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+			var operations jsonpatch.Patch
+			if operations, err = jsonpatch.DecodePatch(data); err != nil {
+				return nil, fmt.Errorf("failed to parse the JSON 6902 patch %d, error: %v", i, err)
+			}
+			if target == (kusv1.Selector{}) {
+				return nil, fmt.Errorf("the target of the JSON 6902 patch %d is required", i)
+			}
+			apply = func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				return patchObject(obj, func(original []byte) ([]byte, error) {
+					return operations.Apply(original)
+				})
+			}
+		} else {
+			// the kind of a patch is optional if it has a target
+			patchObj := &unstructured.Unstructured{}
+			if err = json.Unmarshal(data, &patchObj.Object); err != nil {
+				return nil, fmt.Errorf("failed to parse the strategic merge patch %d, error: %v", i, err)
+			}
+			if target == (kusv1.Selector{}) {
+				gvk := patchObj.GroupVersionKind()
+				target = kusv1.Selector{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind,
+					Name: patchObj.GetName(), Namespace: patchObj.GetNamespace()}
+			}
+			apply = func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+				if directive, _ := patchObj.Object["$patch"].(string); directive == "delete" {
+					return nil, nil
+				}
+				return patchObject(obj, func(original []byte) ([]byte, error) {
+					return strategicMerge(obj.GroupVersionKind(), original, data)
+				})
+			}
+		}
+
+		var patched []*unstructured.Unstructured
+		for _, obj := range objs {
+			if matched, matchErr := matchSelector(obj, target); matchErr != nil {
+				return nil, fmt.Errorf("invalid target of the patch %d, error: %v", i, matchErr)
+			} else if !matched {
+				patched = append(patched, obj)
+				continue
+			}
+			if obj, err = apply(obj); err != nil {
+				return nil, fmt.Errorf("failed to apply the patch %d, error: %v", i, err)
+			} else if obj != nil {
+				patched = append(patched, obj)
+			}
+		}
+		objs = patched
+	}
+	return objs, nil
+}
+
+func patchObject(obj *unstructured.Unstructured, patch func(original []byte) ([]byte, error)) (
+	*unstructured.Unstructured, error) {
+	original, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if data, err = patch(original); err != nil {
+		return nil, err
+	}
+	patched := &unstructured.Unstructured{}
+	err = patched.UnmarshalJSON(data)
+	return patched, err
+}
+
+// strategicMerge merges the patch with the schema of the kind, the kinds without schemas like the custom resources
+// are merged like a JSON merge patch, the same as kustomize does
+func strategicMerge(gvk schema.GroupVersionKind, original, patch []byte) ([]byte, error) {
+	dataStruct, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return jsonpatch.MergePatch(original, patch)
+	}
+	return strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+}
+
+// matchSelector checks if the object is selected, the group, version, kind, name and namespace are regular expressions
+func matchSelector(obj *unstructured.Unstructured, selector kusv1.Selector) (bool, error) {
+	gvk := obj.GroupVersionKind()
+	for _, item := range [][2]string{
+		{selector.Group, gvk.Group},
+		{selector.Version, gvk.Version},
+		{selector.Kind, gvk.Kind},
+		{selector.Name, obj.GetName()},
+		{selector.Namespace, obj.GetNamespace()},
+	} {
+		if item[0] == "" {
+			continue
+		}
+		pattern, err := regexp.Compile("^(?:" + item[0] + ")$")
+		if err != nil {
+			return false, err
+		}
+		if !pattern.MatchString(item[1]) {
+			return false, nil
+		}
+	}
+
+	for _, item := range []struct {
+		selector string
+		set      map[string]string
+	}{
+		{selector.LabelSelector, obj.GetLabels()},
+		{selector.AnnotationSelector, obj.GetAnnotations()},
+	} {
+		if item.selector == "" {
+			continue
+		}
+		parsed, err := labels.Parse(item.selector)
+		if err != nil {
+			return false, err
+		}
+		if !parsed.Matches(labels.Set(item.set)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"testing"
+
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_applyPatches(t *testing.T) {
+	newObjs := func() []*unstructured.Unstructured {
+		deployment, err := parseManifests("app.yaml", []byte(deploymentManifest))
+		assert.Nil(t, err)
+		custom := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Custom",
+			"spec":       map[string]interface{}{"items": []interface{}{"a"}, "keep": true},
+		}}
+		custom.SetName("custom")
+		custom.SetLabels(map[string]string{"app": "custom"})
+		return append(deployment, custom)
+	}
+
+	tests := []struct {
+		name    string
+		patches []kusv1.Patch
+		verify  func(t *testing.T, objs []*unstructured.Unstructured)
+		wantErr string
+	}{{
+		name: "strategic merge patch of a known kind",
+		patches: []kusv1.Patch{{Patch: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: busybox
+`}},
+		verify: func(t *testing.T, objs []*unstructured.Unstructured) {
+			containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+			// the containers are merged by their names
+			assert.Len(t, containers, 2)
+		},
+	}, {
+		name: "merge patch of an unknown kind",
+		patches: []kusv1.Patch{{
+			Patch:  "spec:\n  items:\n  - b\n",
+			Target: kusv1.Selector{Group: "example.com", Kind: "Cus.*", LabelSelector: "app=custom"},
+		}},
+		verify: func(t *testing.T, objs []*unstructured.Unstructured) {
+			assert.Equal(t, map[string]interface{}{"items": []interface{}{"b"}, "keep": true}, objs[1].Object["spec"])
+		},
+	}, {
+		name: "JSON 6902 patch",
+		patches: []kusv1.Patch{{
+			Patch:  `[{"op": "add", "path": "/metadata/annotations", "value": {"patched": "true"}}]`,
+			Target: kusv1.Selector{Name: "app|custom", AnnotationSelector: "!patched"},
+		}},
+		verify: func(t *testing.T, objs []*unstructured.Unstructured) {
+			assert.Equal(t, "true", objs[0].GetAnnotations()["patched"])
+			assert.Equal(t, "true", objs[1].GetAnnotations()["patched"])
+		},
+	}, {
+		name:    "delete an object",
+		patches: []kusv1.Patch{{Patch: "$patch: delete\napiVersion: example.com/v1\nkind: Custom\nmetadata:\n  name: custom\n"}},
+		verify: func(t *testing.T, objs []*unstructured.Unstructured) {
+			if assert.Len(t, objs, 1) {
+				assert.Equal(t, "Deployment", objs[0].GetKind())
+			}
+		},
+	}, {
+		name:    "JSON 6902 patch without a target",
+		patches: []kusv1.Patch{{Patch: `[{"op": "remove", "path": "/spec"}]`}},
+		wantErr: "the target of the JSON 6902 patch 0 is required",
+	}, {
+		name:    "invalid target",
+		patches: []kusv1.Patch{{Patch: `[{"op": "remove", "path": "/spec"}]`, Target: kusv1.Selector{Name: "("}}},
+		wantErr: "invalid target of the patch 0",
+	}, {
+		name:    "failed to apply a patch",
+		patches: []kusv1.Patch{{Patch: `[{"op": "remove", "path": "/fake"}]`, Target: kusv1.Selector{Kind: "Deployment"}}},
+		wantErr: "failed to apply the patch 0",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := applyPatches(newObjs(), tt.patches)
+			if tt.wantErr != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			if assert.Nil(t, err) {
+				tt.verify(t, objs)
+			}
+		})
+	}
+}
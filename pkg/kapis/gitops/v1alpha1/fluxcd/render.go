@@ -0,0 +1,486 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// maxArtifactSize is the max size of a source artifact which can be downloaded
+	maxArtifactSize = 50 << 20
+	// maxUncompressedArtifactSize is the max size of a source artifact after decompression
+	maxUncompressedArtifactSize = 200 << 20
+	// maxManifestFileSize is the max size of a manifest file in a source artifact
+	maxManifestFileSize = 5 << 20
+	// maxManifestsSize is the max size of all the manifest files in a source artifact
+	maxManifestsSize = 50 << 20
+	// maxRenderDepth is the max depth of the nested directories which are rendered
+	maxRenderDepth = 20
+)
+
+// errPreviewNotSupported is returned if the manifests need the features of kustomize which are not supported yet
+var errPreviewNotSupported = errors.New("not supported by the preview")
+
+// variablePattern matches the escaped $$ and the variables of the post build of FluxCD, such as ${var},
+// ${var:=default} and ${var:-default}
+var variablePattern = regexp.MustCompile(`\$\$|\$\{([_a-zA-Z][_a-zA-Z0-9]*)(?:(:?[-=])([^}]*))?\}`)
+
+// substituteDisabledAnnotation disables the substitution of an object, the same as FluxCD
+const substituteDisabledAnnotation = "kustomize.toolkit.fluxcd.io/substitute"
+
+// kustomizationFileNames are the file names of a kustomization, the same as kustomize
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// fetchArtifact downloads a source artifact from FluxCD source-controller, only the manifests are kept.
+// The key is the relative path of a file.
+func fetchArtifact(ctx context.Context, url string) (files map[string][]byte, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err != nil {
+		return
+	}
+	var resp *http.Response
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("failed to download the artifact %s, status code: %d", url, resp.StatusCode)
+		return
+	}
+
+	var gzipReader *gzip.Reader
+	if gzipReader, err = gzip.NewReader(io.LimitReader(resp.Body, maxArtifactSize)); err != nil {
+		return
+	}
+	files = map[string][]byte{}
+	// the compressed size says nothing about the decompressed one, limit both of them
+	tarReader := tar.NewReader(&sizeLimitedReader{reader: gzipReader, remaining: maxUncompressedArtifactSize,
+		err: fmt.Errorf("the artifact %s is larger than %d bytes after decompression", url, maxUncompressedArtifactSize)})
+	var total int64
+	for {
+		var header *tar.Header
+		if header, err = tarReader.Next(); errors.Is(err, io.EOF) {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || !isManifestFile(name) {
+			continue
+		}
+		var data []byte
+		if data, err = io.ReadAll(io.LimitReader(tarReader, maxManifestFileSize+1)); err != nil {
+			return
+		}
+		if len(data) > maxManifestFileSize {
+			err = fmt.Errorf("the file %s of the artifact %s is larger than %d bytes", name, url, maxManifestFileSize)
+			return
+		}
+		if total += int64(len(data)); total > maxManifestsSize {
+			err = fmt.Errorf("the manifests of the artifact %s are larger than %d bytes", url, maxManifestsSize)
+			return
+		}
+		files[name] = data
+	}
+	return
+}
+
+// sizeLimitedReader reads at most the remaining bytes, then returns the error instead of a silent EOF
+type sizeLimitedReader struct {
+	reader    io.Reader
+	remaining int64
+	err       error
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (n int, err error) {
+	if r.remaining <= 0 {
+		return 0, r.err
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err = r.reader.Read(p)
+	r.remaining -= int64(n)
+	return
+}
+
+func isManifestFile(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".yaml" || ext == ".yml" || path.Base(name) == "Kustomization"
+}
+
+// kustomizePatch is a patch of a kustomization file, it's inline or in a file
+type kustomizePatch struct {
+	Path   string          `json:"path,omitempty"`
+	Patch  string          `json:"patch,omitempty"`
+	Target *kusv1.Selector `json:"target,omitempty"`
+}
+
+// kustomizePatches are the patches of a kustomization file
+type kustomizePatches struct {
+	PatchesStrategicMerge []string         `json:"patchesStrategicMerge,omitempty"`
+	Patches               []kustomizePatch `json:"patches,omitempty"`
+	PatchesJSON6902       []kustomizePatch `json:"patchesJson6902,omitempty"`
+}
+
+// manifestRenderer renders the manifests of a directory like FluxCD kustomize-controller does. The plain manifests,
+// and the resources, namespace and patches of a kustomization file are supported. Other features of kustomize are not.
+type manifestRenderer struct {
+	files        map[string][]byte
+	isNamespaced func(obj runtime.Object) (bool, error)
+
+	// visited are the directories which are rendered, a directory can only be included once like kustomize does.
+	// It stops the directories which include themselves or each other.
+	visited map[string]bool
+	depth   int
+}
+
+func (r *manifestRenderer) render(dir string) (objs []*unstructured.Unstructured, err error) {
+	dir = path.Clean(strings.TrimPrefix(dir, "/"))
+	if dir == ".." || strings.HasPrefix(dir, "../") {
+		return nil, fmt.Errorf("path %s is out of the source", dir)
+	}
+	if r.visited == nil {
+		r.visited = map[string]bool{}
+	}
+	if r.visited[dir] {
+		return nil, fmt.Errorf("the directory %s is included more than once", dir)
+	}
+	if r.depth >= maxRenderDepth {
+		return nil, fmt.Errorf("the directory %s is nested deeper than %d", dir, maxRenderDepth)
+	}
+	r.visited[dir] = true
+	r.depth++
+	defer func() {
+		r.depth--
+	}()
+
+	for _, name := range kustomizationFileNames {
+		if data, ok := r.files[path.Join(dir, name)]; ok {
+			return r.renderKustomization(dir, data)
+		}
+	}
+
+	// there is no kustomization file, take all the manifests like FluxCD does,
+	// the sub-directories which have a kustomization file are rendered separately
+	var paths []string
+	kustomizationDirs := map[string]bool{}
+	for name := range r.files {
+		if dir != "." && !strings.HasPrefix(name, dir+"/") {
+			continue
+		}
+		if kustomizationDir := r.findKustomizationDir(dir, path.Dir(name)); kustomizationDir != "" {
+			kustomizationDirs[kustomizationDir] = true
+			continue
+		}
+		paths = append(paths, name)
+	}
+	for kustomizationDir := range kustomizationDirs {
+		paths = append(paths, kustomizationDir)
+	}
+	sort.Strings(paths)
+
+	for _, name := range paths {
+		var items []*unstructured.Unstructured
+		if kustomizationDirs[name] {
+			items, err = r.render(name)
+		} else {
+			items, err = parseManifests(name, r.files[name])
+		}
+		if err != nil {
+			return
+		}
+		objs = append(objs, items...)
+	}
+	return
+}
+
+// findKustomizationDir returns the top directory which has a kustomization file under the root
+func (r *manifestRenderer) findKustomizationDir(root, dir string) (kustomizationDir string) {
+	for ; dir != root && dir != "." && dir != "/"; dir = path.Dir(dir) {
+		for _, name := range kustomizationFileNames {
+			if _, ok := r.files[path.Join(dir, name)]; ok {
+				kustomizationDir = dir
+			}
+		}
+	}
+	return
+}
+
+func (r *manifestRenderer) renderKustomization(dir string, data []byte) (objs []*unstructured.Unstructured, err error) {
+	kustomization := map[string]interface{}{}
+	if err = yaml.Unmarshal(data, &kustomization); err != nil {
+		return nil, fmt.Errorf("failed to parse the kustomization of %s, error: %v", dir, err)
+	}
+	var unsupported []string
+	for key := range kustomization {
+		switch key {
+		case "apiVersion", "kind", "namespace", "resources", "patches", "patchesStrategicMerge", "patchesJson6902":
+		default:
+			unsupported = append(unsupported, key)
+		}
+	}
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return nil, fmt.Errorf("the kustomization of %s has the fields which are %w: %s",
+			dir, errPreviewNotSupported, strings.Join(unsupported, ", "))
+	}
+
+	resources, _, err := unstructured.NestedStringSlice(kustomization, "resources")
+	if err != nil {
+		return nil, fmt.Errorf("invalid resources of the kustomization of %s, error: %v", dir, err)
+	}
+	for _, resource := range resources {
+		if strings.Contains(resource, "://") {
+			return nil, fmt.Errorf("the remote resource %s of %s is %w", resource, dir, errPreviewNotSupported)
+		}
+
+		var items []*unstructured.Unstructured
+		resourcePath := path.Join(dir, resource)
+		if data, ok := r.files[resourcePath]; ok {
+			items, err = parseManifests(resourcePath, data)
+		} else if r.hasDir(resourcePath) {
+			items, err = r.render(resourcePath)
+		} else {
+			err = fmt.Errorf("the resource %s of %s is not found", resource, dir)
+		}
+		if err != nil {
+			return
+		}
+		objs = append(objs, items...)
+	}
+
+	// the same order as kustomize, the JSON 6902 patches are applied after the namespace
+	patches := &kustomizePatches{}
+	if err = yaml.Unmarshal(data, patches); err != nil {
+		return nil, fmt.Errorf("invalid patches of the kustomization of %s, error: %v", dir, err)
+	}
+	var strategicMergePatches, json6902Patches []kusv1.Patch
+	if strategicMergePatches, err = r.getPatches(dir, append(toKustomizePatches(patches.PatchesStrategicMerge),
+		patches.Patches...)); err != nil {
+		return
+	}
+	if json6902Patches, err = r.getPatches(dir, patches.PatchesJSON6902); err != nil {
+		return
+	}
+	if objs, err = applyPatches(objs, strategicMergePatches); err != nil {
+		return nil, fmt.Errorf("failed to patch the kustomization of %s, %v", dir, err)
+	}
+	if namespace, _, _ := unstructured.NestedString(kustomization, "namespace"); namespace != "" {
+		r.setNamespace(objs, namespace, true)
+	}
+	if objs, err = applyPatches(objs, json6902Patches); err != nil {
+		return nil, fmt.Errorf("failed to patch the kustomization of %s, %v", dir, err)
+	}
+	return
+}
+
+// toKustomizePatches converts the strategic merge patches, each of them is a file or an inline patch
+func toKustomizePatches(strategicMergePatches []string) (patches []kustomizePatch) {
+	for _, patch := range strategicMergePatches {
+		if strings.Contains(patch, "\n") {
+			patches = append(patches, kustomizePatch{Patch: patch})
+		} else {
+			patches = append(patches, kustomizePatch{Path: patch})
+		}
+	}
+	return
+}
+
+// getPatches reads the patches of a kustomization from the files
+func (r *manifestRenderer) getPatches(dir string, patches []kustomizePatch) (result []kusv1.Patch, err error) {
+	for _, patch := range patches {
+		item := kusv1.Patch{Patch: patch.Patch}
+		if patch.Target != nil {
+			item.Target = *patch.Target
+		}
+		if patch.Path != "" {
+			data, ok := r.files[path.Join(dir, patch.Path)]
+			if !ok {
+				return nil, fmt.Errorf("the patch %s of %s is not found", patch.Path, dir)
+			}
+			item.Patch = string(data)
+		}
+		result = append(result, item)
+	}
+	return
+}
+
+func (r *manifestRenderer) hasDir(dir string) bool {
+	for name := range r.files {
+		if dir == "." || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// setNamespace sets the namespace of the namespaced objects, the objects of the unknown kinds are taken as namespaced
+func (r *manifestRenderer) setNamespace(objs []*unstructured.Unstructured, namespace string, override bool) {
+	for _, obj := range objs {
+		if namespaced, err := r.isNamespaced(obj); err == nil && !namespaced {
+			continue
+		}
+		if override || obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+	}
+}
+
+// parseManifests parses the objects of a multi-document YAML file
+func parseManifests(name string, data []byte) (objs []*unstructured.Unstructured, err error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		var doc []byte
+		if doc, err = reader.Read(); errors.Is(err, io.EOF) {
+			err = nil
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s, error: %v", name, err)
+		}
+
+		var jsonData []byte
+		if jsonData, err = yaml.YAMLToJSON(doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s, error: %v", name, err)
+		}
+		if len(bytes.TrimSpace(jsonData)) == 0 || string(jsonData) == "null" {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		if err = obj.UnmarshalJSON(jsonData); err != nil {
+			return nil, fmt.Errorf("failed to parse %s, error: %v", name, err)
+		}
+		objs = append(objs, obj)
+	}
+	return
+}
+
+// substituteVariables replaces the variables of the objects like the post build of FluxCD does. A variable is replaced
+// by its value, or the default value if it's not set, and an empty string if there is no default value.
+// The objects with the annotation kustomize.toolkit.fluxcd.io/substitute: disabled are skipped.
+func substituteVariables(objs []*unstructured.Unstructured, vars map[string]string) (
+	result []*unstructured.Unstructured, err error) {
+	for _, obj := range objs {
+		if obj.GetAnnotations()[substituteDisabledAnnotation] == "disabled" {
+			result = append(result, obj)
+			continue
+		}
+
+		var data []byte
+		if data, err = yaml.Marshal(obj.Object); err != nil {
+			return
+		}
+		data = variablePattern.ReplaceAllFunc(data, func(match []byte) []byte {
+			groups := variablePattern.FindSubmatch(match)
+			if groups[1] == nil {
+				return []byte("$")
+			}
+			value, ok := vars[string(groups[1])]
+			switch operator := string(groups[2]); {
+			case operator == "":
+				return []byte(value)
+			case strings.HasPrefix(operator, ":") && value == "", !ok:
+				return groups[3]
+			default:
+				return []byte(value)
+			}
+		})
+
+		substituted := &unstructured.Unstructured{}
+		if data, err = yaml.YAMLToJSON(data); err == nil {
+			err = substituted.UnmarshalJSON(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute the variables of %s %s, error: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		result = append(result, substituted)
+	}
+	return
+}
+
+// setImages overrides the images of the containers like the images of a kustomization
+func setImages(obj interface{}, images []kusv1.Image) {
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if containers, ok := item.([]interface{}); ok && (key == "containers" || key == "initContainers") {
+				for _, container := range containers {
+					if container, ok := container.(map[string]interface{}); ok {
+						if image, ok := container["image"].(string); ok {
+							container["image"] = overrideImage(image, images)
+						}
+					}
+				}
+				continue
+			}
+			setImages(item, images)
+		}
+	case []interface{}:
+		for _, item := range value {
+			setImages(item, images)
+		}
+	}
+}
+
+func overrideImage(image string, images []kusv1.Image) string {
+	name, suffix := image, ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, suffix = name[:i], name[i:]+suffix
+	}
+
+	for _, override := range images {
+		if override.Name != name {
+			continue
+		}
+		if override.NewName != "" {
+			name = override.NewName
+		}
+		if override.Digest != "" {
+			suffix = "@" + override.Digest
+		} else if override.NewTag != "" {
+			suffix = ":" + override.NewTag
+		}
+		break
+	}
+	return name + suffix
+}
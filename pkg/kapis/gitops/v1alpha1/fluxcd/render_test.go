@@ -0,0 +1,382 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	deploymentManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.24
+`
+	multiManifests = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+# an empty document
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`
+)
+
+func newArtifact(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		assert.Nil(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzipWriter.Close())
+	return buf.Bytes()
+}
+
+func Test_fetchArtifact(t *testing.T) {
+	artifact := newArtifact(t, map[string]string{
+		"./deploy/app.yaml": deploymentManifest,
+		"README.md":         "readme",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/artifact.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(artifact)
+	}))
+	defer server.Close()
+
+	files, err := fetchArtifact(context.TODO(), server.URL+"/artifact.tar.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]byte{"deploy/app.yaml": []byte(deploymentManifest)}, files)
+
+	_, err = fetchArtifact(context.TODO(), server.URL+"/not-found.tar.gz")
+	assert.NotNil(t, err)
+}
+
+func Test_fetchArtifact_decompressedSize(t *testing.T) {
+	// the highly compressible files are tiny before decompression
+	largeFile := strings.Repeat("a", maxManifestFileSize+1)
+	manyFiles := map[string]string{}
+	for i := 0; i <= maxManifestsSize/maxManifestFileSize; i++ {
+		manyFiles[fmt.Sprintf("app-%d.yaml", i)] = strings.Repeat("a", maxManifestFileSize)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{{
+		name:    "a large manifest",
+		files:   map[string]string{"app.yaml": largeFile},
+		wantErr: "the file app.yaml of the artifact",
+	}, {
+		name:    "too many manifests",
+		files:   manyFiles,
+		wantErr: "the manifests of the artifact",
+	}, {
+		name:    "a large artifact",
+		files:   map[string]string{"README.md": strings.Repeat("a", maxUncompressedArtifactSize)},
+		wantErr: "after decompression",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artifact := newArtifact(t, tt.files)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(artifact)
+			}))
+			defer server.Close()
+
+			_, err := fetchArtifact(context.TODO(), server.URL)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// nestedKustomizations returns the kustomizations which include the directories nested in them
+func nestedKustomizations(depth int) map[string]string {
+	files := map[string]string{}
+	dir := "."
+	for i := 0; i < depth; i++ {
+		files[path.Join(dir, "kustomization.yaml")] = "resources:\n- sub\n"
+		dir = path.Join(dir, "sub")
+	}
+	files[path.Join(dir, "app.yaml")] = deploymentManifest
+	return files
+}
+
+func Test_manifestRenderer_render(t *testing.T) {
+	// the namespaces are cluster scoped
+	isNamespaced := func(obj runtime.Object) (bool, error) {
+		return obj.GetObjectKind().GroupVersionKind().Kind != "Namespace", nil
+	}
+	getNames := func(objs []*unstructured.Unstructured) (names []string) {
+		for _, obj := range objs {
+			names = append(names, obj.GetKind()+":"+obj.GetNamespace()+"/"+obj.GetName())
+		}
+		return
+	}
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		path      string
+		wantNames []string
+		wantErr   string
+	}{{
+		name: "plain manifests",
+		files: map[string]string{
+			"deploy/app.yaml":        deploymentManifest,
+			"deploy/config/all.yaml": multiManifests,
+			"other/app.yaml":         deploymentManifest,
+		},
+		path:      "./deploy",
+		wantNames: []string{"Deployment:/app", "ConfigMap:/config", "Namespace:/app"},
+	}, {
+		name: "the resources and namespace of a kustomization",
+		files: map[string]string{
+			"deploy/kustomization.yaml": "resources:\n- app.yaml\n- ../config\nnamespace: fake\n",
+			"deploy/app.yaml":           deploymentManifest,
+			"deploy/ignored.yaml":       deploymentManifest,
+			"config/all.yaml":           multiManifests,
+		},
+		path:      "deploy",
+		wantNames: []string{"Deployment:fake/app", "ConfigMap:fake/config", "Namespace:/app"},
+	}, {
+		name: "a sub-directory has a kustomization",
+		files: map[string]string{
+			"app.yaml":                 deploymentManifest,
+			"config/kustomization.yml": "resources:\n- all.yaml\nnamespace: config\n",
+			"config/all.yaml":          multiManifests,
+			"config/ignored.yaml":      deploymentManifest,
+		},
+		path:      "",
+		wantNames: []string{"Deployment:/app", "ConfigMap:config/config", "Namespace:/app"},
+	}, {
+		name: "the patches of a kustomization",
+		files: map[string]string{
+			"kustomization.yaml": `resources:
+- app.yaml
+namespace: fake
+patchesStrategicMerge:
+- rename.yaml
+patches:
+- target:
+    kind: Deployment
+  patch: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: not-used
+      labels:
+        patched: "true"
+patchesJson6902:
+- target:
+    kind: Deployment
+    namespace: fake
+  path: json6902.yaml
+`,
+			"app.yaml":      deploymentManifest,
+			"rename.yaml":   "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 2\n",
+			"json6902.yaml": "- op: replace\n  path: /metadata/name\n  value: renamed\n",
+		},
+		wantNames: []string{"Deployment:fake/renamed"},
+	}, {
+		name: "the patch of a kustomization is not found",
+		files: map[string]string{
+			"kustomization.yaml": "resources:\n- app.yaml\npatchesStrategicMerge:\n- patch.yaml\n",
+			"app.yaml":           deploymentManifest,
+		},
+		wantErr: "the patch patch.yaml of . is not found",
+	}, {
+		name: "unsupported fields of a kustomization",
+		files: map[string]string{
+			"kustomization.yaml": "resources:\n- app.yaml\nconfigMapGenerator:\n- name: fake\nimages:\n- name: nginx\n",
+			"app.yaml":           deploymentManifest,
+		},
+		wantErr: "not supported by the preview: configMapGenerator, images",
+	}, {
+		name:    "remote resource",
+		files:   map[string]string{"kustomization.yaml": "resources:\n- https://github.com/fake/fake\n"},
+		wantErr: "the remote resource https://github.com/fake/fake of . is not supported by the preview",
+	}, {
+		name:    "the resource is not found",
+		files:   map[string]string{"kustomization.yaml": "resources:\n- app.yaml\n"},
+		wantErr: "the resource app.yaml of . is not found",
+	}, {
+		name:    "the path is out of the source",
+		files:   map[string]string{"app.yaml": deploymentManifest},
+		path:    "../",
+		wantErr: "path .. is out of the source",
+	}, {
+		name:    "invalid manifest",
+		files:   map[string]string{"app.yaml": "kind: [invalid"},
+		wantErr: "failed to parse app.yaml",
+	}, {
+		name:    "a kustomization includes itself",
+		files:   map[string]string{"kustomization.yaml": "resources:\n- .\n"},
+		wantErr: "the directory . is included more than once",
+	}, {
+		name:    "a kustomization of a sub-directory includes itself",
+		files:   map[string]string{"deploy/kustomization.yaml": "resources:\n- ./\n"},
+		path:    "deploy",
+		wantErr: "the directory deploy is included more than once",
+	}, {
+		name: "two kustomizations include each other",
+		files: map[string]string{
+			"a/kustomization.yaml": "resources:\n- ../b\n",
+			"b/kustomization.yaml": "resources:\n- ../a\n",
+		},
+		path:    "a",
+		wantErr: "the directory a is included more than once",
+	}, {
+		name:    "the directories are nested too deep",
+		files:   nestedKustomizations(maxRenderDepth + 1),
+		wantErr: "is nested deeper than 20",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string][]byte{}
+			for name, content := range tt.files {
+				files[name] = []byte(content)
+			}
+			renderer := &manifestRenderer{files: files, isNamespaced: isNamespaced}
+			objs, err := renderer.render(tt.path)
+			if tt.wantErr != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantNames, getNames(objs))
+		})
+	}
+}
+
+func Test_substituteVariables(t *testing.T) {
+	newObj := func(data map[string]interface{}, annotations map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "data": data}}
+		obj.SetName("config")
+		obj.SetAnnotations(annotations)
+		return obj
+	}
+
+	objs, err := substituteVariables([]*unstructured.Unstructured{
+		newObj(map[string]interface{}{
+			"set":         "${set}",
+			"unset":       "${unset}",
+			"default":     "${empty:=default}",
+			"setDefault":  "${set:-default}",
+			"emptyUnset":  "${empty-default}",
+			"unsetUnset":  "${unset=default}",
+			"escaped":     "$${set}",
+			"unsupported": "${set/a/b}",
+		}, nil),
+		newObj(map[string]interface{}{"set": "${set}"}, map[string]string{substituteDisabledAnnotation: "disabled"}),
+	}, map[string]string{"set": "value", "empty": "", "replicas": "3"})
+	assert.Nil(t, err)
+	if assert.Len(t, objs, 2) {
+		assert.Equal(t, map[string]interface{}{
+			"set": "value",
+			// the same as FluxCD, an empty value is null in YAML
+			"unset":       nil,
+			"default":     "default",
+			"setDefault":  "value",
+			"emptyUnset":  nil,
+			"unsetUnset":  "default",
+			"escaped":     "${set}",
+			"unsupported": "${set/a/b}",
+		}, objs[0].Object["data"])
+		assert.Equal(t, map[string]interface{}{"set": "${set}"}, objs[1].Object["data"])
+	}
+
+	// the variables are substituted in the YAML, so a number is parsed as a number of the manifests
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1", "kind": "Deployment", "spec": map[string]interface{}{"replicas": "${replicas}"}}}
+	objs, err = substituteVariables([]*unstructured.Unstructured{deployment}, map[string]string{"replicas": "3"})
+	assert.Nil(t, err)
+	if assert.Len(t, objs, 1) {
+		replicas, _, _ := unstructured.NestedInt64(objs[0].Object, "spec", "replicas")
+		assert.Equal(t, int64(3), replicas)
+	}
+}
+
+func Test_overrideImage(t *testing.T) {
+	images := []kusv1.Image{
+		{Name: "nginx", NewTag: "1.25"},
+		{Name: "ghcr.io/fake/app", NewName: "docker.io/fake/app"},
+		{Name: "localhost:5000/fake", Digest: "sha256:fake"},
+	}
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "nginx", want: "nginx:1.25"},
+		{image: "nginx:1.24", want: "nginx:1.25"},
+		{image: "ghcr.io/fake/app:v1", want: "docker.io/fake/app:v1"},
+		{image: "localhost:5000/fake:v1", want: "localhost:5000/fake@sha256:fake"},
+		{image: "redis:7", want: "redis:7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, overrideImage(tt.image, images))
+		})
+	}
+}
+
+func Test_setImages(t *testing.T) {
+	objs, err := parseManifests("app.yaml", []byte(deploymentManifest))
+	assert.Nil(t, err)
+	if assert.Len(t, objs, 1) {
+		setImages(objs[0].Object, []kusv1.Image{{Name: "nginx", NewTag: "1.25"}})
+		containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+		if assert.Len(t, containers, 1) {
+			assert.Equal(t, "nginx:1.25", containers[0].(map[string]interface{})["image"])
+		}
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 )
 
 var (
@@ -44,6 +45,16 @@ type ApplicationPageResult struct {
 	TotalItems int                    `json:"totalItems"`
 }
 
+// ApplicationRollbackRequest is a request to pin an application to a previous revision.
+type ApplicationRollbackRequest struct {
+	// Revision is the chart version of a HelmRelease application, or the source revision of a Kustomization application.
+	// An empty revision un-pins the source of a Kustomization application.
+	Revision string `json:"revision"`
+	// Force pins the source even if other applications or FluxCD objects refer to it, all of them are rolled back.
+	// It never pins a source out of the namespace of the application
+	Force bool `json:"force,omitempty"`
+}
+
 // RegisterRoutes is for registering Argo CD Application routes into WebService.
 func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOption *config.FluxCDOption) {
 	handler := newHandler(options, fluxOption)
@@ -98,6 +109,27 @@ func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOp
 		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
 		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
 
+	service.Route(service.POST("/namespaces/{namespace}/applications/{application}/rollback").
+		To(handler.handleRollbackApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Reads(ApplicationRollbackRequest{}).
+		Doc("Rollback a particular application by pinning the chart version or the source revision. "+
+			"Only a source in the namespace of the application can be pinned, the source shared by other applications or "+
+			"FluxCD objects is pinned only if force is true, an empty revision un-pins the source").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/diff").
+		To(handler.applicationDiff).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Preview the changes of a particular application by comparing the manifests with the live objects. "+
+			"HelmReleases and the generators of kustomize are not supported yet (501), "+
+			"see docs/gitops-diff.md").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, gitops.ApplicationDiff{}))
+
 	service.Route(service.GET("/clusters").
 		To(handler.getClusters).
 		Doc("Get the clusters list").
@@ -0,0 +1,224 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DiffAction is the action which a sync takes on a resource
+type DiffAction string
+
+const (
+	// DiffActionCreate indicates the resource does not exist in the destination
+	DiffActionCreate DiffAction = "Create"
+	// DiffActionUpdate indicates the live object is different from the target manifest
+	DiffActionUpdate DiffAction = "Update"
+	// DiffActionDelete indicates the live object is no longer in the target manifests
+	DiffActionDelete DiffAction = "Delete"
+	// DiffActionNone indicates the live object is the same as the target manifest
+	DiffActionNone DiffAction = "None"
+	// DiffActionUnknown indicates the difference could not be determined
+	DiffActionUnknown DiffAction = "Unknown"
+)
+
+// ApplicationDiff is the preview of the changes which a sync applies to the destination
+type ApplicationDiff struct {
+	// Revision is the source revision of the target manifests
+	Revision  string         `json:"revision,omitempty"`
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// ResourceDiff is the difference between the target manifest and the live object of a resource
+type ResourceDiff struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Destination is the cluster of the resource
+	Destination string     `json:"destination,omitempty"`
+	Action      DiffAction `json:"action"`
+	Message     string     `json:"message,omitempty"`
+	// Fields are the fields of the target manifest which are different from the live object
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+// FieldDiff is a field which has different values in the target manifest and the live object
+type FieldDiff struct {
+	// Path is the path of the field, such as spec.template.spec.containers[0].image
+	Path   string      `json:"path"`
+	Live   interface{} `json:"live,omitempty"`
+	Target interface{} `json:"target,omitempty"`
+}
+
+// DiffResource compares a target manifest with its live object, the live object is nil if it does not exist.
+// Only the fields of the target manifest are compared, so the fields set by the API server or the
+// controllers are not taken as differences. The status is ignored, and the data of Secrets is hidden.
+func DiffResource(target, live *unstructured.Unstructured) ResourceDiff {
+	gvk := target.GroupVersionKind()
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		target, live = hideSecretData(target, live)
+	}
+	diff := ResourceDiff{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: target.GetNamespace(),
+		Name:      target.GetName(),
+		Action:    DiffActionCreate,
+	}
+	if live == nil {
+		return diff
+	}
+
+	for _, key := range sortedKeys(target.Object) {
+		switch key {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			// the name and namespace are the same, the others are maintained by the API server
+			for _, field := range []string{"labels", "annotations"} {
+				targetValue, _, _ := unstructured.NestedFieldNoCopy(target.Object, "metadata", field)
+				liveValue, _, _ := unstructured.NestedFieldNoCopy(live.Object, "metadata", field)
+				if targetValue != nil {
+					diffFields("metadata."+field, targetValue, liveValue, &diff.Fields)
+				}
+			}
+		default:
+			diffFields(key, target.Object[key], live.Object[key], &diff.Fields)
+		}
+	}
+	if len(diff.Fields) == 0 {
+		diff.Action = DiffActionNone
+	} else {
+		diff.Action = DiffActionUpdate
+	}
+	return diff
+}
+
+// HideLiveValues removes the values of the live object, it's for the caller who is not allowed to read the object
+func (d *ResourceDiff) HideLiveValues() {
+	for i := range d.Fields {
+		d.Fields[i].Live = nil
+	}
+}
+
+// hideSecretData returns the copies of the Secrets whose values of the data are replaced by '+' like Argo CD does.
+// The same values have the same replacement, so that the changed keys can still be found.
+func hideSecretData(target, live *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	target = target.DeepCopy()
+	// the stringData is merged into the data by the API server
+	if stringData, _, _ := unstructured.NestedStringMap(target.Object, "stringData"); len(stringData) > 0 {
+		data, _, _ := unstructured.NestedMap(target.Object, "data")
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		for key, value := range stringData {
+			data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+		target.Object["data"] = data
+	}
+	unstructured.RemoveNestedField(target.Object, "stringData")
+
+	replacements := map[string]string{}
+	hide := func(obj *unstructured.Unstructured) {
+		// the last applied configuration has the data as well
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+		data, ok := obj.Object["data"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, key := range sortedKeys(data) {
+			value := fmt.Sprint(data[key])
+			replacement, found := replacements[value]
+			if !found {
+				replacement = strings.Repeat("+", len(replacements)+8)
+				replacements[value] = replacement
+			}
+			data[key] = replacement
+		}
+	}
+	hide(target)
+	if live != nil {
+		live = live.DeepCopy()
+		hide(live)
+	}
+	return target, live
+}
+
+func diffFields(path string, target, live interface{}, fields *[]FieldDiff) {
+	switch targetValue := target.(type) {
+	case map[string]interface{}:
+		if liveValue, ok := live.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(targetValue) {
+				diffFields(fieldPath(path, key), targetValue[key], liveValue[key], fields)
+			}
+			return
+		}
+	case []interface{}:
+		// compare the items one by one if the length is not changed, otherwise take the whole list as a field
+		if liveValue, ok := live.([]interface{}); ok && len(liveValue) == len(targetValue) {
+			for i := range targetValue {
+				diffFields(fmt.Sprintf("%s[%d]", path, i), targetValue[i], liveValue[i], fields)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(target, live) {
+		*fields = append(*fields, FieldDiff{Path: path, Live: live, Target: target})
+	}
+}
+
+func fieldPath(parent, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return fmt.Sprintf("%s[%q]", parent, key)
+	}
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetLiveObject returns the live object of a target manifest, it's nil if the object does not exist
+func GetLiveObject(ctx context.Context, c client.Client, target *unstructured.Unstructured) (live *unstructured.Unstructured, err error) {
+	live = &unstructured.Unstructured{}
+	live.SetGroupVersionKind(target.GroupVersionKind())
+	if err = c.Get(ctx, client.ObjectKeyFromObject(target), live); apierrors.IsNotFound(err) {
+		live, err = nil, nil
+	}
+	return
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newConfigMap(data map[string]interface{}, labels map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": "fake", "namespace": "default"}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       data,
+	}}
+}
+
+func newSecret(data, stringData map[string]interface{}) *unstructured.Unstructured {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "fake", "namespace": "default"},
+		"data":       data,
+	}}
+	if stringData != nil {
+		secret.Object["stringData"] = stringData
+	}
+	return secret
+}
+
+func TestDiffResource(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     *unstructured.Unstructured
+		live       *unstructured.Unstructured
+		wantAction DiffAction
+		wantFields []FieldDiff
+	}{{
+		name:       "the live object does not exist",
+		target:     newConfigMap(map[string]interface{}{"key": "value"}, nil),
+		wantAction: DiffActionCreate,
+	}, {
+		name:   "the same as the live object",
+		target: newConfigMap(map[string]interface{}{"key": "value"}, nil),
+		live: func() *unstructured.Unstructured {
+			live := newConfigMap(map[string]interface{}{"key": "value"}, map[string]interface{}{"app": "fake"})
+			live.SetResourceVersion("1")
+			live.Object["status"] = map[string]interface{}{"phase": "fake"}
+			return live
+		}(),
+		wantAction: DiffActionNone,
+	}, {
+		name:       "different data and labels",
+		target:     newConfigMap(map[string]interface{}{"key": "new", "added": "value"}, map[string]interface{}{"app.kubernetes.io/name": "fake"}),
+		live:       newConfigMap(map[string]interface{}{"key": "old"}, nil),
+		wantAction: DiffActionUpdate,
+		wantFields: []FieldDiff{
+			{Path: "data.added", Target: "value"},
+			{Path: "data.key", Live: "old", Target: "new"},
+			{Path: "metadata.labels", Target: map[string]interface{}{"app.kubernetes.io/name": "fake"}},
+		},
+	}, {
+		name: "different items of a list",
+		target: &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "fake", "labels": map[string]interface{}{"app.kubernetes.io/name": "new"}},
+			"spec": map[string]interface{}{
+				"replicas":   int64(2),
+				"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx:1.25"}},
+				"ports":      []interface{}{int64(80)},
+			},
+		}},
+		live: &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "fake", "labels": map[string]interface{}{"app.kubernetes.io/name": "old"}},
+			"spec": map[string]interface{}{
+				"replicas":   int64(1),
+				"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx:1.24", "imagePullPolicy": "Always"}},
+				"ports":      []interface{}{int64(80), int64(443)},
+			},
+		}},
+		wantAction: DiffActionUpdate,
+		wantFields: []FieldDiff{
+			{Path: `metadata.labels["app.kubernetes.io/name"]`, Live: "old", Target: "new"},
+			{Path: "spec.containers[0].image", Live: "nginx:1.24", Target: "nginx:1.25"},
+			{Path: "spec.ports", Live: []interface{}{int64(80), int64(443)}, Target: []interface{}{int64(80)}},
+			{Path: "spec.replicas", Live: int64(1), Target: int64(2)},
+		},
+	}, {
+		name:   "hide the data of a Secret",
+		target: newSecret(map[string]interface{}{"changed": "bmV3", "same": "c2FtZQ=="}, map[string]interface{}{"plain": "same"}),
+		live: func() *unstructured.Unstructured {
+			live := newSecret(map[string]interface{}{"changed": "b2xk", "same": "c2FtZQ==", "plain": "c2FtZQ=="}, nil)
+			live.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": `{"data":{"changed":"b2xk"}}`})
+			return live
+		}(),
+		wantAction: DiffActionUpdate,
+		wantFields: []FieldDiff{
+			{Path: "data.changed", Live: "++++++++++", Target: "++++++++"},
+		},
+	}, {
+		name:       "hide the data of a Secret which does not exist",
+		target:     newSecret(nil, map[string]interface{}{"plain": "value"}),
+		wantAction: DiffActionCreate,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.target.DeepCopy()
+			diff := DiffResource(tt.target, tt.live)
+			assert.Equal(t, target, tt.target, "the target should not be changed")
+			assert.Equal(t, tt.wantAction, diff.Action)
+			assert.Equal(t, tt.wantFields, diff.Fields)
+			assert.Equal(t, tt.target.GetKind(), diff.Kind)
+			assert.Equal(t, tt.target.GetName(), diff.Name)
+		})
+	}
+}
+
+func TestResourceDiff_HideLiveValues(t *testing.T) {
+	diff := DiffResource(newConfigMap(map[string]interface{}{"key": "new"}, nil),
+		newConfigMap(map[string]interface{}{"key": "old"}, nil))
+	diff.HideLiveValues()
+	assert.Equal(t, DiffActionUpdate, diff.Action)
+	assert.Equal(t, []FieldDiff{{Path: "data.key", Target: "new"}}, diff.Fields)
+}
+
+func TestGetLiveObject(t *testing.T) {
+	live := newConfigMap(map[string]interface{}{"key": "value"}, nil)
+	fakeClient := fake.NewClientBuilder().WithObjects(live.DeepCopy()).Build()
+
+	obj, err := GetLiveObject(context.TODO(), fakeClient, newConfigMap(nil, nil))
+	assert.Nil(t, err)
+	if assert.NotNil(t, obj) {
+		assert.Equal(t, "value", obj.Object["data"].(map[string]interface{})["key"])
+	}
+
+	target := newConfigMap(nil, nil)
+	target.SetName("not-found")
+	obj, err = GetLiveObject(context.TODO(), fakeClient, target)
+	assert.Nil(t, err)
+	assert.Nil(t, obj)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// writeBack commits the revision to a field of the YAML file in a git repository. The commit is empty
+// if the field already is the revision.
+func (h *handler) writeBack(ctx context.Context, namespace string, writeBack *v1alpha1.PromotionGitWriteBack,
+	revision, message string, currentUser user.Info) (commit string, err error) {
+	factory := h.getRepoFactory()
+	if factory == nil {
+		return "", errors.New("the git repository service is not available")
+	}
+
+	var repoService devopsgitops.GitRepoService
+	if repoService, err = factory.NewRepoService(ctx, currentUser, types.NamespacedName{
+		Namespace: namespace,
+		Name:      writeBack.Repository,
+	}); err != nil {
+		return
+	}
+
+	var coOut *devopsgitops.CheckOutBranchOutput
+	if coOut, err = repoService.CheckOutBranch(ctx, &devopsgitops.CheckOutBranchInput{
+		Branch: writeBack.Branch,
+		Force:  true,
+	}); err != nil {
+		return
+	}
+	w := coOut.WorkTree
+	if _, err = repoService.CleanAndPull(ctx, &devopsgitops.CleanAndPullInput{
+		WorkTree: w,
+		Branch:   writeBack.Branch,
+	}); err != nil {
+		return
+	}
+
+	var data []byte
+	if data, err = util.ReadFile(w.Filesystem, writeBack.File); err != nil {
+		return "", fmt.Errorf("failed to read %s, error: %v", writeBack.File, err)
+	}
+	if data, err = setYAMLField(data, writeBack.Key, revision); err != nil {
+		return "", fmt.Errorf("failed to set %s of %s, error: %v", writeBack.Key, writeBack.File, err)
+	}
+	if err = util.WriteFile(w.Filesystem, writeBack.File, data, 0644); err != nil {
+		return
+	}
+
+	var cpOut *devopsgitops.CommitAndPushOutput
+	if cpOut, err = repoService.CommitAndPush(ctx, &devopsgitops.CommitAndPushInput{
+		Branch:   writeBack.Branch,
+		WorkTree: w,
+		Message:  message,
+		SignOff:  true,
+	}); err != nil {
+		if errors.Is(err, devopsgitops.ErrWorkTreeClean) {
+			err = nil
+		}
+		return
+	}
+	return cpOut.Commit.Hash, nil
+}
+
+// setYAMLField sets the value of a field in a YAML document, the key is a dot-separated path like image.tag.
+// The missing fields are created, and the comments are kept.
+func setYAMLField(data []byte, key, value string) ([]byte, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	node := doc.Content[0]
+	fields := strings.Split(key, ".")
+	for i, field := range fields {
+		if node.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("%s is not a mapping", strings.Join(fields[:i], "."))
+		}
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == field {
+				child = node.Content[j+1]
+				break
+			}
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field}, child)
+		}
+		node = child
+	}
+	if node.Kind == yaml.MappingNode && len(node.Content) > 0 || node.Kind == yaml.SequenceNode {
+		return nil, fmt.Errorf("%s is not a scalar", key)
+	}
+	// the tag makes sure that a version like 1.10 is quoted
+	node.Kind, node.Tag, node.Value = yaml.ScalarNode, "!!str", value
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
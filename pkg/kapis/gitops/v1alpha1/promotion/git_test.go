@@ -0,0 +1,188 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+type fakeRepoFactory struct {
+	service *fakeRepoService
+	repo    types.NamespacedName
+	user    user.Info
+}
+
+func (f *fakeRepoFactory) NewRepoService(ctx context.Context, user user.Info, repo types.NamespacedName) (devopsgitops.GitRepoService, error) {
+	f.repo, f.user = repo, user
+	return f.service, nil
+}
+
+func (f *fakeRepoFactory) DeleteRepoClone(ctx context.Context, repo types.NamespacedName) error {
+	return nil
+}
+
+// fakeRepoService commits the changes to an in-memory repository
+type fakeRepoService struct {
+	devopsgitops.GitRepoService
+	repo     *git.Repository
+	messages []string
+}
+
+func newFakeRepoService(t *testing.T, files map[string]string) *fakeRepoService {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	assert.Nil(t, err)
+	w, err := repo.Worktree()
+	assert.Nil(t, err)
+	for name, content := range files {
+		assert.Nil(t, util.WriteFile(w.Filesystem, name, []byte(content), 0644))
+	}
+	service := &fakeRepoService{repo: repo}
+	_, err = service.CommitAndPush(context.TODO(), &devopsgitops.CommitAndPushInput{WorkTree: w, Message: "init"})
+	assert.Nil(t, err)
+	return service
+}
+
+func (s *fakeRepoService) CheckOutBranch(ctx context.Context, input *devopsgitops.CheckOutBranchInput) (*devopsgitops.CheckOutBranchOutput, error) {
+	w, err := s.repo.Worktree()
+	return &devopsgitops.CheckOutBranchOutput{WorkTree: w}, err
+}
+
+func (s *fakeRepoService) CleanAndPull(ctx context.Context, input *devopsgitops.CleanAndPullInput) (*devopsgitops.CleanAndPullOutput, error) {
+	return &devopsgitops.CleanAndPullOutput{}, nil
+}
+
+func (s *fakeRepoService) CommitAndPush(ctx context.Context, input *devopsgitops.CommitAndPushInput) (*devopsgitops.CommitAndPushOutput, error) {
+	w := input.WorkTree
+	if _, err := w.Add("."); err != nil {
+		return nil, err
+	}
+	if status, err := w.Status(); err != nil {
+		return nil, err
+	} else if status.IsClean() {
+		return nil, devopsgitops.ErrWorkTreeClean
+	}
+	hash, err := w.Commit(input.Message, &git.CommitOptions{
+		Author: &object.Signature{Name: "fake", Email: "fake@kubesphere.io", When: time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.messages = append(s.messages, input.Message)
+	return &devopsgitops.CommitAndPushOutput{Commit: &devopsgitops.Commit{Hash: hash.String()}}, nil
+}
+
+func (s *fakeRepoService) readFile(t *testing.T, name string) string {
+	w, err := s.repo.Worktree()
+	assert.Nil(t, err)
+	data, err := util.ReadFile(w.Filesystem, name)
+	assert.Nil(t, err)
+	return string(data)
+}
+
+func Test_handler_writeBack(t *testing.T) {
+	service := newFakeRepoService(t, map[string]string{"envs/prod/values.yaml": "image:\n  tag: v1\n"})
+	factory := &fakeRepoFactory{service: service}
+	h := &handler{repoFactory: factory}
+	writeBack := &v1alpha1.PromotionGitWriteBack{
+		Repository: "config",
+		Branch:     "main",
+		File:       "envs/prod/values.yaml",
+		Key:        "image.tag",
+	}
+	currentUser := &user.DefaultInfo{Name: "fake-user"}
+
+	commit, err := h.writeBack(context.TODO(), "fake-namespace", writeBack, "v2", "Promote v2", currentUser)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, commit)
+	assert.Equal(t, types.NamespacedName{Namespace: "fake-namespace", Name: "config"}, factory.repo)
+	assert.Equal(t, currentUser, factory.user)
+	assert.Equal(t, "image:\n  tag: v2\n", service.readFile(t, "envs/prod/values.yaml"))
+	assert.Equal(t, []string{"init", "Promote v2"}, service.messages)
+
+	// nothing is changed
+	commit, err = h.writeBack(context.TODO(), "fake-namespace", writeBack, "v2", "Promote v2", currentUser)
+	assert.Nil(t, err)
+	assert.Empty(t, commit)
+
+	writeBack.File = "not-found.yaml"
+	_, err = h.writeBack(context.TODO(), "fake-namespace", writeBack, "v2", "Promote v2", currentUser)
+	assert.NotNil(t, err)
+}
+
+func Test_setYAMLField(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		key     string
+		value   string
+		want    string
+		wantErr string
+	}{{
+		name:  "an existing field",
+		data:  "# the values of prod\nimage:\n  repository: nginx\n  tag: 1.24 # the tag\nreplicas: 2\n",
+		key:   "image.tag",
+		value: "1.25",
+		want:  "# the values of prod\nimage:\n  repository: nginx\n  tag: \"1.25\" # the tag\nreplicas: 2\n",
+	}, {
+		name:  "a missing field",
+		data:  "replicas: 2\n",
+		key:   "image.tag",
+		value: "v2",
+		want:  "replicas: 2\nimage:\n  tag: v2\n",
+	}, {
+		name:  "an empty file",
+		key:   "revision",
+		value: "fake-commit",
+		want:  "revision: fake-commit\n",
+	}, {
+		name:    "not a mapping",
+		data:    "image: nginx\n",
+		key:     "image.tag",
+		wantErr: "image is not a mapping",
+	}, {
+		name:    "not a scalar",
+		data:    "image:\n  tag: v1\n",
+		key:     "image",
+		wantErr: "image is not a scalar",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setYAMLField([]byte(tt.data), tt.key, tt.value)
+			if tt.wantErr != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
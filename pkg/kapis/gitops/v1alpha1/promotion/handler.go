@@ -0,0 +1,238 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	serverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	utilretry "k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	resources "kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kubesphere.io/kubesphere/pkg/apiserver/query"
+	"kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions,verbs=get;list;create;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions/status,verbs=get;update
+
+var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
+	"unauthenticated request")
+var notWaitingForApprovalError = restful.NewError(http.StatusBadRequest,
+	"the promotion is not waiting for approval")
+var selfApprovalError = restful.NewError(http.StatusForbidden,
+	"the promotion cannot be approved by the user who requested it")
+
+type handler struct {
+	client.Client
+	// repoFactory is used to write the revision back to a git repository, the default one is
+	// initialized by the GitRepository APIs
+	repoFactory devopsgitops.GitRepoFactory
+}
+
+func newHandler(options *common.Options) *handler {
+	return &handler{
+		Client: options.GenericClient,
+	}
+}
+
+func (h *handler) getRepoFactory() devopsgitops.GitRepoFactory {
+	if h.repoFactory != nil {
+		return h.repoFactory
+	}
+	return devopsgitops.DefaultGitRepoFactory
+}
+
+func (h *handler) listPromotions(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+
+	promotionList := &v1alpha1.PromotionList{}
+	if err := h.List(context.Background(), promotionList, client.InNamespace(namespace)); err != nil {
+		common.Response(req, res, promotionList, err)
+		return
+	}
+
+	objects := make([]runtime.Object, len(promotionList.Items))
+	for i := range promotionList.Items {
+		objects[i] = &promotionList.Items[i]
+	}
+	queryParam := query.ParseQueryParameter(req)
+	compareFunc := func(left runtime.Object, right runtime.Object, field query.Field) bool {
+		return resources.DefaultObjectMetaCompare(left.(*v1alpha1.Promotion).ObjectMeta, right.(*v1alpha1.Promotion).ObjectMeta, field)
+	}
+	filterFunc := func(obj runtime.Object, filter query.Filter) bool {
+		return resources.DefaultObjectMetaFilter(obj.(*v1alpha1.Promotion).ObjectMeta, filter)
+	}
+	list := v1alpha3.DefaultList(objects, queryParam, compareFunc, filterFunc)
+
+	common.Response(req, res, list, nil)
+}
+
+func (h *handler) getPromotion(req *restful.Request, res *restful.Response) {
+	promotion, err := h.getPromotionFromRequest(req)
+	common.Response(req, res, promotion, err)
+}
+
+func (h *handler) createPromotion(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+
+	promotion := &v1alpha1.Promotion{}
+	if err := req.ReadEntity(promotion); err != nil {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	if err := validatePromotion(promotion); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	ctx := context.Background()
+	promotion.Namespace = namespace
+	promotion.Status = v1alpha1.PromotionStatus{}
+	if err := h.Create(ctx, promotion); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+
+	promotion.Status.RequestedBy = currentUser.GetName()
+	if promotion.Spec.RequireApproval {
+		promotion.Status.Phase = v1alpha1.PromotionPhaseWaitingForApproval
+		err := h.Status().Update(ctx, promotion)
+		common.Response(req, res, promotion, err)
+		return
+	}
+	err := h.claimAndPromote(ctx, promotion, currentUser)
+	common.Response(req, res, promotion, err)
+}
+
+func (h *handler) approvePromotion(req *restful.Request, res *restful.Response) {
+	h.reviewPromotion(req, res, true)
+}
+
+func (h *handler) rejectPromotion(req *restful.Request, res *restful.Response) {
+	h.reviewPromotion(req, res, false)
+}
+
+func (h *handler) reviewPromotion(req *restful.Request, res *restful.Response, approved bool) {
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	promotion, err := h.getPromotionFromRequest(req)
+	if err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+	if promotion.Status.Phase != v1alpha1.PromotionPhaseWaitingForApproval {
+		common.Response(req, res, nil, notWaitingForApprovalError)
+		return
+	}
+
+	if approved && promotion.Status.RequestedBy == currentUser.GetName() {
+		common.Response(req, res, nil, selfApprovalError)
+		return
+	}
+
+	ctx := context.Background()
+	promotion.Status.ReviewedBy = currentUser.GetName()
+	if approved {
+		err = h.claimAndPromote(ctx, promotion, currentUser)
+	} else {
+		now := metav1.Now()
+		promotion.Status.Phase = v1alpha1.PromotionPhaseRejected
+		promotion.Status.FinishedAt = &now
+		// the resource version makes sure that a promotion is reviewed only once
+		err = h.Status().Update(ctx, promotion)
+	}
+	common.Response(req, res, promotion, err)
+}
+
+func (h *handler) getPromotionFromRequest(req *restful.Request) (promotion *v1alpha1.Promotion, err error) {
+	promotion = &v1alpha1.Promotion{}
+	err = h.Get(context.Background(), types.NamespacedName{
+		Namespace: common.GetPathParameter(req, common.NamespacePathParameter),
+		Name:      common.GetPathParameter(req, pathParameterPromotion),
+	}, promotion)
+	return
+}
+
+// claimAndPromote claims the promotion by updating its phase to Promoting, then promotes the revision and records
+// the result. The resource version makes sure that only one request claims the promotion, so it's executed only once.
+func (h *handler) claimAndPromote(ctx context.Context, promotion *v1alpha1.Promotion, currentUser user.Info) (err error) {
+	promotion.Status.Phase = v1alpha1.PromotionPhasePromoting
+	if err = h.Status().Update(ctx, promotion); err != nil {
+		return
+	}
+
+	h.promote(ctx, promotion, currentUser)
+	status := promotion.Status
+	// nobody else updates a claimed promotion, the conflict only comes from the changes of the metadata or spec
+	return utilretry.RetryOnConflict(utilretry.DefaultRetry, func() (err error) {
+		if err = h.Get(ctx, client.ObjectKeyFromObject(promotion), promotion); err != nil {
+			return
+		}
+		promotion.Status = status
+		return h.Status().Update(ctx, promotion)
+	})
+}
+
+// promote promotes the revision and records the result in the status of the promotion
+func (h *handler) promote(ctx context.Context, promotion *v1alpha1.Promotion, currentUser user.Info) {
+	now := metav1.Now()
+	promotion.Status.FinishedAt = &now
+	if err := h.executePromotion(ctx, promotion, currentUser); err != nil {
+		klog.V(4).Infof("failed to promote %s/%s, error: %v", promotion.Namespace, promotion.Name, err)
+		promotion.Status.Phase = v1alpha1.PromotionPhaseFailed
+		promotion.Status.Message = err.Error()
+		return
+	}
+	promotion.Status.Phase = v1alpha1.PromotionPhaseSucceeded
+	promotion.Status.Message = ""
+}
+
+func validatePromotion(promotion *v1alpha1.Promotion) error {
+	spec := promotion.Spec
+	switch {
+	case spec.Source.Name == "" || spec.Target.Name == "":
+		return restful.NewError(http.StatusBadRequest, "the source and target applications are required")
+	case spec.Source.Name == spec.Target.Name:
+		return restful.NewError(http.StatusBadRequest, "the source and target applications cannot be the same")
+	case spec.GitWriteBack != nil && (spec.GitWriteBack.Repository == "" || spec.GitWriteBack.Branch == "" ||
+		spec.GitWriteBack.File == "" || spec.GitWriteBack.Key == ""):
+		return restful.NewError(http.StatusBadRequest, "the repository, branch, file and key of the git write-back are required")
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	"github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newRequest(method, name, body string, withUser bool) *restful.Request {
+	testReq := httptest.NewRequest(method, "/promotions", bytes.NewBufferString(body))
+	testReq.Header.Set(restful.HEADER_ContentType, restful.MIME_JSON)
+	if withUser {
+		testReq = testReq.WithContext(request.WithUser(testReq.Context(), &user.DefaultInfo{Name: "fake-user"}))
+	}
+	req := restful.NewRequest(testReq)
+	req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-namespace"
+	req.PathParameters()[pathParameterPromotion.Data().Name] = name
+	return req
+}
+
+func newTestApps() []client.Object {
+	staging := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "staging", Namespace: "fake-namespace"},
+		Spec:       v1alpha1.ApplicationSpec{Kind: v1alpha1.ArgoCD, ArgoApp: &v1alpha1.ArgoApplication{}},
+		Status: v1alpha1.ApplicationStatus{ArgoAppStatus: &v1alpha1.ArgoApplicationStatus{
+			Sync:    v1alpha1.SyncStatus{Revision: "fake-commit"},
+			Health:  v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy},
+			Summary: v1alpha1.ApplicationSummary{Images: []string{"ghcr.io/fake/app:v2"}},
+		}},
+	}
+	prod := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.ArgoCD, ArgoApp: &v1alpha1.ArgoApplication{
+			Spec: v1alpha1.ArgoApplicationSpec{Source: v1alpha1.ApplicationSource{
+				TargetRevision: "old-commit",
+				Kustomize:      &v1alpha1.ApplicationSourceKustomize{Images: v1alpha1.KustomizeImages{"ghcr.io/fake/app:v1"}},
+			}},
+		}},
+	}
+	fluxStaging := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "flux-staging", Namespace: "fake-namespace"},
+		Spec:       v1alpha1.ApplicationSpec{Kind: v1alpha1.FluxCD, FluxApp: &v1alpha1.FluxApplication{}},
+		Status: v1alpha1.ApplicationStatus{FluxApp: v1alpha1.FluxApplicationStatus{
+			HelmReleaseStatus: map[string]*helmv2.HelmReleaseStatus{"app": {
+				LastAppliedRevision: "1.2.0",
+				Conditions:          []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse}},
+			}},
+		}},
+	}
+	fluxProd := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "flux-prod", Namespace: "fake-namespace"},
+		Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.FluxCD, FluxApp: &v1alpha1.FluxApplication{
+			Spec: v1alpha1.FluxApplicationSpec{Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{Chart: &v1alpha1.HelmChartTemplateSpec{Chart: "app", Version: "1.1.0"}},
+			}},
+		}},
+	}
+	return []client.Object{staging, prod, fluxStaging, fluxProd}
+}
+
+func newFakeClient(objs ...client.Object) client.Client {
+	return newFakeClientWithInterceptor(interceptor.Funcs{}, objs...)
+}
+
+func newFakeClientWithInterceptor(funcs interceptor.Funcs, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&v1alpha1.Promotion{}).WithInterceptorFuncs(funcs).Build()
+}
+
+func Test_handler_createPromotion(t *testing.T) {
+	getTarget := func(t *testing.T, c client.Client, name string) *v1alpha1.Application {
+		app := &v1alpha1.Application{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: name}, app))
+		return app
+	}
+
+	tests := []struct {
+		name             string
+		body             string
+		withoutUser      bool
+		wantResponseCode int
+		verify           func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService)
+	}{{
+		name:             "the source and target are the same",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"prod"},"target":{"name":"prod"}}}`,
+		wantResponseCode: http.StatusBadRequest,
+	}, {
+		name:             "unauthenticated",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"staging"},"target":{"name":"prod"}}}`,
+		withoutUser:      true,
+		wantResponseCode: http.StatusUnauthorized,
+	}, {
+		name:             "promote the commit of a healthy application",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"staging"},"target":{"name":"prod"},"requireHealthy":true}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseSucceeded, promotion.Status.Phase)
+			assert.Equal(t, "fake-commit", promotion.Status.Revision)
+			assert.NotNil(t, promotion.Status.FinishedAt)
+			assert.Equal(t, "fake-user", promotion.Status.RequestedBy)
+			assert.Equal(t, "fake-commit", getTarget(t, c, "prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+		},
+	}, {
+		name:             "promote the image tag",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"staging"},"target":{"name":"prod"},"image":"ghcr.io/fake/app"}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseSucceeded, promotion.Status.Phase)
+			assert.Equal(t, "v2", promotion.Status.Revision)
+			source := getTarget(t, c, "prod").Spec.ArgoApp.Spec.Source
+			assert.Equal(t, "old-commit", source.TargetRevision)
+			assert.Equal(t, v1alpha1.KustomizeImages{"ghcr.io/fake/app:v2"}, source.Kustomize.Images)
+		},
+	}, {
+		name:             "promote the chart version of a FluxCD application",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"flux-staging"},"target":{"name":"flux-prod"}}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseSucceeded, promotion.Status.Phase)
+			assert.Equal(t, "1.2.0", getTarget(t, c, "flux-prod").Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+		},
+	}, {
+		name:             "the source is not healthy",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"flux-staging"},"target":{"name":"flux-prod"},"requireHealthy":true}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseFailed, promotion.Status.Phase)
+			assert.Equal(t, "the source application flux-staging is not healthy", promotion.Status.Message)
+			assert.Equal(t, "1.1.0", getTarget(t, c, "flux-prod").Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+		},
+	}, {
+		name:             "the source is not found",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"fake"},"target":{"name":"prod"}}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseFailed, promotion.Status.Phase)
+			assert.Contains(t, promotion.Status.Message, "failed to get the source application fake")
+		},
+	}, {
+		name:             "wait for approval",
+		body:             `{"metadata":{"name":"fake"},"spec":{"source":{"name":"staging"},"target":{"name":"prod"},"requireApproval":true}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseWaitingForApproval, promotion.Status.Phase)
+			assert.Nil(t, promotion.Status.FinishedAt)
+			assert.Equal(t, "old-commit", getTarget(t, c, "prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+
+			stored := &v1alpha1.Promotion{}
+			assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: "fake"}, stored))
+			assert.Equal(t, v1alpha1.PromotionPhaseWaitingForApproval, stored.Status.Phase)
+			assert.Equal(t, "fake-user", stored.Status.RequestedBy)
+		},
+	}, {
+		name: "write the revision back to git",
+		body: `{"metadata":{"name":"fake"},"spec":{"source":{"name":"staging"},"target":{"name":"prod"},"image":"ghcr.io/fake/app",
+"gitWriteBack":{"repository":"config","branch":"main","file":"values.yaml","key":"image.tag"}}}`,
+		wantResponseCode: http.StatusOK,
+		verify: func(t *testing.T, c client.Client, promotion *v1alpha1.Promotion, repo *fakeRepoService) {
+			assert.Equal(t, v1alpha1.PromotionPhaseSucceeded, promotion.Status.Phase)
+			assert.NotEmpty(t, promotion.Status.Commit)
+			assert.Equal(t, "image:\n  tag: v2\n", repo.readFile(t, "values.yaml"))
+			assert.Equal(t, "Promote v2 from staging to prod", repo.messages[1])
+			// the target is synced from the git repository
+			assert.Equal(t, v1alpha1.KustomizeImages{"ghcr.io/fake/app:v1"}, getTarget(t, c, "prod").Spec.ArgoApp.Spec.Source.Kustomize.Images)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := newFakeClient(newTestApps()...)
+			repo := newFakeRepoService(t, map[string]string{"values.yaml": "image:\n  tag: v1\n"})
+			h := &handler{Client: fakeClient, repoFactory: &fakeRepoFactory{service: repo}}
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			h.createPromotion(newRequest(http.MethodPost, "", tt.body, !tt.withoutUser), resp)
+			assert.Equal(t, tt.wantResponseCode, recorder.Code, recorder.Body.String())
+
+			if tt.verify != nil {
+				promotion := &v1alpha1.Promotion{}
+				assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), promotion))
+				tt.verify(t, fakeClient, promotion, repo)
+			}
+		})
+	}
+}
+
+func Test_handler_reviewPromotion(t *testing.T) {
+	newPromotion := func(name, requestedBy string, phase v1alpha1.PromotionPhase) *v1alpha1.Promotion {
+		return &v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fake-namespace"},
+			Spec: v1alpha1.PromotionSpec{
+				Source:          v1.LocalObjectReference{Name: "staging"},
+				Target:          v1.LocalObjectReference{Name: "prod"},
+				RequireApproval: true,
+			},
+			Status: v1alpha1.PromotionStatus{Phase: phase, RequestedBy: requestedBy},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		promotion        string
+		approved         bool
+		withoutUser      bool
+		claimed          bool
+		wantResponseCode int
+		wantPhase        v1alpha1.PromotionPhase
+		wantRevision     string
+		wantPhases       []v1alpha1.PromotionPhase
+	}{{
+		name:             "approve a promotion",
+		promotion:        "waiting",
+		approved:         true,
+		wantResponseCode: http.StatusOK,
+		wantPhase:        v1alpha1.PromotionPhaseSucceeded,
+		wantRevision:     "fake-commit",
+		wantPhases:       []v1alpha1.PromotionPhase{v1alpha1.PromotionPhasePromoting, v1alpha1.PromotionPhaseSucceeded},
+	}, {
+		name:             "the promotion is claimed by another request",
+		promotion:        "waiting",
+		approved:         true,
+		claimed:          true,
+		wantResponseCode: http.StatusConflict,
+		wantPhase:        v1alpha1.PromotionPhaseWaitingForApproval,
+		wantRevision:     "old-commit",
+		wantPhases:       []v1alpha1.PromotionPhase{v1alpha1.PromotionPhasePromoting},
+	}, {
+		name:             "approve a promotion by the requester",
+		promotion:        "self-requested",
+		approved:         true,
+		wantResponseCode: http.StatusForbidden,
+		wantPhase:        v1alpha1.PromotionPhaseWaitingForApproval,
+		wantRevision:     "old-commit",
+	}, {
+		name:             "reject a promotion by the requester",
+		promotion:        "self-requested",
+		wantResponseCode: http.StatusOK,
+		wantPhase:        v1alpha1.PromotionPhaseRejected,
+		wantRevision:     "old-commit",
+		wantPhases:       []v1alpha1.PromotionPhase{v1alpha1.PromotionPhaseRejected},
+	}, {
+		name:             "reject a promotion",
+		promotion:        "waiting",
+		wantResponseCode: http.StatusOK,
+		wantPhase:        v1alpha1.PromotionPhaseRejected,
+		wantRevision:     "old-commit",
+		wantPhases:       []v1alpha1.PromotionPhase{v1alpha1.PromotionPhaseRejected},
+	}, {
+		name:             "approve a finished promotion",
+		promotion:        "succeeded",
+		approved:         true,
+		wantResponseCode: http.StatusBadRequest,
+		wantPhase:        v1alpha1.PromotionPhaseSucceeded,
+		wantRevision:     "old-commit",
+	}, {
+		name:             "unauthenticated",
+		promotion:        "waiting",
+		approved:         true,
+		withoutUser:      true,
+		wantResponseCode: http.StatusUnauthorized,
+		wantPhase:        v1alpha1.PromotionPhaseWaitingForApproval,
+		wantRevision:     "old-commit",
+	}, {
+		name:             "not found",
+		promotion:        "fake",
+		approved:         true,
+		wantResponseCode: http.StatusNotFound,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// record the phases of the status updates, a claimed promotion fails with a conflict
+			var phases []v1alpha1.PromotionPhase
+			fakeClient := newFakeClientWithInterceptor(interceptor.Funcs{
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object,
+					opts ...client.SubResourceUpdateOption) error {
+					phases = append(phases, obj.(*v1alpha1.Promotion).Status.Phase)
+					if tt.claimed {
+						return apierrors.NewConflict(schema.GroupResource{Resource: "promotions"}, obj.GetName(), nil)
+					}
+					return c.Status().Update(ctx, obj, opts...)
+				},
+			}, append(newTestApps(),
+				newPromotion("waiting", "requester", v1alpha1.PromotionPhaseWaitingForApproval),
+				newPromotion("self-requested", "fake-user", v1alpha1.PromotionPhaseWaitingForApproval),
+				newPromotion("succeeded", "requester", v1alpha1.PromotionPhaseSucceeded))...)
+			h := &handler{Client: fakeClient}
+
+			recorder := httptest.NewRecorder()
+			resp := restful.NewResponse(recorder)
+			resp.SetRequestAccepts(restful.MIME_JSON)
+			req := newRequest(http.MethodPost, tt.promotion, "", !tt.withoutUser)
+			if tt.approved {
+				h.approvePromotion(req, resp)
+			} else {
+				h.rejectPromotion(req, resp)
+			}
+			assert.Equal(t, tt.wantResponseCode, recorder.Code, recorder.Body.String())
+			assert.Equal(t, tt.wantPhases, phases)
+			if tt.wantPhase == "" {
+				return
+			}
+
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: tt.promotion}, promotion))
+			assert.Equal(t, tt.wantPhase, promotion.Status.Phase)
+			if tt.wantResponseCode == http.StatusOK {
+				assert.Equal(t, "fake-user", promotion.Status.ReviewedBy)
+				assert.NotNil(t, promotion.Status.FinishedAt)
+			}
+
+			target := &v1alpha1.Application{}
+			assert.Nil(t, fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "fake-namespace", Name: "prod"}, target))
+			assert.Equal(t, tt.wantRevision, target.Spec.ArgoApp.Spec.Source.TargetRevision)
+		})
+	}
+}
+
+func Test_handler_listPromotions(t *testing.T) {
+	fakeClient := newFakeClient(&v1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake", Namespace: "fake-namespace"},
+	}, &v1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other-namespace"},
+	})
+	h := &handler{Client: fakeClient}
+
+	recorder := httptest.NewRecorder()
+	resp := restful.NewResponse(recorder)
+	resp.SetRequestAccepts(restful.MIME_JSON)
+	h.listPromotions(newRequest(http.MethodGet, "", "", true), resp)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	result := &PromotionPageResult{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), result))
+	assert.Equal(t, 1, result.TotalItems)
+	if assert.Len(t, result.Items, 1) {
+		assert.Equal(t, "fake", result.Items[0].Name)
+	}
+}
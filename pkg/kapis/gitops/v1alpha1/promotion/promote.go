@@ -0,0 +1,258 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	utilretry "k8s.io/client-go/util/retry"
+)
+
+// executePromotion copies the revision of the source application to the target one,
+// or to a git repository if the git write-back is set
+func (h *handler) executePromotion(ctx context.Context, promotion *v1alpha1.Promotion, currentUser user.Info) (err error) {
+	spec := promotion.Spec
+	source := &v1alpha1.Application{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: promotion.Namespace, Name: spec.Source.Name}, source); err != nil {
+		return fmt.Errorf("failed to get the source application %s, error: %v", spec.Source.Name, err)
+	}
+	if spec.RequireHealthy {
+		if err = checkHealthy(source); err != nil {
+			return
+		}
+	}
+
+	revision := spec.Revision
+	if revision == "" {
+		if revision = getRevision(source, spec.Image); revision == "" {
+			return fmt.Errorf("cannot find the revision of the source application %s, please specify it", source.Name)
+		}
+	}
+	promotion.Status.Revision = revision
+
+	if spec.GitWriteBack != nil {
+		message := fmt.Sprintf("Promote %s from %s to %s", revision, spec.Source.Name, spec.Target.Name)
+		promotion.Status.Commit, err = h.writeBack(ctx, promotion.Namespace, spec.GitWriteBack, revision, message, currentUser)
+		return
+	}
+	return h.updateTarget(ctx, types.NamespacedName{Namespace: promotion.Namespace, Name: spec.Target.Name}, spec.Image, revision)
+}
+
+// checkHealthy returns an error if the application is not healthy, a FluxCD application is healthy
+// only if all the HelmReleases and Kustomizations are ready
+func checkHealthy(app *v1alpha1.Application) error {
+	switch {
+	case app.Spec.ArgoApp != nil:
+		if status := app.Status.ArgoAppStatus; status != nil && status.Health.Status == v1alpha1.HealthStatusHealthy {
+			return nil
+		}
+	case app.Spec.FluxApp != nil:
+		var conditions [][]metav1.Condition
+		for _, status := range app.Status.FluxApp.HelmReleaseStatus {
+			if status != nil {
+				conditions = append(conditions, status.Conditions)
+			}
+		}
+		for _, status := range app.Status.FluxApp.KustomizationStatus {
+			if status != nil {
+				conditions = append(conditions, status.Conditions)
+			}
+		}
+		ready := len(conditions) > 0
+		for _, item := range conditions {
+			ready = ready && apimeta.IsStatusConditionTrue(item, meta.ReadyCondition)
+		}
+		if ready {
+			return nil
+		}
+	}
+	return fmt.Errorf("the source application %s is not healthy", app.Name)
+}
+
+// getRevision returns the current revision of an application. It's the tag of the image if the image is not empty.
+func getRevision(app *v1alpha1.Application, image string) (revision string) {
+	switch {
+	case app.Spec.ArgoApp != nil:
+		status := app.Status.ArgoAppStatus
+		if status == nil {
+			return
+		}
+		if image == "" {
+			return status.Sync.Revision
+		}
+		for _, item := range status.Summary.Images {
+			if name, tag := splitImage(item); name == image {
+				return tag
+			}
+		}
+	case app.Spec.FluxApp != nil:
+		if image != "" {
+			if config := app.Spec.FluxApp.Spec.Config; config != nil {
+				for _, kustomization := range config.Kustomization {
+					for _, item := range kustomization.Images {
+						if item.Name == image && item.NewTag != "" {
+							return item.NewTag
+						}
+					}
+				}
+			}
+			return
+		}
+
+		// the revision of a HelmRelease is the chart version
+		status := app.Status.FluxApp
+		for _, name := range sortedKeys(status.HelmReleaseStatus) {
+			if helmStatus := status.HelmReleaseStatus[name]; helmStatus != nil && helmStatus.LastAppliedRevision != "" {
+				return helmStatus.LastAppliedRevision
+			}
+		}
+		// the revision of a Kustomization looks like main/<sha> or main@sha1:<sha>
+		for _, name := range sortedKeys(status.KustomizationStatus) {
+			if kusStatus := status.KustomizationStatus[name]; kusStatus != nil && kusStatus.LastAppliedRevision != "" {
+				revision = kusStatus.LastAppliedRevision
+				return revision[strings.LastIndexAny(revision, "/:")+1:]
+			}
+		}
+	}
+	return
+}
+
+// updateTarget sets the revision to the target application
+func (h *handler) updateTarget(ctx context.Context, key types.NamespacedName, image, revision string) error {
+	return utilretry.RetryOnConflict(utilretry.DefaultRetry, func() (err error) {
+		target := &v1alpha1.Application{}
+		if err = h.Get(ctx, key, target); err != nil {
+			return fmt.Errorf("failed to get the target application %s, error: %v", key.Name, err)
+		}
+
+		switch {
+		case target.Spec.ArgoApp != nil:
+			err = setArgoRevision(&target.Spec.ArgoApp.Spec.Source, image, revision)
+		case target.Spec.FluxApp != nil && target.Spec.FluxApp.Spec.Config != nil:
+			err = setFluxRevision(target.Spec.FluxApp.Spec.Config, image, revision)
+		default:
+			err = fmt.Errorf("the target application %s is not configured", key.Name)
+		}
+		if err != nil {
+			return
+		}
+		return h.Update(ctx, target)
+	})
+}
+
+func setArgoRevision(source *v1alpha1.ApplicationSource, image, revision string) error {
+	if image == "" {
+		source.TargetRevision = revision
+		return nil
+	}
+	if source.Helm != nil {
+		return errors.New("cannot promote the image of a Helm application, please use the git write-back")
+	}
+	if source.Kustomize == nil {
+		source.Kustomize = &v1alpha1.ApplicationSourceKustomize{}
+	}
+	source.Kustomize.Images = setKustomizeImage(source.Kustomize.Images, image, revision)
+	return nil
+}
+
+func setFluxRevision(config *v1alpha1.FluxApplicationConfig, image, revision string) error {
+	switch {
+	case config.HelmRelease != nil:
+		if image != "" {
+			return errors.New("cannot promote the image of a HelmRelease, please use the git write-back")
+		}
+		if config.HelmRelease.Chart == nil {
+			return errors.New("cannot promote the chart version of a HelmRelease template")
+		}
+		config.HelmRelease.Chart.Version = revision
+	case len(config.Kustomization) > 0:
+		if image == "" {
+			// pinning the revision of the source affects all the applications which refer to it
+			return errors.New("cannot promote the source revision of a Kustomization, please use the git write-back")
+		}
+		for _, kustomization := range config.Kustomization {
+			kustomization.Images = setFluxImage(kustomization.Images, image, revision)
+		}
+	default:
+		return errors.New("the target application is not configured")
+	}
+	return nil
+}
+
+// setKustomizeImage sets the tag of an Argo CD Kustomize image which is in the format [old_image_name=]<image_name>:<image_tag>
+func setKustomizeImage(images v1alpha1.KustomizeImages, image, tag string) v1alpha1.KustomizeImages {
+	for i, item := range images {
+		oldName, newName := "", string(item)
+		if index := strings.Index(newName, "="); index >= 0 {
+			oldName, newName = newName[:index], newName[index+1:]
+		}
+		if name := splitName(newName); name == image || splitName(oldName) == image {
+			if oldName != "" {
+				oldName += "="
+			}
+			images[i] = v1alpha1.KustomizeImage(oldName + name + ":" + tag)
+			return images
+		}
+	}
+	return append(images, v1alpha1.KustomizeImage(image+":"+tag))
+}
+
+func setFluxImage(images []kusv1.Image, image, tag string) []kusv1.Image {
+	for i := range images {
+		if images[i].Name == image {
+			images[i].NewTag = tag
+			images[i].Digest = ""
+			return images
+		}
+	}
+	return append(images, kusv1.Image{Name: image, NewTag: tag})
+}
+
+// splitImage splits an image into the name and the tag, the digest is ignored
+func splitImage(image string) (name, tag string) {
+	if index := strings.Index(image, "@"); index >= 0 {
+		image = image[:index]
+	}
+	if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+		return image[:index], image[index+1:]
+	}
+	return image, ""
+}
+
+func splitName(image string) (name string) {
+	name, _ = splitImage(image)
+	return
+}
+
+func sortedKeys[T any](items map[string]T) (keys []string) {
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_getRevision(t *testing.T) {
+	argoApp := &v1alpha1.Application{
+		Spec: v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+		Status: v1alpha1.ApplicationStatus{ArgoAppStatus: &v1alpha1.ArgoApplicationStatus{
+			Sync:    v1alpha1.SyncStatus{Revision: "fake-commit"},
+			Summary: v1alpha1.ApplicationSummary{Images: []string{"nginx:1.25", "localhost:5000/fake/app:v2@sha256:fake"}},
+		}},
+	}
+	kusApp := &v1alpha1.Application{
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Config: &v1alpha1.FluxApplicationConfig{Kustomization: []*v1alpha1.KustomizationSpec{{
+				Images: []kusv1.Image{{Name: "nginx", NewTag: "1.25"}},
+			}}},
+		}}},
+		Status: v1alpha1.ApplicationStatus{FluxApp: v1alpha1.FluxApplicationStatus{
+			KustomizationStatus: map[string]*kusv1.KustomizationStatus{"app": {LastAppliedRevision: "main@sha1:fake-commit"}},
+		}},
+	}
+	helmApp := &v1alpha1.Application{
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}},
+		Status: v1alpha1.ApplicationStatus{FluxApp: v1alpha1.FluxApplicationStatus{
+			HelmReleaseStatus: map[string]*helmv2.HelmReleaseStatus{"app": {LastAppliedRevision: "1.2.0"}},
+		}},
+	}
+
+	tests := []struct {
+		name  string
+		app   *v1alpha1.Application
+		image string
+		want  string
+	}{
+		{name: "the commit of an Argo CD application", app: argoApp, want: "fake-commit"},
+		{name: "the image of an Argo CD application", app: argoApp, image: "localhost:5000/fake/app", want: "v2"},
+		{name: "the missing image of an Argo CD application", app: argoApp, image: "redis", want: ""},
+		{name: "the commit of a Kustomization", app: kusApp, want: "fake-commit"},
+		{name: "the image of a Kustomization", app: kusApp, image: "nginx", want: "1.25"},
+		{name: "the chart version of a HelmRelease", app: helmApp, want: "1.2.0"},
+		{name: "the application without status", app: &v1alpha1.Application{
+			Spec: v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+		}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getRevision(tt.app, tt.image))
+		})
+	}
+}
+
+func Test_checkHealthy(t *testing.T) {
+	readyConditions := []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionTrue}}
+	notReadyConditions := []metav1.Condition{{Type: meta.ReadyCondition, Status: metav1.ConditionFalse}}
+	newFluxApp := func(helmConditions, kusConditions []metav1.Condition) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake"},
+			Spec:       v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}},
+			Status: v1alpha1.ApplicationStatus{FluxApp: v1alpha1.FluxApplicationStatus{
+				HelmReleaseStatus:   map[string]*helmv2.HelmReleaseStatus{"helm": {Conditions: helmConditions}},
+				KustomizationStatus: map[string]*kusv1.KustomizationStatus{"kus": {Conditions: kusConditions}},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		app     *v1alpha1.Application
+		healthy bool
+	}{{
+		name: "a healthy Argo CD application",
+		app: &v1alpha1.Application{
+			Spec: v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+			Status: v1alpha1.ApplicationStatus{ArgoAppStatus: &v1alpha1.ArgoApplicationStatus{
+				Health: v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy},
+			}},
+		},
+		healthy: true,
+	}, {
+		name: "a degraded Argo CD application",
+		app: &v1alpha1.Application{
+			Spec: v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{}},
+			Status: v1alpha1.ApplicationStatus{ArgoAppStatus: &v1alpha1.ArgoApplicationStatus{
+				Health: v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusDegraded},
+			}},
+		},
+	}, {
+		name:    "all the FluxCD resources are ready",
+		app:     newFluxApp(readyConditions, readyConditions),
+		healthy: true,
+	}, {
+		name: "one of the FluxCD resources is not ready",
+		app:  newFluxApp(readyConditions, notReadyConditions),
+	}, {
+		name: "a FluxCD application without status",
+		app:  &v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{}}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.healthy, checkHealthy(tt.app) == nil)
+		})
+	}
+}
+
+func Test_setKustomizeImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		images v1alpha1.KustomizeImages
+		image  string
+		want   v1alpha1.KustomizeImages
+	}{{
+		name:   "replace the tag",
+		images: v1alpha1.KustomizeImages{"redis:7", "ghcr.io/fake/app:v1"},
+		image:  "ghcr.io/fake/app",
+		want:   v1alpha1.KustomizeImages{"redis:7", "ghcr.io/fake/app:v2"},
+	}, {
+		name:   "replace the tag of an alias",
+		images: v1alpha1.KustomizeImages{"app=ghcr.io/fake/app:v1"},
+		image:  "app",
+		want:   v1alpha1.KustomizeImages{"app=ghcr.io/fake/app:v2"},
+	}, {
+		name:  "add an image",
+		image: "localhost:5000/fake/app",
+		want:  v1alpha1.KustomizeImages{"localhost:5000/fake/app:v2"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, setKustomizeImage(tt.images, tt.image, "v2"))
+		})
+	}
+}
+
+func Test_splitImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		wantName string
+		wantTag  string
+	}{
+		{image: "nginx", wantName: "nginx"},
+		{image: "nginx:1.25", wantName: "nginx", wantTag: "1.25"},
+		{image: "localhost:5000/nginx", wantName: "localhost:5000/nginx"},
+		{image: "localhost:5000/nginx:1.25@sha256:fake", wantName: "localhost:5000/nginx", wantTag: "1.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			name, tag := splitImage(tt.image)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantTag, tag)
+		})
+	}
+}
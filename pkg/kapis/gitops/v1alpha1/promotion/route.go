@@ -0,0 +1,90 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package promotion
+
+import (
+	"net/http"
+
+	restfulspec "github.com/emicklei/go-restful-openapi"
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/kubesphere/ks-devops/pkg/api"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+)
+
+var (
+	// pathParameterPromotion is a path parameter definition for promotion.
+	pathParameterPromotion = restful.PathParameter("promotion", "The promotion name")
+)
+
+// PromotionPageResult is the model of page result of Promotions.
+type PromotionPageResult struct {
+	Items      []v1alpha1.Promotion `json:"items"`
+	TotalItems int                  `json:"totalItems"`
+}
+
+// RegisterRoutes is for registering Promotion routes into WebService.
+func RegisterRoutes(service *restful.WebService, options *common.Options) {
+	handler := newHandler(options)
+
+	service.Route(service.GET("/namespaces/{namespace}/promotions").
+		To(handler.listPromotions).
+		Param(common.NamespacePathParameter).
+		Param(common.PageQueryParameter).
+		Param(common.LimitQueryParameter).
+		Param(common.NameQueryParameter).
+		Param(common.SortByQueryParameter).
+		Param(common.AscendingQueryParameter).
+		Doc("Search promotions").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, PromotionPageResult{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions").
+		To(handler.createPromotion).
+		Param(common.NamespacePathParameter).
+		Reads(v1alpha1.Promotion{}).
+		Doc("Promote a revision from an application to another one. It waits for an approval if it is required").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/promotions/{promotion}").
+		To(handler.getPromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Get a particular promotion").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions/{promotion}/approve").
+		To(handler.approvePromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Approve a particular promotion which is waiting for approval, then promote the revision. "+
+			"It cannot be approved by the user who created it").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions/{promotion}/reject").
+		To(handler.rejectPromotion).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Doc("Reject a particular promotion which is waiting for approval").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/argocd"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/fluxcd"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/promotion"
 )
 
 // TODO perhaps we can find a better way to declaim the permission needs of the apiserver
@@ -45,6 +46,7 @@ func AddToContainer(container *restful.Container, options *common.Options, argoO
 		default:
 			return nil
 		}
+		promotion.RegisterRoutes(service, options)
 		container.Add(service)
 	}
 	return services
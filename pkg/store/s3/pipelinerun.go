@@ -0,0 +1,197 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	s3client "github.com/kubesphere/ks-devops/pkg/client/s3"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+// KeyPrefix is the prefix of all the object keys of PipelineRun data
+const KeyPrefix = "pipelineruns"
+
+// S3Store represents a key-value store base on S3 compatible object storage,
+// every key of the PipelineRun data is mapped to an object.
+type S3Store struct {
+	s3Client s3client.Interface
+	prefix   string
+
+	cache map[string]string
+	dirty map[string]bool
+}
+
+// NewS3Store creates a PipelineRun data store
+func NewS3Store(key client.ObjectKey, s3Client s3client.Interface) (result store.ObjectStore, err error) {
+	if s3Client == nil {
+		err = fmt.Errorf("the S3 client is required by the PipelineRun data store")
+		return
+	}
+	result = &S3Store{
+		s3Client: s3Client,
+		prefix:   fmt.Sprintf("%s/%s/%s", KeyPrefix, key.Namespace, key.Name),
+		cache:    map[string]string{},
+		dirty:    map[string]bool{},
+	}
+	return
+}
+
+// GetStages returns the stage data
+func (s *S3Store) GetStages() string {
+	return s.Get(store.DataKeyStage)
+}
+
+// SetStages stores the stage data
+func (s *S3Store) SetStages(stages string) {
+	s.Set(store.DataKeyStage, stages)
+}
+
+// GetStatus returns the status
+func (s *S3Store) GetStatus() string {
+	return s.Get(store.DataKeyStatus)
+}
+
+// SetStatus stores the status
+func (s *S3Store) SetStatus(status string) {
+	s.Set(store.DataKeyStatus, status)
+}
+
+// GetStepLog returns the step log
+func (s *S3Store) GetStepLog(stage, step int) string {
+	return s.Get(store.StepLogKey(stage, step))
+}
+
+// SetStepLog stores the step log
+func (s *S3Store) SetStepLog(stage, step int, log string) {
+	s.Set(store.StepLogKey(stage, step), log)
+}
+
+// GetAllLog returns the whole log
+func (s *S3Store) GetAllLog() string {
+	return s.Get(store.DataKeyAllLog)
+}
+
+// SetAllLog store the whole log
+func (s *S3Store) SetAllLog(log string) {
+	s.Set(store.DataKeyAllLog, log)
+}
+
+// Get returns the value by a key, the object will be read only once
+func (s *S3Store) Get(key string) string {
+	if value, ok := s.cache[key]; ok {
+		return value
+	}
+
+	data, err := s.s3Client.Read(s.objectKey(key))
+	if err != nil {
+		if !isNotFound(err) {
+			klog.Errorf("failed to read object: %s, error: %v", s.objectKey(key), err)
+		}
+		return ""
+	}
+	s.cache[key] = string(data)
+	return s.cache[key]
+}
+
+// Set puts a key and value
+func (s *S3Store) Set(key, value string) {
+	s.cache[key] = value
+	s.dirty[key] = true
+}
+
+// Save uploads the changed data into the object storage
+func (s *S3Store) Save() (err error) {
+	for key := range s.dirty {
+		if err = s.s3Client.Upload(s.objectKey(key), key, bytes.NewBufferString(s.cache[key])); err != nil {
+			return
+		}
+		delete(s.dirty, key)
+	}
+	return
+}
+
+// Delete removes all the objects of the PipelineRun, including the step logs found in the stage data
+func (s *S3Store) Delete() (err error) {
+	keys := map[string]bool{
+		store.DataKeyStatus: true,
+		store.DataKeyStage:  true,
+		store.DataKeyAllLog: true,
+	}
+	for _, key := range stepLogKeys(s.GetStages()) {
+		keys[key] = true
+	}
+	for key := range s.cache {
+		keys[key] = true
+	}
+
+	for key := range keys {
+		if err = s.s3Client.Delete(s.objectKey(key)); err != nil && !isNotFound(err) {
+			return
+		}
+		err = nil
+		delete(s.cache, key)
+		delete(s.dirty, key)
+	}
+	return
+}
+
+func (s *S3Store) objectKey(key string) string {
+	return fmt.Sprintf("%s/%s", s.prefix, key)
+}
+
+// stepLogKeys returns the keys of all step logs according to the stage data
+func stepLogKeys(stagesJSON string) (keys []string) {
+	if stagesJSON == "" {
+		return
+	}
+	var stages []struct {
+		Steps []json.RawMessage `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(stagesJSON), &stages); err != nil {
+		klog.V(4).Infof("failed to parse the stage data, error: %v", err)
+		return
+	}
+	for i := range stages {
+		for j := range stages[i].Steps {
+			keys = append(keys, store.StepLogKey(i, j))
+		}
+	}
+	return
+}
+
+// IsBucketNotFound returns true if the bucket of the data store does not exist
+func IsBucketNotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == awss3.ErrCodeNoSuchBucket
+	}
+	return false
+}
+
+func isNotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == awss3.ErrCodeNoSuchKey
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/client/s3/fake"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestS3Store(t *testing.T) {
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	_, err := NewS3Store(key, nil)
+	assert.NotNil(t, err)
+
+	fakeS3 := fake.NewFakeS3()
+	objStore, err := NewS3Store(key, fakeS3)
+	assert.NotNil(t, objStore)
+	assert.Nil(t, err)
+
+	assert.Empty(t, objStore.GetStages())
+	objStore.SetStages(`[{"steps":[{},{}]},{"steps":[{}]}]`)
+	assert.Equal(t, `[{"steps":[{},{}]},{"steps":[{}]}]`, objStore.GetStages())
+
+	assert.Empty(t, objStore.GetStatus())
+	objStore.SetStatus("status")
+	assert.Equal(t, "status", objStore.GetStatus())
+
+	assert.Empty(t, objStore.GetStepLog(1, 0))
+	objStore.SetStepLog(1, 0, "step")
+	assert.Equal(t, "step", objStore.GetStepLog(1, 0))
+
+	assert.Empty(t, objStore.GetAllLog())
+	objStore.SetAllLog("log")
+	assert.Equal(t, "log", objStore.GetAllLog())
+
+	assert.Nil(t, objStore.Save())
+	assert.Len(t, fakeS3.Storage, 4)
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/ns/name/status")
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/ns/name/log-step-1-0")
+
+	// read the data from the object storage
+	anotherStore, err := NewS3Store(key, fakeS3)
+	assert.Nil(t, err)
+	assert.Equal(t, "status", anotherStore.GetStatus())
+
+	fakeS3.Storage["pipelineruns/ns/name/log-step-0-1"] = &fake.Object{Key: "pipelineruns/ns/name/log-step-0-1"}
+	fakeS3.Storage["pipelineruns/other/name/status"] = &fake.Object{Key: "pipelineruns/other/name/status"}
+	assert.Nil(t, anotherStore.Delete())
+	assert.Len(t, fakeS3.Storage, 1)
+	assert.Contains(t, fakeS3.Storage, "pipelineruns/other/name/status")
+}
+
+func Test_stepLogKeys(t *testing.T) {
+	assert.Empty(t, stepLogKeys(""))
+	assert.Empty(t, stepLogKeys("invalid"))
+	assert.Equal(t, []string{"log-step-0-0", "log-step-0-1", "log-step-1-0"},
+		stepLogKeys(`[{"steps":[{},{}]},{"steps":[{}]}]`))
+}
@@ -61,3 +61,10 @@ type ConfigMapStore interface {
 	PipelineRunDataStore
 	SetOwnerReference(owner metav1.OwnerReference)
 }
+
+// ObjectStore represents a store base on an object storage
+type ObjectStore interface {
+	PipelineRunDataStore
+	// Delete removes all the data of the PipelineRun from the object storage
+	Delete() error
+}